@@ -0,0 +1,92 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// audit holds the *auditOutput writing the audit events to Config.AuditFile.
+var audit atomic.Value
+
+// auditOutput is the file of the audit events and the core writing them.
+type auditOutput struct {
+	core zapcore.Core
+	file *rotatingFile
+}
+
+// auditCore returns the core writing the audit events to Config.AuditFile, a
+// no-op core when not set.
+func auditCore() zapcore.Core {
+	if out, _ := audit.Load().(*auditOutput); out != nil {
+		return out.core
+	}
+	return zapcore.NewNopCore()
+}
+
+// newAuditEncoder returns the encoder of the audit events, writing them as
+// JSON objects of a fixed schema regardless of the format of the logs.
+func newAuditEncoder() zapcore.Encoder {
+	return zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+		TimeKey:        "time",
+		NameKey:        "subsystem",
+		CallerKey:      "caller",
+		MessageKey:     "event",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	})
+}
+
+// setAuditFile opens the file the audit events are written to, closing the
+// previous one, or only closes it when path is empty. loggerMutex must be
+// held.
+func setAuditFile(path string, rotation RotationConfig) {
+	var out *auditOutput
+	if path != "" {
+		if file, err := openRotatingFile(path, rotation); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open audit file '%s': %s\n", path, err)
+		} else {
+			out = &auditOutput{
+				core: zapcore.NewCore(newAuditEncoder(), file, zapcore.Level(levelAll)),
+				file: file,
+			}
+		}
+	}
+	prev, _ := audit.Load().(*auditOutput)
+	audit.Store(out)
+	if prev != nil {
+		prev.file.Close() // nolint:errcheck
+	}
+}
+
+// Audit logs a security-relevant event, i.e. a login, a configuration change
+// or a key rotation, with key-value pairs like Infow. Audit events are
+// written at LevelInfo to all the outputs regardless of the levels and the
+// outputs of the subsystems, with an "audit" field set to true, and to
+// Config.AuditFile as JSON objects of the schema:
+//
+//	{"time": "2024-05-01T12:00:00.000000000Z", "subsystem": "auth",
+//		"caller": "auth/login.go:42", "event": "login", "fields": {"user": "alice"}}
+//
+// the fields including the fields of With.
+func (logger *ZapEventLogger) Audit(event string, fields ...interface{}) {
+	file := auditCore().With(append([]zapcore.Field{zap.Namespace("fields")}, logger.fields...))
+	base := logger.skipLogger.Desugar().WithOptions(
+		zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			// bypass the level and the output of the subsystem
+			if leveled, ok := core.(*leveledCore); ok {
+				core = leveled.labeled()
+			}
+			core = core.With([]zapcore.Field{zap.Bool("audit", true)})
+			return zapcore.NewTee(core, file)
+		}),
+	)
+	if ce := base.Check(zapcore.InfoLevel, event); ce != nil {
+		ce.Write(sweetenFields(fields)...)
+	}
+}
@@ -0,0 +1,56 @@
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	auditPath := filepath.Join(dir, "audit.log")
+	SetupLogging(Config{Level: LevelError, Format: FormatJSONOutput, File: path, AuditFile: auditPath})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	log := Logger("auth").With("session", "s1")
+	log.Info("filtered")
+	log.Audit("login", "user", "alice")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["msg"] != "login" || entry["level"] != "info" || entry["audit"] != true || entry["user"] != "alice" || entry["session"] != "s1" {
+		t.Errorf("got %s, wanted the audit event", data)
+	}
+
+	data, err = ioutil.ReadFile(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatal(err)
+	}
+	if caller, _ := event["caller"].(string); !strings.Contains(caller, "audit_test.go") {
+		t.Errorf("got caller %s, wanted the test", caller)
+	}
+	delete(event, "time")
+	delete(event, "caller")
+	want := map[string]interface{}{
+		"subsystem": "auth",
+		"event":     "login",
+		"fields":    map[string]interface{}{"session": "s1", "user": "alice"},
+	}
+	if !reflect.DeepEqual(event, want) {
+		t.Errorf("got %s, wanted %v", data, want)
+	}
+}
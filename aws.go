@@ -0,0 +1,279 @@
+package log
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file implements the parts of the AWS SDK needed by the AWS outputs:
+// credentials lookup and Signature Version 4 request signing.
+
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+const (
+	awsMetadataEndpoint  = "http://169.254.169.254"
+	awsContainerEndpoint = "http://169.254.170.2"
+)
+
+// awsCredentialsProvider looks up credentials like the AWS SDKs do: static
+// credentials, then environment variables (which covers Lambda), then the
+// ECS task role, then the EC2 instance role. Temporary credentials are
+// cached until shortly before they expire.
+type awsCredentialsProvider struct {
+	static awsCredentials
+	client *http.Client
+
+	mu     sync.Mutex
+	cached awsCredentials
+}
+
+func newAWSCredentialsProvider(accessKeyID, secretAccessKey, sessionToken string) *awsCredentialsProvider {
+	return &awsCredentialsProvider{
+		static: awsCredentials{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+			SessionToken:    sessionToken,
+		},
+		client: &http.Client{Timeout: 2 * time.Second},
+	}
+}
+
+func (p *awsCredentialsProvider) credentials() (awsCredentials, error) {
+	if p.static.AccessKeyID != "" {
+		return p.static, nil
+	}
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return awsCredentials{
+			AccessKeyID:     id,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cached.AccessKeyID != "" && time.Now().Add(5*time.Minute).Before(p.cached.Expiration) {
+		return p.cached, nil
+	}
+
+	var creds awsCredentials
+	var err error
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		creds, err = p.fetch(awsContainerEndpoint+uri, "")
+	} else {
+		creds, err = p.instanceCredentials()
+	}
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("no AWS credentials found: %s", err)
+	}
+	p.cached = creds
+	return creds, nil
+}
+
+// instanceCredentials fetches the credentials of the EC2 instance role using
+// IMDSv2.
+func (p *awsCredentialsProvider) instanceCredentials() (awsCredentials, error) {
+	req, err := http.NewRequest(http.MethodPut, awsMetadataEndpoint+"/latest/api/token", nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token, err := p.get(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+
+	const rolesPath = "/latest/meta-data/iam/security-credentials/"
+	req, err = http.NewRequest(http.MethodGet, awsMetadataEndpoint+rolesPath, nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", string(token))
+	roles, err := p.get(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+	if role == "" {
+		return awsCredentials{}, errors.New("no instance role")
+	}
+
+	return p.fetch(awsMetadataEndpoint+rolesPath+role, string(token))
+}
+
+func (p *awsCredentialsProvider) fetch(url, metadataToken string) (awsCredentials, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	if metadataToken != "" {
+		req.Header.Set("X-aws-ec2-metadata-token", metadataToken)
+	}
+	data, err := p.get(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+
+	var creds struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		Token           string
+		Expiration      time.Time
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return awsCredentials{}, err
+	}
+	return awsCredentials{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.Token,
+		Expiration:      creds.Expiration,
+	}, nil
+}
+
+func (p *awsCredentialsProvider) get(req *http.Request) ([]byte, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response %s from %s", resp.Status, req.URL)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// awsRegion returns region, or the region configured in the environment.
+func awsRegion(region string) string {
+	if region != "" {
+		return region
+	}
+	if region = os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// signAWSRequest signs req with AWS Signature Version 4. The request must not
+// have a body other than payload.
+func signAWSRequest(req *http.Request, payload []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	payloadHash := sha256.Sum256(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	if service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	}
+
+	// canonical headers: host, content-type and x-amz-*
+	headers := map[string]string{"host": req.URL.Host}
+	if req.Host != "" {
+		headers["host"] = req.Host
+	}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// canonical query string
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, awsURIEncode(k, true)+"="+awsURIEncode(v, true))
+		}
+	}
+
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsURIEncode(path, false),
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data)) // nolint:errcheck
+	return h.Sum(nil)
+}
+
+// awsURIEncode percent-encodes s as required by Signature Version 4. Slashes
+// are kept unless encodeSlash is set.
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,38 @@
+package log
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSignAWSRequest(t *testing.T) {
+	// example from the AWS Signature Version 4 documentation
+	req, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	creds := awsCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	signAWSRequest(req, nil, creds, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+}
+
+func TestAWSURIEncode(t *testing.T) {
+	if got := awsURIEncode("/logs/a b+c~", false); got != "/logs/a%20b%2Bc~" {
+		t.Errorf("got %q", got)
+	}
+	if got := awsURIEncode("a/b", true); got != "a%2Fb" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,152 @@
+package log
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AzureMonitorConfig configures sending logs to an Azure Monitor Log
+// Analytics workspace with the HTTP Data Collector API.
+type AzureMonitorConfig struct {
+	// WorkspaceID is the ID of the Log Analytics workspace.
+	WorkspaceID string
+
+	// SharedKey is the primary or secondary key of the workspace.
+	SharedKey string
+
+	// LogType is the name of the custom log type, made of letters, digits
+	// and underscores. Log Analytics appends "_CL" to it. Defaults to "GoLog".
+	LogType string
+
+	// BatchSize is the maximum number of records sent in one request.
+	// Defaults to 100.
+	BatchSize int
+
+	// FlushInterval is the maximum time a record waits before being sent.
+	// Defaults to one second.
+	FlushInterval time.Duration
+
+	// Endpoint overrides the Data Collector API endpoint of the workspace.
+	Endpoint string
+}
+
+const (
+	defaultAzureLogType   = "GoLog"
+	azureMaxLogTypeLength = 100
+	azureMaxRetries       = 3
+	azureTimeField        = "ts"
+)
+
+// newAzureMonitorCore creates a core sending entries as custom log records to
+// Log Analytics.
+func newAzureMonitorCore(cfg AzureMonitorConfig, level LogLevel) (zapcore.Core, error) {
+	if cfg.WorkspaceID == "" {
+		return nil, errors.New("missing Log Analytics workspace ID")
+	}
+	key, err := base64.StdEncoding.DecodeString(cfg.SharedKey)
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("invalid Log Analytics shared key")
+	}
+	logType := cfg.LogType
+	if logType == "" {
+		logType = defaultAzureLogType
+	}
+	if !isAzureLogType(logType) {
+		return nil, fmt.Errorf("invalid Log Analytics log type %q", logType)
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://" + cfg.WorkspaceID + ".ods.opinsights.azure.com"
+	}
+
+	a := &azureClient{
+		url:         strings.TrimSuffix(endpoint, "/") + "/api/logs?api-version=2016-04-01",
+		workspaceID: cfg.WorkspaceID,
+		key:         key,
+		logType:     logType,
+		client:      newHTTPClient(nil),
+	}
+
+	encCfg := newEncoderConfig()
+	encCfg.TimeKey = azureTimeField
+	encCfg.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+
+	return &batchCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          zapcore.NewJSONEncoder(encCfg),
+		batcher:      newBatcher(cfg.BatchSize, cfg.FlushInterval, a.send),
+	}, nil
+}
+
+// isAzureLogType reports whether name is a valid custom log type name.
+func isAzureLogType(name string) bool {
+	if len(name) > azureMaxLogTypeLength {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+type azureClient struct {
+	url         string
+	workspaceID string
+	key         []byte
+	logType     string
+	client      *http.Client
+}
+
+func (a *azureClient) send(batch []batchEntry) error {
+	var body bytes.Buffer
+	body.WriteByte('[')
+	for i, e := range batch {
+		if i > 0 {
+			body.WriteByte(',')
+		}
+		body.Write(bytes.TrimRight(e.data, "\n"))
+	}
+	body.WriteByte(']')
+	data := body.Bytes()
+
+	return retryWithBackoff(azureMaxRetries, func() (bool, error) {
+		req, err := http.NewRequest(http.MethodPost, a.url, bytes.NewReader(data))
+		if err != nil {
+			return false, err
+		}
+		date := time.Now().UTC().Format(http.TimeFormat)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Log-Type", a.logType)
+		req.Header.Set("x-ms-date", date)
+		req.Header.Set("time-generated-field", azureTimeField)
+		req.Header.Set("Authorization", a.authorization(len(data), date))
+
+		resp, err := a.client.Do(req)
+		if err != nil {
+			return true, err
+		}
+		err = checkHTTPResponse(resp)
+		return isRetryableHTTPError(err), err
+	})
+}
+
+// authorization returns the shared key authorization of a request.
+func (a *azureClient) authorization(contentLength int, date string) string {
+	stringToSign := "POST\n" + strconv.Itoa(contentLength) + "\napplication/json\nx-ms-date:" + date + "\n/api/logs"
+	h := hmac.New(sha256.New, a.key)
+	h.Write([]byte(stringToSign)) // nolint:errcheck
+	return "SharedKey " + a.workspaceID + ":" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,107 @@
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// testAzureSharedKey is "scooby doo where are you" in base64.
+const testAzureSharedKey = "c2Nvb2J5IGRvbyB3aGVyZSBhcmUgeW91"
+
+func TestAzureAuthorization(t *testing.T) {
+	// computed with the HMAC-SHA256 of the string to sign documented by
+	// the Data Collector API
+	a := &azureClient{workspaceID: "workspace", key: []byte("scooby doo where are you")}
+	got := a.authorization(1024, "Mon, 02 Jan 2006 15:04:05 GMT")
+	if want := "SharedKey workspace:7k0owFTS2kAJuH8NbiNk9MY6fniWJ9s7vd9DoHiiQ2I="; got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+
+	for _, cfg := range []AzureMonitorConfig{
+		{SharedKey: testAzureSharedKey},
+		{WorkspaceID: "workspace", SharedKey: "not base64"},
+		{WorkspaceID: "workspace", SharedKey: testAzureSharedKey, LogType: "with spaces"},
+	} {
+		if _, err := newAzureMonitorCore(cfg, LevelDebug); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestAzureMonitorCore(t *testing.T) {
+	defer func(d time.Duration) { retryBackoff = d }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	var attempts int
+	var got []map[string]interface{}
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/api/logs" || r.URL.Query().Get("api-version") != "2016-04-01" {
+			t.Errorf("got %s %s", r.Method, r.URL)
+		}
+		header = r.Header
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Error(err)
+			return
+		}
+		a := &azureClient{workspaceID: "workspace", key: []byte("scooby doo where are you")}
+		if auth, want := r.Header.Get("Authorization"), a.authorization(len(data), r.Header.Get("x-ms-date")); auth != want {
+			t.Errorf("got authorization %q, wanted %q", auth, want)
+		}
+	}))
+	defer srv.Close()
+
+	core, err := newAzureMonitorCore(AzureMonitorConfig{
+		WorkspaceID: "workspace",
+		SharedKey:   testAzureSharedKey,
+		LogType:     "App",
+		Endpoint:    srv.URL,
+	}, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*batchCore).Close()
+
+	zap.New(core).Named("dht").Error("scooby", zap.Int("count", 3))
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts != 2 {
+		t.Errorf("got %d attempts, wanted the failed request to be retried", attempts)
+	}
+	for key, want := range map[string]string{
+		"Content-Type":         "application/json",
+		"Log-Type":             "App",
+		"time-generated-field": "ts",
+	} {
+		if got := header.Get(key); got != want {
+			t.Errorf("got %s %q, wanted %q", key, got, want)
+		}
+	}
+	if _, err := time.Parse(http.TimeFormat, header.Get("x-ms-date")); err != nil {
+		t.Errorf("got x-ms-date %q: %s", header.Get("x-ms-date"), err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d records, wanted 1", len(got))
+	}
+	if got[0]["msg"] != "scooby" || got[0]["logger"] != "dht" || got[0]["count"] != float64(3) || got[0]["ts"] == nil {
+		t.Errorf("got record %v", got[0])
+	}
+}
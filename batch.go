@@ -0,0 +1,276 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+
+	// minBatchLimit is the minimum number of pending entries of a batcher,
+	// which otherwise keeps up to 10 batches.
+	minBatchLimit = 1000
+)
+
+// batchEntry is a log entry waiting to be shipped by a batcher.
+type batchEntry struct {
+	ent  zapcore.Entry
+	data []byte
+
+	// payload is an output specific representation of the entry, for the
+	// outputs that do not encode entries to bytes.
+	payload interface{}
+}
+
+// batcher collects entries and hands them to flush in batches, whenever size
+// entries are pending and at least every interval. Flushing happens in the
+// background so that logging never waits on the network.
+type batcher struct {
+	size     int
+	interval time.Duration
+	flush    func([]batchEntry) error
+
+	// limit bounds the number of pending entries, so that memory doesn't
+	// grow while the endpoint is unreachable. Entries added beyond it are
+	// dropped and reported on the next flush.
+	limit int
+
+	mu      sync.Mutex // guards pending, dropped and flushing
+	pending []batchEntry
+	dropped int
+	// flushing is the number of entries being flushed.
+	flushing int
+
+	flushMu sync.Mutex // serializes calls to flush
+
+	trigger   chan struct{}
+	closing   chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newBatcher(size int, interval time.Duration, flush func([]batchEntry) error) *batcher {
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	limit := 10 * size
+	if limit < minBatchLimit {
+		limit = minBatchLimit
+	}
+	b := &batcher{
+		size:     size,
+		interval: interval,
+		flush:    flush,
+		limit:    limit,
+		trigger:  make(chan struct{}, 1),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go b.loop()
+	return b
+}
+
+func (b *batcher) add(e batchEntry) {
+	b.mu.Lock()
+	if len(b.pending) >= b.limit {
+		b.dropped++
+	} else {
+		b.pending = append(b.pending, e)
+	}
+	full := len(b.pending) >= b.size
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.trigger <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (b *batcher) loop() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.trigger:
+		case <-b.closing:
+			return
+		}
+		if err := b.Sync(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to flush log batch: %s\n", err)
+		}
+	}
+}
+
+// Sync flushes all pending entries.
+func (b *batcher) Sync() error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	dropped := b.dropped
+	b.dropped = 0
+	b.mu.Unlock()
+	if dropped > 0 {
+		fmt.Fprintf(os.Stderr, "dropped %d log entries: too many pending entries\n", dropped)
+	}
+
+	var err error
+	for {
+		b.mu.Lock()
+		n := len(b.pending)
+		if n > b.size {
+			n = b.size
+		}
+		batch := b.pending[:n:n]
+		b.pending = b.pending[n:]
+		b.flushing = n
+		b.mu.Unlock()
+
+		if len(batch) == 0 {
+			return err
+		}
+		ferr := b.flush(batch)
+		b.mu.Lock()
+		b.flushing = 0
+		b.mu.Unlock()
+		if ferr != nil {
+			err = ferr
+		}
+	}
+}
+
+// Close stops the background flushing and flushes the remaining entries.
+func (b *batcher) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.closing)
+	})
+	<-b.done
+	return b.Sync()
+}
+
+// shutdown stops the background flushing and flushes the remaining entries
+// until ctx is done, when the entries not flushed yet are dropped, returning
+// ctx.Err() and their number.
+func (b *batcher) shutdown(ctx context.Context) (dropped int, err error) {
+	b.closeOnce.Do(func() {
+		close(b.closing)
+	})
+	flushed := make(chan error, 1)
+	go func() {
+		<-b.done
+		flushed <- b.Sync()
+	}()
+
+	select {
+	case err := <-flushed:
+		return 0, err
+	case <-ctx.Done():
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		dropped = len(b.pending) + b.flushing
+		b.pending = nil
+		return dropped, ctx.Err()
+	}
+}
+
+// batchCore is a core encoding entries and handing them to a batcher.
+type batchCore struct {
+	zapcore.LevelEnabler
+	enc     zapcore.Encoder
+	batcher *batcher
+
+	// sync, if set, ships the entries buffered downstream of the batcher
+	// once it has been flushed.
+	sync func() error
+
+	// close, if set, releases the resources used for shipping entries once
+	// the batcher has been flushed.
+	close func() error
+}
+
+func (c *batchCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.enc = c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(clone.enc)
+	}
+	return &clone
+}
+
+func (c *batchCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *batchCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	data := make([]byte, buf.Len())
+	copy(data, buf.Bytes())
+	buf.Free()
+
+	c.batcher.add(batchEntry{ent: ent, data: data})
+	return nil
+}
+
+func (c *batchCore) Sync() error {
+	err := c.batcher.Sync()
+	if c.sync != nil {
+		err = multierr.Append(err, c.sync())
+	}
+	return err
+}
+
+func (c *batchCore) Close() error {
+	err := c.batcher.Close()
+	if c.close != nil {
+		err = multierr.Append(err, c.close())
+	}
+	return err
+}
+
+func (c *batchCore) shutdown(ctx context.Context) (int, error) {
+	dropped, err := c.batcher.shutdown(ctx)
+	if err == nil && c.close != nil {
+		err = c.close()
+	}
+	return dropped, err
+}
+
+// retryBackoff is the delay before the first retry, doubled for every retry.
+var retryBackoff = 500 * time.Millisecond
+
+// retryWithBackoff calls fn until it succeeds, returns an error that is not
+// retryable or maxRetries retries have been made.
+func retryWithBackoff(maxRetries int, fn func() (retryable bool, err error)) error {
+	delay := retryBackoff
+	for attempt := 0; ; attempt++ {
+		retryable, err := fn()
+		if err == nil || !retryable || attempt >= maxRetries {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,26 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBatcherLimit(t *testing.T) {
+	// the endpoint is stuck, the entries stay pending
+	stuck := make(chan struct{})
+	b := newBatcher(10, time.Hour, func([]batchEntry) error {
+		<-stuck
+		return nil
+	})
+	defer b.Close()
+	defer close(stuck)
+
+	for i := 0; i < 2*minBatchLimit; i++ {
+		b.add(batchEntry{data: []byte("scooby")})
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.pending) != minBatchLimit || b.dropped == 0 {
+		t.Errorf("got %d pending and %d dropped entries, wanted %d pending", len(b.pending), b.dropped, minBatchLimit)
+	}
+}
@@ -0,0 +1,511 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// binaryFormat appends the values of a binary serialization format.
+type binaryFormat interface {
+	appendNil(b []byte) []byte
+	appendBool(b []byte, v bool) []byte
+	appendInt(b []byte, v int64) []byte
+	appendUint(b []byte, v uint64) []byte
+	appendFloat(b []byte, v float64) []byte
+	appendString(b []byte, v string) []byte
+	appendBytes(b []byte, v []byte) []byte
+	appendTime(b []byte, v time.Time) []byte
+	// appendKey appends a key of a map, before its value
+	appendKey(b []byte, key string) []byte
+	// appendArray and appendMap append the arrays and maps of n elements
+	// encoded in body, key-value pairs for maps
+	appendArray(b []byte, n int, body []byte) []byte
+	appendMap(b []byte, n int, body []byte) []byte
+}
+
+// binaryEncoder is an encoder writing entries as maps of a binary format,
+// concatenated without separators. The keys of entries are those of the
+// encoder config, times are native times of the format unless EncodeTime is
+// set, and durations are nanoseconds unless EncodeDuration is set.
+type binaryEncoder struct {
+	*zapcore.EncoderConfig
+	format binaryFormat
+
+	// sorted indicates whether the keys of maps are sorted bytewise, the
+	// last field with a key replacing the others, for entries to be encoded
+	// deterministically.
+	sorted bool
+
+	// objects are the object of the entry fields followed by the objects
+	// of the open namespaces.
+	objects []*binaryObject
+}
+
+// binaryObject is a map being encoded.
+type binaryObject struct {
+	// key is the key of the map in its parent object, for namespaces
+	key   string
+	buf   []byte
+	pairs []int // offsets in buf of the keys, and of the values after them
+}
+
+func newBinaryEncoder(cfg zapcore.EncoderConfig, format binaryFormat, sorted bool) *binaryEncoder {
+	return &binaryEncoder{
+		EncoderConfig: &cfg,
+		format:        format,
+		sorted:        sorted,
+		objects:       []*binaryObject{{}},
+	}
+}
+
+// addKey appends key to the innermost object, before its value.
+func (e *binaryEncoder) addKey(key string) *binaryObject {
+	o := e.objects[len(e.objects)-1]
+	o.pairs = append(o.pairs, len(o.buf))
+	o.buf = e.format.appendKey(o.buf, key)
+	o.pairs = append(o.pairs, len(o.buf))
+	return o
+}
+
+// encode appends o as a map to b.
+func (o *binaryObject) encode(b []byte, format binaryFormat, sorted bool) []byte {
+	body, n := o.body(sorted)
+	return format.appendMap(b, n, body)
+}
+
+// body returns the key-value pairs of o, and their number.
+func (o *binaryObject) body(sorted bool) ([]byte, int) {
+	if !sorted {
+		return o.buf, len(o.pairs) / 2
+	}
+
+	type pair struct{ key, value []byte }
+	pairs := make([]pair, 0, len(o.pairs)/2)
+	for i := 0; i < len(o.pairs); i += 2 {
+		end := len(o.buf)
+		if i+2 < len(o.pairs) {
+			end = o.pairs[i+2]
+		}
+		pairs = append(pairs, pair{key: o.buf[o.pairs[i]:o.pairs[i+1]], value: o.buf[o.pairs[i+1]:end]})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return bytes.Compare(pairs[i].key, pairs[j].key) < 0 })
+	body := make([]byte, 0, len(o.buf))
+	n := 0
+	for i, p := range pairs {
+		if i+1 < len(pairs) && bytes.Equal(p.key, pairs[i+1].key) {
+			continue
+		}
+		body = append(append(body, p.key...), p.value...)
+		n++
+	}
+	return body, n
+}
+
+// closeNamespaces adds the open namespaces to their parent objects.
+func (e *binaryEncoder) closeNamespaces() {
+	for len(e.objects) > 1 {
+		ns := e.objects[len(e.objects)-1]
+		e.objects = e.objects[:len(e.objects)-1]
+		o := e.addKey(ns.key)
+		o.buf = ns.encode(o.buf, e.format, e.sorted)
+	}
+}
+
+// close closes the open namespaces, and appends the fields to b as a map.
+func (e *binaryEncoder) close(b []byte) []byte {
+	e.closeNamespaces()
+	return e.objects[0].encode(b, e.format, e.sorted)
+}
+
+func (e *binaryEncoder) Clone() zapcore.Encoder {
+	return e.clone()
+}
+
+func (e *binaryEncoder) clone() *binaryEncoder {
+	clone := &binaryEncoder{
+		EncoderConfig: e.EncoderConfig,
+		format:        e.format,
+		sorted:        e.sorted,
+		objects:       make([]*binaryObject, len(e.objects)),
+	}
+	for i, o := range e.objects {
+		clone.objects[i] = &binaryObject{
+			key:   o.key,
+			buf:   append([]byte(nil), o.buf...),
+			pairs: append([]int(nil), o.pairs...),
+		}
+	}
+	return clone
+}
+
+func (e *binaryEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := e.encodeFields(ent, fields)
+	buf := encoderPool.Get()
+	buf.Write(final.encode(nil, e.format, e.sorted)) // nolint:errcheck
+	return buf, nil
+}
+
+// encodeFields returns the object of the keys of ent, followed by the context
+// and fields.
+func (e *binaryEncoder) encodeFields(ent zapcore.Entry, fields []zapcore.Field) *binaryObject {
+	final := &binaryEncoder{
+		EncoderConfig: e.EncoderConfig,
+		format:        e.format,
+		sorted:        e.sorted,
+		objects:       []*binaryObject{{}},
+	}
+
+	if e.TimeKey != "" {
+		o := final.addKey(e.TimeKey)
+		o.buf = final.encodeTime(o.buf, ent.Time)
+	}
+	if e.LevelKey != "" {
+		o := final.addKey(e.LevelKey)
+		if e.EncodeLevel != nil {
+			o.buf = final.encodePrimitive(o.buf, func(arr zapcore.PrimitiveArrayEncoder) { e.EncodeLevel(ent.Level, arr) })
+		} else {
+			o.buf = final.format.appendString(o.buf, levelName(ent.Level))
+		}
+	}
+	if e.NameKey != "" && ent.LoggerName != "" {
+		o := final.addKey(e.NameKey)
+		if e.EncodeName != nil {
+			o.buf = final.encodePrimitive(o.buf, func(arr zapcore.PrimitiveArrayEncoder) { e.EncodeName(ent.LoggerName, arr) })
+		} else {
+			o.buf = final.format.appendString(o.buf, ent.LoggerName)
+		}
+	}
+	if e.CallerKey != "" && ent.Caller.Defined {
+		o := final.addKey(e.CallerKey)
+		if e.EncodeCaller != nil {
+			o.buf = final.encodePrimitive(o.buf, func(arr zapcore.PrimitiveArrayEncoder) { e.EncodeCaller(ent.Caller, arr) })
+		} else {
+			o.buf = final.format.appendString(o.buf, callerString(ent.Caller))
+		}
+	}
+	if e.MessageKey != "" {
+		o := final.addKey(e.MessageKey)
+		o.buf = final.format.appendString(o.buf, ent.Message)
+	}
+
+	// the context comes after the entry keys
+	context := e.clone().objects
+	root := final.objects[0]
+	for _, offset := range context[0].pairs {
+		root.pairs = append(root.pairs, len(root.buf)+offset)
+	}
+	root.buf = append(root.buf, context[0].buf...)
+	final.objects = append(final.objects, context[1:]...)
+
+	for i := range fields {
+		fields[i].AddTo(final)
+	}
+	final.closeNamespaces()
+	if e.StacktraceKey != "" && ent.Stack != "" {
+		final.AddString(e.StacktraceKey, ent.Stack)
+	}
+
+	return final.objects[0]
+}
+
+// encodeTime appends t with EncodeTime if set, as a native time otherwise.
+func (e *binaryEncoder) encodeTime(b []byte, t time.Time) []byte {
+	if e.EncodeTime == nil {
+		return e.format.appendTime(b, t)
+	}
+	return e.encodePrimitive(b, func(arr zapcore.PrimitiveArrayEncoder) { e.EncodeTime(t, arr) })
+}
+
+// encodeDuration appends d with EncodeDuration if set, in nanoseconds
+// otherwise.
+func (e *binaryEncoder) encodeDuration(b []byte, d time.Duration) []byte {
+	if e.EncodeDuration == nil {
+		return e.format.appendInt(b, int64(d))
+	}
+	return e.encodePrimitive(b, func(arr zapcore.PrimitiveArrayEncoder) { e.EncodeDuration(d, arr) })
+}
+
+// encodePrimitive appends the value appended by encode, or nil if there is
+// none, or an array if there are several.
+func (e *binaryEncoder) encodePrimitive(b []byte, encode func(zapcore.PrimitiveArrayEncoder)) []byte {
+	arr := &binaryArray{enc: e}
+	encode(arr)
+	switch arr.n {
+	case 0:
+		return e.format.appendNil(b)
+	case 1:
+		return append(b, arr.buf...)
+	default:
+		return e.format.appendArray(b, arr.n, arr.buf)
+	}
+}
+
+func (e *binaryEncoder) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	arr := &binaryArray{enc: e}
+	err := marshaler.MarshalLogArray(arr)
+	o := e.addKey(key)
+	o.buf = e.format.appendArray(o.buf, arr.n, arr.buf)
+	return err
+}
+
+func (e *binaryEncoder) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	nested := newBinaryEncoder(*e.EncoderConfig, e.format, e.sorted)
+	err := marshaler.MarshalLogObject(nested)
+	o := e.addKey(key)
+	o.buf = nested.close(o.buf)
+	return err
+}
+
+func (e *binaryEncoder) AddBinary(key string, value []byte) {
+	o := e.addKey(key)
+	o.buf = e.format.appendBytes(o.buf, value)
+}
+
+func (e *binaryEncoder) AddByteString(key string, value []byte) {
+	o := e.addKey(key)
+	o.buf = e.format.appendString(o.buf, string(value))
+}
+
+func (e *binaryEncoder) AddBool(key string, value bool) {
+	o := e.addKey(key)
+	o.buf = e.format.appendBool(o.buf, value)
+}
+
+func (e *binaryEncoder) AddComplex128(key string, value complex128) {
+	o := e.addKey(key)
+	o.buf = e.format.appendString(o.buf, strconv.FormatComplex(value, 'g', -1, 128))
+}
+
+func (e *binaryEncoder) AddComplex64(key string, value complex64) {
+	o := e.addKey(key)
+	o.buf = e.format.appendString(o.buf, strconv.FormatComplex(complex128(value), 'g', -1, 64))
+}
+
+func (e *binaryEncoder) AddDuration(key string, value time.Duration) {
+	o := e.addKey(key)
+	o.buf = e.encodeDuration(o.buf, value)
+}
+
+func (e *binaryEncoder) AddFloat64(key string, value float64) {
+	o := e.addKey(key)
+	o.buf = e.format.appendFloat(o.buf, value)
+}
+
+func (e *binaryEncoder) AddFloat32(key string, value float32) {
+	e.AddFloat64(key, float64(value))
+}
+
+func (e *binaryEncoder) AddInt(key string, value int)     { e.AddInt64(key, int64(value)) }
+func (e *binaryEncoder) AddInt32(key string, value int32) { e.AddInt64(key, int64(value)) }
+func (e *binaryEncoder) AddInt16(key string, value int16) { e.AddInt64(key, int64(value)) }
+func (e *binaryEncoder) AddInt8(key string, value int8)   { e.AddInt64(key, int64(value)) }
+
+func (e *binaryEncoder) AddInt64(key string, value int64) {
+	o := e.addKey(key)
+	o.buf = e.format.appendInt(o.buf, value)
+}
+
+func (e *binaryEncoder) AddString(key, value string) {
+	o := e.addKey(key)
+	o.buf = e.format.appendString(o.buf, value)
+}
+
+func (e *binaryEncoder) AddTime(key string, value time.Time) {
+	o := e.addKey(key)
+	o.buf = e.encodeTime(o.buf, value)
+}
+
+func (e *binaryEncoder) AddUint(key string, value uint)       { e.AddUint64(key, uint64(value)) }
+func (e *binaryEncoder) AddUint32(key string, value uint32)   { e.AddUint64(key, uint64(value)) }
+func (e *binaryEncoder) AddUint16(key string, value uint16)   { e.AddUint64(key, uint64(value)) }
+func (e *binaryEncoder) AddUint8(key string, value uint8)     { e.AddUint64(key, uint64(value)) }
+func (e *binaryEncoder) AddUintptr(key string, value uintptr) { e.AddUint64(key, uint64(value)) }
+
+func (e *binaryEncoder) AddUint64(key string, value uint64) {
+	o := e.addKey(key)
+	o.buf = e.format.appendUint(o.buf, value)
+}
+
+func (e *binaryEncoder) AddReflected(key string, value interface{}) error {
+	b, err := appendBinaryReflected(nil, e.format, value)
+	if err != nil {
+		return err
+	}
+	o := e.addKey(key)
+	o.buf = append(o.buf, b...)
+	return nil
+}
+
+func (e *binaryEncoder) OpenNamespace(key string) {
+	e.objects = append(e.objects, &binaryObject{key: key})
+}
+
+// binaryArray encodes the elements of an array of a binaryEncoder.
+type binaryArray struct {
+	enc *binaryEncoder
+	buf []byte
+	n   int
+}
+
+func (a *binaryArray) AppendArray(marshaler zapcore.ArrayMarshaler) error {
+	arr := &binaryArray{enc: a.enc}
+	err := marshaler.MarshalLogArray(arr)
+	a.buf = a.enc.format.appendArray(a.buf, arr.n, arr.buf)
+	a.n++
+	return err
+}
+
+func (a *binaryArray) AppendObject(marshaler zapcore.ObjectMarshaler) error {
+	nested := newBinaryEncoder(*a.enc.EncoderConfig, a.enc.format, a.enc.sorted)
+	err := marshaler.MarshalLogObject(nested)
+	a.buf = nested.close(a.buf)
+	a.n++
+	return err
+}
+
+func (a *binaryArray) AppendReflected(value interface{}) error {
+	b, err := appendBinaryReflected(a.buf, a.enc.format, value)
+	if err != nil {
+		return err
+	}
+	a.buf = b
+	a.n++
+	return nil
+}
+
+func (a *binaryArray) AppendBool(v bool) {
+	a.buf = a.enc.format.appendBool(a.buf, v)
+	a.n++
+}
+
+func (a *binaryArray) AppendByteString(v []byte) {
+	a.buf = a.enc.format.appendString(a.buf, string(v))
+	a.n++
+}
+
+func (a *binaryArray) AppendComplex128(v complex128) {
+	a.buf = a.enc.format.appendString(a.buf, strconv.FormatComplex(v, 'g', -1, 128))
+	a.n++
+}
+
+func (a *binaryArray) AppendComplex64(v complex64) {
+	a.buf = a.enc.format.appendString(a.buf, strconv.FormatComplex(complex128(v), 'g', -1, 64))
+	a.n++
+}
+
+func (a *binaryArray) AppendDuration(v time.Duration) {
+	a.buf = a.enc.encodeDuration(a.buf, v)
+	a.n++
+}
+
+func (a *binaryArray) AppendFloat64(v float64) {
+	a.buf = a.enc.format.appendFloat(a.buf, v)
+	a.n++
+}
+
+func (a *binaryArray) AppendFloat32(v float32) { a.AppendFloat64(float64(v)) }
+
+func (a *binaryArray) AppendInt(v int)     { a.AppendInt64(int64(v)) }
+func (a *binaryArray) AppendInt32(v int32) { a.AppendInt64(int64(v)) }
+func (a *binaryArray) AppendInt16(v int16) { a.AppendInt64(int64(v)) }
+func (a *binaryArray) AppendInt8(v int8)   { a.AppendInt64(int64(v)) }
+
+func (a *binaryArray) AppendInt64(v int64) {
+	a.buf = a.enc.format.appendInt(a.buf, v)
+	a.n++
+}
+
+func (a *binaryArray) AppendString(v string) {
+	a.buf = a.enc.format.appendString(a.buf, v)
+	a.n++
+}
+
+func (a *binaryArray) AppendTime(v time.Time) {
+	a.buf = a.enc.encodeTime(a.buf, v)
+	a.n++
+}
+
+func (a *binaryArray) AppendUint(v uint)       { a.AppendUint64(uint64(v)) }
+func (a *binaryArray) AppendUint32(v uint32)   { a.AppendUint64(uint64(v)) }
+func (a *binaryArray) AppendUint16(v uint16)   { a.AppendUint64(uint64(v)) }
+func (a *binaryArray) AppendUint8(v uint8)     { a.AppendUint64(uint64(v)) }
+func (a *binaryArray) AppendUintptr(v uintptr) { a.AppendUint64(uint64(v)) }
+
+func (a *binaryArray) AppendUint64(v uint64) {
+	a.buf = a.enc.format.appendUint(a.buf, v)
+	a.n++
+}
+
+// appendBinaryReflected appends value as its JSON encoding would be decoded,
+// the keys of maps being sorted.
+func appendBinaryReflected(b []byte, format binaryFormat, value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var decoded interface{}
+	if err := dec.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return appendBinaryValue(b, format, decoded), nil
+}
+
+// appendBinaryValue appends v, which must be made of the types produced by
+// decoding JSON into an interface{} with numbers.
+func appendBinaryValue(b []byte, format binaryFormat, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return format.appendNil(b)
+	case bool:
+		return format.appendBool(b, v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return format.appendInt(b, i)
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return format.appendUint(b, u)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			f = math.NaN()
+		}
+		return format.appendFloat(b, f)
+	case string:
+		return format.appendString(b, v)
+	case []interface{}:
+		var body []byte
+		for _, e := range v {
+			body = appendBinaryValue(body, format, e)
+		}
+		return format.appendArray(b, len(v), body)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		// sorted like encoded keys
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+		var body []byte
+		for _, k := range keys {
+			body = format.appendKey(body, k)
+			body = appendBinaryValue(body, format, v[k])
+		}
+		return format.appendMap(b, len(v), body)
+	default:
+		return format.appendNil(b)
+	}
+}
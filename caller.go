@@ -0,0 +1,184 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// The caller formats of Config.Caller.
+const (
+	// CallerShort is the directory and name of the file of the caller, and
+	// its line, i.e. "dht/query.go:42".
+	CallerShort = "short"
+	// CallerFull is the full path of the file of the caller, and its line.
+	CallerFull = "full"
+	// CallerRelative is the path of the file of the caller relative to the
+	// root of its module, and its line, i.e. "net/dht/query.go:42". The files
+	// of other modules are prefixed with the import path of their package.
+	CallerRelative = "relative"
+	// CallerFunction is the package and name of the function of the caller,
+	// i.e. "dht.(*Query).Run".
+	CallerFunction = "function"
+	// CallerNone omits the caller.
+	CallerNone = "none"
+)
+
+var callerFormats = map[string]bool{
+	CallerShort:    true,
+	CallerFull:     true,
+	CallerRelative: true,
+	CallerFunction: true,
+	CallerNone:     true,
+}
+
+// callerOptions are the caller formats of Config.Caller and
+// Config.SubsystemCallers.
+type callerOptions struct {
+	format     string
+	subsystems map[string]string
+}
+
+// callerOptionsOf returns the caller options of cfg, ignoring unknown
+// formats.
+func callerOptionsOf(cfg Config) callerOptions {
+	var opts callerOptions
+	if cfg.Caller != "" {
+		if callerFormats[cfg.Caller] {
+			opts.format = cfg.Caller
+		} else {
+			fmt.Fprintf(os.Stderr, "ignoring unknown caller format '%s'\n", cfg.Caller)
+		}
+	}
+	for name, format := range cfg.SubsystemCallers {
+		if !callerFormats[format] {
+			fmt.Fprintf(os.Stderr, "ignoring unknown caller format '%s' of subsystem '%s'\n", format, name)
+			continue
+		}
+		if opts.subsystems == nil {
+			opts.subsystems = make(map[string]string)
+		}
+		opts.subsystems[name] = format
+	}
+	return opts
+}
+
+// formatOf returns the caller format of the logger named name, which is the
+// format of its subsystem or of the closest parent subsystem, the configured
+// format, or else def.
+func (o callerOptions) formatOf(name, def string) string {
+	for name != "" {
+		if format, ok := o.subsystems[name]; ok {
+			return format
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	if o.format != "" {
+		return o.format
+	}
+	return def
+}
+
+// callerEncoder formats the callers of entries before encoding them: the
+// file of the caller is replaced with the path of the caller format, or
+// cleared with the caller format CallerFunction, only the function being set
+// then. Encoders write the callers with callerString.
+type callerEncoder struct {
+	zapcore.Encoder
+	opts callerOptions
+	// format is the caller format of the encoder when not configured
+	format string
+}
+
+func (e *callerEncoder) Clone() zapcore.Encoder {
+	return &callerEncoder{e.Encoder.Clone(), e.opts, e.format}
+}
+
+func (e *callerEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	if ent.Caller.Defined {
+		ent.Caller = formatCaller(ent.Caller, e.opts.formatOf(ent.LoggerName, e.format))
+	}
+	return e.Encoder.EncodeEntry(ent, fields)
+}
+
+// formatCaller returns caller with its file or function in the given caller
+// format.
+func formatCaller(caller zapcore.EntryCaller, format string) zapcore.EntryCaller {
+	switch format {
+	case CallerNone:
+		return zapcore.EntryCaller{}
+	case CallerFull:
+	case CallerRelative:
+		if pkg := callerPackage(caller.Function); pkg != "" {
+			if mod := mainModule(); mod != "" && strings.HasPrefix(pkg+"/", mod+"/") {
+				pkg = strings.TrimPrefix(strings.TrimPrefix(pkg, mod), "/")
+			}
+			caller.File = path.Join(pkg, path.Base(caller.File))
+		}
+	case CallerFunction:
+		if caller.Function != "" {
+			caller.Function = caller.Function[strings.LastIndexByte(caller.Function, '/')+1:]
+			caller.File = ""
+			caller.Line = 0
+		}
+	default:
+		// like zapcore.EntryCaller.TrimmedPath
+		if i := strings.LastIndexByte(caller.File, '/'); i >= 0 {
+			if j := strings.LastIndexByte(caller.File[:i], '/'); j >= 0 {
+				caller.File = caller.File[j+1:]
+			}
+		}
+	}
+	return caller
+}
+
+// callerPackage returns the import path of the package of the function
+// named function.
+func callerPackage(function string) string {
+	slash := strings.LastIndexByte(function, '/')
+	dot := strings.IndexByte(function[slash+1:], '.')
+	if dot < 0 {
+		return ""
+	}
+	return function[:slash+1+dot]
+}
+
+var (
+	mainModuleOnce sync.Once
+	mainModulePath string
+)
+
+// mainModule returns the path of the main module, if known.
+func mainModule() string {
+	mainModuleOnce.Do(func() {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			mainModulePath = info.Main.Path
+		}
+	})
+	return mainModulePath
+}
+
+// callerString returns a caller formatted by callerEncoder as a string.
+func callerString(caller zapcore.EntryCaller) string {
+	if caller.File == "" {
+		return caller.Function
+	}
+	return caller.File + ":" + strconv.Itoa(caller.Line)
+}
+
+// encodeCaller is the zapcore.CallerEncoder of the callers formatted by
+// callerEncoder.
+func encodeCaller(caller zapcore.EntryCaller, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(callerString(caller))
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestFormatCaller(t *testing.T) {
+	caller := zapcore.EntryCaller{
+		Defined:  true,
+		File:     "/home/user/go/src/example.com/app/net/dht/query.go",
+		Line:     42,
+		Function: "example.com/app/net/dht.(*Query).Run",
+	}
+	for format, want := range map[string]string{
+		CallerShort:    "dht/query.go:42",
+		CallerFull:     "/home/user/go/src/example.com/app/net/dht/query.go:42",
+		CallerFunction: "dht.(*Query).Run",
+	} {
+		if got := callerString(formatCaller(caller, format)); got != want {
+			t.Errorf("got %s caller %q, wanted %q", format, got, want)
+		}
+	}
+	// example.com/app is not the main module
+	if got := callerString(formatCaller(caller, CallerRelative)); got != "example.com/app/net/dht/query.go:42" {
+		t.Errorf("got relative caller %q, wanted the import path of the package", got)
+	}
+	if formatCaller(caller, CallerNone).Defined {
+		t.Error("wanted no caller")
+	}
+}
+
+func TestSubsystemCallers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{
+		Level:            LevelInfo,
+		Format:           FormatJSONOutput,
+		File:             path,
+		Caller:           CallerFunction,
+		SubsystemCallers: map[string]string{"quiet": CallerNone},
+	})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+	getLogger("loud").Error("scooby")
+	getLogger("quiet").Error("doo")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d entries, wanted 2", len(lines))
+	}
+	var loud, quiet map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &loud); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &quiet); err != nil {
+		t.Fatal(err)
+	}
+	if loud["caller"] != "go-log.TestSubsystemCallers" {
+		t.Errorf("got caller %v, wanted the function", loud["caller"])
+	}
+	if _, ok := quiet["caller"]; ok {
+		t.Errorf("got caller %v, wanted none", quiet["caller"])
+	}
+}
@@ -0,0 +1,78 @@
+package log
+
+import "sync"
+
+// Capture records entries, for tests to assert that entries were or weren't
+// logged. See StartCapture.
+type Capture struct {
+	filter func(Entry) bool
+	core   *subscriptionCore
+
+	mu      sync.Mutex // guards entries
+	entries []Entry
+}
+
+// StartCapture starts recording the entries matching filter, or all of them
+// when filter is nil. Like Subscribe, the capture records everything enabled
+// by SetLogLevel. Stop must be called once done.
+func StartCapture(filter func(Entry) bool) *Capture {
+	c := &Capture{filter: filter}
+	c.core = &subscriptionCore{sub: c}
+	loggerCore.AddCore(c.core)
+	return c
+}
+
+func (c *Capture) publish(e Entry) {
+	if c.filter != nil && !c.filter(e) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = append(c.entries, e)
+}
+
+// Entries returns the recorded entries, in the order they were logged.
+func (c *Capture) Entries() []Entry {
+	return c.filterEntries(func(Entry) bool { return true })
+}
+
+// FilterMessage returns the recorded entries with the message msg.
+func (c *Capture) FilterMessage(msg string) []Entry {
+	return c.filterEntries(func(e Entry) bool { return e.Message == msg })
+}
+
+// FilterLevel returns the recorded entries at level or above.
+func (c *Capture) FilterLevel(level LogLevel) []Entry {
+	return c.filterEntries(func(e Entry) bool { return e.Level >= level })
+}
+
+// FilterSubsystem returns the recorded entries of subsystem.
+func (c *Capture) FilterSubsystem(subsystem string) []Entry {
+	return c.filterEntries(func(e Entry) bool { return e.Subsystem == subsystem })
+}
+
+func (c *Capture) filterEntries(match func(Entry) bool) []Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var entries []Entry
+	for _, e := range c.entries {
+		if match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Reset forgets the recorded entries.
+func (c *Capture) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+// Stop stops recording entries. The recorded entries are kept.
+func (c *Capture) Stop() {
+	loggerCore.DeleteCore(c.core)
+}
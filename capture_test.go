@@ -0,0 +1,37 @@
+package log
+
+import "testing"
+
+func TestCapture(t *testing.T) {
+	capture := StartCapture(func(e Entry) bool { return e.Subsystem != "ignored" })
+	defer capture.Stop()
+
+	log := getLogger("captured")
+	SetLogLevel("captured", "info")
+	defer SetLogLevel("captured", "error")
+	log.Debug("filtered")
+	log.Infow("scooby", "id", 3)
+	log.Warn("doo")
+	getLogger("ignored").Error("ignored")
+
+	if entries := capture.Entries(); len(entries) != 2 || entries[0].Fields["id"] != int64(3) {
+		t.Errorf("got %v, wanted the info and warning entries", entries)
+	}
+	if entries := capture.FilterMessage("doo"); len(entries) != 1 || entries[0].Level != LevelWarn {
+		t.Errorf("got %v, wanted the warning", entries)
+	}
+	if entries := capture.FilterLevel(LevelWarn); len(entries) != 1 {
+		t.Errorf("got %v, wanted the warning", entries)
+	}
+	if entries := capture.FilterSubsystem("captured"); len(entries) != 2 {
+		t.Errorf("got %v, wanted the entries of the subsystem", entries)
+	}
+
+	capture.Reset()
+	log.Error("where are you")
+	capture.Stop()
+	log.Error("stopped")
+	if entries := capture.Entries(); len(entries) != 1 || entries[0].Message != "where are you" {
+		t.Errorf("got %v, wanted the entry logged after the reset", entries)
+	}
+}
@@ -0,0 +1,129 @@
+package log
+
+import (
+	"math"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// This file implements the subset of CBOR needed by the CBOR formats. See
+// https://www.rfc-editor.org/rfc/rfc8949.html
+
+const (
+	cborUint   = 0 << 5
+	cborNegint = 1 << 5
+	cborBytes  = 2 << 5
+	cborText   = 3 << 5
+	cborArray  = 4 << 5
+	cborMap    = 5 << 5
+	cborTag    = 6 << 5
+
+	cborFalse   = 0xf4
+	cborTrue    = 0xf5
+	cborNull    = 0xf6
+	cborFloat64 = 0xfb
+
+	// cborEpochTimeTag is the tag of times in seconds since the epoch
+	cborEpochTimeTag = 1
+)
+
+// appendCBORHead appends the head of a data item of major type major, with
+// argument n in its shortest form.
+func appendCBORHead(b []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return append(b, major|25, byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		return appendUint32(append(b, major|26), uint32(n))
+	default:
+		return appendUint64(append(b, major|27), n)
+	}
+}
+
+// cborFormat is the binaryFormat of CBOR. In deterministic mode, times are
+// RFC3339 strings in UTC instead of tagged epoch times, and NaN and
+// infinities are strings, so that entries are valid DAG-CBOR.
+type cborFormat struct {
+	deterministic bool
+}
+
+func (cborFormat) appendNil(b []byte) []byte {
+	return append(b, cborNull)
+}
+
+func (cborFormat) appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, cborTrue)
+	}
+	return append(b, cborFalse)
+}
+
+func (cborFormat) appendInt(b []byte, v int64) []byte {
+	if v < 0 {
+		return appendCBORHead(b, cborNegint, uint64(-1-v))
+	}
+	return appendCBORHead(b, cborUint, uint64(v))
+}
+
+func (cborFormat) appendUint(b []byte, v uint64) []byte {
+	return appendCBORHead(b, cborUint, v)
+}
+
+// appendFloat appends v as a 64-bit float, which DAG-CBOR requires.
+func (f cborFormat) appendFloat(b []byte, v float64) []byte {
+	if f.deterministic && (math.IsNaN(v) || math.IsInf(v, 0)) {
+		// like the JSON encoder
+		switch {
+		case math.IsNaN(v):
+			return f.appendString(b, "NaN")
+		case v > 0:
+			return f.appendString(b, "+Inf")
+		default:
+			return f.appendString(b, "-Inf")
+		}
+	}
+	return appendUint64(append(b, cborFloat64), math.Float64bits(v))
+}
+
+func (cborFormat) appendString(b []byte, v string) []byte {
+	return append(appendCBORHead(b, cborText, uint64(len(v))), v...)
+}
+
+func (cborFormat) appendBytes(b []byte, v []byte) []byte {
+	return append(appendCBORHead(b, cborBytes, uint64(len(v))), v...)
+}
+
+func (f cborFormat) appendTime(b []byte, v time.Time) []byte {
+	if f.deterministic {
+		return f.appendString(b, v.UTC().Format(time.RFC3339Nano))
+	}
+	b = appendCBORHead(b, cborTag, cborEpochTimeTag)
+	if v.Nanosecond() == 0 {
+		return f.appendInt(b, v.Unix())
+	}
+	return f.appendFloat(b, float64(v.UnixNano())/1e9)
+}
+
+func (f cborFormat) appendKey(b []byte, key string) []byte {
+	return f.appendString(b, key)
+}
+
+func (cborFormat) appendArray(b []byte, n int, body []byte) []byte {
+	return append(appendCBORHead(b, cborArray, uint64(n)), body...)
+}
+
+func (cborFormat) appendMap(b []byte, n int, body []byte) []byte {
+	return append(appendCBORHead(b, cborMap, uint64(n)), body...)
+}
+
+// newCBOREncoder returns the encoder of FormatCBOROutput, or of
+// FormatCBORDeterministicOutput, writing entries as CBOR maps with the keys
+// of cfg.
+func newCBOREncoder(cfg zapcore.EncoderConfig, deterministic bool) zapcore.Encoder {
+	return newBinaryEncoder(cfg, cborFormat{deterministic: deterministic}, deterministic)
+}
@@ -0,0 +1,55 @@
+package log
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestCBORDeterministicFormat(t *testing.T) {
+	ent := zapcore.Entry{
+		Level:      zapcore.InfoLevel,
+		Time:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*3600)),
+		LoggerName: "dht",
+		Message:    "hi",
+	}
+
+	enc := newEncoder(FormatCBORDeterministicOutput)
+	enc.AddString("peer", "a")
+	buf, err := enc.EncodeEntry(ent, []zapcore.Field{zap.Int("n", -2), zap.Float64("f", 0.5), zap.String("peer", "b")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a7" + // map of 7 pairs, sorted by encoded keys
+		"6166" + "fb3fe0000000000000" + // f: 0.5
+		"616e" + "21" + // n: -2
+		"627473" + "74" + hex.EncodeToString([]byte("2024-05-01T10:00:00Z")) + // ts in UTC
+		"636d7367" + "626869" + // msg: hi
+		"6470656572" + "6162" + // peer: b, replacing a
+		"656c6576656c" + "64696e666f" + // level: info
+		"666c6f67676572" + "63646874" // logger: dht
+	if got := hex.EncodeToString(buf.Bytes()); got != want {
+		t.Errorf("got  %s\nwanted %s", got, want)
+	}
+
+	// the same fields in another order are encoded the same
+	enc = newEncoder(FormatCBORDeterministicOutput)
+	enc.AddString("peer", "a")
+	other, err := enc.EncodeEntry(ent, []zapcore.Field{zap.String("peer", "b"), zap.Float64("f", 0.5), zap.Int("n", -2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.String() != buf.String() {
+		t.Error("expected entries with fields in any order to be encoded the same")
+	}
+}
+
+func TestCBORFormatTime(t *testing.T) {
+	b := cborFormat{}.appendTime(nil, time.Unix(1700000000, 0))
+	if got := hex.EncodeToString(b); got != "c11a6553f100" {
+		t.Errorf("got %s, wanted an epoch time tag", got)
+	}
+}
@@ -0,0 +1,114 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// cefVendor is the device vendor of CEF events.
+const cefVendor = "go-log"
+
+// cefSeverity maps a log level to a CEF severity, from 0 to 10.
+func cefSeverity(lvl zapcore.Level) int {
+	switch {
+	case lvl < zapcore.DebugLevel:
+		return 0
+	case lvl == zapcore.DebugLevel:
+		return 1
+	case lvl == zapcore.InfoLevel:
+		return 3
+	case lvl == zapcore.WarnLevel:
+		return 5
+	case lvl == zapcore.ErrorLevel:
+		return 7
+	case lvl == zapcore.DPanicLevel:
+		return 8
+	case lvl == zapcore.PanicLevel:
+		return 9
+	default:
+		return 10
+	}
+}
+
+// newCEFEncoder returns the encoder of FormatCEFOutput, writing entries as
+// ArcSight Common Event Format events of the program, with the subsystem as
+// signature ID, the message as name, and the fields as extensions.
+func newCEFEncoder() zapcore.Encoder {
+	hostname, _ := os.Hostname()
+	product := filepath.Base(os.Args[0])
+	version := "0"
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		version = info.Main.Version
+	}
+	pid := strconv.Itoa(os.Getpid())
+	prefix := "CEF:0|" + cefHeaderEscaper.Replace(cefVendor) + "|" + cefHeaderEscaper.Replace(product) + "|" + cefHeaderEscaper.Replace(version) + "|"
+
+	return newStructuredEncoder(func(buf *buffer.Buffer, ent zapcore.Entry, fields map[string]interface{}) error {
+		signature := ent.LoggerName
+		if signature == "" {
+			signature = "-"
+		}
+		buf.AppendString(prefix)
+		buf.AppendString(cefHeaderEscaper.Replace(signature))
+		buf.AppendByte('|')
+		buf.AppendString(cefHeaderEscaper.Replace(ent.Message))
+		buf.AppendByte('|')
+		buf.AppendInt(int64(cefSeverity(ent.Level)))
+		buf.AppendByte('|')
+
+		buf.AppendString("rt=")
+		buf.AppendInt(ent.Time.UnixNano() / 1e6)
+		if hostname != "" {
+			buf.AppendString(" dvchost=" + cefExtensionEscaper.Replace(hostname))
+		}
+		buf.AppendString(" dvcpid=" + pid)
+
+		if ent.Caller.Defined {
+			fields["caller"] = callerString(ent.Caller)
+		}
+		if ent.Stack != "" {
+			fields["stacktrace"] = ent.Stack
+		}
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			buf.AppendByte(' ')
+			buf.AppendString(cefExtensionKey(k))
+			buf.AppendByte('=')
+			buf.AppendString(cefExtensionEscaper.Replace(fieldString(fields[k])))
+		}
+		buf.AppendByte('\n')
+		return nil
+	})
+}
+
+// cefHeaderEscaper escapes the values of the header fields, on a single line.
+var cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r\n", " ", "\n", " ", "\r", " ")
+
+// cefExtensionEscaper escapes the values of extensions.
+var cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+
+// cefExtensionKey returns key made a valid extension key, made of letters,
+// digits and underscores.
+func cefExtensionKey(key string) string {
+	b := []byte(key)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
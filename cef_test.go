@@ -0,0 +1,27 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestCEFFormat(t *testing.T) {
+	buf := &bytes.Buffer{}
+	core := newCore(FormatCEFOutput, zapcore.AddSync(buf), LevelDebug)
+	zap.New(core).Named("auth").Error("login|failed", zap.String("user name", "a=b\nc"))
+
+	line := buf.String()
+	if !strings.HasPrefix(line, "CEF:0|go-log|") {
+		t.Errorf("got %q, wanted a CEF header", line)
+	}
+	if !strings.Contains(line, `|auth|login\|failed|7|rt=`) {
+		t.Errorf("got %q, wanted the subsystem, message and severity in the header", line)
+	}
+	if !strings.HasSuffix(line, ` user_name=a\=b\nc`+"\n") {
+		t.Errorf("got %q, wanted the fields as extensions", line)
+	}
+}
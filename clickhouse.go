@@ -0,0 +1,196 @@
+package log
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ClickHouseConfig configures inserting entries into a ClickHouse table with
+// the HTTP interface, in a table with the columns timestamp, level,
+// subsystem, message and fields.
+type ClickHouseConfig struct {
+	// URL is the URL of the HTTP interface, i.e. "http://localhost:8123".
+	// Credentials may be given as user info.
+	URL string
+
+	// Database and Table name the table entries are inserted into. They
+	// default to "default" and "logs".
+	Database string
+	Table    string
+
+	// CreateTable enables creating the table if it does not exist, with the
+	// MergeTree engine.
+	CreateTable bool
+
+	// WaitForAsyncInsert makes every insert wait for the asynchronous insert
+	// buffer of the server to be flushed.
+	WaitForAsyncInsert bool
+
+	// BatchSize is the maximum number of rows sent in one insert.
+	// Defaults to 100.
+	BatchSize int
+
+	// FlushInterval is the maximum time an entry waits before being sent.
+	// Defaults to one second.
+	FlushInterval time.Duration
+
+	// MaxRetries is the number of times an insert failing because of the
+	// network or an overloaded server is retried. Defaults to 3.
+	MaxRetries int
+
+	// TLS configures HTTPS connections when not nil.
+	TLS *tls.Config
+}
+
+const (
+	defaultClickHouseDatabase   = "default"
+	defaultClickHouseTable      = "logs"
+	defaultClickHouseMaxRetries = 3
+
+	clickHouseTimeLayout = "2006-01-02 15:04:05.000000000"
+)
+
+// newClickHouseCore creates a core inserting batches of rows into ClickHouse,
+// with asynchronous inserts so that the server buffers small batches before
+// writing its columns. The fields of the entries are stored as JSON.
+func newClickHouseCore(cfg ClickHouseConfig, level LogLevel) (zapcore.Core, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("missing ClickHouse URL")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	database := cfg.Database
+	if database == "" {
+		database = defaultClickHouseDatabase
+	}
+	table := cfg.Table
+	if table == "" {
+		table = defaultClickHouseTable
+	}
+	maxRetries := cfg.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultClickHouseMaxRetries
+	}
+
+	c := &clickHouseClient{
+		table:      "`" + strings.ReplaceAll(database, "`", "") + "`.`" + strings.ReplaceAll(table, "`", "") + "`",
+		maxRetries: maxRetries,
+		client:     newHTTPClient(cfg.TLS),
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+		u.User = nil
+	}
+	c.url = strings.TrimSuffix(u.String(), "/") + "/"
+
+	wait := "0"
+	if cfg.WaitForAsyncInsert {
+		wait = "1"
+	}
+	c.insertQuery = url.Values{
+		"query":                 {"INSERT INTO " + c.table + " (timestamp, level, subsystem, message, fields) FORMAT JSONEachRow"},
+		"async_insert":          {"1"},
+		"wait_for_async_insert": {wait},
+	}.Encode()
+
+	if cfg.CreateTable {
+		if err := c.exec(`CREATE TABLE IF NOT EXISTS ` + c.table + ` (
+			timestamp DateTime64(9, 'UTC'),
+			level LowCardinality(String),
+			subsystem LowCardinality(String),
+			message String,
+			fields String
+		) ENGINE = MergeTree ORDER BY (subsystem, timestamp)`); err != nil {
+			return nil, fmt.Errorf("failed to create ClickHouse table: %s", err)
+		}
+	}
+
+	return &batchCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          newFieldsEncoder(),
+		batcher:      newBatcher(cfg.BatchSize, cfg.FlushInterval, c.insert),
+	}, nil
+}
+
+type clickHouseClient struct {
+	url         string
+	username    string
+	password    string
+	table       string
+	insertQuery string
+	maxRetries  int
+	client      *http.Client
+}
+
+type clickHouseRow struct {
+	Timestamp string `json:"timestamp"`
+	Level     string `json:"level"`
+	Subsystem string `json:"subsystem"`
+	Message   string `json:"message"`
+	Fields    string `json:"fields"`
+}
+
+func (c *clickHouseClient) insert(batch []batchEntry) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, e := range batch {
+		err := enc.Encode(clickHouseRow{
+			Timestamp: e.ent.Time.UTC().Format(clickHouseTimeLayout),
+			Level:     levelName(e.ent.Level),
+			Subsystem: e.ent.LoggerName,
+			Message:   e.ent.Message,
+			Fields:    string(bytes.TrimRight(e.data, "\n")),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	data, err := gzipBytes(body.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return retryWithBackoff(c.maxRetries, func() (bool, error) {
+		req, err := http.NewRequest(http.MethodPost, c.url+"?"+c.insertQuery, bytes.NewReader(data))
+		if err != nil {
+			return false, err
+		}
+		req.Header.Set("Content-Encoding", "gzip")
+		err = c.do(req)
+		return isRetryableHTTPError(err), err
+	})
+}
+
+// exec runs a statement without result.
+func (c *clickHouseClient) exec(query string) error {
+	req, err := http.NewRequest(http.MethodPost, c.url, strings.NewReader(query))
+	if err != nil {
+		return err
+	}
+	return c.do(req)
+}
+
+func (c *clickHouseClient) do(req *http.Request) error {
+	if c.username != "" {
+		req.Header.Set("X-ClickHouse-User", c.username)
+		req.Header.Set("X-ClickHouse-Key", c.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return checkHTTPResponse(resp)
+}
@@ -0,0 +1,71 @@
+package log
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestClickHouseCore(t *testing.T) {
+	var queries []string
+	var rows []clickHouseRow
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user := r.Header.Get("X-ClickHouse-User"); user != "scooby" {
+			t.Errorf("got user %q", user)
+		}
+		if query := r.URL.Query().Get("query"); query != "" {
+			if r.URL.Query().Get("async_insert") != "1" {
+				t.Error("wanted an asynchronous insert")
+			}
+			queries = append(queries, query)
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec := json.NewDecoder(zr)
+			for dec.More() {
+				var row clickHouseRow
+				if err := dec.Decode(&row); err != nil {
+					t.Fatal(err)
+				}
+				rows = append(rows, row)
+			}
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		queries = append(queries, string(body))
+	}))
+	defer srv.Close()
+
+	core, err := newClickHouseCore(ClickHouseConfig{
+		URL:         strings.Replace(srv.URL, "http://", "http://scooby:doo@", 1),
+		CreateTable: true,
+	}, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*batchCore).Close()
+
+	zap.New(core).Named("dht").Info("where are you", zap.String("user", "shaggy"))
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queries) != 2 || !strings.HasPrefix(queries[0], "CREATE TABLE IF NOT EXISTS `default`.`logs`") ||
+		!strings.HasPrefix(queries[1], "INSERT INTO `default`.`logs`") {
+		t.Errorf("got queries %q", queries)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, wanted 1", len(rows))
+	}
+	row := rows[0]
+	if row.Level != "info" || row.Subsystem != "dht" || row.Message != "where are you" || row.Fields != `{"user":"shaggy"}` {
+		t.Errorf("got row %+v", row)
+	}
+}
@@ -0,0 +1,172 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// CloudLoggingConfig configures writing logs to Google Cloud Logging.
+//
+// Credentials are read from the service account key file given by
+// CredentialsFile or GOOGLE_APPLICATION_CREDENTIALS, and otherwise obtained
+// from the metadata server when running on Google Cloud.
+type CloudLoggingConfig struct {
+	// ProjectID is the project logs are written to. Defaults to the
+	// GOOGLE_CLOUD_PROJECT environment variable, then to the project of the
+	// credentials.
+	ProjectID string
+
+	// LogName is the name of the log. Defaults to the program name.
+	LogName string
+
+	// CredentialsFile is the path to a service account key file.
+	CredentialsFile string
+
+	// ResourceType and ResourceLabels describe the monitored resource
+	// producing the logs. Defaults to the "global" resource.
+	ResourceType   string
+	ResourceLabels map[string]string
+
+	// BatchSize is the maximum number of entries written in one request.
+	// Defaults to 100.
+	BatchSize int
+
+	// FlushInterval is the maximum time an entry waits before being written.
+	// Defaults to one second.
+	FlushInterval time.Duration
+
+	// Endpoint overrides the Cloud Logging API endpoint.
+	Endpoint string
+}
+
+const (
+	cloudLoggingEndpoint   = "https://logging.googleapis.com"
+	cloudLoggingMaxRetries = 3
+)
+
+// newCloudLoggingCore creates a core writing entries to Cloud Logging with
+// the entries.write API.
+func newCloudLoggingCore(cfg CloudLoggingConfig, level LogLevel) (zapcore.Core, error) {
+	ts, err := newGCPTokenSource(cfg.CredentialsFile, gcpLoggingScope)
+	if err != nil {
+		return nil, err
+	}
+
+	project := cfg.ProjectID
+	if project == "" {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	if project == "" {
+		if project, err = ts.projectID(); err != nil {
+			return nil, err
+		}
+	}
+	logName := cfg.LogName
+	if logName == "" {
+		logName = filepath.Base(os.Args[0])
+	}
+	resource := cloudLoggingResource{Type: cfg.ResourceType, Labels: cfg.ResourceLabels}
+	if resource.Type == "" {
+		resource.Type = "global"
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = cloudLoggingEndpoint
+	}
+
+	cl := &cloudLoggingClient{
+		url:      strings.TrimSuffix(endpoint, "/") + "/v2/entries:write",
+		logName:  "projects/" + project + "/logs/" + url.PathEscape(logName),
+		resource: resource,
+		ts:       ts,
+		client:   newHTTPClient(nil),
+	}
+
+	// time and severity are entry metadata, the rest is the JSON payload
+	encCfg := newEncoderConfig()
+	encCfg.TimeKey = ""
+	encCfg.LevelKey = ""
+	encCfg.MessageKey = "message"
+
+	return &batchCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          zapcore.NewJSONEncoder(encCfg),
+		batcher:      newBatcher(cfg.BatchSize, cfg.FlushInterval, cl.write),
+	}, nil
+}
+
+type cloudLoggingClient struct {
+	url      string
+	logName  string
+	resource cloudLoggingResource
+	ts       *gcpTokenSource
+	client   *http.Client
+}
+
+type cloudLoggingResource struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type cloudLoggingEntry struct {
+	Timestamp   string            `json:"timestamp"`
+	Severity    string            `json:"severity"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	JSONPayload json.RawMessage   `json:"jsonPayload"`
+}
+
+func (cl *cloudLoggingClient) write(batch []batchEntry) error {
+	req := struct {
+		LogName  string               `json:"logName"`
+		Resource cloudLoggingResource `json:"resource"`
+		Entries  []cloudLoggingEntry  `json:"entries"`
+	}{
+		LogName:  cl.logName,
+		Resource: cl.resource,
+		Entries:  make([]cloudLoggingEntry, len(batch)),
+	}
+	for i, e := range batch {
+		entry := cloudLoggingEntry{
+			Timestamp:   e.ent.Time.UTC().Format(time.RFC3339Nano),
+			Severity:    gcpSeverity(e.ent.Level),
+			JSONPayload: bytes.TrimRight(e.data, "\n"),
+		}
+		if e.ent.LoggerName != "" {
+			entry.Labels = map[string]string{"subsystem": e.ent.LoggerName}
+		}
+		req.Entries[i] = entry
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	return retryWithBackoff(cloudLoggingMaxRetries, func() (bool, error) {
+		token, err := cl.ts.accessToken()
+		if err != nil {
+			return true, err
+		}
+		httpReq, err := http.NewRequest(http.MethodPost, cl.url, bytes.NewReader(body))
+		if err != nil {
+			return false, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := cl.client.Do(httpReq)
+		if err != nil {
+			return true, err
+		}
+		err = checkHTTPResponse(resp)
+		return isRetryableHTTPError(err), err
+	})
+}
@@ -0,0 +1,143 @@
+package log
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestCloudLoggingCore(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		LogName string
+		Entries []cloudLoggingEntry
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.FormValue("assertion") == "" {
+				t.Errorf("missing JWT assertion")
+			}
+			w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+		case "/v2/entries:write":
+			if auth := r.Header.Get("Authorization"); auth != "Bearer token" {
+				t.Errorf("got authorization %q", auth)
+			}
+			json.NewDecoder(r.Body).Decode(&got)
+			w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	keyFile := filepath.Join(t.TempDir(), "key.json")
+	keyJSON, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"project_id":   "my-project",
+		"client_email": "logger@my-project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    srv.URL + "/token",
+	})
+	if err := ioutil.WriteFile(keyFile, keyJSON, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	core, err := newCloudLoggingCore(CloudLoggingConfig{
+		LogName:         "app",
+		CredentialsFile: keyFile,
+		Endpoint:        srv.URL,
+	}, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*batchCore).Close()
+
+	zap.New(core).Named("dht").Warn("scooby")
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.LogName != "projects/my-project/logs/app" {
+		t.Errorf("got log name %q", got.LogName)
+	}
+	if len(got.Entries) != 1 {
+		t.Fatalf("got %d entries, wanted 1", len(got.Entries))
+	}
+	entry := got.Entries[0]
+	if entry.Severity != "WARNING" || entry.Labels["subsystem"] != "dht" {
+		t.Errorf("got severity %q and labels %v", entry.Severity, entry.Labels)
+	}
+	if !strings.Contains(string(entry.JSONPayload), `"message":"scooby"`) {
+		t.Errorf("got payload %s, wanted it to contain the message", entry.JSONPayload)
+	}
+}
+
+func TestGCPFormat(t *testing.T) {
+	buf := &bytes.Buffer{}
+	core := newCore(FormatGCPOutput, zapcore.AddSync(buf), LevelDebug)
+	zap.New(core).Warn("scooby")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["severity"] != "WARNING" || entry["message"] != "scooby" || entry["timestamp"] == nil {
+		t.Errorf("got %v, wanted Cloud Logging keys", entry)
+	}
+}
+
+func TestGCPFormatLabels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{
+		Level:  LevelInfo,
+		Format: FormatGCPOutput,
+		File:   path,
+		Labels: map[string]string{"app": "example"},
+	})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	getLogger("gcp").Error("scooby")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry struct {
+		Labels         map[string]string `json:"logging.googleapis.com/labels"`
+		SourceLocation struct {
+			File string `json:"file"`
+			Line string `json:"line"`
+		} `json:"logging.googleapis.com/sourceLocation"`
+		App string `json:"app"`
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Labels["app"] != "example" || entry.App != "" {
+		t.Errorf("got %s, wanted the labels in their own object", data)
+	}
+	if !strings.HasSuffix(entry.SourceLocation.File, "cloudlogging_test.go") || entry.SourceLocation.Line == "" {
+		t.Errorf("got %s, wanted the source location of the caller", data)
+	}
+}
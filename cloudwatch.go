@@ -0,0 +1,285 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// CloudWatchConfig configures sending logs to AWS CloudWatch Logs.
+//
+// Credentials are looked up like the AWS SDKs do when not given: from the
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment
+// variables, then from the ECS task role, then from the EC2 instance role.
+type CloudWatchConfig struct {
+	// Region is the AWS region. Defaults to the AWS_REGION environment variable.
+	Region string
+
+	// LogGroup is the log group entries are sent to. It is created if needed.
+	// Defaults to the log group of the Lambda function, if any.
+	LogGroup string
+
+	// LogStream is the log stream entries are sent to. It is created if
+	// needed. Defaults to the log stream of the Lambda function, if any, or
+	// to the host name.
+	LogStream string
+
+	// AccessKeyID, SecretAccessKey and SessionToken are static credentials.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// BatchSize is the maximum number of entries sent in one request.
+	// Defaults to 100.
+	BatchSize int
+
+	// FlushInterval is the maximum time an entry waits before being sent.
+	// Defaults to one second.
+	FlushInterval time.Duration
+
+	// Endpoint overrides the CloudWatch Logs endpoint of the region.
+	Endpoint string
+}
+
+// PutLogEvents limits, see
+// https://docs.aws.amazon.com/AmazonCloudWatchLogs/latest/APIReference/API_PutLogEvents.html
+const (
+	cloudWatchMaxBatchEvents = 10000
+	cloudWatchMaxBatchBytes  = 1048576
+	cloudWatchMaxBatchSpan   = 24 * time.Hour
+	cloudWatchEventOverhead  = 26
+	cloudWatchMaxEventBytes  = 262144 - cloudWatchEventOverhead
+)
+
+// newCloudWatchCore creates a core sending entries to CloudWatch Logs.
+func newCloudWatchCore(cfg CloudWatchConfig, level LogLevel) (zapcore.Core, error) {
+	region := awsRegion(cfg.Region)
+	if region == "" && cfg.Endpoint == "" {
+		return nil, errors.New("missing AWS region")
+	}
+	group := cfg.LogGroup
+	if group == "" {
+		group = os.Getenv("AWS_LAMBDA_LOG_GROUP_NAME")
+	}
+	if group == "" {
+		return nil, errors.New("missing CloudWatch log group")
+	}
+	stream := cfg.LogStream
+	if stream == "" {
+		stream = os.Getenv("AWS_LAMBDA_LOG_STREAM_NAME")
+	}
+	if stream == "" {
+		stream, _ = os.Hostname()
+	}
+	if stream == "" {
+		return nil, errors.New("missing CloudWatch log stream")
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://logs." + region + ".amazonaws.com"
+	}
+
+	cw := &cloudWatchClient{
+		endpoint: strings.TrimSuffix(endpoint, "/") + "/",
+		region:   region,
+		group:    group,
+		stream:   stream,
+		creds:    newAWSCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, cfg.SessionToken),
+		client:   newHTTPClient(nil),
+	}
+
+	// the timestamp is sent next to every message
+	encCfg := newEncoderConfig()
+	encCfg.TimeKey = ""
+
+	return &batchCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          zapcore.NewJSONEncoder(encCfg),
+		batcher:      newBatcher(cfg.BatchSize, cfg.FlushInterval, cw.put),
+	}, nil
+}
+
+type cloudWatchClient struct {
+	endpoint string
+	region   string
+	group    string
+	stream   string
+	creds    *awsCredentialsProvider
+	client   *http.Client
+
+	// only accessed by the batcher flushing, which is serialized
+	created       bool
+	sequenceToken string
+}
+
+type cloudWatchEvent struct {
+	Timestamp int64  `json:"timestamp"`
+	Message   string `json:"message"`
+}
+
+// cloudWatchError is an error returned by the CloudWatch Logs API.
+type cloudWatchError struct {
+	Type                  string `json:"__type"`
+	Message               string `json:"message"`
+	ExpectedSequenceToken string `json:"expectedSequenceToken"`
+}
+
+func (e *cloudWatchError) Error() string {
+	return e.Type + ": " + e.Message
+}
+
+func (cw *cloudWatchClient) put(batch []batchEntry) error {
+	if !cw.created {
+		if err := cw.createStream(); err != nil {
+			return err
+		}
+		cw.created = true
+	}
+
+	events := make([]cloudWatchEvent, len(batch))
+	for i, e := range batch {
+		msg := bytes.TrimRight(e.data, "\n")
+		if len(msg) > cloudWatchMaxEventBytes {
+			msg = msg[:cloudWatchMaxEventBytes]
+		}
+		events[i] = cloudWatchEvent{
+			Timestamp: e.ent.Time.UnixNano() / int64(time.Millisecond),
+			Message:   string(msg),
+		}
+	}
+	// events of a request must be in chronological order
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp < events[j].Timestamp
+	})
+
+	for len(events) > 0 {
+		n, size := 0, 0
+		for ; n < len(events) && n < cloudWatchMaxBatchEvents; n++ {
+			eventSize := len(events[n].Message) + cloudWatchEventOverhead
+			if size+eventSize > cloudWatchMaxBatchBytes ||
+				events[n].Timestamp-events[0].Timestamp >= int64(cloudWatchMaxBatchSpan/time.Millisecond) {
+				break
+			}
+			size += eventSize
+		}
+		if err := cw.putEvents(events[:n]); err != nil {
+			return err
+		}
+		events = events[n:]
+	}
+	return nil
+}
+
+func (cw *cloudWatchClient) putEvents(events []cloudWatchEvent) error {
+	for attempt := 0; ; attempt++ {
+		req := struct {
+			LogGroupName  string            `json:"logGroupName"`
+			LogStreamName string            `json:"logStreamName"`
+			LogEvents     []cloudWatchEvent `json:"logEvents"`
+			SequenceToken string            `json:"sequenceToken,omitempty"`
+		}{cw.group, cw.stream, events, cw.sequenceToken}
+		var resp struct {
+			NextSequenceToken string `json:"nextSequenceToken"`
+		}
+
+		err := cw.call("PutLogEvents", req, &resp)
+		if err == nil {
+			cw.sequenceToken = resp.NextSequenceToken
+			return nil
+		}
+
+		cwErr, ok := err.(*cloudWatchError)
+		if !ok || attempt >= 2 {
+			return err
+		}
+		switch {
+		case strings.HasSuffix(cwErr.Type, "InvalidSequenceTokenException"):
+			cw.sequenceToken = cwErr.ExpectedSequenceToken
+		case strings.HasSuffix(cwErr.Type, "DataAlreadyAcceptedException"):
+			cw.sequenceToken = cwErr.ExpectedSequenceToken
+			return nil
+		case strings.HasSuffix(cwErr.Type, "ResourceNotFoundException"):
+			// the group or stream was deleted since
+			if err := cw.createStream(); err != nil {
+				return err
+			}
+			cw.sequenceToken = ""
+		default:
+			return err
+		}
+	}
+}
+
+// createStream creates the log group and stream unless they already exist.
+func (cw *cloudWatchClient) createStream() error {
+	err := cw.call("CreateLogGroup", map[string]string{"logGroupName": cw.group}, nil)
+	if err != nil && !isCloudWatchAlreadyExists(err) {
+		return err
+	}
+	err = cw.call("CreateLogStream", map[string]string{
+		"logGroupName":  cw.group,
+		"logStreamName": cw.stream,
+	}, nil)
+	if err != nil && !isCloudWatchAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+func isCloudWatchAlreadyExists(err error) bool {
+	cwErr, ok := err.(*cloudWatchError)
+	return ok && strings.HasSuffix(cwErr.Type, "ResourceAlreadyExistsException")
+}
+
+// call invokes action with the JSON encoded request, decoding the response
+// into resp if not nil.
+func (cw *cloudWatchClient) call(action string, req, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	creds, err := cw.creds.credentials()
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, cw.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	httpReq.Header.Set("X-Amz-Target", "Logs_20140328."+action)
+	signAWSRequest(httpReq, body, creds, cw.region, "logs", time.Now())
+
+	httpResp, err := cw.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	data, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		cwErr := &cloudWatchError{}
+		if json.Unmarshal(data, cwErr) != nil || cwErr.Type == "" {
+			return fmt.Errorf("unexpected response %s: %s", httpResp.Status, bytes.TrimSpace(data))
+		}
+		return cwErr
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.Unmarshal(data, resp)
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestCloudWatchCoreSequenceToken(t *testing.T) {
+	var actions []string
+	var events int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			t.Errorf("got unsigned request")
+		}
+		action := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "Logs_20140328.")
+		actions = append(actions, action)
+
+		var req struct {
+			SequenceToken string
+			LogEvents     []cloudWatchEvent
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		switch {
+		case action == "CreateLogGroup":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ResourceAlreadyExistsException","message":"exists"}`))
+		case action == "PutLogEvents" && req.SequenceToken != "token-1":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"InvalidSequenceTokenException","expectedSequenceToken":"token-1"}`))
+		case action == "PutLogEvents":
+			events += len(req.LogEvents)
+			w.Write([]byte(`{"nextSequenceToken":"token-2"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	core, err := newCloudWatchCore(CloudWatchConfig{
+		Region:          "us-east-1",
+		LogGroup:        "group",
+		LogStream:       "stream",
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+		Endpoint:        srv.URL,
+	}, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*batchCore).Close()
+
+	logger := zap.New(core)
+	logger.Info("scooby")
+	logger.Info("velma")
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "CreateLogGroup CreateLogStream PutLogEvents PutLogEvents"
+	if got := strings.Join(actions, " "); got != want {
+		t.Errorf("got actions %q, wanted %q", got, want)
+	}
+	if events != 2 {
+		t.Errorf("got %d events, wanted 2", events)
+	}
+}
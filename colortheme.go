@@ -0,0 +1,194 @@
+package log
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// ColorTheme are the colors of FormatColorizedOutput and of the keys of
+// FormatJSONPrettyOutput. Colors are ANSI SGR parameters, i.e. "31" for red,
+// "1;33" for bold yellow or "38;5;208" for orange, and empty colors leave
+// the text uncolored.
+type ColorTheme struct {
+	// Levels are the colors of the levels, the levels registered with
+	// RegisterLevel defaulting to their color.
+	Levels map[LogLevel]string
+
+	// Time, Logger and Caller are the colors of the times, logger names and
+	// callers of entries.
+	Time   string
+	Logger string
+	Caller string
+
+	// Key is the color of the keys of FormatJSONPrettyOutput.
+	Key string
+
+	// Subsystems is a palette the logger names are colored from, every
+	// subsystem always getting the same color, instead of Logger.
+	Subsystems []string
+}
+
+var (
+	// DefaultColorTheme is the theme of FormatColorizedOutput by default.
+	DefaultColorTheme = &ColorTheme{
+		Levels: map[LogLevel]string{
+			LevelTrace:  "90",
+			LevelDebug:  "35",
+			LevelInfo:   "34",
+			LevelWarn:   "33",
+			LevelError:  "31",
+			LevelDPanic: "31",
+			LevelPanic:  "31",
+			LevelFatal:  "31",
+		},
+		Key: "34",
+	}
+
+	// HighContrastColorTheme uses bold and bright colors.
+	HighContrastColorTheme = &ColorTheme{
+		Levels: map[LogLevel]string{
+			LevelTrace:  "97",
+			LevelDebug:  "1;97",
+			LevelInfo:   "1;96",
+			LevelWarn:   "1;93",
+			LevelError:  "1;91",
+			LevelDPanic: "1;97;41",
+			LevelPanic:  "1;97;41",
+			LevelFatal:  "1;97;41",
+		},
+		Time:       "97",
+		Logger:     "1;97",
+		Caller:     "97",
+		Key:        "1;96",
+		Subsystems: []string{"1;96", "1;93", "1;95", "1;92", "1;94"},
+	}
+
+	// ColorblindColorTheme uses the colors of the Okabe-Ito palette, which
+	// are distinguishable with the common color vision deficiencies, and
+	// makes errors bold rather than red only.
+	ColorblindColorTheme = &ColorTheme{
+		Levels: map[LogLevel]string{
+			LevelTrace:  "38;5;240",
+			LevelDebug:  "38;5;246",
+			LevelInfo:   "38;5;32",
+			LevelWarn:   "38;5;214",
+			LevelError:  "1;38;5;166",
+			LevelDPanic: "1;4;38;5;166",
+			LevelPanic:  "1;4;38;5;166",
+			LevelFatal:  "1;4;38;5;166",
+		},
+		Key:        "38;5;32",
+		Subsystems: []string{"38;5;32", "38;5;214", "38;5;74", "38;5;178", "38;5;169"},
+	}
+)
+
+// colorThemes are the themes by name, as set with GOLOG_COLORS.
+var colorThemes = map[string]*ColorTheme{
+	"default":       DefaultColorTheme,
+	"high-contrast": HighContrastColorTheme,
+	"colorblind":    ColorblindColorTheme,
+}
+
+// parseColorTheme parses a GOLOG_COLORS theme: the name of a preset theme,
+// the default one if omitted, followed by comma-separated colors replacing
+// its colors, i.e. "colorblind,logger=1,subsystems=31:32:33". Colors are
+// set for levels by their names, and for "time", "logger", "caller", "key"
+// and "subsystems".
+func parseColorTheme(s string) (*ColorTheme, error) {
+	theme := *DefaultColorTheme
+	parts := strings.Split(s, ",")
+	if preset, ok := colorThemes[parts[0]]; ok {
+		theme = *preset
+		parts = parts[1:]
+	}
+	levels := make(map[LogLevel]string, len(theme.Levels))
+	for lvl, color := range theme.Levels {
+		levels[lvl] = color
+	}
+	theme.Levels = levels
+
+	for _, part := range parts {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid color %q", part)
+		}
+		switch kv[0] {
+		case "time":
+			theme.Time = kv[1]
+		case "logger":
+			theme.Logger = kv[1]
+		case "caller":
+			theme.Caller = kv[1]
+		case "key":
+			theme.Key = kv[1]
+		case "subsystems":
+			theme.Subsystems = strings.Split(kv[1], ":")
+		default:
+			lvl, err := LevelFromString(kv[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid color %q: %w", part, err)
+			}
+			theme.Levels[lvl] = kv[1]
+		}
+	}
+	return &theme, nil
+}
+
+const ansiReset = "\x1b[0m"
+
+// colorize returns s in color.
+func colorize(color, s string) string {
+	if color == "" {
+		return s
+	}
+	return "\x1b[" + color + "m" + s + ansiReset
+}
+
+// setEncoders sets the encoders of cfg coloring entries with the theme.
+func (t *ColorTheme) setEncoders(cfg *zapcore.EncoderConfig) {
+	cfg.EncodeLevel = func(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+		color, ok := t.Levels[LogLevel(l)]
+		if !ok {
+			color = getRegisteredLevels().colors[LogLevel(l)]
+		}
+		enc.AppendString(colorize(color, capitalLevelName(l)))
+	}
+	if t.Time != "" {
+		encodeTime := cfg.EncodeTime
+		cfg.EncodeTime = func(ts time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			// the time may be encoded as a number
+			m := zapcore.NewMapObjectEncoder()
+			_ = m.AddArray("t", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+				encodeTime(ts, arr)
+				return nil
+			}))
+			for _, v := range m.Fields["t"].([]interface{}) {
+				enc.AppendString(colorize(t.Time, fmt.Sprint(v)))
+			}
+		}
+	}
+	if t.Logger != "" || len(t.Subsystems) > 0 {
+		cfg.EncodeName = func(name string, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendString(colorize(t.loggerColor(name), name))
+		}
+	}
+	if t.Caller != "" {
+		cfg.EncodeCaller = func(caller zapcore.EntryCaller, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendString(colorize(t.Caller, callerString(caller)))
+		}
+	}
+}
+
+// loggerColor returns the color of the logger named name.
+func (t *ColorTheme) loggerColor(name string) string {
+	if len(t.Subsystems) == 0 {
+		return t.Logger
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return t.Subsystems[h.Sum32()%uint32(len(t.Subsystems))]
+}
@@ -0,0 +1,96 @@
+package log
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParseColorTheme(t *testing.T) {
+	theme, err := parseColorTheme("colorblind,error=1;31,logger=36,subsystems=32:33")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if theme.Levels[LevelError] != "1;31" || theme.Levels[LevelWarn] != ColorblindColorTheme.Levels[LevelWarn] {
+		t.Errorf("got levels %v, wanted the colorblind colors with error replaced", theme.Levels)
+	}
+	if ColorblindColorTheme.Levels[LevelError] == "1;31" {
+		t.Error("wanted the preset to be left unchanged")
+	}
+	if theme.Logger != "36" || len(theme.Subsystems) != 2 || theme.Key != ColorblindColorTheme.Key {
+		t.Errorf("got %+v, wanted the logger and subsystems colors replaced", theme)
+	}
+
+	if theme, err := parseColorTheme("info=32"); err != nil || theme.Levels[LevelDebug] != "35" {
+		t.Errorf("got %v %v, wanted the default theme", theme, err)
+	}
+	for _, s := range []string{"default,loud=1", "default,error"} {
+		if _, err := parseColorTheme(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestColorTheme(t *testing.T) {
+	ent := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		LoggerName: "dht",
+		Message:    "scooby",
+	}
+
+	var cfg zapcore.EncoderConfig
+	DefaultColorTheme.setEncoders(&cfg)
+	arr := zapcore.NewMapObjectEncoder()
+	_ = arr.AddArray("level", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		cfg.EncodeLevel(ent.Level, enc)
+		zapcore.CapitalColorLevelEncoder(ent.Level, enc)
+		return nil
+	}))
+	if levels := arr.Fields["level"].([]interface{}); levels[0] != levels[1] {
+		t.Errorf("got %q, wanted the level colored like zap", levels[0])
+	}
+
+	SetupLogging(Config{
+		Format: FormatColorizedOutput,
+		Stderr: true,
+		Level:  LevelError,
+		ColorTheme: &ColorTheme{
+			Levels:     map[LogLevel]string{LevelWarn: "1"},
+			Time:       "2",
+			Subsystems: []string{"36"},
+		},
+	})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+	buf, err := newEncoder(FormatColorizedOutput).EncodeEntry(ent, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\x1b[2m2024-05-01T00:00:00.000Z\x1b[0m\t\x1b[1mWARN\x1b[0m\t\x1b[36mdht\x1b[0m\tscooby\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+}
+
+func TestNoColor(t *testing.T) {
+	// stderr is not a TTY in tests
+	os.Setenv(envForceColor, "1")
+	cfg := configFromEnv()
+	if cfg.Format != FormatColorizedOutput || cfg.ColorTheme != nil {
+		t.Errorf("got format %d, wanted colors to be forced", cfg.Format)
+	}
+
+	os.Setenv(envNoColor, "1")
+	if cfg := configFromEnv(); cfg.Format != FormatColorizedOutput {
+		t.Errorf("got format %d, wanted FORCE_COLOR to override NO_COLOR", cfg.Format)
+	}
+
+	os.Unsetenv(envForceColor)
+	defer os.Unsetenv(envNoColor)
+	cfg = configFromEnv()
+	if cfg.Format != FormatPlaintextOutput || cfg.ColorTheme == nil || len(cfg.ColorTheme.Levels) != 0 || cfg.ColorTheme.Key != "" {
+		t.Errorf("got format %d and theme %+v, wanted no colors", cfg.Format, cfg.ColorTheme)
+	}
+}
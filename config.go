@@ -1,27 +1,267 @@
 package log
 
+import "time"
+
 type Config struct {
 	// Format overrides the format of the log output. Defaults to ColorizedOutput
 	Format LogFormat
 
+	// TimeFormat is the encoding of the times of the formats which don't
+	// mandate one: "iso8601", "rfc3339", "rfc3339nano", "epoch" for seconds
+	// since the epoch, "epoch_millis", "epoch_nanos", or a Go time layout.
+	// Defaults to iso8601, and to the native times of binary formats.
+	TimeFormat string
+
+	// JSONKeys renames the keys of the entries of FormatJSONOutput, and of the
+	// formats using them: "ts", "level", "logger", "caller", "msg" and
+	// "stacktrace". Keys renamed to "" are omitted.
+	JSONKeys map[string]string
+
+	// Caller is the format of the callers of entries: CallerShort,
+	// CallerFull, CallerRelative, CallerFunction or CallerNone. Defaults to
+	// CallerShort, and to CallerFull for FormatGCPOutput and FormatECSOutput.
+	Caller string
+
+	// SubsystemCallers are the caller formats per-subsystem, which apply to
+	// the loggers named after them too. When unspecified, defaults to Caller.
+	SubsystemCallers map[string]string
+
+	// Multiline is how FormatColorizedOutput and FormatPlaintextOutput write
+	// the line breaks and control characters of messages: MultilineEscape,
+	// MultilineIndent or MultilineRaw. Defaults to MultilineEscape, so that
+	// messages cannot forge entries.
+	Multiline string
+
+	// ErrorDetails writes the error fields, i.e. the errors passed with
+	// WithError or zap.Error, as objects of their message, type, the causes
+	// they wrap and their stack trace for the errors printing one with %+v,
+	// like the errors of github.com/pkg/errors, rather than as their
+	// message.
+	ErrorDetails bool
+
+	// ColorTheme is the color theme of FormatColorizedOutput and
+	// FormatJSONPrettyOutput. Defaults to DefaultColorTheme.
+	ColorTheme *ColorTheme
+
 	// Level is the default minimum enabled logging level.
 	Level LogLevel
 
+	// StacktraceLevel is the level from which stack traces are attached to
+	// entries, or "never". Defaults to never. See WithStacktrace for single
+	// loggers.
+	StacktraceLevel string
+
+	// FatalBehavior is what the Fatal methods of the loggers do once the
+	// entry is logged and the OnFatal hooks are called: FatalExit, FatalPanic
+	// or FatalLog. Defaults to FatalExit.
+	FatalBehavior string
+
 	// SubsystemLevels are the default levels per-subsystem. When unspecified, defaults to Level.
 	SubsystemLevels map[string]LogLevel
 
+	// SubsystemOutputs are the outputs per-subsystem, "none" discarding
+	// their entries and "default" writing them to the configured outputs.
+	// When unspecified, defaults to "default".
+	SubsystemOutputs map[string]string
+
+	// SubsystemSampling throttles the entries of chatty subsystems, which are
+	// not sampled when unspecified.
+	SubsystemSampling map[string]SamplingConfig
+
+	// SubsystemRateLimits limits the rate of the entries of subsystems, which
+	// are not limited when unspecified.
+	SubsystemRateLimits map[string]RateLimitConfig
+
+	// Deduplicate collapses the identical consecutive entries of a subsystem,
+	// with the same level and message, logged within this window into a
+	// "last message repeated N times" entry, logged when another entry
+	// follows or at the end of the window. Entries at LevelDPanic and above
+	// are never collapsed. Disabled when 0.
+	Deduplicate time.Duration
+
 	// Stderr indicates whether logs should be written to stderr.
 	Stderr bool
 
 	// Stdout indicates whether logs should be written to stdout.
 	Stdout bool
 
+	// Split indicates whether logs at Info and below should be written to
+	// stdout, and logs at Warn and above to stderr. It is meant to be used
+	// instead of Stderr and Stdout.
+	Split bool
+
 	// File is a path to a file that logs will be written to.
 	File string
 
-	// URL with schema supported by zap. Use zap.RegisterSink
+	// Rotation configures the rotation of File.
+	Rotation RotationConfig
+
+	// FileLock indicates whether writes to File should hold an exclusive
+	// advisory lock on it, so that processes sharing File don't interleave
+	// partial lines. When rotating, the process writing past the limit
+	// rotates File and the others follow it.
+	FileLock bool
+
+	// MinFreeDiskSpace is the free space in bytes of the filesystem of File
+	// below which only Warn logs and above are written to File, a warning
+	// being printed to stderr. Disabled when 0.
+	MinFreeDiskSpace int64
+
+	// ReopenOnSIGHUP indicates whether File and the files of SubsystemFiles
+	// should be reopened on SIGHUP, for external rotation with logrotate.
+	// See ReopenFiles.
+	ReopenOnSIGHUP bool
+
+	// ReloadOnSIGHUP indicates whether the logging should be set up again on
+	// SIGHUP from the environment variables and the config file, like
+	// daemons reload their configuration, the files being reopened too. See
+	// ReloadConfig.
+	ReloadOnSIGHUP bool
+
+	// DebugOnSIGUSR1 indicates whether all subsystems should be set to
+	// LevelDebug on SIGUSR1, and restored to their levels on SIGUSR2, to
+	// debug live processes temporarily. See DebugAll.
+	DebugOnSIGUSR1 bool
+
+	// LevelOutputs routes level ranges to outputs, in addition to the other
+	// outputs. Every range starts at its level and ends before the next
+	// level of the map, i.e. {LevelDebug: {"debug.log"}, LevelError:
+	// {"stderr", url}} writes Debug to Warn logs to debug.log, and Error logs
+	// and above to stderr and url. Outputs are zap paths like File and URL,
+	// or stdout and stderr.
+	LevelOutputs map[LogLevel][]string
+
+	// SubsystemFiles maps subsystems to paths of files that only their logs
+	// are written to, in addition to the other outputs.
+	SubsystemFiles map[string]string
+
+	// SubsystemRotations configures the rotation of the files of
+	// SubsystemFiles per-subsystem, independently of Rotation. The files of
+	// subsystems without rotation configured are not rotated.
+	SubsystemRotations map[string]RotationConfig
+
+	// AuditFile is the path of the file the events of Audit are written to,
+	// in addition to the other outputs, with a fixed schema. Audit events
+	// are not written to another file when empty.
+	AuditFile string
+
+	// AuditRotation configures the rotation of AuditFile, independently of
+	// Rotation. AuditFile is not rotated when unspecified.
+	AuditRotation RotationConfig
+
+	// URL with schema supported by zap. Use RegisterSink to add schemes.
+	//
+	// The following schemes are supported natively:
+	//  - tcp://host:port?buffer=1000 streams entries over a persistent
+	//    connection, buffering up to buffer entries while reconnecting.
+	//  - udp://host:port?max_size=1400 sends every entry as a datagram,
+	//    truncated to max_size bytes.
+	//  - unix:///path/to/socket and unixgram:///path/to/socket write to stream
+	//    and datagram unix domain sockets.
+	//  - fluent://host:port?tag=prefix&ack=true sends entries to Fluentd
+	//    using the forward protocol.
+	//  - logstash://host:port?buffer=1000 streams JSON lines to the tcp input
+	//    of Logstash with the json_lines codec, reconnecting like tcp://.
+	//  - splunk://token@host:port?index=main&sourcetype=app sends entries to
+	//    a Splunk HTTP Event Collector, splunk+http:// without TLS.
 	URL string
 
+	// Journald indicates whether logs should be sent to the systemd journal,
+	// with structured fields as journal fields.
+	Journald bool
+
+	// Logcat indicates whether logs should be sent to the Android log, with
+	// the subsystem as the tag. It defaults to true on Android builds with cgo.
+	Logcat bool
+
+	// Syslog enables logging to a syslog daemon when not nil.
+	Syslog *SyslogConfig
+
+	// Loki enables pushing logs to Grafana Loki when not nil.
+	Loki *LokiConfig
+
+	// Elasticsearch enables indexing logs into Elasticsearch when not nil.
+	Elasticsearch *ElasticsearchConfig
+
+	// Kafka enables publishing logs to a Kafka topic when not nil.
+	Kafka *KafkaConfig
+
+	// Splunk enables sending logs to a Splunk HTTP Event Collector when not nil.
+	Splunk *SplunkConfig
+
+	// CloudWatch enables sending logs to AWS CloudWatch Logs when not nil.
+	CloudWatch *CloudWatchConfig
+
+	// CloudLogging enables writing logs to Google Cloud Logging when not nil.
+	CloudLogging *CloudLoggingConfig
+
+	// AzureMonitor enables sending logs to an Azure Monitor Log Analytics
+	// workspace when not nil.
+	AzureMonitor *AzureMonitorConfig
+
+	// Datadog enables sending logs to the Datadog HTTP intake when not nil.
+	Datadog *DatadogConfig
+
+	// OTLP enables exporting logs to an OpenTelemetry collector when not nil.
+	OTLP *OTLPConfig
+
+	// Sentry enables reporting errors to Sentry when not nil.
+	Sentry *SentryConfig
+
+	// PagerDuty enables triggering PagerDuty incidents on DPanic, Panic and
+	// Fatal entries when not nil. Repeated crashes of a subsystem with the
+	// same message are grouped into one incident.
+	PagerDuty *PagerDutyConfig
+
+	// Opsgenie enables creating Opsgenie alerts on DPanic, Panic and Fatal
+	// entries when not nil, grouped like PagerDuty incidents.
+	Opsgenie *OpsgenieConfig
+
+	// Email enables sending digests of errors via SMTP when not nil.
+	Email *EmailConfig
+
+	// Webhook enables posting alerts for errors to a chat webhook when not
+	// nil.
+	Webhook *WebhookConfig
+
+	// HTTP enables posting batches of JSON entries to an HTTP endpoint when
+	// not nil.
+	HTTP *HTTPConfig
+
+	// NATS enables publishing logs to NATS when not nil.
+	NATS *NATSConfig
+
+	// MQTT enables publishing logs to an MQTT broker when not nil.
+	MQTT *MQTTConfig
+
+	// Redis enables adding logs to a Redis stream when not nil.
+	Redis *RedisConfig
+
+	// ZeroMQ enables publishing logs on a ZeroMQ PUB socket when not nil.
+	ZeroMQ *ZeroMQConfig
+
+	// SQLite enables writing logs to a SQLite database when not nil.
+	SQLite *SQLiteConfig
+
+	// ClickHouse enables inserting logs into ClickHouse when not nil.
+	ClickHouse *ClickHouseConfig
+
+	// S3 enables uploading chunks of logs to S3 when not nil.
+	S3 *S3Config
+
+	// RecentEntries is the number of last entries kept in memory for
+	// DumpRecent, at all levels regardless of the levels of the loggers.
+	// Disabled when 0.
+	RecentEntries int
+
 	// Labels is a set of key-values to apply to all loggers
 	Labels map[string]string
+
+	// Hostname, PID and Executable indicate whether the "hostname", "pid"
+	// and "executable" fields, with the name of the host, the ID of the
+	// process and the name of its executable, should be added to all
+	// entries like Labels.
+	Hostname   bool
+	PID        bool
+	Executable bool
 }
@@ -0,0 +1,298 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the configuration of a config file, see
+// SetupLoggingFromFile.
+type fileConfig struct {
+	Level            string              `json:"level"`
+	SubsystemLevels  map[string]string   `json:"subsystem_levels"`
+	Format           string              `json:"format"`
+	StacktraceLevel  string              `json:"stacktrace_level"`
+	Stderr           *bool               `json:"stderr"`
+	Stdout           *bool               `json:"stdout"`
+	File             string              `json:"file"`
+	URL              string              `json:"url"`
+	LevelOutputs     map[string][]string `json:"level_outputs"`
+	SubsystemFiles   map[string]string   `json:"subsystem_files"`
+	SubsystemOutputs map[string]string   `json:"subsystem_outputs"`
+	Labels           map[string]string   `json:"labels"`
+	Rotation         *fileRotationConfig `json:"rotation"`
+	Sampling         map[string]string   `json:"sampling"`
+	RateLimits       map[string]string   `json:"rate_limits"`
+	Deduplicate      string              `json:"deduplicate"`
+}
+
+// fileRotationConfig is the rotation of a config file, written like the
+// environment variables.
+type fileRotationConfig struct {
+	MaxSize      string `json:"max_size"`
+	Interval     string `json:"interval"`
+	MaxBackups   int    `json:"max_backups"`
+	MaxAge       string `json:"max_age"`
+	MaxTotalSize string `json:"max_total_size"`
+	Compress     bool   `json:"compress"`
+	NameTemplate string `json:"name_template"`
+	TimeFormat   string `json:"time_format"`
+	Symlink      string `json:"symlink"`
+}
+
+// SetupLoggingFromFile sets up the logging like SetupLogging with the
+// configuration of the environment variables, overridden by the config file
+// at path. The file is YAML, JSON or TOML, according to its extension, i.e.
+// in YAML:
+//
+//	level: info
+//	subsystem_levels: {dht: debug}
+//	format: json
+//	file: /var/log/app.log
+//	labels: {app: example}
+//	rotation: {max_size: 100M, max_backups: 5, compress: true}
+//	sampling: {dht: "100:10"}
+//
+// The keys are the lower-case names of the fields of Config, and their values
+// are written like the environment variables. GOLOG_CONFIG sets up the
+// logging from a config file on startup.
+func SetupLoggingFromFile(path string) error {
+	cfg, err := configFromFile(configFromEnv(), path)
+	if err != nil {
+		return err
+	}
+
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+
+	configFilePath = path
+	setupLogging(cfg)
+	return nil
+}
+
+// configFromFile returns cfg overridden by the config file at path.
+func configFromFile(cfg Config, path string) (Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	return configFromFileData(cfg, path, data)
+}
+
+// configFromFileData returns cfg overridden by data, the content of the
+// config file at path.
+func configFromFileData(cfg Config, path string, data []byte) (Config, error) {
+	var raw interface{}
+	var err error
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		raw = json.RawMessage(data)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		var table map[string]interface{}
+		err = toml.Unmarshal(data, &table)
+		raw = table
+	default:
+		return cfg, fmt.Errorf("unknown config file extension %q, expected .yaml, .json or .toml", ext)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if data, err = json.Marshal(raw); err != nil {
+		return cfg, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	var fc fileConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fc); err != nil {
+		return cfg, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if err := fc.apply(&cfg); err != nil {
+		return cfg, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// apply overrides cfg with the settings of the file, replacing rather than
+// modifying its maps.
+func (fc *fileConfig) apply(cfg *Config) error {
+	if fc.Level != "" {
+		lvl, err := LevelFromString(fc.Level)
+		if err != nil {
+			return err
+		}
+		cfg.Level = lvl
+	}
+	if len(fc.SubsystemLevels) > 0 {
+		levels := make(map[string]LogLevel, len(cfg.SubsystemLevels)+len(fc.SubsystemLevels))
+		for name, lvl := range cfg.SubsystemLevels {
+			levels[name] = lvl
+		}
+		for name, level := range fc.SubsystemLevels {
+			lvl, err := LevelFromString(level)
+			if err != nil {
+				return fmt.Errorf("level of %s: %w", name, err)
+			}
+			levels[name] = lvl
+		}
+		cfg.SubsystemLevels = levels
+	}
+	if fc.Format != "" {
+		format, ok := formatFromString(fc.Format)
+		if !ok {
+			return fmt.Errorf("unknown log format %q", fc.Format)
+		}
+		cfg.Format = format
+		// the format of the environment is not waited for anymore
+		loggerMutex.Lock()
+		pendingFormatName = ""
+		loggerMutex.Unlock()
+	}
+	if fc.StacktraceLevel != "" {
+		cfg.StacktraceLevel = fc.StacktraceLevel
+	}
+
+	if fc.File != "" {
+		cfg.File = fc.File
+		// like GOLOG_FILE, the file replaces stderr unless set
+		cfg.Stderr = false
+	}
+	if fc.Stderr != nil {
+		cfg.Stderr = *fc.Stderr
+	}
+	if fc.Stdout != nil {
+		cfg.Stdout = *fc.Stdout
+	}
+	if fc.URL != "" {
+		cfg.URL = fc.URL
+	}
+	if len(fc.LevelOutputs) > 0 {
+		cfg.LevelOutputs = make(map[LogLevel][]string, len(fc.LevelOutputs))
+		for level, outputs := range fc.LevelOutputs {
+			lvl, err := LevelFromString(level)
+			if err != nil {
+				return fmt.Errorf("level outputs: %w", err)
+			}
+			cfg.LevelOutputs[lvl] = outputs
+		}
+	}
+	cfg.SubsystemFiles = mergeStrings(cfg.SubsystemFiles, fc.SubsystemFiles)
+	cfg.SubsystemOutputs = mergeStrings(cfg.SubsystemOutputs, fc.SubsystemOutputs)
+	cfg.Labels = mergeStrings(cfg.Labels, fc.Labels)
+
+	if r := fc.Rotation; r != nil {
+		var err error
+		if r.MaxSize != "" {
+			if cfg.Rotation.MaxSize, err = parseByteSize(r.MaxSize); err != nil {
+				return err
+			}
+		}
+		if r.Interval != "" {
+			if cfg.Rotation.Interval, err = parseRotationInterval(r.Interval); err != nil {
+				return err
+			}
+		}
+		if r.MaxAge != "" {
+			if cfg.Rotation.MaxAge, err = parseMaxAge(r.MaxAge); err != nil {
+				return err
+			}
+		}
+		if r.MaxTotalSize != "" {
+			if cfg.Rotation.MaxTotalSize, err = parseByteSize(r.MaxTotalSize); err != nil {
+				return err
+			}
+		}
+		if r.MaxBackups != 0 {
+			cfg.Rotation.MaxBackups = r.MaxBackups
+		}
+		if r.Compress {
+			cfg.Rotation.Compress = true
+		}
+		if r.NameTemplate != "" {
+			cfg.Rotation.NameTemplate = r.NameTemplate
+		}
+		if r.TimeFormat != "" {
+			cfg.Rotation.TimeFormat = r.TimeFormat
+		}
+		if r.Symlink != "" {
+			cfg.Rotation.Symlink = r.Symlink
+		}
+	}
+
+	if len(fc.Sampling) > 0 {
+		sampling := make(map[string]SamplingConfig, len(cfg.SubsystemSampling)+len(fc.Sampling))
+		for name, s := range cfg.SubsystemSampling {
+			sampling[name] = s
+		}
+		for name, s := range fc.Sampling {
+			var err error
+			if sampling[name], err = parseSampling(s); err != nil {
+				return fmt.Errorf("sampling of %s: %w", name, err)
+			}
+		}
+		cfg.SubsystemSampling = sampling
+	}
+	if len(fc.RateLimits) > 0 {
+		limits := make(map[string]RateLimitConfig, len(cfg.SubsystemRateLimits)+len(fc.RateLimits))
+		for name, l := range cfg.SubsystemRateLimits {
+			limits[name] = l
+		}
+		for name, l := range fc.RateLimits {
+			var err error
+			if limits[name], err = parseRateLimit(l); err != nil {
+				return fmt.Errorf("rate limit of %s: %w", name, err)
+			}
+		}
+		cfg.SubsystemRateLimits = limits
+	}
+	if fc.Deduplicate != "" {
+		window, err := time.ParseDuration(fc.Deduplicate)
+		if err != nil {
+			return fmt.Errorf("deduplication window: %w", err)
+		}
+		cfg.Deduplicate = window
+	}
+	return nil
+}
+
+// mergeStrings returns a copy of dst with the entries of src, or dst when
+// src is empty.
+func mergeStrings(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	merged := make(map[string]string, len(dst)+len(src))
+	for k, v := range dst {
+		merged[k] = v
+	}
+	for k, v := range src {
+		merged[k] = v
+	}
+	return merged
+}
+
+// configFromEnvAndFile returns the configuration of the environment
+// variables, overridden by the config file of GOLOG_CONFIG if set.
+func configFromEnvAndFile() Config {
+	cfg := configFromEnv()
+	path := os.Getenv(envLoggingConfig)
+	if path == "" {
+		return cfg
+	}
+	fileCfg, err := configFromFile(cfg, path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ignoring config file: %s\n", err)
+		return cfg
+	}
+	return fileCfg
+}
@@ -0,0 +1,109 @@
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var testConfigFiles = map[string]string{
+	"log.yaml": `
+level: info
+subsystem_levels: {dht: debug}
+format: json
+stderr: false
+labels: {app: example}
+rotation: {max_size: 100M, max_backups: 5}
+sampling: {chatty: "100:10"}
+`,
+	"log.json": `{
+	"level": "info",
+	"subsystem_levels": {"dht": "debug"},
+	"format": "json",
+	"stderr": false,
+	"labels": {"app": "example"},
+	"rotation": {"max_size": "100M", "max_backups": 5},
+	"sampling": {"chatty": "100:10"}
+}`,
+	"log.toml": `
+level = "info" # the default level
+format = "json"
+stderr = false
+subsystem_levels = { dht = "debug" }
+labels.app = "example"
+sampling = { chatty = "100:10" }
+
+[rotation]
+max_size = "100M"
+max_backups = 5
+`,
+}
+
+func TestConfigFromFile(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range testConfigFiles {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := configFromFile(Config{Stderr: true, Level: LevelError, SubsystemLevels: map[string]LogLevel{"bitswap": LevelWarn}}, path)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		want := Config{
+			Level:             LevelInfo,
+			Format:            FormatJSONOutput,
+			SubsystemLevels:   map[string]LogLevel{"dht": LevelDebug, "bitswap": LevelWarn},
+			Labels:            map[string]string{"app": "example"},
+			Rotation:          RotationConfig{MaxSize: 100 << 20, MaxBackups: 5},
+			SubsystemSampling: map[string]SamplingConfig{"chatty": {Initial: 100, Thereafter: 10}},
+		}
+		if !reflect.DeepEqual(cfg, want) {
+			t.Errorf("%s: got %+v, wanted %+v", name, cfg, want)
+		}
+	}
+
+	path := filepath.Join(dir, "invalid.yaml")
+	if err := ioutil.WriteFile(path, []byte("levl: info\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := configFromFile(Config{}, path); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
+
+func TestSetupLoggingFromFile(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	cfgPath := filepath.Join(dir, "log.json")
+	data, _ := json.Marshal(map[string]interface{}{"level": "warn", "format": "json", "file": logPath})
+	if err := ioutil.WriteFile(cfgPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetupLoggingFromFile(cfgPath); err != nil {
+		t.Fatal(err)
+	}
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	log := Logger("fromfile")
+	log.Info("filtered")
+	log.Warn("scooby")
+
+	data, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["msg"] != "scooby" {
+		t.Errorf("got %s, wanted the warning", data)
+	}
+
+	if err := SetupLoggingFromFile(filepath.Join(dir, "log.ini")); err == nil {
+		t.Error("expected an error for an unknown extension")
+	}
+}
@@ -0,0 +1,150 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// WatchConfigFile polls the config file at path every interval and sets up
+// the logging again like SetupLoggingFromFile when it changes, so that the
+// levels, the format and the outputs can be changed without restarting. The
+// loggers are kept, their levels and the primary core being replaced. The
+// changes are applied once the file is unchanged for an interval, so that it
+// is not read while being written. Invalid changes are reported to stderr and
+// ignored, like empty files. The levels set with SetLogLevel are reset by the
+// changes.
+//
+// GOLOG_CONFIG_WATCH sets the interval the config file of GOLOG_CONFIG is
+// polled at on startup.
+//
+// stop stops watching the file. The interval must be positive.
+func WatchConfigFile(path string, interval time.Duration) (stop func(), err error) {
+	return watchConfigFile(path, interval, nil)
+}
+
+// errEmptyConfigFile is the error of a config file found empty, which is
+// ignored as it is likely being written.
+var errEmptyConfigFile = errors.New("empty config file")
+
+// watchConfigFile is WatchConfigFile, calling polled if not nil once every
+// change to the file is applied, with a nil error, or ignored.
+func watchConfigFile(path string, interval time.Duration, polled func(error)) (stop func(), err error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid config file polling interval %s", interval)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &configWatcher{
+		path:    path,
+		data:    data,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		polled:  polled,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.run(interval)
+
+	return func() {
+		select {
+		case <-w.stop:
+		default:
+			close(w.stop)
+		}
+		<-w.done
+	}, nil
+}
+
+// configWatcher polls a config file.
+type configWatcher struct {
+	path string
+
+	// data is the content of the file when last applied.
+	data []byte
+	// modTime and size are the modification time and the size of the file
+	// when last polled, changed when the file changed since applied.
+	modTime time.Time
+	size    int64
+	changed bool
+
+	polled func(error)
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (w *configWatcher) run(interval time.Duration) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.poll()
+		}
+	}
+}
+
+// poll sets up the logging again when the file changed, once it is the same
+// for two polls in a row so that it is not read while being written.
+func (w *configWatcher) poll() {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		// the file may be replaced rather than written, keep the current
+		// configuration until it is back
+		return
+	}
+	if !info.ModTime().Equal(w.modTime) || info.Size() != w.size {
+		w.modTime, w.size = info.ModTime(), info.Size()
+		w.changed = true
+		return
+	}
+	if !w.changed {
+		return
+	}
+	w.changed = false
+
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		return
+	}
+	if bytes.Equal(data, w.data) {
+		return
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		// an empty document would reset the configuration to the
+		// environment variables
+		w.notify(errEmptyConfigFile)
+		return
+	}
+	w.data = data
+
+	cfg, err := configFromFileData(configFromEnv(), w.path, data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to reload config file, keeping the current configuration: %s\n", err)
+		w.notify(err)
+		return
+	}
+	SetupLogging(cfg)
+	w.notify(nil)
+}
+
+func (w *configWatcher) notify(err error) {
+	if w.polled != nil {
+		w.polled(err)
+	}
+}
@@ -0,0 +1,97 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.yaml")
+	if err := ioutil.WriteFile(path, []byte("level: error\nstderr: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetupLoggingFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	polled := make(chan error, 1)
+	stop, err := watchConfigFile(path, 10*time.Millisecond, func(err error) { polled <- err })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	log := Logger("watched")
+	if log.Enabled(LevelInfo) {
+		t.Fatal("expected info entries to be disabled")
+	}
+
+	// reload writes the file and waits until the change is applied or
+	// ignored
+	modTime := time.Now()
+	reload := func(data string) error {
+		if err := ioutil.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// the modification time may not change within the resolution of
+		// the filesystem
+		modTime = modTime.Add(time.Minute)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case err := <-polled:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the change to be polled")
+			return nil
+		}
+	}
+
+	if err := reload("level: error\nsubsystem_levels: {watched: info}\nstderr: true\n"); err != nil {
+		t.Fatal(err)
+	}
+	if !log.Enabled(LevelInfo) {
+		t.Fatal("expected the level of the subsystem to be reloaded")
+	}
+
+	// invalid changes are ignored, like empty files
+	if err := reload("level: loud\n"); err == nil {
+		t.Error("expected the invalid change to be ignored")
+	}
+	if err := reload(""); err != errEmptyConfigFile {
+		t.Errorf("got %v, wanted the empty file to be ignored", err)
+	}
+	if !log.Enabled(LevelInfo) {
+		t.Error("expected the configuration to be kept")
+	}
+
+	stop()
+	if err := ioutil.WriteFile(path, []byte("level: error\nstderr: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-polled:
+		t.Errorf("got %v, wanted the file not to be watched once stopped", err)
+	default:
+	}
+	if !log.Enabled(LevelInfo) {
+		t.Error("expected the file not to be watched once stopped")
+	}
+}
+
+func TestWatchConfigFileInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.yaml")
+	if err := ioutil.WriteFile(path, []byte("level: error\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if _, err := WatchConfigFile(path, interval); err == nil {
+			t.Errorf("expected an error for the interval %s", interval)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// The multiline modes of Config.Multiline.
+const (
+	// MultilineEscape escapes line breaks and the other control characters
+	// of messages, i.e. "\n" and "\x1b", so that every entry is one line.
+	MultilineEscape = "escape"
+	// MultilineIndent keeps the line breaks of messages, indenting the
+	// lines following them, and escapes the other control characters.
+	MultilineIndent = "indent"
+	// MultilineRaw writes messages as they are.
+	MultilineRaw = "raw"
+)
+
+// multilineIndent is the indentation of the continuation lines of messages
+// with MultilineIndent.
+const multilineIndent = "    "
+
+// consoleEncoder writes the messages of entries in their multiline mode, and
+// the values of ByteSize fields in binary units, for the console formats.
+type consoleEncoder struct {
+	zapcore.Encoder
+	multiline string
+}
+
+// multilineOf returns the multiline mode of a Config.Multiline.
+func multilineOf(mode string) string {
+	switch mode {
+	case "":
+		return MultilineEscape
+	case MultilineEscape, MultilineIndent, MultilineRaw:
+		return mode
+	default:
+		fmt.Fprintf(os.Stderr, "unrecognized multiline mode '%s', escaping line breaks\n", mode)
+		return MultilineEscape
+	}
+}
+
+func (e *consoleEncoder) Clone() zapcore.Encoder {
+	return &consoleEncoder{e.Encoder.Clone(), e.multiline}
+}
+
+func (e *consoleEncoder) AddReflected(key string, value interface{}) error {
+	if n, ok := value.(ByteSize); ok {
+		e.Encoder.AddString(key, formatByteSize(n))
+		return nil
+	}
+	return e.Encoder.AddReflected(key, value)
+}
+
+func (e *consoleEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	if e.multiline != MultilineRaw {
+		ent.Message = escapeMessage(ent.Message, e.multiline == MultilineIndent)
+	}
+
+	// the fields are the logger's
+	copied := false
+	for i, f := range fields {
+		if n, ok := f.Interface.(ByteSize); ok && f.Type == zapcore.ReflectType {
+			if !copied {
+				fields = append([]zapcore.Field(nil), fields...)
+				copied = true
+			}
+			fields[i] = zap.String(f.Key, formatByteSize(n))
+		}
+	}
+	return e.Encoder.EncodeEntry(ent, fields)
+}
+
+// escapeMessage escapes the control characters of msg but tabs, and its line
+// breaks unless indent, indenting the lines following them then.
+func escapeMessage(msg string, indent bool) string {
+	if strings.IndexFunc(msg, isEscaped) < 0 {
+		return msg
+	}
+
+	var b strings.Builder
+	for _, r := range msg {
+		switch {
+		case r == '\n' && indent:
+			b.WriteString("\n" + multilineIndent)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case isEscaped(r):
+			// like strconv.Quote
+			b.WriteString(strings.Trim(strconv.QuoteRune(r), "'"))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isEscaped returns whether r is a control character escaped in messages,
+// which are the C0 and C1 controls but tabs.
+func isEscaped(r rune) bool {
+	return (r < 0x20 && r != '\t') || (r >= 0x7f && r <= 0x9f)
+}
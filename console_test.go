@@ -0,0 +1,47 @@
+package log
+
+import (
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestEscapeMessage(t *testing.T) {
+	for _, c := range []struct {
+		msg    string
+		indent bool
+		want   string
+	}{
+		{"scooby\tdoo", false, "scooby\tdoo"},
+		{"scooby\n2024-05-01T00:00:00.000Z\tERROR\tforged", false, `scooby\n2024-05-01T00:00:00.000Z` + "\tERROR\tforged"},
+		{"scooby\r\ndoo\x1b[31m", false, `scooby\r\ndoo\x1b[31m`},
+		{"scooby\ndoo\x1b[31m", true, "scooby\n    doo\\x1b[31m"},
+		{"scooby\u0085doo", false, `scooby\u0085doo`},
+	} {
+		if got := escapeMessage(c.msg, c.indent); got != c.want {
+			t.Errorf("got %q for %q, wanted %q", got, c.msg, c.want)
+		}
+	}
+}
+
+func TestMultiline(t *testing.T) {
+	ent := zapcore.Entry{Level: zapcore.InfoLevel, Message: "scooby\ndoo"}
+	for mode, want := range map[string]string{
+		"":              "INFO\tscooby\\ndoo\n",
+		MultilineIndent: "INFO\tscooby\n    doo\n",
+		MultilineRaw:    "INFO\tscooby\ndoo\n",
+	} {
+		enc := &consoleEncoder{zapcore.NewConsoleEncoder(zapcore.EncoderConfig{
+			LevelKey:    "level",
+			MessageKey:  "msg",
+			EncodeLevel: zapcore.CapitalLevelEncoder,
+		}), multilineOf(mode)}
+		buf, err := enc.EncodeEntry(ent, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("got %q with mode %q, wanted %q", got, mode, want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package log
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type contextFieldsKey struct{}
+
+// WithContext returns a copy of ctx carrying fields, in addition to the
+// fields ctx already carries, for the loggers returned by FromContext to add
+// them to all their entries. Fields are zap.Field values or key-value pairs,
+// like the arguments of Infow.
+func WithContext(ctx context.Context, fields ...interface{}) context.Context {
+	parent := contextFields(ctx)
+	all := make([]interface{}, 0, len(parent)+len(fields))
+	all = append(append(all, parent...), fields...)
+	return context.WithValue(ctx, contextFieldsKey{}, all)
+}
+
+// FromContext retrieves the event logger of system, adding the fields carried
+// by ctx to all its entries, and the "trace_id" and "span_id" fields of the
+// OpenTelemetry span of ctx if any.
+func FromContext(ctx context.Context, system string) *ZapEventLogger {
+	logger := Logger(system)
+	fields := contextFields(ctx)
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		fields = append(fields[:len(fields):len(fields)],
+			"trace_id", span.TraceID().String(),
+			"span_id", span.SpanID().String(),
+		)
+	}
+	if len(fields) > 0 {
+		return logger.With(fields...)
+	}
+	return logger
+}
+
+// contextFields returns the fields carried by ctx.
+func contextFields(ctx context.Context) []interface{} {
+	fields, _ := ctx.Value(contextFieldsKey{}).([]interface{})
+	return fields
+}
@@ -0,0 +1,77 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func TestFromContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelInfo, Format: FormatJSONOutput, File: path})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	ctx := WithContext(context.Background(), "request", "abc")
+	child := WithContext(ctx, zap.String("peer", "Qm"))
+	FromContext(child, "ctx").Error("scooby")
+	FromContext(ctx, "ctx").Warning("doo")
+	FromContext(context.Background(), "ctx").Error("where are you")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d entries, wanted 3", len(lines))
+	}
+	for i, want := range []map[string]interface{}{
+		{"request": "abc", "peer": "Qm"},
+		{"request": "abc", "peer": nil},
+		{"request": nil, "peer": nil},
+	} {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+			t.Fatal(err)
+		}
+		for key, value := range want {
+			if entry[key] != value {
+				t.Errorf("got entry %s, wanted %s %v", lines[i], key, value)
+			}
+		}
+		if caller, _ := entry["caller"].(string); !strings.Contains(caller, "context_test.go") {
+			t.Errorf("got caller %s, wanted the test", caller)
+		}
+	}
+}
+
+func TestFromContextSpan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelInfo, Format: FormatJSONOutput, File: path})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	span := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	})
+	ctx := trace.ContextWithSpanContext(WithContext(context.Background(), "request", "abc"), span)
+	FromContext(ctx, "ctx").Error("scooby")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || entry["span_id"] != "00f067aa0ba902b7" || entry["request"] != "abc" {
+		t.Errorf("got %s, wanted the trace and span IDs", data)
+	}
+}
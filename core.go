@@ -1,8 +1,12 @@
 package log
 
 import (
+	"fmt"
+	"os"
 	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
@@ -12,6 +16,10 @@ import (
 var _ zapcore.Core = (*lockedMultiCore)(nil)
 
 type lockedMultiCore struct {
+	// version is incremented by the changes to the cores, for the cores
+	// derived from them to be built again.
+	version uint64
+
 	mu    sync.RWMutex // guards mutations to cores slice
 	cores []zapcore.Core
 }
@@ -48,6 +56,18 @@ func (l *lockedMultiCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *za
 	return ce
 }
 
+// checkRecent checks ent against the cores recording the last entries only.
+func (l *lockedMultiCore) checkRecent(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for i := range l.cores {
+		if _, ok := l.cores[i].(*recentCore); ok {
+			ce = l.cores[i].Check(ent, ce)
+		}
+	}
+	return ce
+}
+
 func (l *lockedMultiCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
@@ -73,6 +93,7 @@ func (l *lockedMultiCore) AddCore(core zapcore.Core) {
 	defer l.mu.Unlock()
 
 	l.cores = append(l.cores, core)
+	atomic.AddUint64(&l.version, 1)
 }
 
 func (l *lockedMultiCore) DeleteCore(core zapcore.Core) {
@@ -88,6 +109,7 @@ func (l *lockedMultiCore) DeleteCore(core zapcore.Core) {
 		w++
 	}
 	l.cores = l.cores[:w]
+	atomic.AddUint64(&l.version, 1)
 }
 
 func (l *lockedMultiCore) ReplaceCore(original, replacement zapcore.Core) {
@@ -99,6 +121,7 @@ func (l *lockedMultiCore) ReplaceCore(original, replacement zapcore.Core) {
 			l.cores[i] = replacement
 		}
 	}
+	atomic.AddUint64(&l.version, 1)
 }
 
 // ZapEventLogger implements the EventLogger and wraps a go-logging Logger
@@ -107,6 +130,8 @@ type ZapEventLogger struct {
 	// used to fix the caller location when calling Warning and Warningf.
 	skipLogger zap.SugaredLogger
 	system     string
+	// fields are the fields added by With, for the OnFatal hooks.
+	fields []zapcore.Field
 }
 
 // Warning is for compatibility
@@ -121,6 +146,135 @@ func (logger *ZapEventLogger) Warningf(format string, args ...interface{}) {
 	logger.skipLogger.Warnf(format, args...)
 }
 
+// Trace logs a message at LevelTrace, like Debug.
+func (logger *ZapEventLogger) Trace(args ...interface{}) {
+	if logger.Enabled(LevelTrace) {
+		logger.log(LevelTrace, fmt.Sprint(args...), nil)
+	}
+}
+
+// Tracef logs a message at LevelTrace, like Debugf.
+func (logger *ZapEventLogger) Tracef(template string, args ...interface{}) {
+	if logger.Enabled(LevelTrace) {
+		logger.log(LevelTrace, fmt.Sprintf(template, args...), nil)
+	}
+}
+
+// Tracew logs a message at LevelTrace with key-value pairs, like Debugw.
+func (logger *ZapEventLogger) Tracew(msg string, keysAndValues ...interface{}) {
+	if logger.Enabled(LevelTrace) {
+		logger.log(LevelTrace, msg, keysAndValues)
+	}
+}
+
+// Log logs a message at level, which may be a level registered with
+// RegisterLevel, like Info.
+func (logger *ZapEventLogger) Log(level LogLevel, args ...interface{}) {
+	if logger.Enabled(level) {
+		logger.log(level, fmt.Sprint(args...), nil)
+	}
+}
+
+// Logf logs a message at level, like Infof.
+func (logger *ZapEventLogger) Logf(level LogLevel, template string, args ...interface{}) {
+	if logger.Enabled(level) {
+		logger.log(level, fmt.Sprintf(template, args...), nil)
+	}
+}
+
+// Logw logs a message at level with key-value pairs, like Infow.
+func (logger *ZapEventLogger) Logw(level LogLevel, msg string, keysAndValues ...interface{}) {
+	if logger.Enabled(level) {
+		logger.log(level, msg, keysAndValues)
+	}
+}
+
+// Enabled returns whether the entries at level are logged, which are the
+// entries at the level of the subsystem or above, so that expensive fields
+// are only computed when needed:
+//
+//	if logger.Enabled(log.LevelDebug) {
+//		logger.Debugw("received", "block", dump(block))
+//	}
+func (logger *ZapEventLogger) Enabled(level LogLevel) bool {
+	// the panic and fatal levels are logged anyway
+	return level >= LevelDPanic || logger.Desugar().Core().Enabled(zapcore.Level(level))
+}
+
+// Check returns the entry of msg at level if it is logged, or nil, so that
+// the fields are only computed when needed. The entry is logged by writing
+// it with its fields:
+//
+//	if ce := logger.Check(log.LevelDebug, "received"); ce != nil {
+//		ce.Write(zap.Any("block", dump(block)))
+//	}
+//
+// Entries checked at LevelFatal exit once written, regardless of
+// Config.FatalBehavior.
+func (logger *ZapEventLogger) Check(level LogLevel, msg string) *zapcore.CheckedEntry {
+	// the caller is the caller of Check
+	return logger.skipLogger.Desugar().Check(zapcore.Level(level), msg)
+}
+
+// log logs msg at level, for the Trace and Log methods, since the
+// SugaredLogger only logs at its own levels.
+func (logger *ZapEventLogger) log(level LogLevel, msg string, keysAndValues []interface{}) {
+	// the caller is the caller of the Trace and Log methods
+	base := logger.skipLogger.Desugar().WithOptions(zap.AddCallerSkip(1))
+	ce := base.Check(zapcore.Level(level), msg)
+	if ce == nil {
+		return
+	}
+
+	ce.Write(sweetenFields(keysAndValues)...)
+}
+
+// sweetenFields returns the fields of keysAndValues, which are zap.Field
+// values or key-value pairs.
+func sweetenFields(keysAndValues []interface{}) []zapcore.Field {
+	fields := make([]zapcore.Field, 0, len(keysAndValues)/2)
+	for i := 0; i < len(keysAndValues); i++ {
+		if f, ok := keysAndValues[i].(zapcore.Field); ok {
+			fields = append(fields, f)
+			continue
+		}
+		key := fmt.Sprint(keysAndValues[i])
+		if i++; i == len(keysAndValues) {
+			fields = append(fields, zap.String(key, "(MISSING)"))
+			break
+		}
+		fields = append(fields, zap.Any(key, keysAndValues[i]))
+	}
+	return fields
+}
+
+// With returns a child logger of the same subsystem, adding args to all its
+// entries. Args are zap.Field values or key-value pairs, like the arguments
+// of Infow.
+func (logger *ZapEventLogger) With(args ...interface{}) *ZapEventLogger {
+	child := *logger
+	child.fields = append(logger.fields[:len(logger.fields):len(logger.fields)], sweetenFields(args)...)
+	child.SugaredLogger = *logger.SugaredLogger.With(args...)
+	child.skipLogger = *logger.skipLogger.With(args...)
+	return &child
+}
+
+// WithOptions returns a child logger of the same subsystem with the zap
+// options applied, i.e. zap.AddCallerSkip or zap.Hooks.
+func (logger *ZapEventLogger) WithOptions(opts ...zap.Option) *ZapEventLogger {
+	child := *logger
+	child.SugaredLogger = *logger.SugaredLogger.Desugar().WithOptions(opts...).Sugar()
+	child.skipLogger = *logger.skipLogger.Desugar().WithOptions(opts...).Sugar()
+	return &child
+}
+
+// CallerSkip returns a child logger of the same subsystem skipping skip more
+// stack frames for the callers of its entries, so that the packages wrapping
+// it report the callers of their functions rather than themselves.
+func (logger *ZapEventLogger) CallerSkip(skip int) *ZapEventLogger {
+	return logger.WithOptions(zap.AddCallerSkip(skip))
+}
+
 func WithStacktrace(l *ZapEventLogger, level LogLevel) *ZapEventLogger {
 	copyLogger := *l
 	copyLogger.SugaredLogger = *copyLogger.SugaredLogger.Desugar().
@@ -130,20 +284,183 @@ func WithStacktrace(l *ZapEventLogger, level LogLevel) *ZapEventLogger {
 }
 
 func newCore(format LogFormat, ws zapcore.WriteSyncer, level LogLevel) zapcore.Core {
+	return zapcore.NewCore(newEncoder(format), ws, zap.NewAtomicLevelAt(zapcore.Level(level)))
+}
+
+// encoderOptions customizes the encoders of the formats.
+type encoderOptions struct {
+	// timeEncoder encodes the times of the formats which don't mandate a
+	// time format, if set.
+	timeEncoder zapcore.TimeEncoder
+
+	// keys renames the keys of the formats using the keys of
+	// FormatJSONOutput.
+	keys map[string]string
+
+	// callers are the caller formats.
+	callers callerOptions
+
+	// theme is the color theme of the colored formats, if not the default.
+	theme *ColorTheme
+
+	// multiline is the multiline mode of the console formats.
+	multiline string
+
+	// errorDetails expands the error fields.
+	errorDetails bool
+}
+
+// jsonKeys are the keys of the entries of FormatJSONOutput.
+var jsonKeys = map[string]bool{"ts": true, "level": true, "logger": true, "caller": true, "msg": true, "stacktrace": true}
+
+// renameKeys renames the keys of cfg, which are the keys of
+// FormatJSONOutput.
+func (o encoderOptions) renameKeys(cfg *zapcore.EncoderConfig) {
+	for _, key := range []*string{&cfg.TimeKey, &cfg.LevelKey, &cfg.NameKey, &cfg.CallerKey, &cfg.MessageKey, &cfg.StacktraceKey} {
+		if renamed, ok := o.keys[*key]; ok {
+			*key = renamed
+		}
+	}
+}
+
+// colorTheme returns the color theme of the colored formats.
+func (o encoderOptions) colorTheme() *ColorTheme {
+	if o.theme == nil {
+		return DefaultColorTheme
+	}
+	return o.theme
+}
+
+// currentEncoderOptions holds the encoderOptions configured by SetupLogging
+var currentEncoderOptions atomic.Value
+
+func getEncoderOptions() encoderOptions {
+	opts, _ := currentEncoderOptions.Load().(encoderOptions)
+	return opts
+}
+
+// setEncoderOptions sets the encoder options of cfg, for the encoders
+// created next.
+func setEncoderOptions(cfg Config) {
+	var opts encoderOptions
+	if cfg.TimeFormat != "" {
+		opts.timeEncoder = timeEncoderOf(cfg.TimeFormat)
+	}
+	if len(cfg.JSONKeys) > 0 {
+		opts.keys = make(map[string]string, len(cfg.JSONKeys))
+	}
+	for key, renamed := range cfg.JSONKeys {
+		if !jsonKeys[key] {
+			fmt.Fprintf(os.Stderr, "ignoring unknown JSON key '%s'\n", key)
+			continue
+		}
+		opts.keys[key] = renamed
+	}
+	opts.callers = callerOptionsOf(cfg)
+	opts.theme = cfg.ColorTheme
+	opts.multiline = multilineOf(cfg.Multiline)
+	opts.errorDetails = cfg.ErrorDetails
+	currentEncoderOptions.Store(opts)
+}
+
+// timeEncoderOf returns the time encoder of a Config.TimeFormat.
+func timeEncoderOf(format string) zapcore.TimeEncoder {
+	switch strings.ToLower(format) {
+	case "iso8601":
+		return zapcore.ISO8601TimeEncoder
+	case "rfc3339":
+		return zapcore.RFC3339TimeEncoder
+	case "rfc3339nano":
+		return zapcore.RFC3339NanoTimeEncoder
+	case "epoch":
+		return zapcore.EpochTimeEncoder
+	case "epoch_millis":
+		return zapcore.EpochMillisTimeEncoder
+	case "epoch_nanos":
+		return zapcore.EpochNanosTimeEncoder
+	default:
+		return zapcore.TimeEncoderOfLayout(format)
+	}
+}
+
+// newEncoderConfig returns the production encoder config, with the level
+// names of LogLevel.
+func newEncoderConfig() zapcore.EncoderConfig {
 	encCfg := zap.NewProductionEncoderConfig()
+	encCfg.EncodeLevel = encodeLevel
+	return encCfg
+}
+
+// newEncoder returns the encoder of the cores with the given format.
+func newEncoder(format LogFormat) zapcore.Encoder {
+	opts := getEncoderOptions()
+	encCfg := newEncoderConfig()
 	encCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+	if opts.timeEncoder != nil {
+		encCfg.EncodeTime = opts.timeEncoder
+	}
+	encCfg.EncodeCaller = encodeCaller
+	// the caller format defaults to the short path, and to the full path for
+	// the formats of log services, GCP and ECS, below
+	callerFormat := CallerShort
 
 	var encoder zapcore.Encoder
 	switch format {
 	case FormatPlaintextOutput:
-		encCfg.EncodeLevel = zapcore.CapitalLevelEncoder
-		encoder = zapcore.NewConsoleEncoder(encCfg)
+		encCfg.EncodeLevel = encodeCapitalLevel
+		encCfg.EncodeDuration = zapcore.StringDurationEncoder
+		encoder = &consoleEncoder{zapcore.NewConsoleEncoder(encCfg), opts.multiline}
 	case FormatJSONOutput:
+		opts.renameKeys(&encCfg)
 		encoder = zapcore.NewJSONEncoder(encCfg)
+	case FormatJSONPrettyOutput:
+		opts.renameKeys(&encCfg)
+		encoder = &prettyJSONEncoder{zapcore.NewJSONEncoder(encCfg), opts.colorTheme().Key}
+	case FormatGCPOutput:
+		encCfg.TimeKey = "timestamp"
+		encCfg.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+		encCfg.LevelKey = "severity"
+		encCfg.EncodeLevel = gcpSeverityEncoder
+		encCfg.MessageKey = "message"
+		encCfg.StacktraceKey = "stack_trace"
+		encCfg.CallerKey = ""
+		callerFormat = CallerFull
+		encoder = &gcpEncoder{zapcore.NewJSONEncoder(encCfg)}
+	case FormatRFC5424Output:
+		encoder = newRFC5424Encoder()
+	case FormatCEFOutput:
+		encoder = newCEFEncoder()
+	case FormatECSOutput:
+		callerFormat = CallerFull
+		encoder = newECSEncoder()
+	case FormatMsgpackOutput, FormatCBOROutput, FormatCBORDeterministicOutput:
+		// binary formats encode times natively, and durations as nanoseconds
+		encCfg.EncodeTime = opts.timeEncoder
+		encCfg.EncodeDuration = nil
+		opts.renameKeys(&encCfg)
+		switch format {
+		case FormatMsgpackOutput:
+			encoder = newMsgpackEncoder(encCfg)
+		case FormatCBOROutput:
+			encoder = newCBOREncoder(encCfg, false)
+		default:
+			// times are strings in DAG-CBOR
+			encCfg.EncodeTime = nil
+			encoder = newCBOREncoder(encCfg, true)
+		}
+	case FormatProtobufOutput:
+		encoder = newProtoEncoder()
 	default:
-		encCfg.EncodeLevel = zapcore.CapitalColorLevelEncoder
-		encoder = zapcore.NewConsoleEncoder(encCfg)
+		if factory := customFormat(format); factory != nil {
+			opts.renameKeys(&encCfg)
+			encoder = factory(encCfg)
+			break
+		}
+		opts.colorTheme().setEncoders(&encCfg)
+		encCfg.EncodeDuration = zapcore.StringDurationEncoder
+		encoder = &consoleEncoder{zapcore.NewConsoleEncoder(encCfg), opts.multiline}
 	}
 
-	return zapcore.NewCore(encoder, ws, zap.NewAtomicLevelAt(zapcore.Level(level)))
+	encoder = &errorEncoder{encoder, opts.errorDetails}
+	return &callerEncoder{encoder, opts.callers, callerFormat}
 }
@@ -0,0 +1,187 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DatadogConfig configures sending logs to the Datadog HTTP intake.
+//
+// The "service" and "source" labels of Config.Labels are used as the Datadog
+// service and source, other labels are sent as tags.
+type DatadogConfig struct {
+	// APIKey is the Datadog API key. Defaults to the DD_API_KEY environment
+	// variable.
+	APIKey string
+
+	// Site is the Datadog site, i.e. "datadoghq.eu". Defaults to the DD_SITE
+	// environment variable, then to "datadoghq.com".
+	Site string
+
+	// BatchSize is the maximum number of entries sent in one request.
+	// Defaults to 100, at most 1000.
+	BatchSize int
+
+	// FlushInterval is the maximum time an entry waits before being sent.
+	// Defaults to one second.
+	FlushInterval time.Duration
+
+	// Endpoint overrides the intake endpoint of the site.
+	Endpoint string
+}
+
+const (
+	defaultDatadogSite   = "datadoghq.com"
+	datadogMaxBatchSize  = 1000
+	datadogMaxRetries    = 3
+	datadogLogsIntakeAPI = "/api/v2/logs"
+)
+
+// newDatadogCore creates a core sending gzipped batches of entries to the
+// Datadog logs intake.
+func newDatadogCore(cfg DatadogConfig, labels map[string]string, level LogLevel) (zapcore.Core, error) {
+	apiKey := cfg.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("DD_API_KEY")
+	}
+	if apiKey == "" {
+		return nil, errors.New("missing Datadog API key")
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		site := cfg.Site
+		if site == "" {
+			site = os.Getenv("DD_SITE")
+		}
+		if site == "" {
+			site = defaultDatadogSite
+		}
+		endpoint = "https://http-intake.logs." + site
+	}
+	batchSize := cfg.BatchSize
+	if batchSize > datadogMaxBatchSize {
+		batchSize = datadogMaxBatchSize
+	}
+
+	d := &datadogClient{
+		url:    strings.TrimSuffix(endpoint, "/") + datadogLogsIntakeAPI,
+		apiKey: apiKey,
+		client: newHTTPClient(nil),
+	}
+	d.hostname, _ = os.Hostname()
+
+	var tags []string
+	for k, v := range labels {
+		switch k {
+		case "service":
+			d.service = v
+		case "source":
+			d.source = v
+		default:
+			tags = append(tags, k+":"+v)
+		}
+	}
+	sort.Strings(tags)
+	d.tags = strings.Join(tags, ",")
+
+	// the level is sent as the Datadog status
+	encCfg := newEncoderConfig()
+	encCfg.LevelKey = ""
+	encCfg.MessageKey = "message"
+	encCfg.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+
+	return &batchCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          zapcore.NewJSONEncoder(encCfg),
+		batcher:      newBatcher(batchSize, cfg.FlushInterval, d.send),
+	}, nil
+}
+
+type datadogClient struct {
+	url      string
+	apiKey   string
+	hostname string
+	service  string
+	source   string
+	tags     string
+	client   *http.Client
+}
+
+// datadogStatus maps a log level to a Datadog status.
+func datadogStatus(lvl zapcore.Level) string {
+	switch lvl {
+	case zapcore.DPanicLevel:
+		return "critical"
+	case zapcore.PanicLevel:
+		return "alert"
+	case zapcore.FatalLevel:
+		return "emergency"
+	default:
+		return levelName(lvl)
+	}
+}
+
+func (d *datadogClient) send(batch []batchEntry) error {
+	var body bytes.Buffer
+	body.WriteByte('[')
+	for i, e := range batch {
+		if i > 0 {
+			body.WriteByte(',')
+		}
+
+		tags := d.tags
+		if e.ent.LoggerName != "" {
+			if tags != "" {
+				tags += ","
+			}
+			tags += "subsystem:" + e.ent.LoggerName
+		}
+		attrs, err := json.Marshal(struct {
+			Source   string `json:"ddsource,omitempty"`
+			Tags     string `json:"ddtags,omitempty"`
+			Hostname string `json:"hostname,omitempty"`
+			Service  string `json:"service,omitempty"`
+			Status   string `json:"status"`
+		}{d.source, tags, d.hostname, d.service, datadogStatus(e.ent.Level)})
+		if err != nil {
+			return err
+		}
+
+		// merge the reserved attributes into the JSON encoded entry
+		body.Write(attrs[:len(attrs)-1])
+		body.WriteByte(',')
+		body.Write(bytes.TrimRight(e.data, "\n")[1:])
+	}
+	body.WriteByte(']')
+
+	data, err := gzipBytes(body.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return retryWithBackoff(datadogMaxRetries, func() (bool, error) {
+		req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(data))
+		if err != nil {
+			return false, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("DD-API-KEY", d.apiKey)
+
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return true, err
+		}
+		err = checkHTTPResponse(resp)
+		return isRetryableHTTPError(err), err
+	})
+}
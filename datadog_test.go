@@ -0,0 +1,60 @@
+package log
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestDatadogCore(t *testing.T) {
+	var got []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get("DD-API-KEY"); key != "secret" {
+			t.Errorf("got API key %q", key)
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(zr).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	core, err := newDatadogCore(DatadogConfig{
+		APIKey:   "secret",
+		Endpoint: srv.URL,
+	}, map[string]string{"service": "api", "env": "prod"}, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*batchCore).Close()
+
+	zap.New(core).Named("dht").Error("scooby", zap.Int("count", 3))
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("got %d entries, wanted 1", len(got))
+	}
+	for k, want := range map[string]interface{}{
+		"service": "api",
+		"ddtags":  "env:prod,subsystem:dht",
+		"status":  "error",
+		"message": "scooby",
+		"count":   float64(3),
+	} {
+		if got[0][k] != want {
+			t.Errorf("got %s %v, wanted %v", k, got[0][k], want)
+		}
+	}
+}
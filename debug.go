@@ -0,0 +1,72 @@
+package log
+
+import (
+	"fmt"
+	"os"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// debugSavedLevels are the levels of the subsystems before DebugAll, nil
+// when not debugging.
+var debugSavedLevels map[string]zapcore.Level
+
+// DebugAll sets all subsystems to LevelDebug temporarily, i.e. on SIGUSR1
+// with Config.DebugOnSIGUSR1, until RestoreLevels is called. The subsystems
+// at LevelTrace are kept at it.
+func DebugAll() {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+
+	if debugSavedLevels != nil {
+		return
+	}
+	debugSavedLevels = make(map[string]zapcore.Level, len(levels))
+	for name, level := range levels {
+		debugSavedLevels[name] = level.Level()
+		if level.Level() > zapcore.DebugLevel {
+			level.SetLevel(zapcore.DebugLevel)
+		}
+	}
+}
+
+// RestoreLevels restores the levels of the subsystems set to LevelDebug by
+// DebugAll, i.e. on SIGUSR2 with Config.DebugOnSIGUSR1.
+func RestoreLevels() {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+
+	restoreLevels()
+}
+
+func restoreLevels() {
+	for name, lvl := range debugSavedLevels {
+		if level, ok := levels[name]; ok {
+			level.SetLevel(lvl)
+		}
+	}
+	debugSavedLevels = nil
+}
+
+// stopDebugOnSignal stops debugging on SIGUSR1 when not nil.
+var stopDebugOnSignal func()
+
+// setDebugOnSignal starts or stops calling DebugAll on SIGUSR1 and
+// RestoreLevels on SIGUSR2, loggerMutex must be held.
+func setDebugOnSignal(enabled bool) {
+	if enabled == (stopDebugOnSignal != nil) {
+		return
+	}
+	if !enabled {
+		stopDebugOnSignal()
+		stopDebugOnSignal = nil
+		return
+	}
+
+	stop, err := notifyDebugSignals(DebugAll, RestoreLevels)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to debug on signals, use DebugAll: %s\n", err)
+		return
+	}
+	stopDebugOnSignal = stop
+}
@@ -0,0 +1,11 @@
+//go:build windows || plan9 || js
+// +build windows plan9 js
+
+package log
+
+import "errors"
+
+// notifyDebugSignals fails, there is no SIGUSR1 on this platform.
+func notifyDebugSignals(debug, restore func()) (stop func(), err error) {
+	return nil, errors.New("SIGUSR1 is not supported on this platform")
+}
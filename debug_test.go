@@ -0,0 +1,24 @@
+package log
+
+import "testing"
+
+func TestDebugAll(t *testing.T) {
+	SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, SubsystemLevels: map[string]LogLevel{"traced": LevelTrace}})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	Logger("bumped")
+	Logger("traced")
+	if err := SetLogLevel("bumped", "warn"); err != nil {
+		t.Fatal(err)
+	}
+
+	DebugAll()
+	DebugAll()
+	if levels := AllLevels(); levels["bumped"] != "debug" || levels["traced"] != "trace" {
+		t.Errorf("got %v, wanted debug and the trace level kept", levels)
+	}
+	RestoreLevels()
+	if levels := AllLevels(); levels["bumped"] != "warn" || levels["traced"] != "trace" {
+		t.Errorf("got %v, wanted the previous levels", levels)
+	}
+}
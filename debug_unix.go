@@ -0,0 +1,36 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package log
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyDebugSignals calls debug on every SIGUSR1 and restore on every
+// SIGUSR2, until stop is called.
+func notifyDebugSignals(debug, restore func()) (stop func(), err error) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if sig == syscall.SIGUSR1 {
+					debug()
+				} else {
+					restore()
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}, nil
+}
@@ -0,0 +1,36 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package log
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDebugOnSIGUSR1(t *testing.T) {
+	SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, DebugOnSIGUSR1: true})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	log := Logger("signaled")
+	waitFor := func(debug bool) {
+		deadline := time.Now().Add(2 * time.Second)
+		for log.Enabled(LevelDebug) != debug {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected debug entries enabled: %t", debug)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(true)
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR2); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(false)
+}
@@ -0,0 +1,111 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// dedup holds the *deduplicator set by Config.Deduplicate, nil when
+// disabled.
+var dedup atomic.Value
+
+func init() {
+	dedup.Store((*deduplicator)(nil))
+}
+
+// setDeduplicate enables collapsing the repeated entries within window, or
+// disables it when window is 0. The entries suppressed by the previous
+// deduplicator are still summarized.
+func setDeduplicate(window time.Duration) {
+	if prev, _ := dedup.Load().(*deduplicator); prev != nil && prev.window == window {
+		return
+	}
+	var d *deduplicator
+	if window > 0 {
+		d = &deduplicator{window: window, last: make(map[string]*repeatedEntry)}
+	}
+	dedup.Store(d)
+}
+
+// deduplicator collapses the identical consecutive entries of subsystems.
+type deduplicator struct {
+	window time.Duration
+
+	mu   sync.Mutex                // guards last
+	last map[string]*repeatedEntry // last entry of each subsystem
+}
+
+// repeatedEntry is the last entry of a subsystem, and the number of times it
+// was repeated since logged.
+type repeatedEntry struct {
+	level   zapcore.Level
+	message string
+	repeats int
+	flush   *time.Timer
+}
+
+// unique reports whether ent is logged, i.e. whether it is not a repetition
+// of the last entry of its subsystem within the window. The repetitions of
+// the previous entry are summarized first when it is not.
+func (d *deduplicator) unique(ent zapcore.Entry) bool {
+	if ent.Level >= zapcore.DPanicLevel {
+		return true
+	}
+
+	d.mu.Lock()
+	last := d.last[ent.LoggerName]
+	if last != nil && last.level == ent.Level && last.message == ent.Message {
+		last.repeats++
+		d.mu.Unlock()
+		return false
+	}
+	if last != nil {
+		last.flush.Stop()
+	}
+	r := &repeatedEntry{level: ent.Level, message: ent.Message}
+	r.flush = time.AfterFunc(d.window, func() {
+		d.flush(ent.LoggerName, r)
+	})
+	d.last[ent.LoggerName] = r
+	d.mu.Unlock()
+
+	if last != nil {
+		logRepeats(ent.LoggerName, last)
+	}
+	return true
+}
+
+// flush summarizes the repetitions of r at the end of the window, the next
+// identical entry being logged again.
+func (d *deduplicator) flush(subsystem string, r *repeatedEntry) {
+	d.mu.Lock()
+	if d.last[subsystem] != r {
+		// replaced by another entry meanwhile
+		d.mu.Unlock()
+		return
+	}
+	delete(d.last, subsystem)
+	d.mu.Unlock()
+
+	logRepeats(subsystem, r)
+}
+
+// logRepeats logs the number of repetitions of r, if any, like r.
+func logRepeats(subsystem string, r *repeatedEntry) {
+	if r.repeats == 0 {
+		return
+	}
+	ent := zapcore.Entry{
+		LoggerName: subsystem,
+		Time:       time.Now(),
+		Level:      r.level,
+		Message:    fmt.Sprintf("last message repeated %d times", r.repeats),
+	}
+	if ce := loggerCore.Check(ent, nil); ce != nil {
+		ce.Write()
+	}
+}
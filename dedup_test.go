@@ -0,0 +1,47 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeduplicate(t *testing.T) {
+	SetupLogging(Config{Level: LevelInfo, Format: FormatPlaintextOutput, Deduplicate: 50 * time.Millisecond})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	capture := StartCapture(func(e Entry) bool { return e.Subsystem == "retries" })
+	defer capture.Stop()
+
+	log := Logger("retries")
+	for i := 0; i < 5; i++ {
+		log.Warn("retry failed")
+	}
+	log.Info("recovered")
+	log.Info("recovered")
+	log.Info("recovered")
+	time.Sleep(100 * time.Millisecond)
+	log.Info("recovered")
+
+	var messages []string
+	for _, e := range capture.Entries() {
+		messages = append(messages, e.Message)
+	}
+	want := []string{
+		"retry failed",
+		"last message repeated 4 times",
+		"recovered",
+		"last message repeated 2 times",
+		"recovered",
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("got %q, wanted %q", messages, want)
+	}
+	for i := range want {
+		if messages[i] != want[i] {
+			t.Fatalf("got %q, wanted %q", messages, want)
+		}
+	}
+	if entries := capture.FilterMessage("last message repeated 4 times"); entries[0].Level != LevelWarn {
+		t.Errorf("got %v, wanted the level of the repeated entry", entries[0])
+	}
+}
@@ -0,0 +1,64 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// diskSpaceCheckInterval is the minimum time between two checks of the free
+// space of a filesystem.
+const diskSpaceCheckInterval = 10 * time.Second
+
+// diskSpaceEnabler enables all levels while the filesystem of a log file has
+// enough free space, and only Warn and above otherwise.
+type diskSpaceEnabler struct {
+	path    string
+	minFree uint64
+
+	low     uint32 // 1 while the free space is below minFree
+	checked int64  // time of the last check, in Unix nanoseconds
+}
+
+func newDiskSpaceEnabler(path string, minFree int64) *diskSpaceEnabler {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return &diskSpaceEnabler{path: path, minFree: uint64(minFree)}
+}
+
+func (e *diskSpaceEnabler) Enabled(lvl zapcore.Level) bool {
+	if lvl >= zapcore.WarnLevel {
+		return true
+	}
+	e.check()
+	return atomic.LoadUint32(&e.low) == 0
+}
+
+// check checks the free space, unless it has been checked recently.
+func (e *diskSpaceEnabler) check() {
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&e.checked)
+	if last != 0 && now-last < int64(diskSpaceCheckInterval) {
+		return
+	}
+	if !atomic.CompareAndSwapInt64(&e.checked, last, now) {
+		return
+	}
+
+	free, err := freeDiskSpace(filepath.Dir(e.path))
+	if err != nil {
+		return
+	}
+	if free < e.minFree {
+		if atomic.SwapUint32(&e.low, 1) == 0 {
+			fmt.Fprintf(os.Stderr, "WARNING: %d bytes of free disk space left for log file %s, only logging warnings and above to it\n", free, e.path)
+		}
+	} else if atomic.SwapUint32(&e.low, 0) == 1 {
+		fmt.Fprintf(os.Stderr, "%d bytes of free disk space available for log file %s, logging all levels to it again\n", free, e.path)
+	}
+}
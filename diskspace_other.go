@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !freebsd && !windows
+// +build !linux,!darwin,!freebsd,!windows
+
+package log
+
+import "errors"
+
+// freeDiskSpace fails, the free disk space is not known on this platform.
+func freeDiskSpace(dir string) (uint64, error) {
+	return 0, errors.New("free disk space is not supported on this platform")
+}
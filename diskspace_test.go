@@ -0,0 +1,29 @@
+package log
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestDiskSpaceEnabler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if _, err := freeDiskSpace(filepath.Dir(path)); err != nil {
+		t.Skipf("free disk space unavailable: %s", err)
+	}
+
+	enough := newDiskSpaceEnabler(path, 1)
+	if !enough.Enabled(zapcore.DebugLevel) {
+		t.Error("debug should be enabled with enough free space")
+	}
+
+	low := newDiskSpaceEnabler(path, math.MaxInt64)
+	if low.Enabled(zapcore.InfoLevel) {
+		t.Error("info should be disabled with low free space")
+	}
+	if !low.Enabled(zapcore.WarnLevel) {
+		t.Error("warn should always be enabled")
+	}
+}
@@ -0,0 +1,59 @@
+package log
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// ecsVersion is the version of the Elastic Common Schema followed by
+// FormatECSOutput.
+const ecsVersion = "1.6.0"
+
+// newECSEncoder returns the encoder of FormatECSOutput, writing entries as
+// JSON with the keys of the Elastic Common Schema.
+func newECSEncoder() zapcore.Encoder {
+	encCfg := newEncoderConfig()
+	encCfg.TimeKey = "@timestamp"
+	encCfg.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+	encCfg.LevelKey = "log.level"
+	encCfg.NameKey = "log.logger"
+	encCfg.MessageKey = "message"
+	encCfg.StacktraceKey = "error.stack_trace"
+	// the caller is split in file name and line by ecsEncoder
+	encCfg.CallerKey = ""
+
+	enc := zapcore.NewJSONEncoder(encCfg)
+	enc.AddString("ecs.version", ecsVersion)
+	return &ecsEncoder{enc}
+}
+
+// ecsEncoder moves the caller and the errors of entries to their ECS fields.
+type ecsEncoder struct {
+	zapcore.Encoder
+}
+
+func (e *ecsEncoder) Clone() zapcore.Encoder {
+	return &ecsEncoder{e.Encoder.Clone()}
+}
+
+func (e *ecsEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	ecsFields := make([]zapcore.Field, 0, len(fields)+2)
+	for _, f := range fields {
+		if f.Type == zapcore.ErrorType && f.Key == "error" {
+			f.Key = "error.message"
+		}
+		ecsFields = append(ecsFields, f)
+	}
+	switch {
+	case ent.Caller.File != "":
+		ecsFields = append(ecsFields,
+			zap.String("log.origin.file.name", ent.Caller.File),
+			zap.Int("log.origin.file.line", ent.Caller.Line),
+		)
+	case ent.Caller.Function != "":
+		// formatted with CallerFunction
+		ecsFields = append(ecsFields, zap.String("log.origin.function", ent.Caller.Function))
+	}
+	return e.Encoder.EncodeEntry(ent, ecsFields)
+}
@@ -0,0 +1,40 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestECSFormat(t *testing.T) {
+	buf := &bytes.Buffer{}
+	core := newCore(FormatECSOutput, zapcore.AddSync(buf), LevelDebug)
+	zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel)).Named("dht").Error("scooby", zap.Error(errors.New("doo")))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]interface{}{
+		"log.level":     "error",
+		"log.logger":    "dht",
+		"message":       "scooby",
+		"error.message": "doo",
+		"ecs.version":   ecsVersion,
+	} {
+		if entry[key] != want {
+			t.Errorf("got %s %v, wanted %v", key, entry[key], want)
+		}
+	}
+	if entry["@timestamp"] == nil || entry["error.stack_trace"] == nil {
+		t.Errorf("got %v, wanted a timestamp and a stack trace", entry)
+	}
+	if file, _ := entry["log.origin.file.name"].(string); !strings.HasSuffix(file, "ecs_test.go") || entry["log.origin.file.line"] == nil {
+		t.Errorf("got %v, wanted the caller file and line", entry)
+	}
+}
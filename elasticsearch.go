@@ -0,0 +1,200 @@
+package log
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ElasticsearchConfig configures indexing logs into Elasticsearch.
+type ElasticsearchConfig struct {
+	// URL is the base URL of the Elasticsearch cluster, i.e. "https://localhost:9200".
+	URL string
+
+	// Index is the name of the index entries are written to. A Go time layout
+	// enclosed in braces is replaced by the entry time in UTC, i.e.
+	// "logs-{2006.01.02}" writes to daily indices such as "logs-2024.01.02".
+	// Defaults to "logs-{2006.01.02}".
+	Index string
+
+	// BatchSize is the maximum number of entries sent in one bulk request.
+	// Defaults to 100.
+	BatchSize int
+
+	// FlushInterval is the maximum time an entry waits before being sent.
+	// Defaults to one second.
+	FlushInterval time.Duration
+
+	// MaxRetries is the number of times entries rejected because the cluster
+	// is overloaded (429) are retried. Defaults to 3.
+	MaxRetries int
+
+	// Username and Password are used for basic authentication when set.
+	Username string
+	Password string
+
+	// TLS configures HTTPS connections when not nil.
+	TLS *tls.Config
+}
+
+const (
+	defaultElasticsearchIndex      = "logs-{2006.01.02}"
+	defaultElasticsearchMaxRetries = 3
+)
+
+// newElasticsearchCore creates a core indexing entries into Elasticsearch
+// using the bulk API.
+func newElasticsearchCore(cfg ElasticsearchConfig, level LogLevel) (zapcore.Core, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("missing Elasticsearch URL")
+	}
+	index := cfg.Index
+	if index == "" {
+		index = defaultElasticsearchIndex
+	}
+	if strings.Count(index, "{") != strings.Count(index, "}") {
+		return nil, fmt.Errorf("invalid Elasticsearch index template %q", index)
+	}
+	maxRetries := cfg.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultElasticsearchMaxRetries
+	}
+
+	encCfg := newEncoderConfig()
+	encCfg.TimeKey = "@timestamp"
+	encCfg.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+
+	es := &elasticsearchClient{
+		url:        strings.TrimSuffix(cfg.URL, "/") + "/_bulk",
+		index:      index,
+		maxRetries: maxRetries,
+		username:   cfg.Username,
+		password:   cfg.Password,
+		client:     newHTTPClient(cfg.TLS),
+	}
+
+	return &batchCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          zapcore.NewJSONEncoder(encCfg),
+		batcher:      newBatcher(cfg.BatchSize, cfg.FlushInterval, es.bulk),
+	}, nil
+}
+
+// elasticsearchIndexName expands the time layouts enclosed in braces in
+// template using t.
+func elasticsearchIndexName(template string, t time.Time) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		end := strings.IndexByte(template, '}')
+		if start < 0 || end < start {
+			b.WriteString(template)
+			return b.String()
+		}
+		b.WriteString(template[:start])
+		b.WriteString(t.UTC().Format(template[start+1 : end]))
+		template = template[end+1:]
+	}
+}
+
+type elasticsearchClient struct {
+	url        string
+	index      string
+	maxRetries int
+	username   string
+	password   string
+	client     *http.Client
+}
+
+type elasticsearchBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+func (es *elasticsearchClient) bulk(batch []batchEntry) error {
+	return retryWithBackoff(es.maxRetries, func() (bool, error) {
+		var err error
+		batch, err = es.send(batch)
+		return len(batch) > 0, err
+	})
+}
+
+// send indexes batch, returning the entries that should be retried.
+func (es *elasticsearchClient) send(batch []batchEntry) ([]batchEntry, error) {
+	var body bytes.Buffer
+	for _, e := range batch {
+		action := map[string]map[string]string{
+			"index": {"_index": elasticsearchIndexName(es.index, e.ent.Time)},
+		}
+		if err := json.NewEncoder(&body).Encode(action); err != nil {
+			return nil, err
+		}
+		body.Write(bytes.TrimRight(e.data, "\n"))
+		body.WriteByte('\n')
+	}
+
+	req, err := http.NewRequest(http.MethodPost, es.url, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if es.username != "" || es.password != "" {
+		req.SetBasicAuth(es.username, es.password)
+	}
+
+	resp, err := es.client.Do(req)
+	if err != nil {
+		return batch, err
+	}
+	if resp.StatusCode/100 != 2 {
+		err := checkHTTPResponse(resp)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return batch, err
+		}
+		return nil, err
+	}
+
+	var result elasticsearchBulkResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close() // nolint:errcheck
+	if err != nil {
+		return nil, fmt.Errorf("invalid bulk response: %s", err)
+	}
+	if !result.Errors {
+		return nil, nil
+	}
+
+	var retry []batchEntry
+	var failed int
+	var reason json.RawMessage
+	for i, item := range result.Items {
+		if i >= len(batch) {
+			break
+		}
+		for _, res := range item {
+			switch {
+			case res.Status == http.StatusTooManyRequests:
+				retry = append(retry, batch[i])
+			case res.Status/100 != 2:
+				failed++
+				reason = res.Error
+			}
+		}
+	}
+	if failed > 0 {
+		err = fmt.Errorf("failed to index %d entries: %s", failed, reason)
+	} else if len(retry) > 0 {
+		err = fmt.Errorf("%d entries rejected by an overloaded cluster", len(retry))
+	}
+	return retry, err
+}
@@ -0,0 +1,85 @@
+package log
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestElasticsearchIndexName(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 23, 0, 0, 0, time.FixedZone("", -3600))
+	for template, want := range map[string]string{
+		"logs":                    "logs",
+		"logs-{2006.01.02}":       "logs-2024.01.03",
+		"logs-{2006}-x-{01}-{02}": "logs-2024-x-01-03",
+	} {
+		if got := elasticsearchIndexName(template, ts); got != want {
+			t.Errorf("elasticsearchIndexName(%q) = %q, wanted %q", template, got, want)
+		}
+	}
+}
+
+func TestElasticsearchCoreRetry(t *testing.T) {
+	defer func(d time.Duration) { retryBackoff = d }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	var mu sync.Mutex
+	var requests [][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var lines []string
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+
+		mu.Lock()
+		requests = append(requests, lines)
+		first := len(requests) == 1
+		mu.Unlock()
+
+		// reject the second document of the first request as overloaded
+		var items []string
+		for i := 0; i < len(lines)/2; i++ {
+			status := 201
+			if first && i == 1 {
+				status = 429
+			}
+			items = append(items, fmt.Sprintf(`{"index":{"status":%d}}`, status))
+		}
+		fmt.Fprintf(w, `{"errors":%t,"items":[%s]}`, first, strings.Join(items, ","))
+	}))
+	defer srv.Close()
+
+	core, err := newElasticsearchCore(ElasticsearchConfig{URL: srv.URL, Index: "test"}, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*batchCore).Close()
+
+	logger := zap.New(core)
+	logger.Info("scooby")
+	logger.Info("velma")
+
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, wanted 2", len(requests))
+	}
+	if len(requests[0]) != 4 || !strings.Contains(requests[0][0], `"_index":"test"`) {
+		t.Errorf("got %q, wanted two index actions", requests[0])
+	}
+	if len(requests[1]) != 2 || !strings.Contains(requests[1][1], "velma") {
+		t.Errorf("got %q, wanted only the rejected entry to be retried", requests[1])
+	}
+}
@@ -0,0 +1,305 @@
+package log
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// EmailConfig configures sending digests of the Error, DPanic, Panic and
+// Fatal entries logged over a window by email.
+type EmailConfig struct {
+	// Addr is the address of the SMTP server, i.e. "smtp.example.com:587".
+	// Connections to port 465 use implicit TLS, others STARTTLS when the
+	// server supports it.
+	Addr string
+
+	// Username and Password authenticate with PLAIN authentication, which
+	// is only done over TLS or to localhost.
+	Username string
+	Password string
+
+	// From and To are the sender and the recipients of the digests.
+	From string
+	To   []string
+
+	// Window is the time over which entries are collected into one digest.
+	// Defaults to 5 minutes.
+	Window time.Duration
+
+	// MaxEntries is the maximum number of entries in a digest, the number of
+	// entries beyond it being mentioned only. Defaults to 100.
+	MaxEntries int
+
+	// TLS configures TLS connections when not nil.
+	TLS *tls.Config
+}
+
+const (
+	defaultEmailWindow     = 5 * time.Minute
+	defaultEmailMaxEntries = 100
+
+	// smtpsPort is the port of SMTP over implicit TLS.
+	smtpsPort = "465"
+)
+
+// newEmailCore creates a core sending digests of error entries via SMTP.
+func newEmailCore(cfg EmailConfig, format LogFormat) (zapcore.Core, error) {
+	if cfg.Addr == "" {
+		return nil, errors.New("missing SMTP server address")
+	}
+	if cfg.From == "" || len(cfg.To) == 0 {
+		return nil, errors.New("missing sender or recipients")
+	}
+	host, port, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultEmailWindow
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultEmailMaxEntries
+	}
+	tlsConfig := cfg.TLS
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = host
+	}
+	// the format of the digests is the plaintext one, colors do not belong
+	// in emails
+	if format == FormatColorizedOutput {
+		format = FormatPlaintextOutput
+	}
+
+	d := &emailDigest{
+		cfg:      cfg,
+		host:     host,
+		implicit: port == smtpsPort,
+		tls:      tlsConfig,
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	d.hostname, _ = os.Hostname()
+	go d.loop()
+
+	return &emailCore{
+		LevelEnabler: zapcore.ErrorLevel,
+		enc:          newEncoder(format),
+		digest:       d,
+	}, nil
+}
+
+type emailCore struct {
+	zapcore.LevelEnabler
+	enc    zapcore.Encoder
+	digest *emailDigest
+}
+
+func (c *emailCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.enc = c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(clone.enc)
+	}
+	return &clone
+}
+
+func (c *emailCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *emailCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	c.digest.add(ent.Time, buf.String())
+	buf.Free()
+	return nil
+}
+
+func (c *emailCore) Sync() error {
+	return c.digest.send()
+}
+
+func (c *emailCore) Close() error {
+	return c.digest.Close()
+}
+
+// emailDigest collects entries and sends them by email at the end of every
+// window.
+type emailDigest struct {
+	cfg      EmailConfig
+	host     string
+	implicit bool
+	tls      *tls.Config
+	hostname string
+
+	mu      sync.Mutex // guards the pending digest
+	entries []string
+	omitted int
+	first   time.Time
+	last    time.Time
+
+	sendMu sync.Mutex // serializes sending digests
+
+	closing   chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (d *emailDigest) add(t time.Time, entry string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.entries) == 0 && d.omitted == 0 {
+		d.first = t
+	}
+	d.last = t
+	if len(d.entries) < d.cfg.MaxEntries {
+		d.entries = append(d.entries, entry)
+	} else {
+		d.omitted++
+	}
+}
+
+func (d *emailDigest) loop() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.cfg.Window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-d.closing:
+			return
+		}
+		if err := d.send(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to send log digest: %s\n", err)
+		}
+	}
+}
+
+// send sends the pending digest, if any.
+func (d *emailDigest) send() error {
+	d.sendMu.Lock()
+	defer d.sendMu.Unlock()
+
+	d.mu.Lock()
+	entries, omitted, first, last := d.entries, d.omitted, d.first, d.last
+	d.entries, d.omitted = nil, 0
+	d.mu.Unlock()
+
+	if len(entries) == 0 && omitted == 0 {
+		return nil
+	}
+	return d.sendMail(d.message(entries, omitted, first, last))
+}
+
+// message formats a digest as an email message.
+func (d *emailDigest) message(entries []string, omitted int, first, last time.Time) []byte {
+	count := len(entries) + omitted
+	subject := fmt.Sprintf("%d errors logged", count)
+	if d.hostname != "" {
+		subject += " on " + d.hostname
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "%d errors were logged between %s and %s.\n\n",
+		count, first.Format(time.RFC3339), last.Format(time.RFC3339))
+	for _, entry := range entries {
+		body.WriteString(entry)
+	}
+	if omitted > 0 {
+		fmt.Fprintf(&body, "\n%d more errors were omitted.\n", omitted)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", d.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(d.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body.String(), "\n", "\r\n"))
+	return msg.Bytes()
+}
+
+func (d *emailDigest) sendMail(msg []byte) error {
+	dialer := &net.Dialer{Timeout: httpTimeout}
+	var conn net.Conn
+	var err error
+	if d.implicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", d.cfg.Addr, d.tls)
+	} else {
+		conn, err = dialer.Dial("tcp", d.cfg.Addr)
+	}
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, d.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close() // nolint:errcheck
+
+	if !d.implicit {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(d.tls); err != nil {
+				return err
+			}
+		}
+	}
+	if d.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", d.cfg.Username, d.cfg.Password, d.host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(d.cfg.From); err != nil {
+		return err
+	}
+	for _, to := range d.cfg.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// Close stops sending digests at the end of every window, and sends the
+// pending one.
+func (d *emailDigest) Close() error {
+	d.closeOnce.Do(func() {
+		close(d.closing)
+	})
+	<-d.done
+	return d.send()
+}
@@ -0,0 +1,99 @@
+package log
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// serveSMTP accepts one SMTP session on l and sends the received message.
+func serveSMTP(t *testing.T, l net.Listener, messages chan<- string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP") // nolint:errcheck
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 localhost") // nolint:errcheck
+		case "MAIL", "RCPT":
+			tp.PrintfLine("250 OK") // nolint:errcheck
+		case "DATA":
+			tp.PrintfLine("354 go ahead") // nolint:errcheck
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			messages <- string(data)
+			tp.PrintfLine("250 OK") // nolint:errcheck
+		case "QUIT":
+			tp.PrintfLine("221 bye") // nolint:errcheck
+			return
+		default:
+			tp.PrintfLine("502 %s not implemented", cmd) // nolint:errcheck
+		}
+	}
+}
+
+func TestEmailCoreDigest(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	messages := make(chan string, 1)
+	go serveSMTP(t, l, messages)
+
+	core, err := newEmailCore(EmailConfig{
+		Addr:       l.Addr().String(),
+		From:       "go-log@example.com",
+		To:         []string{"ops@example.com"},
+		Window:     time.Hour,
+		MaxEntries: 2,
+	}, FormatColorizedOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*emailCore).Close()
+
+	logger := zap.New(core).Named("dht")
+	logger.Warn("not in the digest")
+	logger.Error("lookup failed", zap.Int("attempt", 1))
+	logger.Error("lookup failed", zap.Int("attempt", 2))
+	logger.Error("lookup failed", zap.Int("attempt", 3))
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	var msg string
+	select {
+	case msg = <-messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the digest")
+	}
+	r := textproto.NewReader(bufio.NewReader(strings.NewReader(msg)))
+	header, err := r.ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject := header.Get("Subject"); !strings.HasPrefix(subject, "3 errors logged") {
+		t.Errorf("got subject %q", subject)
+	}
+	if strings.Contains(msg, "not in the digest") || strings.Contains(msg, `"attempt": 3`) ||
+		!strings.Contains(msg, `{"attempt": 2}`) || !strings.Contains(msg, "1 more errors were omitted") {
+		t.Errorf("got message %q", msg)
+	}
+}
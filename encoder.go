@@ -0,0 +1,58 @@
+package log
+
+import (
+	"encoding/json"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var encoderPool = buffer.NewPool()
+
+// structuredEncoder is an encoder for the formats zap doesn't provide, which
+// renders entries from their fields decoded from JSON.
+type structuredEncoder struct {
+	// Encoder encodes the fields to a JSON object
+	zapcore.Encoder
+	render func(buf *buffer.Buffer, ent zapcore.Entry, fields map[string]interface{}) error
+}
+
+func newStructuredEncoder(render func(buf *buffer.Buffer, ent zapcore.Entry, fields map[string]interface{}) error) zapcore.Encoder {
+	return &structuredEncoder{Encoder: newFieldsEncoder(), render: render}
+}
+
+func (e *structuredEncoder) Clone() zapcore.Encoder {
+	return &structuredEncoder{Encoder: e.Encoder.Clone(), render: e.render}
+}
+
+func (e *structuredEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	data, err := e.Encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return nil, err
+	}
+	defer data.Free()
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data.Bytes(), &decoded); err != nil {
+		return nil, err
+	}
+	buf := encoderPool.Get()
+	if err := e.render(buf, ent, decoded); err != nil {
+		buf.Free()
+		return nil, err
+	}
+	return buf, nil
+}
+
+// fieldString returns the string of a decoded field value, which is JSON
+// unless it is a string.
+func fieldString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
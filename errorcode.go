@@ -0,0 +1,69 @@
+package log
+
+import "sync"
+
+// ErrorCodeKey is the key of the field of the codes of ErrorCode.
+const ErrorCodeKey = "error_code"
+
+// errorCodes is the registry of the error codes of each subsystem, mapped to
+// their description.
+var errorCodes struct {
+	sync.Mutex
+	codes map[string]map[string]string
+}
+
+// RegisterErrorCode registers an error code of a subsystem with its
+// description, so that the codes can be listed with ErrorCodes, i.e. to set
+// up alerting rules, before they are logged.
+func RegisterErrorCode(subsystem, code, description string) {
+	errorCodes.Lock()
+	defer errorCodes.Unlock()
+
+	registerErrorCode(subsystem, code)[code] = description
+}
+
+// registerErrorCode returns the codes of subsystem, code being registered.
+// errorCodes must be locked.
+func registerErrorCode(subsystem, code string) map[string]string {
+	if errorCodes.codes == nil {
+		errorCodes.codes = make(map[string]map[string]string)
+	}
+	codes, ok := errorCodes.codes[subsystem]
+	if !ok {
+		codes = make(map[string]string)
+		errorCodes.codes[subsystem] = codes
+	}
+	if _, ok := codes[code]; !ok {
+		codes[code] = ""
+	}
+	return codes
+}
+
+// ErrorCodes returns the error codes of each subsystem, registered with
+// RegisterErrorCode or logged with ErrorCode, mapped to their description,
+// empty when not registered.
+func ErrorCodes() map[string]map[string]string {
+	errorCodes.Lock()
+	defer errorCodes.Unlock()
+
+	all := make(map[string]map[string]string, len(errorCodes.codes))
+	for subsystem, codes := range errorCodes.codes {
+		all[subsystem] = make(map[string]string, len(codes))
+		for code, description := range codes {
+			all[subsystem][code] = description
+		}
+	}
+	return all
+}
+
+// ErrorCode logs a message at LevelError with key-value pairs, like Errorw,
+// and the code identifying the error in the field ErrorCodeKey, so that
+// alerting rules can match codes rather than messages. The code is
+// registered for the subsystem.
+func (logger *ZapEventLogger) ErrorCode(code, msg string, keysAndValues ...interface{}) {
+	errorCodes.Lock()
+	registerErrorCode(logger.system, code)
+	errorCodes.Unlock()
+
+	logger.skipLogger.Errorw(msg, append([]interface{}{ErrorCodeKey, code}, keysAndValues...)...)
+}
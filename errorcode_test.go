@@ -0,0 +1,29 @@
+package log
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestErrorCode(t *testing.T) {
+	capture := StartCapture(func(e Entry) bool { return e.Subsystem == "storage" })
+	defer capture.Stop()
+
+	RegisterErrorCode("storage", "E_DISK_FULL", "the disk of the datastore is full")
+	log := Logger("storage")
+	log.ErrorCode("E_CORRUPT", "corrupted block", "cid", "Qm")
+
+	entries := capture.Entries()
+	if len(entries) != 1 || entries[0].Level != LevelError || entries[0].Fields[ErrorCodeKey] != "E_CORRUPT" || entries[0].Fields["cid"] != "Qm" {
+		t.Fatalf("got %v, wanted the error with its code", entries)
+	}
+	if !strings.Contains(entries[0].Caller, "errorcode_test.go") {
+		t.Errorf("got caller %s, wanted the test", entries[0].Caller)
+	}
+
+	want := map[string]string{"E_DISK_FULL": "the disk of the datastore is full", "E_CORRUPT": ""}
+	if codes := ErrorCodes()["storage"]; !reflect.DeepEqual(codes, want) {
+		t.Errorf("got %v, wanted %v", codes, want)
+	}
+}
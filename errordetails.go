@@ -0,0 +1,123 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// WithError returns a child logger adding err to all its entries as the
+// "error" field, which is expanded with Config.ErrorDetails.
+func (logger *ZapEventLogger) WithError(err error) *ZapEventLogger {
+	return logger.With(zap.Object("error", errorDetails{err}))
+}
+
+// errorDetails marshals an error to its message, type, the causes it wraps
+// and the stack trace of the innermost error with one, for
+// Config.ErrorDetails.
+type errorDetails struct {
+	err error
+}
+
+func (d errorDetails) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("message", d.err.Error())
+	enc.AddString("type", fmt.Sprintf("%T", d.err))
+
+	var causes []error
+	for cause := errors.Unwrap(d.err); cause != nil; cause = errors.Unwrap(cause) {
+		causes = append(causes, cause)
+	}
+	if len(causes) > 0 {
+		_ = enc.AddArray("causes", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+			for _, cause := range causes {
+				_ = arr.AppendObject(errorCause{cause})
+			}
+			return nil
+		}))
+	}
+
+	// the stack trace of the innermost error is the closest to its origin
+	for i := len(causes) - 1; i >= -1; i-- {
+		err := d.err
+		if i >= 0 {
+			err = causes[i]
+		}
+		if stack := errorStackTrace(err); stack != "" {
+			enc.AddString("stacktrace", stack)
+			break
+		}
+	}
+	return nil
+}
+
+// errorCause marshals an error wrapped by an errorDetails.
+type errorCause struct {
+	err error
+}
+
+func (c errorCause) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("message", c.err.Error())
+	enc.AddString("type", fmt.Sprintf("%T", c.err))
+	return nil
+}
+
+// errorStackTrace returns the stack trace of the errors printing it after
+// their message with %+v, like the errors of github.com/pkg/errors, or "".
+func errorStackTrace(err error) string {
+	if _, ok := err.(fmt.Formatter); !ok {
+		return ""
+	}
+	msg := err.Error()
+	verbose := fmt.Sprintf("%+v", err)
+	if verbose == msg || !strings.HasPrefix(verbose, msg) {
+		return ""
+	}
+	return strings.TrimLeft(verbose[len(msg):], "\n")
+}
+
+// errorEncoder writes the error fields as errorDetails objects with
+// Config.ErrorDetails, and as their message otherwise.
+type errorEncoder struct {
+	zapcore.Encoder
+	details bool
+}
+
+func (e *errorEncoder) Clone() zapcore.Encoder {
+	return &errorEncoder{e.Encoder.Clone(), e.details}
+}
+
+func (e *errorEncoder) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	// the fields of loggers
+	if d, ok := marshaler.(errorDetails); ok && !e.details {
+		zap.NamedError(key, d.err).AddTo(e.Encoder)
+		return nil
+	}
+	return e.Encoder.AddObject(key, marshaler)
+}
+
+func (e *errorEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	copied := false
+	for i, f := range fields {
+		err, isError := f.Interface.(error)
+		d, isDetails := f.Interface.(errorDetails)
+		var replaced zapcore.Field
+		switch {
+		case f.Type == zapcore.ErrorType && isError && e.details:
+			replaced = zap.Object(f.Key, errorDetails{err})
+		case f.Type == zapcore.ObjectMarshalerType && isDetails && !e.details:
+			replaced = zap.NamedError(f.Key, d.err)
+		default:
+			continue
+		}
+		if !copied {
+			fields = append([]zapcore.Field(nil), fields...)
+			copied = true
+		}
+		fields[i] = replaced
+	}
+	return e.Encoder.EncodeEntry(ent, fields)
+}
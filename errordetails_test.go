@@ -0,0 +1,79 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stackError prints a stack trace with %+v, like the errors of
+// github.com/pkg/errors.
+type stackError struct{ msg string }
+
+func (e *stackError) Error() string { return e.msg }
+
+func (e *stackError) Format(s fmt.State, verb rune) {
+	_, _ = fmt.Fprint(s, e.msg)
+	if s.Flag('+') {
+		_, _ = fmt.Fprint(s, "\nmain.main\n\tmain.go:3")
+	}
+}
+
+func TestErrorDetails(t *testing.T) {
+	err := fmt.Errorf("dialing: %w", &stackError{"refused"})
+
+	for _, details := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "app.log")
+		SetupLogging(Config{Level: LevelError, Format: FormatJSONOutput, File: path, ErrorDetails: details})
+
+		log := Logger("errors")
+		log.WithError(err).Error("scooby")
+		log.Errorw("doo", "err", errors.New("where are you"))
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		var entries [2]map[string]interface{}
+		for i := range entries {
+			if err := json.Unmarshal([]byte(lines[i]), &entries[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !details {
+			if entries[0]["error"] != "dialing: refused" || entries[1]["err"] != "where are you" {
+				t.Errorf("got %s, wanted the error messages", data)
+			}
+			continue
+		}
+
+		want := map[string]interface{}{
+			"message": "dialing: refused",
+			"type":    "*fmt.wrapError",
+			"causes": []interface{}{
+				map[string]interface{}{"message": "refused", "type": "*log.stackError"},
+			},
+			"stacktrace": "main.main\n\tmain.go:3",
+		}
+		if got, _ := json.Marshal(entries[0]["error"]); string(got) != mustJSON(t, want) {
+			t.Errorf("got %s, wanted %s", got, mustJSON(t, want))
+		}
+		if got := entries[1]["err"].(map[string]interface{}); got["message"] != "where are you" || got["type"] != "*errors.errorString" {
+			t.Errorf("got %v, wanted the error details", got)
+		}
+	}
+	SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,70 @@
+package log
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// everyKey identifies a statement logging with Every or Once.
+type everyKey struct {
+	system string
+	pc     uintptr
+}
+
+// everyLast holds the time in Unix nanoseconds, as an *int64, the statements
+// logging with Every or Once last logged at.
+var everyLast sync.Map
+
+// nopEventLogger is the logger returned by Every and Once when the entries
+// are not logged.
+var nopEventLogger = &ZapEventLogger{
+	SugaredLogger: *zap.NewNop().Sugar(),
+	skipLogger:    *zap.NewNop().Sugar(),
+}
+
+// Every returns the logger when the statement calling it has not logged
+// within interval, and otherwise a logger discarding the entries, so that hot
+// paths can log periodic reminders:
+//
+//	log.Every(time.Minute).Warn("peer table is full")
+//
+// The interval applies to the statement and the subsystem, Every being
+// called in the statement logging. Fatal entries still exit.
+func (logger *ZapEventLogger) Every(interval time.Duration) *ZapEventLogger {
+	pc, _, _, _ := runtime.Caller(1)
+	if logger.due(pc, interval) {
+		return logger
+	}
+	return nopEventLogger
+}
+
+// Once returns the logger the first time the statement calling it logs, and
+// otherwise a logger discarding the entries, like Every.
+//
+//	log.Once().Error("deprecated option, ignoring it")
+func (logger *ZapEventLogger) Once() *ZapEventLogger {
+	pc, _, _, _ := runtime.Caller(1)
+	if logger.due(pc, -1) {
+		return logger
+	}
+	return nopEventLogger
+}
+
+// due reports whether the statement at pc logs, i.e. whether it did not log
+// within interval, or never when interval is negative.
+func (logger *ZapEventLogger) due(pc uintptr, interval time.Duration) bool {
+	v, _ := everyLast.LoadOrStore(everyKey{system: logger.system, pc: pc}, new(int64))
+	last := v.(*int64)
+
+	now := time.Now().UnixNano()
+	prev := atomic.LoadInt64(last)
+	if prev != 0 && (interval < 0 || now-prev < int64(interval)) {
+		return false
+	}
+	// only one of the goroutines logging at once logs
+	return atomic.CompareAndSwapInt64(last, prev, now)
+}
@@ -0,0 +1,36 @@
+package log
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// everyRuns makes the subsystem of TestEvery unique, as Once logs once per
+// subsystem for the lifetime of the process, i.e. with -count.
+var everyRuns int32
+
+func TestEvery(t *testing.T) {
+	name := fmt.Sprintf("every%d", atomic.AddInt32(&everyRuns, 1))
+	capture := StartCapture(func(e Entry) bool { return e.Subsystem == name })
+	defer capture.Stop()
+
+	log := Logger(name)
+	for i := 0; i < 3; i++ {
+		log.Every(time.Hour).Error("hourly")
+		log.Every(20 * time.Millisecond).Error("frequent")
+		log.Once().Error("once")
+		log.Once().Error("once again")
+		time.Sleep(30 * time.Millisecond)
+	}
+
+	for msg, want := range map[string]int{"hourly": 1, "frequent": 3, "once": 1, "once again": 1} {
+		if n := len(capture.FilterMessage(msg)); n != want {
+			t.Errorf("got %d %q entries, wanted %d", n, msg, want)
+		}
+	}
+	if entries := capture.FilterMessage("hourly"); len(entries) == 1 && entries[0].Caller == "" {
+		t.Error("expected the caller of the entry")
+	}
+}
@@ -0,0 +1,118 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// The behaviors of Config.FatalBehavior.
+const (
+	// FatalExit exits the process with status 1 after Fatal entries.
+	FatalExit = "exit"
+	// FatalPanic panics with the message after Fatal entries, so that tests
+	// can recover from them.
+	FatalPanic = "panic"
+	// FatalLog returns from Fatal like from Error.
+	FatalLog = "log"
+)
+
+// fatalBehavior holds the behavior of Fatal entries set by SetupLogging.
+var fatalBehavior atomic.Value
+
+// setFatalBehavior sets the behavior of Fatal entries of a
+// Config.FatalBehavior.
+func setFatalBehavior(behavior string) {
+	switch behavior {
+	case "":
+		behavior = FatalExit
+	case FatalExit, FatalPanic, FatalLog:
+	default:
+		fmt.Fprintf(os.Stderr, "unrecognized fatal behavior '%s', exiting on fatal entries\n", behavior)
+		behavior = FatalExit
+	}
+	fatalBehavior.Store(behavior)
+}
+
+var fatalHooks struct {
+	sync.Mutex
+	hooks []*func(Entry)
+}
+
+// OnFatal registers hook to be called with the Fatal entries, once written to
+// the outputs and before exiting or panicking, i.e. to flush buffers or clean
+// up. Hooks are called in the order they were registered.
+//
+// cancel unregisters hook.
+func OnFatal(hook func(Entry)) (cancel func()) {
+	fatalHooks.Lock()
+	defer fatalHooks.Unlock()
+	p := &hook
+	fatalHooks.hooks = append(fatalHooks.hooks, p)
+
+	return func() {
+		fatalHooks.Lock()
+		defer fatalHooks.Unlock()
+		for i, h := range fatalHooks.hooks {
+			if h == p {
+				fatalHooks.hooks = append(fatalHooks.hooks[:i:i], fatalHooks.hooks[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Fatal logs a message at LevelFatal, like Error, and then behaves as set by
+// Config.FatalBehavior, after calling the OnFatal hooks.
+func (logger *ZapEventLogger) Fatal(args ...interface{}) {
+	logger.fatal(fmt.Sprint(args...), nil)
+}
+
+// Fatalf logs a message at LevelFatal, like Errorf, and then behaves as set
+// by Config.FatalBehavior.
+func (logger *ZapEventLogger) Fatalf(template string, args ...interface{}) {
+	logger.fatal(fmt.Sprintf(template, args...), nil)
+}
+
+// Fatalw logs a message at LevelFatal with key-value pairs, like Errorw, and
+// then behaves as set by Config.FatalBehavior.
+func (logger *ZapEventLogger) Fatalw(msg string, keysAndValues ...interface{}) {
+	logger.fatal(msg, keysAndValues)
+}
+
+// fatal logs msg at LevelFatal, writing the entry to the cores directly since
+// the zap loggers always exit after Fatal entries.
+func (logger *ZapEventLogger) fatal(msg string, keysAndValues []interface{}) {
+	// the entry of zap has the caller and stack trace of the options of the
+	// logger, the caller being the caller of the Fatal methods, but it is
+	// not written since zap would exit
+	base := logger.skipLogger.Desugar().WithOptions(zap.AddCallerSkip(1))
+	ent := base.Check(zapcore.FatalLevel, msg).Entry
+
+	fields := sweetenFields(keysAndValues)
+	if ce := logger.Desugar().Core().Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+
+	e := entryOf(ent, logger.fields, fields)
+	fatalHooks.Lock()
+	hooks := make([]*func(Entry), len(fatalHooks.hooks))
+	copy(hooks, fatalHooks.hooks)
+	fatalHooks.Unlock()
+	for _, hook := range hooks {
+		(*hook)(e)
+	}
+
+	switch fatalBehavior.Load() {
+	case FatalPanic:
+		panic(msg)
+	case FatalLog:
+	default:
+		_ = Sync()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,49 @@
+package log
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFatalBehavior(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelError, Format: FormatJSONOutput, File: path, FatalBehavior: FatalPanic})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	var hooked []Entry
+	cancel := OnFatal(func(e Entry) {
+		hooked = append(hooked, e)
+	})
+	log := Logger("fatal").With("peer", "Qm")
+	func() {
+		defer func() {
+			if r := recover(); r != "scooby" {
+				t.Errorf("recovered %v, wanted the message", r)
+			}
+		}()
+		log.Fatalw("scooby", "id", 3)
+	}()
+	if len(hooked) != 1 || hooked[0].Fields["peer"] != "Qm" || hooked[0].Fields["id"] != int64(3) {
+		t.Fatalf("got %v, wanted the fatal entry", hooked)
+	}
+	if !strings.Contains(hooked[0].Caller, "fatal_test.go") {
+		t.Errorf("got caller %s, wanted the test", hooked[0].Caller)
+	}
+
+	cancel()
+	SetupLogging(Config{Level: LevelError, Format: FormatJSONOutput, File: path, FatalBehavior: FatalLog})
+	log.Fatalf("doo %d", 3)
+	if len(hooked) != 1 {
+		t.Errorf("got %d entries, wanted the hook cancelled", len(hooked))
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"level":"fatal"`) || !strings.Contains(string(data), `"msg":"doo 3"`) {
+		t.Errorf("got %s, wanted the fatal entries", data)
+	}
+}
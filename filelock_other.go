@@ -0,0 +1,19 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly && !windows
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly,!windows
+
+package log
+
+import (
+	"errors"
+	"os"
+)
+
+// lockFile fails, files can't be locked on this platform.
+func lockFile(file *os.File) error {
+	return errors.New("file locking is not supported on this platform")
+}
+
+// unlockFile fails, files can't be locked on this platform.
+func unlockFile(file *os.File) error {
+	return errors.New("file locking is not supported on this platform")
+}
@@ -0,0 +1,37 @@
+//go:build windows
+// +build windows
+
+package log
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procLockFileEx   = syscall.NewLazyDLL("kernel32.dll").NewProc("LockFileEx")
+	procUnlockFileEx = syscall.NewLazyDLL("kernel32.dll").NewProc("UnlockFileEx")
+)
+
+const lockfileExclusiveLock = 0x2
+
+// lockFile blocks until it acquires an exclusive lock on all of file.
+func lockFile(file *os.File) error {
+	var ol syscall.Overlapped
+	r, _, err := procLockFileEx.Call(file.Fd(), lockfileExclusiveLock, 0, 0xffffffff, 0xffffffff, uintptr(unsafe.Pointer(&ol)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+// unlockFile releases the lock acquired on file by lockFile.
+func unlockFile(file *os.File) error {
+	var ol syscall.Overlapped
+	r, _, err := procUnlockFileEx.Call(file.Fd(), 0, 0xffffffff, 0xffffffff, uintptr(unsafe.Pointer(&ol)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
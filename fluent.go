@@ -0,0 +1,200 @@
+package log
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// fluentTimeout bounds connecting, writing and waiting for acknowledgements.
+const fluentTimeout = 10 * time.Second
+
+// newFluentCore creates a core sending entries to Fluentd or Fluent Bit using
+// the forward protocol. It is configured by a URL of the form
+//
+//	fluent://host:24224?tag=myapp&ack=true
+//
+// Entries are tagged with the tag parameter followed by the subsystem name,
+// i.e. "myapp.dht". When ack is true, every chunk of entries must be
+// acknowledged by the server, which guarantees at-least-once delivery.
+func newFluentCore(u *url.URL, level LogLevel) (zapcore.Core, error) {
+	if u.Host == "" {
+		return nil, errors.New("missing Fluentd address")
+	}
+
+	f := &fluentClient{
+		address: u.Host,
+		prefix:  u.Query().Get("tag"),
+	}
+	if ack := u.Query().Get("ack"); ack != "" {
+		var err error
+		if f.ack, err = strconv.ParseBool(ack); err != nil {
+			return nil, fmt.Errorf("invalid ack parameter %q", ack)
+		}
+	}
+
+	// the time is sent next to every record
+	encCfg := newEncoderConfig()
+	encCfg.TimeKey = ""
+
+	return &batchCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          zapcore.NewJSONEncoder(encCfg),
+		batcher:      newBatcher(0, 0, f.forward),
+		close:        f.Close,
+	}, nil
+}
+
+type fluentClient struct {
+	address string
+	prefix  string
+	ack     bool
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// tag returns the tag of entries logged by subsystem.
+func (f *fluentClient) tag(subsystem string) string {
+	switch {
+	case f.prefix == "" && subsystem == "":
+		return "golog"
+	case f.prefix == "":
+		return subsystem
+	case subsystem == "":
+		return f.prefix
+	default:
+		return f.prefix + "." + subsystem
+	}
+}
+
+// forward sends batch using the forward mode, one message per tag.
+func (f *fluentClient) forward(batch []batchEntry) error {
+	entries := make(map[string][]byte)
+	counts := make(map[string]int)
+	var tags []string
+	for _, e := range batch {
+		var record map[string]interface{}
+		if err := json.Unmarshal(e.data, &record); err != nil {
+			return err
+		}
+
+		tag := f.tag(e.ent.LoggerName)
+		if _, ok := entries[tag]; !ok {
+			tags = append(tags, tag)
+		}
+		entry := appendMsgpackArrayHeader(entries[tag], 2)
+		entry = appendFluentEventTime(entry, e.ent.Time)
+		entries[tag] = appendMsgpackValue(entry, record)
+		counts[tag]++
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, tag := range tags {
+		msg := appendMsgpackArrayHeader(nil, 3)
+		msg = appendMsgpackString(msg, tag)
+		msg = appendMsgpackArrayHeader(msg, counts[tag])
+		msg = append(msg, entries[tag]...)
+
+		var chunk string
+		if f.ack {
+			id := make([]byte, 16)
+			if _, err := rand.Read(id); err != nil {
+				return err
+			}
+			chunk = base64.StdEncoding.EncodeToString(id)
+			msg = appendMsgpackValue(msg, map[string]interface{}{"chunk": chunk})
+		} else {
+			msg = appendMsgpackMapHeader(msg, 0)
+		}
+
+		if err := f.send(msg, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendFluentEventTime appends t as a forward protocol EventTime.
+func appendFluentEventTime(b []byte, t time.Time) []byte {
+	data := appendUint32(nil, uint32(t.Unix()))
+	data = appendUint32(data, uint32(t.Nanosecond()))
+	return appendMsgpackExt(b, 0, data)
+}
+
+// send writes msg, reconnecting once if the connection was lost, and waits
+// for the acknowledgement of chunk if not empty.
+func (f *fluentClient) send(msg []byte, chunk string) error {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if f.conn == nil {
+			if f.conn, err = net.DialTimeout("tcp", f.address, fluentTimeout); err != nil {
+				return err
+			}
+		}
+		if err = f.roundTrip(msg, chunk); err == nil {
+			return nil
+		}
+		f.conn.Close() // nolint:errcheck
+		f.conn = nil
+	}
+	return err
+}
+
+func (f *fluentClient) roundTrip(msg []byte, chunk string) error {
+	if err := f.conn.SetDeadline(time.Now().Add(fluentTimeout)); err != nil {
+		return err
+	}
+	if _, err := f.conn.Write(msg); err != nil {
+		return err
+	}
+	if chunk == "" {
+		return nil
+	}
+
+	var resp []byte
+	buf := make([]byte, 256)
+	for {
+		n, err := f.conn.Read(buf)
+		if err != nil {
+			return err
+		}
+		resp = append(resp, buf[:n]...)
+
+		ack, err := readMsgpackStringMap(resp)
+		if err == errMsgpackShort {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if ack["ack"] != chunk {
+			return fmt.Errorf("unexpected acknowledgement %q", ack["ack"])
+		}
+		return nil
+	}
+}
+
+func (f *fluentClient) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.conn == nil {
+		return nil
+	}
+	err := f.conn.Close()
+	f.conn = nil
+	return err
+}
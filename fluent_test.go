@@ -0,0 +1,69 @@
+package log
+
+import (
+	"bytes"
+	"net"
+	"net/url"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestFluentCoreAck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var msg []byte
+		buf := make([]byte, 4096)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			msg = append(msg, buf[:n]...)
+
+			i := bytes.Index(msg, appendMsgpackString(nil, "chunk"))
+			if i < 0 {
+				continue
+			}
+			chunk, _, err := readMsgpackString(msg[i+len("chunk")+1:])
+			if err != nil {
+				continue
+			}
+			conn.Write(appendMsgpackValue(nil, map[string]interface{}{"ack": chunk}))
+			received <- msg
+			return
+		}
+	}()
+
+	u, _ := url.Parse("fluent://" + l.Addr().String() + "?tag=myapp&ack=true")
+	core, err := newFluentCore(u, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*batchCore).Close()
+
+	zap.New(core).Named("dht").Info("scooby")
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-received
+	if !bytes.Contains(msg, appendMsgpackString(nil, "myapp.dht")) {
+		t.Errorf("got %q, wanted it to contain the tag", msg)
+	}
+	if !bytes.Contains(msg, appendMsgpackString(nil, "scooby")) {
+		t.Errorf("got %q, wanted it to contain the message", msg)
+	}
+}
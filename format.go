@@ -1,9 +1,137 @@
 package log
 
+import (
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
 type LogFormat int
 
 const (
 	FormatColorizedOutput LogFormat = iota
 	FormatPlaintextOutput
 	FormatJSONOutput
+
+	// FormatGCPOutput is JSON using the keys and severities expected by
+	// Google Cloud Logging, so that logs written to stdout on GKE, Cloud Run
+	// or Cloud Functions are ingested with the correct severity. Labels are
+	// written to "logging.googleapis.com/labels", and callers to
+	// "logging.googleapis.com/sourceLocation".
+	FormatGCPOutput
+
+	// FormatRFC5424Output writes entries as RFC5424 syslog messages, with
+	// their fields as structured data, so that files and streams can be
+	// relayed to syslog servers as is.
+	FormatRFC5424Output
+
+	// FormatCEFOutput writes entries as ArcSight Common Event Format events,
+	// for SIEMs to ingest them without translation.
+	FormatCEFOutput
+
+	// FormatECSOutput is JSON using the keys of the Elastic Common Schema,
+	// for Filebeat and Elasticsearch to ingest it without pipelines.
+	FormatECSOutput
+
+	// FormatMsgpackOutput writes entries as MessagePack maps with the keys
+	// of FormatJSONOutput, concatenated without separators, times being
+	// timestamp extensions and durations nanoseconds.
+	FormatMsgpackOutput
+
+	// FormatCBOROutput writes entries as CBOR maps with the keys of
+	// FormatJSONOutput, concatenated as a CBOR sequence, times being tagged
+	// epoch times and durations nanoseconds.
+	FormatCBOROutput
+
+	// FormatCBORDeterministicOutput is FormatCBOROutput encoded
+	// deterministically, for entries to be hashed reproducibly: map keys are
+	// sorted, the last field with a key replacing the others, and entries are
+	// valid DAG-CBOR, times being RFC3339 strings in UTC.
+	FormatCBORDeterministicOutput
+
+	// FormatProtobufOutput writes entries as Entry messages of the protobuf
+	// schema of entry.proto, each prefixed with its length as a varint.
+	FormatProtobufOutput
+
+	// FormatJSONPrettyOutput is FormatJSONOutput indented over several lines,
+	// with colored keys, for reading structured entries in a terminal.
+	FormatJSONPrettyOutput
 )
+
+// firstCustomFormat is the format of the first format registered with
+// RegisterFormat.
+const firstCustomFormat LogFormat = 1 << 16
+
+var (
+	formatsMu sync.RWMutex
+
+	// formatNames are the formats by name, as set with GOLOG_LOG_FMT
+	formatNames = map[string]LogFormat{
+		"color":              FormatColorizedOutput,
+		"nocolor":            FormatPlaintextOutput,
+		"json":               FormatJSONOutput,
+		"gcp":                FormatGCPOutput,
+		"rfc5424":            FormatRFC5424Output,
+		"cef":                FormatCEFOutput,
+		"ecs":                FormatECSOutput,
+		"msgpack":            FormatMsgpackOutput,
+		"cbor":               FormatCBOROutput,
+		"cbor-deterministic": FormatCBORDeterministicOutput,
+		"protobuf":           FormatProtobufOutput,
+		"json-pretty":        FormatJSONPrettyOutput,
+	}
+
+	// customFormats are the encoder factories of the formats registered
+	// with RegisterFormat
+	customFormats = make(map[LogFormat]func(zapcore.EncoderConfig) zapcore.Encoder)
+)
+
+// RegisterFormat registers a factory creating the encoders of a custom
+// format, which can then be selected with GOLOG_LOG_FMT=name or by setting
+// Config.Format to the returned format. The factory is given the encoder
+// config of the built-in formats.
+//
+// The logging set up from the environment when this package is initialized
+// does not wait for the formats of the application: when GOLOG_LOG_FMT names
+// an unknown format, the logging is set up again once RegisterFormat is
+// called for it.
+func RegisterFormat(name string, factory func(zapcore.EncoderConfig) zapcore.Encoder) (LogFormat, error) {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+
+	formatsMu.Lock()
+	if _, ok := formatNames[name]; ok {
+		formatsMu.Unlock()
+		return 0, fmt.Errorf("log format %q is already registered", name)
+	}
+	format := firstCustomFormat + LogFormat(len(customFormats))
+	formatNames[name] = format
+	customFormats[format] = factory
+	formatsMu.Unlock()
+
+	if pendingFormatConfig != nil && pendingFormatName == name {
+		cfg := *pendingFormatConfig
+		cfg.Format = format
+		setupLogging(cfg)
+	}
+	return format, nil
+}
+
+// formatFromString returns the format named name.
+func formatFromString(name string) (LogFormat, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	format, ok := formatNames[name]
+	return format, ok
+}
+
+// customFormat returns the encoder factory of a format registered with
+// RegisterFormat, if format is one.
+func customFormat(format LogFormat) func(zapcore.EncoderConfig) zapcore.Encoder {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	return customFormats[format]
+}
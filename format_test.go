@@ -0,0 +1,137 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// testFormats makes the formats registered by the tests unique, as a format
+// cannot be registered twice, i.e. with -count.
+var testFormats int32
+
+func TestRegisterFormat(t *testing.T) {
+	name := fmt.Sprintf("test-console%d", atomic.AddInt32(&testFormats, 1))
+	// a console encoder with the level and message only
+	factory := func(cfg zapcore.EncoderConfig) zapcore.Encoder {
+		cfg.TimeKey = ""
+		cfg.NameKey = ""
+		cfg.CallerKey = ""
+		cfg.EncodeLevel = zapcore.CapitalLevelEncoder
+		return zapcore.NewConsoleEncoder(cfg)
+	}
+	format, err := RegisterFormat(name, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RegisterFormat(name, factory); err == nil {
+		t.Error("expected an error registering a format twice")
+	}
+	if _, err := RegisterFormat("json", factory); err == nil {
+		t.Error("expected an error registering a built-in format")
+	}
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelInfo, Format: format, File: path})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+	getLogger("format").Error("scooby")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "ERROR\tscooby" {
+		t.Errorf("got %q, wanted the custom format", got)
+	}
+}
+
+func TestRegisterFormatPending(t *testing.T) {
+	name := fmt.Sprintf("test-pending%d", atomic.AddInt32(&testFormats, 1))
+	path := filepath.Join(t.TempDir(), "app.log")
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	// as if GOLOG_LOG_FMT named the format when this package was initialized
+	cfg := Config{Level: LevelInfo, Format: FormatPlaintextOutput, File: path}
+	SetupLogging(cfg)
+	loggerMutex.Lock()
+	pendingFormatName = name
+	pendingFormatConfig = &cfg
+	loggerMutex.Unlock()
+
+	if _, err := RegisterFormat(name, func(cfg zapcore.EncoderConfig) zapcore.Encoder {
+		return zapcore.NewJSONEncoder(cfg)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	getLogger("format").Error("scooby")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "{") {
+		t.Errorf("got %q, wanted the registered format", data)
+	}
+}
+
+func TestTimeFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelInfo, Format: FormatJSONOutput, File: path, TimeFormat: "epoch_millis"})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+	getLogger("format").Error("scooby")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if ts, ok := entry["ts"].(float64); !ok || ts < 1e12 {
+		t.Errorf("got ts %v, wanted milliseconds since the epoch", entry["ts"])
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	enc.AddArray("t", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		timeEncoderOf("2006/01/02")(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), arr)
+		return nil
+	}))
+	if got := enc.Fields["t"].([]interface{})[0]; got != "2024/05/01" {
+		t.Errorf("got %v, wanted the time with the custom layout", got)
+	}
+}
+
+func TestJSONKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{
+		Level:    LevelInfo,
+		Format:   FormatJSONOutput,
+		File:     path,
+		JSONKeys: map[string]string{"ts": "time", "level": "severity", "logger": "component", "caller": ""},
+	})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+	getLogger("format").Error("scooby")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["time"] == nil || entry["severity"] != "error" || entry["component"] != "format" || entry["msg"] != "scooby" {
+		t.Errorf("got %s, wanted the renamed keys", data)
+	}
+	if entry["ts"] != nil || entry["caller"] != nil {
+		t.Errorf("got %s, wanted the default keys replaced and the caller omitted", data)
+	}
+}
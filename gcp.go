@@ -0,0 +1,280 @@
+package log
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// This file implements the parts of the Google Cloud client libraries needed
+// by the Google Cloud outputs: severities and access tokens lookup.
+
+// gcpSeverity maps a log level to a Cloud Logging severity.
+func gcpSeverity(lvl zapcore.Level) string {
+	switch lvl {
+	case zapcore.Level(LevelTrace), zapcore.DebugLevel:
+		return "DEBUG"
+	case zapcore.InfoLevel:
+		return "INFO"
+	case zapcore.WarnLevel:
+		return "WARNING"
+	case zapcore.ErrorLevel:
+		return "ERROR"
+	case zapcore.DPanicLevel:
+		return "CRITICAL"
+	case zapcore.PanicLevel:
+		return "ALERT"
+	case zapcore.FatalLevel:
+		return "EMERGENCY"
+	default:
+		return "DEFAULT"
+	}
+}
+
+// gcpSeverityEncoder encodes levels as Cloud Logging severities.
+func gcpSeverityEncoder(lvl zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(gcpSeverity(lvl))
+}
+
+const (
+	// gcpLabelsKey and gcpSourceLocationKey are the keys of the labels and
+	// of the caller of structured logs read by Cloud Logging.
+	gcpLabelsKey         = "logging.googleapis.com/labels"
+	gcpSourceLocationKey = "logging.googleapis.com/sourceLocation"
+)
+
+// gcpLabels are the labels of entries, encoded as an object of strings.
+type gcpLabels map[string]string
+
+func (l gcpLabels) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for k, v := range l {
+		enc.AddString(k, v)
+	}
+	return nil
+}
+
+// gcpEncoder moves the caller of entries to their source location.
+type gcpEncoder struct {
+	zapcore.Encoder
+}
+
+func (e *gcpEncoder) Clone() zapcore.Encoder {
+	return &gcpEncoder{e.Encoder.Clone()}
+}
+
+func (e *gcpEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	if ent.Caller.Defined {
+		caller := ent.Caller
+		fields = append(fields[:len(fields):len(fields)], zap.Object(gcpSourceLocationKey, zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			if caller.File != "" {
+				enc.AddString("file", caller.File)
+				// the line is an int64, which is a string in JSON
+				enc.AddString("line", strconv.Itoa(caller.Line))
+			}
+			if caller.Function != "" {
+				enc.AddString("function", caller.Function)
+			}
+			return nil
+		})))
+	}
+	return e.Encoder.EncodeEntry(ent, fields)
+}
+
+const (
+	gcpMetadataEndpoint = "http://metadata.google.internal/computeMetadata/v1"
+	gcpLoggingScope     = "https://www.googleapis.com/auth/logging.write"
+)
+
+// gcpTokenSource provides OAuth2 access tokens, using the service account
+// key file when given and the metadata server otherwise, which covers GCE,
+// GKE, Cloud Run and Cloud Functions. Tokens are cached until shortly before
+// they expire.
+type gcpTokenSource struct {
+	key    *gcpServiceAccountKey
+	scope  string
+	client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+type gcpServiceAccountKey struct {
+	Type        string `json:"type"`
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	signer *rsa.PrivateKey
+}
+
+// newGCPTokenSource creates a token source for scope, reading the service
+// account key from credentialsFile, or from GOOGLE_APPLICATION_CREDENTIALS
+// when empty.
+func newGCPTokenSource(credentialsFile, scope string) (*gcpTokenSource, error) {
+	ts := &gcpTokenSource{
+		scope:  scope,
+		client: &http.Client{Timeout: httpTimeout},
+	}
+
+	if credentialsFile == "" {
+		credentialsFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if credentialsFile == "" {
+		return ts, nil
+	}
+
+	data, err := ioutil.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	key := &gcpServiceAccountKey{}
+	if err := json.Unmarshal(data, key); err != nil {
+		return nil, fmt.Errorf("invalid credentials file: %s", err)
+	}
+	if key.Type != "service_account" {
+		return nil, fmt.Errorf("unsupported credentials type %q", key.Type)
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, errors.New("invalid private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %s", err)
+	}
+	signer, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	key.signer = signer
+	ts.key = key
+
+	return ts, nil
+}
+
+// projectID returns the project of the service account or of the instance.
+func (ts *gcpTokenSource) projectID() (string, error) {
+	if ts.key != nil && ts.key.ProjectID != "" {
+		return ts.key.ProjectID, nil
+	}
+	id, err := ts.metadata("/project/project-id")
+	return string(id), err
+}
+
+func (ts *gcpTokenSource) accessToken() (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token != "" && time.Now().Add(time.Minute).Before(ts.expires) {
+		return ts.token, nil
+	}
+
+	var data []byte
+	var err error
+	if ts.key != nil {
+		data, err = ts.exchangeJWT()
+	} else {
+		data, err = ts.metadata("/instance/service-accounts/default/token?scopes=" + url.QueryEscape(ts.scope))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get an access token: %s", err)
+	}
+
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", err
+	}
+	ts.token = resp.AccessToken
+	ts.expires = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	return ts.token, nil
+}
+
+// exchangeJWT exchanges a JWT signed with the service account key for an
+// access token.
+func (ts *gcpTokenSource) exchangeJWT() ([]byte, error) {
+	now := time.Now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   ts.key.ClientEmail,
+		"scope": ts.scope,
+		"aud":   ts.key.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, ts.key.signer, crypto.SHA256, hash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)},
+	}
+	resp, err := ts.client.PostForm(ts.key.TokenURI, form)
+	if err != nil {
+		return nil, err
+	}
+	return readGCPResponse(resp)
+}
+
+func (ts *gcpTokenSource) metadata(path string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, gcpMetadataEndpoint+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := readGCPResponse(resp)
+	return []byte(strings.TrimSpace(string(data))), err
+}
+
+func readGCPResponse(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
@@ -3,7 +3,17 @@ module github.com/jianbo-zh/go-log
 go 1.16
 
 require (
+	github.com/BurntSushi/toml v1.2.1
+	github.com/go-logr/logr v1.2.3
 	github.com/mattn/go-isatty v0.0.14
+	github.com/segmentio/kafka-go v0.4.47
+	github.com/sirupsen/logrus v1.8.1
+	go.opentelemetry.io/otel/trace v1.7.0
+	go.opentelemetry.io/proto/otlp v0.19.0
 	go.uber.org/multierr v1.7.0
 	go.uber.org/zap v1.19.1
+	golang.org/x/net v0.17.0
+	google.golang.org/grpc v1.42.0
+	google.golang.org/protobuf v1.27.1
+	gopkg.in/yaml.v3 v3.0.1
 )
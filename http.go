@@ -0,0 +1,77 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// httpTimeout bounds every request made by the HTTP based outputs.
+const httpTimeout = 10 * time.Second
+
+// newHTTPClient returns a client for the HTTP based outputs, using tlsConfig
+// for HTTPS connections when not nil.
+func newHTTPClient(tlsConfig *tls.Config) *http.Client {
+	if tlsConfig == nil {
+		return &http.Client{Timeout: httpTimeout}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Timeout: httpTimeout, Transport: transport}
+}
+
+// isRetryableHTTPError reports whether a request failing with err is worth
+// retrying: network errors, throttling and server errors.
+func isRetryableHTTPError(err error) bool {
+	statusErr, ok := err.(*httpStatusError)
+	if !ok {
+		return true
+	}
+	return statusErr.code == http.StatusTooManyRequests || statusErr.code >= 500
+}
+
+// gzipBytes compresses data with gzip.
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// httpStatusError is returned by checkHTTPResponse for unsuccessful responses.
+type httpStatusError struct {
+	code   int
+	status string
+	msg    []byte
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected response %s: %s", e.status, e.msg)
+}
+
+// checkHTTPResponse drains and closes the response body, returning an
+// *httpStatusError describing the response if it does not indicate success.
+func checkHTTPResponse(resp *http.Response) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body) // nolint:errcheck
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	return &httpStatusError{
+		code:   resp.StatusCode,
+		status: resp.Status,
+		msg:    bytes.TrimSpace(msg),
+	}
+}
@@ -0,0 +1,127 @@
+package log
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// HTTPConfig configures sending batches of entries as newline delimited JSON
+// to an HTTP endpoint with POST requests.
+type HTTPConfig struct {
+	// URL is the endpoint receiving the entries.
+	URL string
+
+	// Headers are added to every request, i.e. for authentication.
+	Headers map[string]string
+
+	// Gzip enables compressing the requests.
+	Gzip bool
+
+	// BatchSize is the maximum number of entries sent in one request.
+	// Defaults to 100.
+	BatchSize int
+
+	// FlushInterval is the maximum time an entry waits before being sent.
+	// Defaults to one second.
+	FlushInterval time.Duration
+
+	// MaxRetries is the number of times a request failing because of the
+	// network or an overloaded endpoint is retried. Defaults to 3.
+	MaxRetries int
+
+	// QueueSize is the maximum number of entries waiting to be sent. Newer
+	// entries are dropped while the queue is full. Defaults to 10000.
+	QueueSize int
+
+	// TLS configures HTTPS connections when not nil.
+	TLS *tls.Config
+}
+
+const (
+	defaultHTTPMaxRetries = 3
+	defaultHTTPQueueSize  = 10000
+)
+
+// newHTTPCore creates a core posting batches of NDJSON entries to an HTTP
+// endpoint.
+func newHTTPCore(cfg HTTPConfig, level LogLevel) (zapcore.Core, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("missing HTTP output URL")
+	}
+	maxRetries := cfg.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultHTTPMaxRetries
+	}
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultHTTPQueueSize
+	}
+
+	h := &httpClient{
+		url:        cfg.URL,
+		headers:    cfg.Headers,
+		gzip:       cfg.Gzip,
+		maxRetries: maxRetries,
+		client:     newHTTPClient(cfg.TLS),
+	}
+
+	encCfg := newEncoderConfig()
+	encCfg.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+
+	b := newBatcher(cfg.BatchSize, cfg.FlushInterval, h.send)
+	b.limit = queueSize
+	return &batchCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          zapcore.NewJSONEncoder(encCfg),
+		batcher:      b,
+	}, nil
+}
+
+type httpClient struct {
+	url        string
+	headers    map[string]string
+	gzip       bool
+	maxRetries int
+	client     *http.Client
+}
+
+func (h *httpClient) send(batch []batchEntry) error {
+	var body bytes.Buffer
+	for _, e := range batch {
+		body.Write(e.data)
+	}
+	data := body.Bytes()
+	if h.gzip {
+		var err error
+		if data, err = gzipBytes(data); err != nil {
+			return err
+		}
+	}
+
+	return retryWithBackoff(h.maxRetries, func() (bool, error) {
+		req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(data))
+		if err != nil {
+			return false, err
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		if h.gzip {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+		for k, v := range h.headers {
+			req.Header.Set(k, v)
+		}
+
+		resp, err := h.client.Do(req)
+		if err != nil {
+			return true, err
+		}
+		err = checkHTTPResponse(resp)
+		return isRetryableHTTPError(err), err
+	})
+}
@@ -0,0 +1,70 @@
+package log
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestHTTPCore(t *testing.T) {
+	defer func(backoff time.Duration) { retryBackoff = backoff }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	var requests int
+	var got []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer xyz" {
+			t.Errorf("got authorization %q", auth)
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(zr)
+		for scanner.Scan() {
+			var entry map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				t.Error(err)
+			}
+			got = append(got, entry)
+		}
+	}))
+	defer srv.Close()
+
+	core, err := newHTTPCore(HTTPConfig{
+		URL:       srv.URL,
+		Headers:   map[string]string{"Authorization": "Bearer xyz"},
+		Gzip:      true,
+		QueueSize: 2,
+	}, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*batchCore).Close()
+
+	logger := zap.New(core).Named("dht")
+	logger.Info("scooby")
+	logger.Info("doo")
+	logger.Info("dropped")
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if requests != 2 {
+		t.Errorf("got %d requests, wanted a retry", requests)
+	}
+	if len(got) != 2 || got[0]["msg"] != "scooby" || got[1]["msg"] != "doo" {
+		t.Errorf("got %v, wanted the first 2 entries", got)
+	}
+}
@@ -0,0 +1,32 @@
+package log
+
+import "strconv"
+
+// ByteSize is a number of bytes, which the console formats write in binary
+// units, i.e. "3.4MiB", and the other formats as a number. Pass it as the
+// value of a field:
+//
+//	logger.Infow("uploaded", "size", log.ByteSize(n))
+type ByteSize int64
+
+// formatByteSize returns n in the largest binary unit it is at least one of.
+func formatByteSize(n ByteSize) string {
+	const units = "KMGTPE"
+
+	abs := float64(n)
+	if abs < 0 {
+		abs = -abs
+	}
+	if abs < 1024 {
+		return strconv.FormatInt(int64(n), 10) + "B"
+	}
+	unit := -1
+	for abs >= 1024 && unit < len(units)-1 {
+		abs /= 1024
+		unit++
+	}
+	if n < 0 {
+		abs = -abs
+	}
+	return strconv.FormatFloat(abs, 'f', 1, 64) + units[unit:unit+1] + "iB"
+}
@@ -0,0 +1,52 @@
+package log
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestFormatByteSize(t *testing.T) {
+	for n, want := range map[ByteSize]string{
+		0:                 "0B",
+		1023:              "1023B",
+		1024:              "1.0KiB",
+		3565158:           "3.4MiB",
+		-1536:             "-1.5KiB",
+		5 << 60:           "5.0EiB",
+		1<<40 + 1<<39 + 1: "1.5TiB",
+	} {
+		if got := formatByteSize(n); got != want {
+			t.Errorf("got %s for %d, wanted %s", got, n, want)
+		}
+	}
+}
+
+func TestHumanValues(t *testing.T) {
+	ent := zapcore.Entry{Level: zapcore.InfoLevel, Message: "uploaded"}
+	fields := []zapcore.Field{zap.Any("size", ByteSize(3565158)), zap.Duration("took", 1200*time.Millisecond)}
+
+	enc := newEncoder(FormatPlaintextOutput)
+	enc.AddReflected("total", ByteSize(2048))
+	buf, err := enc.EncodeEntry(ent, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, `{"total": "2.0KiB", "size": "3.4MiB", "took": "1.2s"}`) {
+		t.Errorf("got %q, wanted human-friendly values", out)
+	}
+	if fields[0].Type != zapcore.ReflectType {
+		t.Error("wanted the fields to be left unchanged")
+	}
+
+	buf, err = newEncoder(FormatJSONOutput).EncodeEntry(ent, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, `"size":3565158,"took":1.2`) {
+		t.Errorf("got %q, wanted raw values", out)
+	}
+}
@@ -0,0 +1,251 @@
+package log
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// PagerDutyConfig configures triggering PagerDuty incidents with the Events
+// API v2 on DPanic, Panic and Fatal entries.
+type PagerDutyConfig struct {
+	// RoutingKey is the integration key of the service.
+	RoutingKey string
+
+	// URL overrides the Events API v2 endpoint.
+	URL string
+}
+
+// OpsgenieConfig configures creating Opsgenie alerts on DPanic, Panic and
+// Fatal entries.
+type OpsgenieConfig struct {
+	// APIKey is the key of an API integration.
+	APIKey string
+
+	// URL overrides the alert API endpoint, i.e. for the EU instance
+	// "https://api.eu.opsgenie.com/v2/alerts".
+	URL string
+}
+
+const (
+	defaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	defaultOpsgenieURL  = "https://api.opsgenie.com/v2/alerts"
+
+	// opsgenieMaxMessage is the maximum length of an Opsgenie alert message.
+	opsgenieMaxMessage = 130
+)
+
+// incident is a crash reported to the incident management services.
+type incident struct {
+	// dedupKey groups the incidents of the same subsystem and message.
+	dedupKey  string
+	summary   string
+	subsystem string
+	level     zapcore.Level
+	time      time.Time
+	source    string
+	details   map[string]interface{}
+}
+
+// incidentDedupKey derives the key grouping repeated crashes into one
+// incident from the subsystem and the message of an entry.
+func incidentDedupKey(subsystem, message string) string {
+	sum := sha256.Sum256([]byte(subsystem + "\x00" + message))
+	return "go-log-" + hex.EncodeToString(sum[:16])
+}
+
+// newIncidentCore creates a core triggering incidents with PagerDuty and
+// Opsgenie, whichever is configured. Incidents are sent synchronously, as
+// Fatal entries terminate the program once written.
+func newIncidentCore(pagerDuty *PagerDutyConfig, opsgenie *OpsgenieConfig) (zapcore.Core, error) {
+	c := &incidentCore{
+		LevelEnabler: zapcore.DPanicLevel,
+		client:       newHTTPClient(nil),
+	}
+	c.source, _ = os.Hostname()
+
+	if pagerDuty != nil {
+		if pagerDuty.RoutingKey == "" {
+			return nil, errors.New("missing PagerDuty routing key")
+		}
+		c.pagerDuty = *pagerDuty
+		if c.pagerDuty.URL == "" {
+			c.pagerDuty.URL = defaultPagerDutyURL
+		}
+	}
+	if opsgenie != nil {
+		if opsgenie.APIKey == "" {
+			return nil, errors.New("missing Opsgenie API key")
+		}
+		c.opsgenie = *opsgenie
+		if c.opsgenie.URL == "" {
+			c.opsgenie.URL = defaultOpsgenieURL
+		}
+	}
+	return c, nil
+}
+
+type incidentCore struct {
+	zapcore.LevelEnabler
+	pagerDuty PagerDutyConfig
+	opsgenie  OpsgenieConfig
+	source    string
+	client    *http.Client
+	fields    []zapcore.Field
+}
+
+func (c *incidentCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+	return &clone
+}
+
+func (c *incidentCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *incidentCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range append(c.fields[:len(c.fields):len(c.fields)], fields...) {
+		f.AddTo(enc)
+	}
+	if ent.Caller.Defined {
+		enc.Fields["caller"] = ent.Caller.TrimmedPath()
+	}
+	if ent.Stack != "" {
+		enc.Fields["stacktrace"] = ent.Stack
+	}
+
+	summary := ent.Message
+	if ent.LoggerName != "" {
+		summary = ent.LoggerName + ": " + summary
+	}
+	inc := incident{
+		dedupKey:  incidentDedupKey(ent.LoggerName, ent.Message),
+		summary:   summary,
+		subsystem: ent.LoggerName,
+		level:     ent.Level,
+		time:      ent.Time,
+		source:    c.source,
+		details:   enc.Fields,
+	}
+
+	var err error
+	if c.pagerDuty.RoutingKey != "" {
+		err = multierr.Append(err, c.triggerPagerDuty(inc))
+	}
+	if c.opsgenie.APIKey != "" {
+		err = multierr.Append(err, c.createOpsgenieAlert(inc))
+	}
+	return err
+}
+
+func (c *incidentCore) Sync() error {
+	return nil
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string `json:"routing_key"`
+	EventAction string `json:"event_action"`
+	DedupKey    string `json:"dedup_key"`
+	Payload     struct {
+		Summary       string                 `json:"summary"`
+		Source        string                 `json:"source"`
+		Severity      string                 `json:"severity"`
+		Timestamp     string                 `json:"timestamp"`
+		Component     string                 `json:"component,omitempty"`
+		Class         string                 `json:"class"`
+		CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+	} `json:"payload"`
+}
+
+func (c *incidentCore) triggerPagerDuty(inc incident) error {
+	event := pagerDutyEvent{
+		RoutingKey:  c.pagerDuty.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    inc.dedupKey,
+	}
+	event.Payload.Summary = inc.summary
+	event.Payload.Source = inc.source
+	event.Payload.Severity = "critical"
+	event.Payload.Timestamp = inc.time.UTC().Format(time.RFC3339Nano)
+	event.Payload.Component = inc.subsystem
+	event.Payload.Class = capitalLevelName(inc.level)
+	event.Payload.CustomDetails = inc.details
+
+	return c.post(c.pagerDuty.URL, "", event)
+}
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	Priority    string            `json:"priority"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+func (c *incidentCore) createOpsgenieAlert(inc incident) error {
+	alert := opsgenieAlert{
+		Message:     inc.summary,
+		Alias:       inc.dedupKey,
+		Description: inc.summary,
+		Source:      inc.source,
+		Priority:    "P1",
+		Tags:        []string{capitalLevelName(inc.level)},
+		Details:     make(map[string]string, len(inc.details)),
+	}
+	if len(alert.Message) > opsgenieMaxMessage {
+		alert.Message = alert.Message[:opsgenieMaxMessage]
+	}
+	if inc.subsystem != "" {
+		alert.Tags = append(alert.Tags, inc.subsystem)
+	}
+	// details are string values only
+	for k, v := range inc.details {
+		if s, ok := v.(string); ok {
+			alert.Details[k] = s
+		} else if data, err := json.Marshal(v); err == nil {
+			alert.Details[k] = string(data)
+		} else {
+			alert.Details[k] = fmt.Sprint(v)
+		}
+	}
+
+	return c.post(c.opsgenie.URL, "GenieKey "+c.opsgenie.APIKey, alert)
+}
+
+func (c *incidentCore) post(url, auth string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return checkHTTPResponse(resp)
+}
@@ -0,0 +1,70 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestIncidentCore(t *testing.T) {
+	var events []pagerDutyEvent
+	var alerts []opsgenieAlert
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pagerduty", func(w http.ResponseWriter, r *http.Request) {
+		var event pagerDutyEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Error(err)
+		}
+		events = append(events, event)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("/opsgenie", func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "GenieKey scooby" {
+			t.Errorf("got authorization %q", auth)
+		}
+		var alert opsgenieAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Error(err)
+		}
+		alerts = append(alerts, alert)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	core, err := newIncidentCore(
+		&PagerDutyConfig{RoutingKey: "doo", URL: srv.URL + "/pagerduty"},
+		&OpsgenieConfig{APIKey: "scooby", URL: srv.URL + "/opsgenie"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := zap.New(core).Named("dht")
+	logger.Error("not an incident")
+	logger.DPanic("routing table corrupted", zap.Int("peers", 3))
+	logger.DPanic("routing table corrupted", zap.Int("peers", 4))
+	logger.Named("swarm").DPanic("routing table corrupted")
+
+	if len(events) != 3 || len(alerts) != 3 {
+		t.Fatalf("got %d events and %d alerts, wanted 3", len(events), len(alerts))
+	}
+	event := events[0]
+	if event.RoutingKey != "doo" || event.EventAction != "trigger" || event.Payload.Summary != "dht: routing table corrupted" ||
+		event.Payload.Component != "dht" || event.Payload.CustomDetails["peers"] != 3.0 {
+		t.Errorf("got event %+v", event)
+	}
+	if alerts[0].Details["peers"] != "3" || alerts[0].Priority != "P1" {
+		t.Errorf("got alert %+v", alerts[0])
+	}
+
+	if events[0].DedupKey != events[1].DedupKey || alerts[0].Alias != events[0].DedupKey {
+		t.Error("repeated crashes should share a dedup key")
+	}
+	if events[0].DedupKey == events[2].DedupKey {
+		t.Error("crashes of different subsystems should not share a dedup key")
+	}
+}
@@ -0,0 +1,193 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// journaldSocket is the socket of the systemd journal native protocol.
+const journaldSocket = "/run/systemd/journal/socket"
+
+// newJournaldCore creates a core sending every entry to the systemd journal.
+// Structured fields are sent as journal fields so they can be queried with
+// journalctl, i.e. `journalctl SUBSYSTEM=dht`.
+func newJournaldCore(socket string, level LogLevel) zapcore.Core {
+	return &journaldCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		w:            &journaldWriter{socket: socket},
+		identifier:   filepath.Base(os.Args[0]),
+	}
+}
+
+type journaldCore struct {
+	zapcore.LevelEnabler
+	w          *journaldWriter
+	identifier string
+	fields     []zapcore.Field
+}
+
+func (c *journaldCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+	return &clone
+}
+
+func (c *journaldCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *journaldCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for i := range c.fields {
+		c.fields[i].AddTo(enc)
+	}
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+	resolveLazyFields(enc.Fields)
+
+	var buf bytes.Buffer
+	writeJournaldField(&buf, "MESSAGE", ent.Message)
+	writeJournaldField(&buf, "PRIORITY", strconv.Itoa(syslogSeverity(ent.Level)))
+	writeJournaldField(&buf, "SYSLOG_IDENTIFIER", c.identifier)
+	if ent.LoggerName != "" {
+		writeJournaldField(&buf, "SUBSYSTEM", ent.LoggerName)
+	}
+	if ent.Caller.Defined {
+		writeJournaldField(&buf, "CODE_FILE", ent.Caller.File)
+		writeJournaldField(&buf, "CODE_LINE", strconv.Itoa(ent.Caller.Line))
+		if ent.Caller.Function != "" {
+			writeJournaldField(&buf, "CODE_FUNC", ent.Caller.Function)
+		}
+	}
+	if ent.Stack != "" {
+		writeJournaldField(&buf, "STACKTRACE", ent.Stack)
+	}
+	for k, v := range enc.Fields {
+		name := journaldFieldName(k)
+		if name == "" {
+			continue
+		}
+		writeJournaldField(&buf, name, journaldFieldValue(v))
+	}
+
+	return c.w.write(buf.Bytes())
+}
+
+func (c *journaldCore) Sync() error {
+	return nil
+}
+
+func (c *journaldCore) Close() error {
+	return c.w.Close()
+}
+
+// journaldFieldName converts a field key into a valid journal field name:
+// upper-case letters, digits and underscores, not starting with an underscore
+// (reserved for trusted fields) or a digit.
+func journaldFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	name = strings.TrimLeft(name, "_0123456789")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+func journaldFieldValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	case error:
+		return v.Error()
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// writeJournaldField appends a field in the journal native protocol format.
+func writeJournaldField(buf *bytes.Buffer, name, value string) {
+	if !strings.ContainsRune(value, '\n') {
+		buf.WriteString(name)
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	// values containing newlines are sent with an explicit length
+	buf.WriteString(name)
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value))) // nolint:errcheck
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+type journaldWriter struct {
+	mu     sync.Mutex
+	socket string
+	conn   net.Conn
+}
+
+func (w *journaldWriter) write(msg []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		conn, err := net.Dial("unixgram", w.socket)
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+	}
+	if _, err := w.conn.Write(msg); err != nil {
+		w.conn.Close() // nolint:errcheck
+		w.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (w *journaldWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
@@ -0,0 +1,59 @@
+package log
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestJournaldCore(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %s", err)
+	}
+	defer conn.Close()
+
+	core := newJournaldCore(socket, LevelDebug)
+	defer core.(*journaldCore).Close()
+
+	zap.New(core).Named("test").With(zap.String("peer.id", "Qm")).Warn("line1\nline2", zap.Int("count", 3))
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+
+	for _, want := range []string{
+		"MESSAGE\n",
+		"line1\nline2\n",
+		"PRIORITY=4\n",
+		"SUBSYSTEM=test\n",
+		"PEER_ID=Qm\n",
+		"COUNT=3\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("got %q, wanted it to contain %q", msg, want)
+		}
+	}
+}
+
+func TestJournaldFieldName(t *testing.T) {
+	for key, want := range map[string]string{
+		"peer.id":  "PEER_ID",
+		"_private": "PRIVATE",
+		"1st":      "ST",
+		"Request":  "REQUEST",
+	} {
+		if got := journaldFieldName(key); got != want {
+			t.Errorf("journaldFieldName(%q) = %q, wanted %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// prettyJSONEncoder indents the entries of a JSON encoder, and colors their
+// keys.
+type prettyJSONEncoder struct {
+	zapcore.Encoder
+	keyColor string
+}
+
+func (e *prettyJSONEncoder) Clone() zapcore.Encoder {
+	return &prettyJSONEncoder{e.Encoder.Clone(), e.keyColor}
+}
+
+func (e *prettyJSONEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	data, err := e.Encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return nil, err
+	}
+	defer data.Free()
+
+	// the line ending is kept
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	buf := encoderPool.Get()
+	appendColoredJSONKeys(buf, indented.Bytes(), e.keyColor)
+	return buf, nil
+}
+
+// appendColoredJSONKeys appends the JSON of data to buf, coloring the keys of
+// its objects.
+func appendColoredJSONKeys(buf *buffer.Buffer, data []byte, color string) {
+	for i := 0; i < len(data); i++ {
+		if data[i] != '"' {
+			buf.AppendByte(data[i])
+			continue
+		}
+		end := i + 1
+		for ; end < len(data) && data[end] != '"'; end++ {
+			if data[end] == '\\' {
+				end++
+			}
+		}
+		str := data[i : end+1]
+		// keys are directly followed by a colon once indented
+		if end+1 < len(data) && data[end+1] == ':' {
+			buf.AppendString(colorize(color, string(str)))
+		} else {
+			buf.Write(str)
+		}
+		i = end
+	}
+}
@@ -0,0 +1,34 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestJSONPrettyFormat(t *testing.T) {
+	enc := newEncoder(FormatJSONPrettyOutput)
+	buf, err := enc.EncodeEntry(zapcore.Entry{
+		Level:      zapcore.InfoLevel,
+		Time:       time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		LoggerName: "dht",
+		Message:    `say "hi":`,
+	}, []zapcore.Field{zap.Strings("peers", []string{"a"})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n" +
+		"  \x1b[34m\"level\"\x1b[0m: \"info\",\n" +
+		"  \x1b[34m\"ts\"\x1b[0m: \"2024-05-01T00:00:00.000Z\",\n" +
+		"  \x1b[34m\"logger\"\x1b[0m: \"dht\",\n" +
+		"  \x1b[34m\"msg\"\x1b[0m: \"say \\\"hi\\\":\",\n" +
+		"  \x1b[34m\"peers\"\x1b[0m: [\n" +
+		"    \"a\"\n" +
+		"  ]\n" +
+		"}\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+}
@@ -0,0 +1,52 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// KafkaConfig configures publishing logs to a Kafka topic.
+type KafkaConfig struct {
+	// Brokers are the addresses of the Kafka brokers, i.e. "localhost:9092".
+	Brokers []string
+
+	// Topic is the topic entries are published to.
+	Topic string
+
+	// KeyField selects the message key used for partitioning: "subsystem",
+	// "level", or the name of a field of the entries. Entries with the same
+	// key are published to the same partition. Defaults to "subsystem".
+	KeyField string
+
+	// BatchSize is the maximum number of entries published at once.
+	// Defaults to 100.
+	BatchSize int
+
+	// FlushInterval is the maximum time an entry waits before being published.
+	// Defaults to one second.
+	FlushInterval time.Duration
+}
+
+// kafkaMessageKey returns the value of keyField for e.
+func kafkaMessageKey(keyField string, e batchEntry) []byte {
+	switch keyField {
+	case "subsystem":
+		return []byte(e.ent.LoggerName)
+	case "level":
+		return []byte(levelName(e.ent.Level))
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(e.data, &fields); err != nil {
+		return nil
+	}
+	switch v := fields[keyField].(type) {
+	case nil:
+		return nil
+	case string:
+		return []byte(v)
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,14 @@
+//go:build plan9
+// +build plan9
+
+package log
+
+import (
+	"errors"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func newKafkaCore(cfg KafkaConfig, level LogLevel) (zapcore.Core, error) {
+	return nil, errors.New("Kafka is not supported on this platform")
+}
@@ -0,0 +1,25 @@
+package log
+
+import (
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestKafkaMessageKey(t *testing.T) {
+	e := batchEntry{
+		ent:  zapcore.Entry{LoggerName: "dht", Level: zapcore.WarnLevel},
+		data: []byte(`{"msg":"scooby","peer":"Qm","count":3}` + "\n"),
+	}
+	for field, want := range map[string]string{
+		"subsystem": "dht",
+		"level":     "warn",
+		"peer":      "Qm",
+		"count":     "3",
+		"missing":   "",
+	} {
+		if got := string(kafkaMessageKey(field, e)); got != want {
+			t.Errorf("kafkaMessageKey(%q) = %q, wanted %q", field, got, want)
+		}
+	}
+}
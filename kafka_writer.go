@@ -0,0 +1,68 @@
+//go:build !plan9
+// +build !plan9
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newKafkaCore creates a core publishing JSON encoded entries to Kafka.
+func newKafkaCore(cfg KafkaConfig, level LogLevel) (zapcore.Core, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("missing Kafka brokers")
+	}
+	if cfg.Topic == "" {
+		return nil, errors.New("missing Kafka topic")
+	}
+	keyField := cfg.KeyField
+	if keyField == "" {
+		keyField = "subsystem"
+	}
+
+	k := &kafkaClient{
+		keyField: keyField,
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Topic:        cfg.Topic,
+			Balancer:     &kafka.Hash{},
+			BatchSize:    cfg.BatchSize,
+			BatchTimeout: 10 * time.Millisecond, // batches are assembled by the batcher
+			RequiredAcks: kafka.RequireOne,
+		},
+	}
+
+	encCfg := newEncoderConfig()
+	encCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	return &batchCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          zapcore.NewJSONEncoder(encCfg),
+		batcher:      newBatcher(cfg.BatchSize, cfg.FlushInterval, k.publish),
+		close:        k.writer.Close,
+	}, nil
+}
+
+type kafkaClient struct {
+	keyField string
+	writer   *kafka.Writer
+}
+
+func (k *kafkaClient) publish(batch []batchEntry) error {
+	msgs := make([]kafka.Message, len(batch))
+	for i, e := range batch {
+		msgs[i] = kafka.Message{
+			Key:   kafkaMessageKey(k.keyField, e),
+			Value: bytes.TrimRight(e.data, "\n"),
+			Time:  e.ent.Time,
+		}
+	}
+	return k.writer.WriteMessages(context.Background(), msgs...)
+}
@@ -0,0 +1,57 @@
+package log
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// KitLogger is a go-kit log.Logger writing the key-value pairs it is given as
+// the fields of entries of a logger, without encoding them first.
+type KitLogger struct {
+	logger *zap.Logger
+}
+
+// NewKitLogger returns a go-kit logger writing to the logger of system. The
+// message of entries is the value of the "msg" key, and their level the value
+// of the "level" key, like the values of the go-kit level package, or else
+// LevelInfo. The entries have no caller, go-kit adding its own.
+func NewKitLogger(system string) *KitLogger {
+	return &KitLogger{logger: getLogger(system).Desugar().WithOptions(zap.WithCaller(false))}
+}
+
+// Log writes the key-value pairs keyvals as an entry.
+func (l *KitLogger) Log(keyvals ...interface{}) error {
+	if len(keyvals)%2 != 0 {
+		// like go-kit
+		keyvals = append(keyvals, "(MISSING)")
+	}
+
+	level := zapcore.InfoLevel
+	var msg string
+	fields := make([]zapcore.Field, 0, len(keyvals)/2)
+	for i := 0; i < len(keyvals); i += 2 {
+		key, value := fmt.Sprint(keyvals[i]), keyvals[i+1]
+		switch key {
+		case "msg":
+			msg = fmt.Sprint(value)
+			continue
+		case "level":
+			if lvl, err := LevelFromString(fmt.Sprint(value)); err == nil {
+				level = zapcore.Level(lvl)
+				continue
+			}
+		}
+		if err, ok := value.(error); ok {
+			fields = append(fields, zap.NamedError(key, err))
+		} else {
+			fields = append(fields, zap.Any(key, value))
+		}
+	}
+
+	if ce := l.logger.Check(level, msg); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// kitLogger is the go-kit log.Logger interface
+type kitLogger interface {
+	Log(keyvals ...interface{}) error
+}
+
+func TestKitLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelInfo, Format: FormatJSONOutput, File: path})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	var logger kitLogger = NewKitLogger("kit")
+	logger.Log("level", "debug", "msg", "filtered")
+	logger.Log("method", "GET", "took", 3, "err", errors.New("doo"), "level", "warn", "msg", "scooby", "odd")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d entries, wanted the warning only", len(lines))
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]interface{}{
+		"level":  "warn",
+		"logger": "kit",
+		"msg":    "scooby",
+		"method": "GET",
+		"took":   float64(3),
+		"err":    "doo",
+		"odd":    "(MISSING)",
+	} {
+		if entry[key] != want {
+			t.Errorf("got %s %v, wanted %v", key, entry[key], want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package log
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Lazy returns a field value computed by f only when the entry is written,
+// so that expensive values cost nothing when the entry is below the level of
+// the subsystem. Pass it as the value of a field:
+//
+//	logger.Debugw("received", "block", log.Lazy(func() interface{} {
+//		return dump(block)
+//	}))
+//
+// f is called once, by the first output writing the entry, the other outputs
+// writing the same value. The value is kept once computed, so Lazy should be
+// called for every entry rather than reused across entries.
+func Lazy(f func() interface{}) *LazyValue {
+	return &LazyValue{f: f}
+}
+
+// LazyValue is a field value computed when first written, see Lazy.
+type LazyValue struct {
+	once sync.Once
+	f    func() interface{}
+	v    interface{}
+}
+
+// value returns the value of the field, the messages of errors being their
+// value like with zap.Error.
+func (l *LazyValue) value() interface{} {
+	l.once.Do(func() {
+		l.v = l.f()
+		if err, ok := l.v.(error); ok {
+			l.v = err.Error()
+		}
+	})
+	return l.v
+}
+
+// MarshalJSON encodes the value for the JSON and binary formats, which encode
+// the values of fields of arbitrary types as JSON.
+func (l *LazyValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.value())
+}
+
+// resolveLazyFields replaces the Lazy values of fields with their values, for
+// the outputs decoding the fields to a map.
+func resolveLazyFields(fields map[string]interface{}) {
+	for key, value := range fields {
+		if l, ok := value.(*LazyValue); ok {
+			fields[key] = l.value()
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLazy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelError, Format: FormatJSONOutput, File: path})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+	entries, cancel := Subscribe(nil)
+	defer cancel()
+
+	calls := 0
+	compute := func() interface{} {
+		calls++
+		return map[string]int{"height": 3}
+	}
+	log := getLogger("lazy")
+	log.Debugw("filtered", "block", Lazy(compute))
+	if calls != 0 {
+		t.Fatalf("got %d calls, wanted none below the level", calls)
+	}
+	log.Errorw("scooby", "block", Lazy(compute), "err", Lazy(func() interface{} { return errors.New("doo") }))
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"block":{"height":3},"err":"doo"`) {
+		t.Errorf("got %s, wanted the computed values", data)
+	}
+	if e := <-entries; e.Fields["block"].(map[string]int)["height"] != 3 {
+		t.Errorf("got %v, wanted the computed value", e.Fields)
+	}
+}
+
+func TestLazyOutputs(t *testing.T) {
+	var first, second bytes.Buffer
+	logger := zap.New(zapcore.NewTee(
+		zapcore.NewCore(newEncoder(FormatJSONOutput), zapcore.AddSync(&first), zapcore.DebugLevel),
+		zapcore.NewCore(newEncoder(FormatJSONOutput), zapcore.AddSync(&second), zapcore.DebugLevel),
+	)).Sugar()
+
+	calls := 0
+	logger.Infow("scooby", "block", Lazy(func() interface{} {
+		calls++
+		return 3
+	}))
+	if calls != 1 {
+		t.Errorf("got %d calls, wanted one for both outputs", calls)
+	}
+	for _, buf := range []*bytes.Buffer{&first, &second} {
+		if !strings.Contains(buf.String(), `"block":3`) {
+			t.Errorf("got %s, wanted the computed value", buf.String())
+		}
+	}
+}
@@ -1,12 +1,23 @@
 package log
 
-import "go.uber.org/zap/zapcore"
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
 
 // LogLevel represents a log severity level. Use the package variables as an
 // enum.
 type LogLevel zapcore.Level
 
 var (
+	// LevelTrace is below LevelDebug, for extremely chatty logs such as the
+	// messages of protocols, which are written with the Trace methods.
+	LevelTrace  = LogLevel(zapcore.DebugLevel - 1)
 	LevelDebug  = LogLevel(zapcore.DebugLevel)
 	LevelInfo   = LogLevel(zapcore.InfoLevel)
 	LevelWarn   = LogLevel(zapcore.WarnLevel)
@@ -16,15 +27,124 @@ var (
 	LevelFatal  = LogLevel(zapcore.FatalLevel)
 )
 
+// levelAll is below all levels, including the registered ones, for the cores
+// logging everything enabled by SetLogLevel.
+const levelAll = LogLevel(math.MinInt8)
+
 // LevelFromString parses a string-based level and returns the corresponding
 // LogLevel.
 //
-// Supported strings are: DEBUG, INFO, WARN, ERROR, DPANIC, PANIC, FATAL, and
-// their lower-case forms.
+// Supported strings are: TRACE, DEBUG, INFO, WARN, ERROR, DPANIC, PANIC,
+// FATAL, the names of the levels registered with RegisterLevel, and their
+// lower-case forms.
 //
 // The returned LogLevel must be discarded if error is not nil.
 func LevelFromString(level string) (LogLevel, error) {
+	if level == "trace" || level == "TRACE" {
+		return LevelTrace, nil
+	}
+	if lvl, ok := getRegisteredLevels().byName[strings.ToLower(level)]; ok {
+		return lvl, nil
+	}
 	lvl := zapcore.InfoLevel // zero value
 	err := lvl.Set(level)
 	return LogLevel(lvl), err
 }
+
+// registeredLevels are the levels registered with RegisterLevel.
+type registeredLevels struct {
+	byName map[string]LogLevel
+	names  map[LogLevel]string
+	colors map[LogLevel]string
+}
+
+var (
+	// currentRegisteredLevels holds the registeredLevels, which are copied
+	// on registration since they are read by every entry.
+	currentRegisteredLevels atomic.Value
+	registerLevelMutex      sync.Mutex
+)
+
+func getRegisteredLevels() registeredLevels {
+	levels, _ := currentRegisteredLevels.Load().(registeredLevels)
+	return levels
+}
+
+// RegisterLevel registers the level named name, i.e. "audit", which
+// LevelFromString and SetLogLevel accept, and the encoders write by its
+// lower-case name. Levels are ordered by their values, so a level above
+// LevelFatal is always logged and a level below LevelTrace logged only when
+// enabled explicitly. color is the color of the level in
+// FormatColorizedOutput unless set by the ColorTheme, see ColorTheme.
+//
+// Entries are logged at the registered levels with the Log methods, i.e.
+//
+//	var LevelAudit = log.LogLevel(6)
+//
+//	func init() {
+//		if err := log.RegisterLevel("audit", LevelAudit, "1;35"); err != nil {
+//			panic(err)
+//		}
+//	}
+//
+//	logger.Logw(LevelAudit, "deleted", "user", user)
+func RegisterLevel(name string, level LogLevel, color string) error {
+	registerLevelMutex.Lock()
+	defer registerLevelMutex.Unlock()
+
+	name = strings.ToLower(name)
+	if name == "" {
+		return fmt.Errorf("empty level name")
+	}
+	if level >= LevelTrace && level <= LevelFatal {
+		return fmt.Errorf("level %d is the built-in level %s", level, levelName(zapcore.Level(level)))
+	}
+	if _, err := LevelFromString(name); err == nil {
+		return fmt.Errorf("level %q already exists", name)
+	}
+	current := getRegisteredLevels()
+	if other, ok := current.names[level]; ok {
+		return fmt.Errorf("level %d is already registered as %q", level, other)
+	}
+
+	levels := registeredLevels{
+		byName: map[string]LogLevel{name: level},
+		names:  map[LogLevel]string{level: name},
+		colors: map[LogLevel]string{level: color},
+	}
+	for lvl, name := range current.names {
+		levels.byName[name] = lvl
+		levels.names[lvl] = name
+		levels.colors[lvl] = current.colors[lvl]
+	}
+	currentRegisteredLevels.Store(levels)
+	return nil
+}
+
+// levelName returns the lower-case name of lvl, which zap doesn't know for
+// LevelTrace and the registered levels.
+func levelName(lvl zapcore.Level) string {
+	if LogLevel(lvl) == LevelTrace {
+		return "trace"
+	}
+	if name, ok := getRegisteredLevels().names[LogLevel(lvl)]; ok {
+		return name
+	}
+	return lvl.String()
+}
+
+// capitalLevelName returns the upper-case name of lvl.
+func capitalLevelName(lvl zapcore.Level) string {
+	return strings.ToUpper(levelName(lvl))
+}
+
+// encodeLevel is the zapcore.LevelEncoder of the lower-case level names.
+func encodeLevel(lvl zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(levelName(lvl))
+}
+
+// encodeCapitalLevel is the zapcore.LevelEncoder of the upper-case level
+// names.
+func encodeCapitalLevel(lvl zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(capitalLevelName(lvl))
+}
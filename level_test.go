@@ -0,0 +1,83 @@
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRegisterLevel(t *testing.T) {
+	defer currentRegisteredLevels.Store(registeredLevels{})
+	audit := LogLevel(6)
+	if err := RegisterLevel("Audit", audit, "1;35"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterLevel("wire", LevelTrace-1, ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name  string
+		level LogLevel
+	}{
+		{"debug", LevelDebug},
+		{"audit", audit},
+		{"dupe", audit},
+		{"notice", LevelWarn},
+	} {
+		if err := RegisterLevel(tc.name, tc.level, ""); err == nil {
+			t.Errorf("registered %s at %d, wanted an error", tc.name, tc.level)
+		}
+	}
+	if lvl, err := LevelFromString("AUDIT"); err != nil || lvl != audit {
+		t.Errorf("got level %d (%v), wanted audit", lvl, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelError, Format: FormatJSONOutput, File: path})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	log := Logger("levels")
+	log.Logw(LevelTrace-1, "filtered")
+	log.Logw(audit, "deleted", "user", "scooby")
+	if err := SetLogLevel("levels", "wire"); err != nil {
+		t.Fatal(err)
+	}
+	log.Logf(LevelTrace-1, "sent %d bytes", 3)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d entries, wanted 2", len(lines))
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "audit" || entry["user"] != "scooby" || entry["stacktrace"] != nil {
+		t.Errorf("got %s, wanted the audit entry", lines[0])
+	}
+	if caller, _ := entry["caller"].(string); !strings.Contains(caller, "level_test.go") {
+		t.Errorf("got caller %s, wanted the test", caller)
+	}
+	if !strings.Contains(lines[1], `"level":"wire"`) {
+		t.Errorf("got %s, wanted the wire entry", lines[1])
+	}
+
+	arr := zapcore.NewMapObjectEncoder()
+	var encCfg zapcore.EncoderConfig
+	DefaultColorTheme.setEncoders(&encCfg)
+	_ = arr.AddArray("level", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		encCfg.EncodeLevel(zapcore.Level(audit), enc)
+		return nil
+	}))
+	if got := arr.Fields["level"].([]interface{})[0]; got != "\x1b[1;35mAUDIT\x1b[0m" {
+		t.Errorf("got %q, wanted the registered color", got)
+	}
+}
@@ -1,8 +1,15 @@
 package log
 
 import (
+	"context"
+	"fmt"
 	"regexp"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -33,7 +40,7 @@ func AllLevels() map[string]string {
 
 	mlevels := make(map[string]string, len(levels))
 	for name, level := range levels {
-		mlevels[name] = level.String()
+		mlevels[name] = levelName(level.Level())
 	}
 
 	return mlevels
@@ -66,6 +73,21 @@ func SetLogLevel(name, level string) error {
 	return nil
 }
 
+// SetStacktraceLevel changes the level from which stack traces are attached to
+// the entries of all subsystems, "never" disabling them.
+func SetStacktraceLevel(level string) error {
+	if level == "never" {
+		stacktraceLevel.SetLevel(stacktraceNever)
+		return nil
+	}
+	lvl, err := LevelFromString(level)
+	if err != nil {
+		return err
+	}
+	stacktraceLevel.SetLevel(zapcore.Level(lvl))
+	return nil
+}
+
 // SetLogLevelRegex sets all loggers to level `l` that match expression `e`.
 // An error is returned if `e` fails to compile.
 func SetLogLevelRegex(e, l string) error {
@@ -90,6 +112,181 @@ func SetLogLevelRegex(e, l string) error {
 	return nil
 }
 
+// SetSubsystemOutput changes the output of a specific subsystem. The output
+// "none" discards its entries while keeping the logger registered and its
+// level controllable, "default" writes them to the configured outputs again.
+func SetSubsystemOutput(name, output string) error {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+
+	if _, ok := levels[name]; !ok {
+		return ErrNoSuchLogger
+	}
+	return setSubsystemOutput(name, output)
+}
+
+func setSubsystemOutput(name, output string) error {
+	var discard uint32
+	switch output {
+	case "none":
+		discard = 1
+	case "default":
+	default:
+		return fmt.Errorf("unknown subsystem output %q", output)
+	}
+	atomic.StoreUint32(subsystemDiscard(name), discard)
+	return nil
+}
+
+// subsystemDiscard returns the flag set when the entries of a subsystem are
+// discarded.
+func subsystemDiscard(name string) *uint32 {
+	discard, ok := discards[name]
+	if !ok {
+		discard = new(uint32)
+		discards[name] = discard
+	}
+	return discard
+}
+
+// SetSubsystemLabels sets the labels added to the entries of a specific
+// subsystem, next to the labels of Config.Labels applied to all of them. nil
+// removes them.
+func SetSubsystemLabels(name string, labels map[string]string) error {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+
+	if _, ok := levels[name]; !ok {
+		return ErrNoSuchLogger
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fields := make([]zapcore.Field, 0, len(keys))
+	for _, k := range keys {
+		fields = append(fields, zap.String(k, labels[k]))
+	}
+	subsystemLabelFields(name).Store(fields)
+	return nil
+}
+
+// SetGlobalLabel sets a label added to the entries of all loggers, like the
+// labels of Config.Labels, without opening the outputs again, i.e. to add a
+// session ID learned after startup. SetupLogging resets the labels to
+// Config.Labels.
+func SetGlobalLabel(key, value string) {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+
+	if v, ok := globalLabels[key]; ok && v == value {
+		return
+	}
+	if globalLabels == nil {
+		globalLabels = make(map[string]string)
+	}
+	globalLabels[key] = value
+	relabelCores()
+}
+
+// RemoveGlobalLabel removes a label set by SetGlobalLabel or Config.Labels.
+func RemoveGlobalLabel(key string) {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+
+	if _, ok := globalLabels[key]; !ok {
+		return
+	}
+	delete(globalLabels, key)
+	relabelCores()
+}
+
+// subsystemLabelFields returns the value holding the label fields of a
+// subsystem.
+func subsystemLabelFields(name string) *atomic.Value {
+	labels, ok := subsystemLabels[name]
+	if !ok {
+		labels = new(atomic.Value)
+		labels.Store([]zapcore.Field(nil))
+		subsystemLabels[name] = labels
+	}
+	return labels
+}
+
+// Sync flushes the entries buffered by the outputs, i.e. the entries not yet
+// sent by the network outputs, so that they are not lost on shutdown.
+func Sync() error {
+	return ignoreSyncErrors(multierr.Append(loggerCore.Sync(), auditCore().Sync()))
+}
+
+// Close flushes and closes the outputs set up by SetupLogging, i.e. files and
+// network outputs, on shutdown. The entries are discarded afterwards, until
+// SetupLogging is called again.
+func Close() error {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+
+	return closeOutputs(context.Background())
+}
+
+// Shutdown is Close, stopping to flush the outputs once ctx is done, i.e. on
+// a deadline, the entries not sent then being dropped. The error is then a
+// *DroppedEntriesError.
+func Shutdown(ctx context.Context) error {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+
+	return closeOutputs(ctx)
+}
+
+// DroppedEntriesError is the error of Shutdown when ctx is done before the
+// outputs are flushed.
+type DroppedEntriesError struct {
+	// Dropped is the number of entries dropped by the outputs shipping
+	// entries in the background, and counting them.
+	Dropped int
+	// Err is the error of the context.
+	Err error
+}
+
+func (e *DroppedEntriesError) Error() string {
+	return fmt.Sprintf("dropped %d log entries: %s", e.Dropped, e.Err)
+}
+
+func (e *DroppedEntriesError) Unwrap() error {
+	return e.Err
+}
+
+// FlushOnExit flushes the entries buffered by the outputs when deferred in
+// main, so that the last entries are not lost when the program returns or
+// panics, the panic being logged first:
+//
+//	func main() {
+//		defer log.FlushOnExit()
+//		...
+//	}
+//
+// Fatal entries are flushed before exiting anyway.
+func FlushOnExit() {
+	if r := recover(); r != nil {
+		ent := zapcore.Entry{
+			LoggerName: "panic",
+			Time:       time.Now(),
+			Level:      zapcore.PanicLevel,
+			Message:    fmt.Sprint(r),
+			// the frames of the panic follow the deferred call and the panic
+			Stack: zap.StackSkip("", 2).String,
+		}
+		if ce := loggerCore.Check(ent, nil); ce != nil {
+			ce.Write()
+		}
+		_ = Sync()
+		panic(r)
+	}
+	_ = Sync()
+}
+
 // SetPrimaryCore changes the primary logging core. If the SetupLogging was
 // called then the previously configured core will be replaced.
 func SetPrimaryCore(core zapcore.Core) {
@@ -97,6 +294,7 @@ func SetPrimaryCore(core zapcore.Core) {
 	defer loggerMutex.Unlock()
 
 	setPrimaryCore(core)
+	unlabeledPrimaryCore = nil
 }
 
 // GetSubsystems returns a slice containing the
@@ -113,6 +311,61 @@ func GetSubsystems() []string {
 	return subs
 }
 
+// RemoveLogger removes a subsystem from the registry, i.e. its level and
+// output settings, so that processes naming subsystems after connections or
+// tenants don't grow it forever. The loggers already retrieved keep logging
+// at their last level, but are no longer controlled by SetLogLevel; Logger
+// registers the subsystem again at the default level.
+func RemoveLogger(name string) {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+
+	removeLogger(name)
+}
+
+func removeLogger(name string) {
+	delete(loggers, name)
+	delete(levels, name)
+	delete(discards, name)
+	delete(subsystemLabels, name)
+	delete(samplers, name)
+	delete(rateLimiters, name)
+	delete(debugSavedLevels, name)
+	delete(loggerRefs, name)
+}
+
+// AcquireLogger retrieves an event logger by name like Logger, counting the
+// references to the subsystem. release drops the reference, the subsystem
+// being removed like by RemoveLogger once all of them are released.
+func AcquireLogger(system string) (logger *ZapEventLogger, release func()) {
+	logger = Logger(system)
+	system = logger.system
+
+	loggerMutex.Lock()
+	loggerRefs[system]++
+	loggerMutex.Unlock()
+
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			loggerMutex.Lock()
+			defer loggerMutex.Unlock()
+
+			refs, ok := loggerRefs[system]
+			if !ok {
+				// removed meanwhile
+				return
+			}
+			if refs > 1 {
+				loggerRefs[system] = refs - 1
+				return
+			}
+			removeLogger(system)
+		})
+	}
+	return logger, release
+}
+
 func getLogger(name string) *zap.SugaredLogger {
 	loggerMutex.Lock()
 	defer loggerMutex.Unlock()
@@ -124,10 +377,17 @@ func getLogger(name string) *zap.SugaredLogger {
 			level = zap.NewAtomicLevelAt(zapcore.Level(defaultLevel))
 			levels[name] = level
 		}
+		discard := subsystemDiscard(name)
+		labels := subsystemLabelFields(name)
+		sampler := subsystemSampler(name)
+		limiter := subsystemRateLimiter(name)
 		log = zap.New(loggerCore).
 			WithOptions(
-				zap.IncreaseLevel(level),
+				zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+					return &leveledCore{Core: core, level: level, discard: discard, labels: labels, sampler: sampler, limiter: limiter}
+				}),
 				zap.AddCaller(),
+				zap.AddStacktrace(stacktraceLevel),
 			).
 			Named(name).
 			Sugar()
@@ -137,3 +397,99 @@ func getLogger(name string) *zap.SugaredLogger {
 
 	return log
 }
+
+// leveledCore applies the level, the output, the labels, the sampling and
+// the rate limit of a logger to loggerCore, and the deduplication of the
+// entries. Entries below the level, discarded or dropped still reach the
+// recentCore, when recording the last entries.
+type leveledCore struct {
+	zapcore.Core
+	level zap.AtomicLevel
+
+	// discard is set to 1 when the entries of the logger are discarded.
+	discard *uint32
+	// labels holds the label fields of the logger.
+	labels *atomic.Value
+	// sampler holds the *sampler of the logger, nil when not sampled.
+	sampler *atomic.Value
+	// limiter holds the *rateLimiter of the logger, nil when not limited.
+	limiter *atomic.Value
+
+	// labeledCore holds the *labeledCore adding the labels, built when the
+	// logger is first used with them.
+	labeledCore atomic.Value
+}
+
+// labeledCore is the core of a logger with its labels, built from the label
+// fields and the version of the cores of the logger.
+type labeledCore struct {
+	labels  []zapcore.Field
+	version uint64
+	core    zapcore.Core
+}
+
+// passes reports whether entries at lvl are passed to all the cores.
+func (c *leveledCore) passes(lvl zapcore.Level) bool {
+	return c.level.Enabled(lvl) && atomic.LoadUint32(c.discard) == 0
+}
+
+func (c *leveledCore) Enabled(lvl zapcore.Level) bool {
+	return (c.passes(lvl) || recentEntries() != nil) && c.Core.Enabled(lvl)
+}
+
+func (c *leveledCore) With(fields []zapcore.Field) zapcore.Core {
+	return &leveledCore{Core: c.Core.With(fields), level: c.level, discard: c.discard, labels: c.labels, sampler: c.sampler, limiter: c.limiter}
+}
+
+// labeled returns the core adding the labels of the logger, built again when
+// the labels or the cores changed.
+func (c *leveledCore) labeled() zapcore.Core {
+	labels, _ := c.labels.Load().([]zapcore.Field)
+	if len(labels) == 0 {
+		return c.Core
+	}
+	var version uint64
+	if multi, ok := c.Core.(*lockedMultiCore); ok {
+		version = atomic.LoadUint64(&multi.version)
+	}
+	// the labels are replaced rather than modified by SetSubsystemLabels
+	if l, _ := c.labeledCore.Load().(*labeledCore); l != nil && l.version == version &&
+		len(l.labels) == len(labels) && &l.labels[0] == &labels[0] {
+		return l.core
+	}
+	l := &labeledCore{labels: labels, version: version, core: c.Core.With(labels)}
+	c.labeledCore.Store(l)
+	return l.core
+}
+
+// sampled reports whether ent passes the sampler of the logger.
+func (c *leveledCore) sampled(ent zapcore.Entry) bool {
+	s, _ := c.sampler.Load().(*sampler)
+	return s == nil || s.sampled(ent)
+}
+
+// allowed reports whether ent is allowed by the rate limit of the logger.
+func (c *leveledCore) allowed(ent zapcore.Entry) bool {
+	l, _ := c.limiter.Load().(*rateLimiter)
+	return l == nil || l.allow(ent)
+}
+
+// unique reports whether ent is not a repetition collapsed by the
+// deduplication of the entries.
+func (c *leveledCore) unique(ent zapcore.Entry) bool {
+	d, _ := dedup.Load().(*deduplicator)
+	return d == nil || d.unique(ent)
+}
+
+func (c *leveledCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.passes(ent.Level) && c.sampled(ent) && c.allowed(ent) && c.unique(ent) {
+		return c.labeled().Check(ent, ce)
+	}
+	if recentEntries() == nil {
+		return ce
+	}
+	if multi, ok := c.labeled().(*lockedMultiCore); ok {
+		return multi.checkRecent(ent, ce)
+	}
+	return ce
+}
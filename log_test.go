@@ -1,10 +1,23 @@
 package log
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
-func TestLogger(t *testing.T) {
+func TestEventLogger(t *testing.T) {
 
 	log := Logger("abcd")
 	SetLogLevel("abcd", "debug")
@@ -14,3 +27,458 @@ func TestLogger(t *testing.T) {
 		"hello", "world",
 	)
 }
+
+func TestSetSubsystemOutput(t *testing.T) {
+	r := NewPipeReader()
+	defer r.Close()
+
+	log := getLogger("noisy")
+	SetLogLevel("noisy", "info")
+
+	go func() {
+		defer r.Close()
+		log.Info("scooby")
+		if err := SetSubsystemOutput("noisy", "none"); err != nil {
+			t.Error(err)
+		}
+		log.Info("doo")
+		if err := SetLogLevel("noisy", "debug"); err != nil {
+			t.Error(err)
+		}
+		log.Debug("doo")
+		if err := SetSubsystemOutput("noisy", "default"); err != nil {
+			t.Error(err)
+		}
+		log.Info("where are you")
+	}()
+
+	buf := &bytes.Buffer{}
+	if _, err := buf.ReadFrom(r); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); strings.Contains(out, "doo") ||
+		!strings.Contains(out, "scooby") || !strings.Contains(out, "where are you") {
+		t.Errorf("got %q, wanted the discarded entries to be missing", out)
+	}
+
+	if err := SetSubsystemOutput("noisy", "/dev/null"); err == nil {
+		t.Error("expected an error for an unknown output")
+	}
+	if err := SetSubsystemOutput("nonexistent", "none"); err != ErrNoSuchLogger {
+		t.Errorf("got %v, wanted ErrNoSuchLogger", err)
+	}
+}
+
+func TestRemoveLogger(t *testing.T) {
+	Logger("conn-1")
+	if err := SetLogLevel("conn-1", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	RemoveLogger("conn-1")
+	if _, ok := AllLevels()["conn-1"]; ok {
+		t.Error("expected the removed subsystem to be unregistered")
+	}
+	if err := SetLogLevel("conn-1", "debug"); err != ErrNoSuchLogger {
+		t.Errorf("got %v, wanted ErrNoSuchLogger", err)
+	}
+
+	_, release1 := AcquireLogger("conn-2")
+	_, release2 := AcquireLogger("conn-2")
+	release1()
+	release1()
+	if _, ok := AllLevels()["conn-2"]; !ok {
+		t.Fatal("expected the subsystem to stay registered while referenced")
+	}
+	release2()
+	for _, name := range GetSubsystems() {
+		if name == "conn-2" {
+			t.Error("expected the released subsystem to be unregistered")
+		}
+	}
+}
+
+func TestStacktraceLevel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{
+		Level:           LevelInfo,
+		Format:          FormatJSONOutput,
+		File:            path,
+		StacktraceLevel: "warn",
+	})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+	log := getLogger("stacks")
+	log.Info("scooby")
+	log.Warn("doo")
+	if err := SetStacktraceLevel("never"); err != nil {
+		t.Fatal(err)
+	}
+	log.Error("where are you")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d entries, wanted 3", len(lines))
+	}
+	for i, want := range []bool{false, true, false} {
+		if got := strings.Contains(lines[i], `"stacktrace"`); got != want {
+			t.Errorf("got entry %s, wanted a stack trace: %t", lines[i], want)
+		}
+	}
+
+	if err := SetStacktraceLevel("sometimes"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestProcessFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{
+		Level:      LevelInfo,
+		Format:     FormatJSONOutput,
+		File:       path,
+		Hostname:   true,
+		PID:        true,
+		Executable: true,
+	})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+	getLogger("process").Error("scooby")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry struct {
+		Hostname   string `json:"hostname"`
+		PID        int    `json:"pid"`
+		Executable string `json:"executable"`
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	hostname, _ := os.Hostname()
+	if entry.Hostname != hostname || entry.PID != os.Getpid() || entry.Executable == "" {
+		t.Errorf("got %s, wanted the process fields", data)
+	}
+}
+
+func TestWith(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelError, Format: FormatJSONOutput, File: path})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	log := Logger("with").With("peer", "Qm")
+	log.Warning("filtered")
+	if err := SetLogLevel("with", "warn"); err != nil {
+		t.Fatal(err)
+	}
+	log.With("request", "abc").Warning("scooby")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["msg"] != "scooby" || entry["logger"] != "with" || entry["peer"] != "Qm" || entry["request"] != "abc" {
+		t.Errorf("got %s, wanted the child logger entry", data)
+	}
+	if caller, _ := entry["caller"].(string); !strings.Contains(caller, "log_test.go") {
+		t.Errorf("got caller %s, wanted the test", caller)
+	}
+}
+
+func TestSubsystemLabels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelWarn, Format: FormatJSONOutput, File: path, Labels: map[string]string{"app": "example"}})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	dht := Logger("dht").With("peer", "Qm")
+	other := Logger("other")
+	if err := SetSubsystemLabels("dht", map[string]string{"component": "routing"}); err != nil {
+		t.Fatal(err)
+	}
+	dht.Warn("labeled")
+	other.Warn("unlabeled")
+	// the logger is labeled again when the labels or the cores change
+	if err := SetSubsystemLabels("dht", map[string]string{"component": "dht"}); err != nil {
+		t.Fatal(err)
+	}
+	Logger("dht").Warn("relabeled")
+	SetGlobalLabel("session", "1")
+	Logger("dht").Warn("session")
+	if err := SetSubsystemLabels("dht", nil); err != nil {
+		t.Fatal(err)
+	}
+	dht.Warn("cleared")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("got %d entries, wanted 5", len(lines))
+	}
+	for i, want := range []string{"routing", "", "dht", "dht", ""} {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if component, _ := entry["component"].(string); component != want || entry["app"] != "example" {
+			t.Errorf("got %s, wanted component %q", lines[i], want)
+		}
+		// dht was derived from the cores before the global label was set
+		if _, ok := entry["session"]; ok != (i == 3) {
+			t.Errorf("got %s, wanted the global label in the fourth entry only", lines[i])
+		}
+	}
+
+	if err := SetSubsystemLabels("nonexistent", nil); err != ErrNoSuchLogger {
+		t.Errorf("got %v, wanted ErrNoSuchLogger", err)
+	}
+}
+
+func TestGlobalLabels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelWarn, Format: FormatJSONOutput, File: path, Labels: map[string]string{"app": "example"}})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	log := Logger("labels")
+	log.Warn("before")
+	SetGlobalLabel("session", "abc")
+	log.Warn("during")
+	RemoveGlobalLabel("session")
+	RemoveGlobalLabel("app")
+	log.Warn("after")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d entries, wanted 3", len(lines))
+	}
+	for i, want := range []struct{ app, session string }{{"example", ""}, {"example", "abc"}, {"", ""}} {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+			t.Fatal(err)
+		}
+		app, _ := entry["app"].(string)
+		session, _ := entry["session"].(string)
+		if app != want.app || session != want.session {
+			t.Errorf("got %s, wanted app %q and session %q", lines[i], want.app, want.session)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelError, Format: FormatJSONOutput, File: path})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	log := Logger("trace")
+	if err := SetLogLevel("trace", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	log.Trace("filtered")
+	if err := SetLogLevel("trace", "trace"); err != nil {
+		t.Fatal(err)
+	}
+	if got := AllLevels()["trace"]; got != "trace" {
+		t.Errorf("got level %s, wanted trace", got)
+	}
+	log.Tracew("scooby", "peer", "Qm", "odd")
+	log.Tracef("doo %d", 3)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d entries, wanted 2", len(lines))
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "trace" || entry["msg"] != "scooby" || entry["peer"] != "Qm" || entry["odd"] != "(MISSING)" {
+		t.Errorf("got %s, wanted the trace entry", lines[0])
+	}
+	if caller, _ := entry["caller"].(string); !strings.Contains(caller, "log_test.go") {
+		t.Errorf("got caller %s, wanted the test", caller)
+	}
+	if !strings.Contains(lines[1], `"msg":"doo 3"`) {
+		t.Errorf("got %s, wanted the formatted entry", lines[1])
+	}
+}
+
+func TestCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelError, Format: FormatJSONOutput, File: path})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	log := Logger("check")
+	if log.Enabled(LevelInfo) || !log.Enabled(LevelError) || !log.Enabled(LevelFatal) {
+		t.Error("wanted the levels from error enabled")
+	}
+	if ce := log.Check(LevelInfo, "filtered"); ce != nil {
+		t.Error("got an entry below the level")
+	}
+	if err := SetLogLevel("check", "info"); err != nil {
+		t.Fatal(err)
+	}
+	if !log.Enabled(LevelInfo) {
+		t.Error("wanted info enabled once set")
+	}
+	if ce := log.Check(LevelInfo, "scooby"); ce != nil {
+		ce.Write()
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["msg"] != "scooby" {
+		t.Errorf("got %s, wanted the checked entry", data)
+	}
+	if caller, _ := entry["caller"].(string); !strings.Contains(caller, "log_test.go") {
+		t.Errorf("got caller %s, wanted the test", caller)
+	}
+}
+
+// warnVia wraps a logger, like the packages built on top of this one.
+func warnVia(log *ZapEventLogger, msg string) {
+	log.Warn(msg)
+}
+
+func TestCallerSkip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelError, Format: FormatJSONOutput, File: path, FatalBehavior: FatalLog})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	log := Logger("skip").CallerSkip(1)
+	SetLogLevel("skip", "warn")
+	_, _, line, _ := runtime.Caller(0)
+	warnVia(log, "scooby")
+	func() { log.Fatal("doo") }()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for i, want := range []int{line + 1, line + 2} {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if caller, _ := entry["caller"].(string); !strings.HasSuffix(caller, fmt.Sprintf("log_test.go:%d", want)) {
+			t.Errorf("got caller %s, wanted line %d", caller, want)
+		}
+	}
+}
+
+func TestClose(t *testing.T) {
+	received := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{
+		Level:  LevelError,
+		Format: FormatJSONOutput,
+		File:   path,
+		HTTP:   &HTTPConfig{URL: srv.URL, FlushInterval: time.Hour},
+	})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	log := getLogger("close")
+	log.Error("scooby")
+	if err := Sync(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-received:
+	default:
+		t.Error("wanted the buffered entry sent on Sync")
+	}
+	log.Error("doo")
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-received:
+	default:
+		t.Error("wanted the buffered entry sent on Close")
+	}
+	log.Error("discarded")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 2 {
+		t.Errorf("got %d entries, wanted the entries before Close", len(lines))
+	}
+}
+
+func TestFlushOnExit(t *testing.T) {
+	capture := StartCapture(nil)
+	defer capture.Stop()
+
+	func() {
+		defer func() {
+			if r := recover(); r != "scooby" {
+				t.Errorf("recovered %v, wanted the panic", r)
+			}
+		}()
+		defer FlushOnExit()
+		panic("scooby")
+	}()
+
+	entries := capture.Entries()
+	if len(entries) != 1 || entries[0].Level != LevelPanic || entries[0].Message != "scooby" {
+		t.Errorf("got %v, wanted the panic entry", entries)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	SetupLogging(Config{
+		Level:  LevelError,
+		Format: FormatJSONOutput,
+		HTTP:   &HTTPConfig{URL: srv.URL, FlushInterval: time.Hour},
+	})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	log := getLogger("shutdown")
+	for i := 0; i < 3; i++ {
+		log.Error("scooby")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := Shutdown(ctx)
+	var dropped *DroppedEntriesError
+	if !errors.As(err, &dropped) || dropped.Dropped != 3 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, wanted the 3 entries dropped", err)
+	}
+}
@@ -0,0 +1,100 @@
+//go:build android && cgo
+// +build android,cgo
+
+package log
+
+/*
+#cgo LDFLAGS: -llog
+#include <stdlib.h>
+#include <android/log.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"unsafe"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// logcatAvailable reports whether entries can be sent to logcat.
+const logcatAvailable = true
+
+// newLogcatCore creates a core writing entries to logcat, with the subsystem
+// as the tag.
+func newLogcatCore(level LogLevel) (zapcore.Core, error) {
+	// logcat records the time and the priority of every message
+	encCfg := newEncoderConfig()
+	encCfg.TimeKey = ""
+	encCfg.LevelKey = ""
+	encCfg.NameKey = ""
+
+	return &logcatCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          zapcore.NewConsoleEncoder(encCfg),
+	}, nil
+}
+
+type logcatCore struct {
+	zapcore.LevelEnabler
+	enc zapcore.Encoder
+}
+
+func (c *logcatCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.enc = c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(clone.enc)
+	}
+	return &clone
+}
+
+func (c *logcatCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// logcatPriority maps a log level to an Android log priority.
+func logcatPriority(lvl zapcore.Level) C.int {
+	switch {
+	case lvl < zapcore.DebugLevel:
+		return C.ANDROID_LOG_VERBOSE
+	case lvl == zapcore.DebugLevel:
+		return C.ANDROID_LOG_DEBUG
+	case lvl == zapcore.InfoLevel:
+		return C.ANDROID_LOG_INFO
+	case lvl == zapcore.WarnLevel:
+		return C.ANDROID_LOG_WARN
+	case lvl == zapcore.ErrorLevel:
+		return C.ANDROID_LOG_ERROR
+	default:
+		return C.ANDROID_LOG_FATAL
+	}
+}
+
+func (c *logcatCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	tag := ent.LoggerName
+	if tag == "" {
+		tag = "go-log"
+	}
+	ctag := C.CString(tag)
+	defer C.free(unsafe.Pointer(ctag))
+	cmsg := C.CString(string(bytes.TrimRight(buf.Bytes(), "\n")))
+	defer C.free(unsafe.Pointer(cmsg))
+
+	C.__android_log_write(logcatPriority(ent.Level), ctag, cmsg)
+	return nil
+}
+
+func (c *logcatCore) Sync() error {
+	return nil
+}
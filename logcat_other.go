@@ -0,0 +1,17 @@
+//go:build !android || !cgo
+// +build !android !cgo
+
+package log
+
+import (
+	"errors"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// logcatAvailable reports whether entries can be sent to logcat.
+const logcatAvailable = false
+
+func newLogcatCore(level LogLevel) (zapcore.Core, error) {
+	return nil, errors.New("logcat is only available on Android with cgo")
+}
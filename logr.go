@@ -0,0 +1,79 @@
+package log
+
+import (
+	"github.com/go-logr/logr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogrSink returns a logr.LogSink writing to the logger of system, for
+// libraries logging with logr, i.e. controller-runtime and the Kubernetes
+// clients:
+//
+//	logger := logr.New(log.LogrSink("k8s"))
+//
+// Verbosity 0 is LevelInfo, and higher verbosities are LevelDebug. Names
+// added with WithName are appended to system like with Named, the level of
+// system applying to them.
+func LogrSink(system string) logr.LogSink {
+	return newLogrSink(getLogger(system), 0)
+}
+
+type logrSink struct {
+	logger *zap.SugaredLogger
+	// depth is the number of frames between the callers and logger
+	depth int
+	// skipLogger is logger skipping the frames to the callers
+	skipLogger *zap.SugaredLogger
+}
+
+func newLogrSink(logger *zap.SugaredLogger, depth int) *logrSink {
+	return &logrSink{
+		logger: logger,
+		depth:  depth,
+		// the methods of logrSink are a frame too
+		skipLogger: logger.Desugar().WithOptions(zap.AddCallerSkip(depth + 1)).Sugar(),
+	}
+}
+
+var _ logr.CallDepthLogSink = (*logrSink)(nil)
+
+func (s *logrSink) Init(info logr.RuntimeInfo) {
+	*s = *newLogrSink(s.logger, s.depth+info.CallDepth)
+}
+
+func (s *logrSink) Enabled(level int) bool {
+	return s.logger.Desugar().Core().Enabled(logrLevel(level))
+}
+
+func (s *logrSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	if level > 0 {
+		s.skipLogger.Debugw(msg, keysAndValues...)
+		return
+	}
+	s.skipLogger.Infow(msg, keysAndValues...)
+}
+
+func (s *logrSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.skipLogger.Errorw(msg, append([]interface{}{zap.Error(err)}, keysAndValues...)...)
+}
+
+func (s *logrSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return newLogrSink(s.logger.With(keysAndValues...), s.depth)
+}
+
+func (s *logrSink) WithName(name string) logr.LogSink {
+	return newLogrSink(s.logger.Named(name), s.depth)
+}
+
+func (s *logrSink) WithCallDepth(depth int) logr.LogSink {
+	return newLogrSink(s.logger, s.depth+depth)
+}
+
+// logrLevel returns the level of a logr verbosity.
+func logrLevel(level int) zapcore.Level {
+	if level > 0 {
+		return zapcore.DebugLevel
+	}
+	return zapcore.InfoLevel
+}
@@ -0,0 +1,54 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestLogrSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelInfo, Format: FormatJSONOutput, File: path})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	logger := logr.New(LogrSink("logr")).WithName("controller").WithValues("peer", "Qm")
+	logger.V(1).Info("filtered")
+	if logger.V(1).Enabled() {
+		t.Error("wanted verbosity 1 to be disabled at info")
+	}
+	logger.Info("scooby", "id", 3)
+	logger.Error(errors.New("where are you"), "doo")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d entries, wanted 2", len(lines))
+	}
+	for i, want := range []map[string]interface{}{
+		{"level": "info", "msg": "scooby", "id": float64(3)},
+		{"level": "error", "msg": "doo", "error": "where are you"},
+	} {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+			t.Fatal(err)
+		}
+		want["logger"] = "logr.controller"
+		want["peer"] = "Qm"
+		for key, value := range want {
+			if entry[key] != value {
+				t.Errorf("got %s %v, wanted %v", key, entry[key], value)
+			}
+		}
+		if caller, _ := entry["caller"].(string); !strings.Contains(caller, "logr_test.go") {
+			t.Errorf("got caller %s, wanted the test", caller)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package log
+
+import (
+	"sort"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogrusHook returns a logrus hook writing the entries of logrus loggers as
+// entries of the logger of system, with their fields, for migrating from
+// logrus incrementally:
+//
+//	logrus.AddHook(log.LogrusHook("legacy"))
+//	logrus.SetOutput(ioutil.Discard)
+//
+// Entries are enabled by the level of system. Logrus itself exits or panics
+// after the Fatal and Panic entries.
+func LogrusHook(system string) logrus.Hook {
+	return &logrusHook{
+		core: getLogger(system).Desugar().Core(),
+		name: system,
+	}
+}
+
+type logrusHook struct {
+	core zapcore.Core
+	name string
+}
+
+func (h *logrusHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *logrusHook) Fire(entry *logrus.Entry) error {
+	ent := zapcore.Entry{
+		LoggerName: h.name,
+		Time:       entry.Time,
+		Level:      logrusLevel(entry.Level),
+		Message:    entry.Message,
+	}
+	if entry.Caller != nil {
+		ent.Caller = zapcore.EntryCaller{
+			Defined:  true,
+			PC:       entry.Caller.PC,
+			File:     entry.Caller.File,
+			Line:     entry.Caller.Line,
+			Function: entry.Caller.Function,
+		}
+	}
+	// the core is written directly, for logrus to exit or panic
+	ce := h.core.Check(ent, nil)
+	if ce == nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fields := make([]zapcore.Field, 0, len(keys))
+	for _, key := range keys {
+		if err, ok := entry.Data[key].(error); ok {
+			fields = append(fields, zap.NamedError(key, err))
+		} else {
+			fields = append(fields, zap.Any(key, entry.Data[key]))
+		}
+	}
+	ce.Write(fields...)
+	return nil
+}
+
+// logrusLevel returns the level of entries of a logrus level.
+func logrusLevel(level logrus.Level) zapcore.Level {
+	switch level {
+	case logrus.PanicLevel:
+		return zapcore.PanicLevel
+	case logrus.FatalLevel:
+		return zapcore.FatalLevel
+	case logrus.ErrorLevel:
+		return zapcore.ErrorLevel
+	case logrus.WarnLevel:
+		return zapcore.WarnLevel
+	case logrus.InfoLevel:
+		return zapcore.InfoLevel
+	case logrus.DebugLevel:
+		return zapcore.DebugLevel
+	default:
+		return zapcore.Level(LevelTrace)
+	}
+}
@@ -0,0 +1,54 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLogrusHook(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelInfo, Format: FormatJSONOutput, File: path})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	logger.SetLevel(logrus.TraceLevel)
+	logger.SetReportCaller(true)
+	logger.AddHook(LogrusHook("logrus"))
+	logger.Debug("filtered")
+	logger.WithFields(logrus.Fields{"id": 3, "peer": "Qm"}).WithError(errors.New("doo")).Warn("scooby")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d entries, wanted the warning only", len(lines))
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]interface{}{
+		"level":  "warn",
+		"logger": "logrus",
+		"msg":    "scooby",
+		"id":     float64(3),
+		"peer":   "Qm",
+		"error":  "doo",
+	} {
+		if entry[key] != want {
+			t.Errorf("got %s %v, wanted %v", key, entry[key], want)
+		}
+	}
+	if caller, _ := entry["caller"].(string); !strings.Contains(caller, "logrus_test.go") {
+		t.Errorf("got caller %s, wanted the test", caller)
+	}
+}
@@ -0,0 +1,43 @@
+package log
+
+import (
+	"errors"
+	"net/url"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newLogstashCore creates a core streaming entries to the tcp input of
+// Logstash with the json_lines codec. It is configured by a URL of the form
+//
+//	logstash://host:5000?buffer=1000
+//
+// Entries are written as one JSON object per line, with their time in
+// @timestamp and @version set to "1" as expected by Logstash. Like tcp://, the
+// connection is reestablished with backoff and up to buffer entries are kept
+// while Logstash is unreachable.
+func newLogstashCore(u *url.URL, level LogLevel) (zapcore.Core, error) {
+	if u.Host == "" {
+		return nil, errors.New("missing Logstash address")
+	}
+	sink, err := newStreamSink("tcp", u.Host, u.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	encCfg := newEncoderConfig()
+	encCfg.TimeKey = "@timestamp"
+	encCfg.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+	encCfg.MessageKey = "message"
+	encCfg.NameKey = "subsystem"
+	encCfg.LineEnding = "\n"
+
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(encCfg), sink, zap.NewAtomicLevelAt(zapcore.Level(level)))
+	return &outputCore{
+		Core: core.With([]zapcore.Field{zap.String("@version", "1")}),
+		close: func() {
+			sink.Close() // nolint:errcheck
+		},
+	}, nil
+}
@@ -0,0 +1,51 @@
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestLogstashCore(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	u, _ := url.Parse("logstash://" + l.Addr().String())
+	core, err := newLogstashCore(u, LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*outputCore).Close()
+
+	zap.New(core).Named("dht").Info("hello", zap.Int("peers", 3))
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second)) // nolint:errcheck
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ev map[string]interface{}
+	if err := json.Unmarshal(line, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev["@version"] != "1" || ev["message"] != "hello" || ev["subsystem"] != "dht" || ev["peers"] != 3.0 {
+		t.Errorf("got event %s", line)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, ev["@timestamp"].(string)); err != nil {
+		t.Errorf("invalid @timestamp: %s", err)
+	}
+}
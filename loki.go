@@ -0,0 +1,141 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LokiConfig configures shipping logs to Grafana Loki.
+type LokiConfig struct {
+	// URL is the base URL of the Loki server, i.e. "http://localhost:3100".
+	URL string
+
+	// BatchSize is the maximum number of entries sent in one push request.
+	// Defaults to 100.
+	BatchSize int
+
+	// FlushInterval is the maximum time an entry waits before being pushed.
+	// Defaults to one second.
+	FlushInterval time.Duration
+
+	// Username and Password are used for basic authentication when set.
+	Username string
+	Password string
+}
+
+// lokiPushPath is the path of the Loki push API.
+const lokiPushPath = "/loki/api/v1/push"
+
+// newLokiCore creates a core pushing entries to Loki. Every subsystem is sent
+// as its own stream, labeled with labels plus the subsystem name.
+func newLokiCore(cfg LokiConfig, labels map[string]string, level LogLevel) (zapcore.Core, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("missing Loki URL")
+	}
+
+	streamLabels := make(map[string]string, len(labels))
+	for k, v := range labels {
+		streamLabels[lokiLabelName(k)] = v
+	}
+
+	// the timestamp is sent next to every line
+	encCfg := newEncoderConfig()
+	encCfg.TimeKey = ""
+
+	l := &lokiClient{
+		url:      strings.TrimSuffix(cfg.URL, "/") + lokiPushPath,
+		username: cfg.Username,
+		password: cfg.Password,
+		labels:   streamLabels,
+		client:   newHTTPClient(nil),
+	}
+
+	return &batchCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          zapcore.NewJSONEncoder(encCfg),
+		batcher:      newBatcher(cfg.BatchSize, cfg.FlushInterval, l.push),
+	}, nil
+}
+
+// lokiLabelName converts a key into a valid Loki label name.
+func lokiLabelName(key string) string {
+	name := []byte(key)
+	for i, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= '0' && c <= '9' && i > 0) {
+			name[i] = '_'
+		}
+	}
+	return string(name)
+}
+
+type lokiClient struct {
+	url      string
+	username string
+	password string
+	labels   map[string]string
+	client   *http.Client
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func (l *lokiClient) push(batch []batchEntry) error {
+	streams := make(map[string]*lokiStream)
+	var order []string
+	for _, e := range batch {
+		s, ok := streams[e.ent.LoggerName]
+		if !ok {
+			labels := make(map[string]string, len(l.labels)+1)
+			for k, v := range l.labels {
+				labels[k] = v
+			}
+			if e.ent.LoggerName != "" {
+				labels["subsystem"] = e.ent.LoggerName
+			}
+			s = &lokiStream{Stream: labels}
+			streams[e.ent.LoggerName] = s
+			order = append(order, e.ent.LoggerName)
+		}
+		s.Values = append(s.Values, [2]string{
+			strconv.FormatInt(e.ent.Time.UnixNano(), 10),
+			string(bytes.TrimRight(e.data, "\n")),
+		})
+	}
+
+	req := struct {
+		Streams []*lokiStream `json:"streams"`
+	}{}
+	for _, name := range order {
+		req.Streams = append(req.Streams, streams[name])
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, l.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if l.username != "" || l.password != "" {
+		httpReq.SetBasicAuth(l.username, l.password)
+	}
+
+	resp, err := l.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	return checkHTTPResponse(resp)
+}
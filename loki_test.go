@@ -0,0 +1,61 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestLokiCore(t *testing.T) {
+	type pushRequest struct {
+		Streams []lokiStream `json:"streams"`
+	}
+
+	var got pushRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != lokiPushPath {
+			t.Errorf("got path %q, wanted %q", r.URL.Path, lokiPushPath)
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			t.Errorf("missing basic auth credentials")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	core, err := newLokiCore(LokiConfig{
+		URL:      srv.URL,
+		Username: "user",
+		Password: "secret",
+	}, map[string]string{"app": "example"}, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*batchCore).Close()
+
+	logger := zap.New(core)
+	logger.Named("dht").Info("scooby")
+	logger.Named("dht").Info("velma")
+	logger.Named("swarm").Error("shaggy")
+
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Streams) != 2 {
+		t.Fatalf("got %d streams, wanted 2", len(got.Streams))
+	}
+	dht := got.Streams[0]
+	if dht.Stream["subsystem"] != "dht" || dht.Stream["app"] != "example" {
+		t.Errorf("got labels %v, wanted subsystem and app labels", dht.Stream)
+	}
+	if len(dht.Values) != 2 {
+		t.Errorf("got %d values, wanted 2", len(dht.Values))
+	}
+}
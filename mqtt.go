@@ -0,0 +1,348 @@
+package log
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// MQTTConfig configures publishing entries to an MQTT broker.
+type MQTTConfig struct {
+	// URL is the broker URL, i.e. "tcp://localhost:1883", or with TLS
+	// "ssl://localhost:8883". Credentials may be given as user info.
+	URL string
+
+	// ClientID identifies the client to the broker. Defaults to a random
+	// "go-log-" prefixed identifier.
+	ClientID string
+
+	// Topic is the topic template of the entries, in which "{subsystem}" and
+	// "{level}" are replaced by the subsystem and the level of the entry.
+	// Defaults to "logs/{subsystem}".
+	Topic string
+
+	// QoS is the MQTT quality of service level of the entries: 0, 1 or 2.
+	QoS byte
+
+	// BatchSize is the maximum number of entries sent at once.
+	// Defaults to 100.
+	BatchSize int
+
+	// FlushInterval is the maximum time an entry waits before being sent.
+	// Defaults to one second.
+	FlushInterval time.Duration
+
+	// TLS configures TLS connections when not nil.
+	TLS *tls.Config
+}
+
+const (
+	defaultMQTTTopic = "logs/{subsystem}"
+	mqttMaxRetries   = 3
+)
+
+// MQTT 3.1.1 control packet types.
+const (
+	mqttConnect    = 1
+	mqttConnack    = 2
+	mqttPublish    = 3
+	mqttPuback     = 4
+	mqttPubrec     = 5
+	mqttPubrel     = 6
+	mqttPubcomp    = 7
+	mqttDisconnect = 14
+)
+
+var mqttPorts = map[string]string{"tcp": "1883", "mqtt": "1883", "ssl": "8883", "mqtts": "8883"}
+
+// newMQTTCore creates a core publishing JSON entries to an MQTT broker.
+func newMQTTCore(cfg MQTTConfig, level LogLevel) (zapcore.Core, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("missing MQTT broker URL")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	port, ok := mqttPorts[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported MQTT URL scheme %q", u.Scheme)
+	}
+	if cfg.QoS > 2 {
+		return nil, fmt.Errorf("invalid MQTT QoS %d", cfg.QoS)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+	topic := cfg.Topic
+	if topic == "" {
+		topic = defaultMQTTTopic
+	}
+	clientID := cfg.ClientID
+	if clientID == "" {
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return nil, err
+		}
+		clientID = "go-log-" + hex.EncodeToString(id)
+	}
+
+	m := &mqttClient{
+		addr:     addr,
+		clientID: clientID,
+		topic:    topic,
+		qos:      cfg.QoS,
+		tls:      cfg.TLS,
+	}
+	if (u.Scheme == "ssl" || u.Scheme == "mqtts") && m.tls == nil {
+		m.tls = &tls.Config{}
+	}
+	if m.tls != nil && m.tls.ServerName == "" {
+		m.tls = m.tls.Clone()
+		m.tls.ServerName = u.Hostname()
+	}
+	if u.User != nil {
+		m.user = u.User.Username()
+		m.password, m.hasPassword = u.User.Password()
+	}
+
+	encCfg := newEncoderConfig()
+	encCfg.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+
+	return &batchCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          zapcore.NewJSONEncoder(encCfg),
+		batcher:      newBatcher(cfg.BatchSize, cfg.FlushInterval, m.send),
+		close:        m.close,
+	}, nil
+}
+
+// mqttTopicLevel returns s made usable in a topic name.
+func mqttTopicLevel(s string) string {
+	if s == "" {
+		return "root"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '+' || r == '#' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// mqttRefusedError is returned when the broker refuses the connection, after
+// which retrying is pointless.
+type mqttRefusedError struct {
+	code byte
+}
+
+func (e *mqttRefusedError) Error() string {
+	return fmt.Sprintf("MQTT connection refused with return code %d", e.code)
+}
+
+type mqttClient struct {
+	addr        string
+	clientID    string
+	topic       string
+	qos         byte
+	user        string
+	password    string
+	hasPassword bool
+	tls         *tls.Config
+
+	conn     net.Conn
+	packetID uint16
+}
+
+func (m *mqttClient) send(batch []batchEntry) error {
+	return retryWithBackoff(mqttMaxRetries, func() (bool, error) {
+		if m.conn == nil {
+			if err := m.connect(); err != nil {
+				_, refused := err.(*mqttRefusedError)
+				return !refused, err
+			}
+		}
+		if err := m.publish(batch); err != nil {
+			m.close() // nolint:errcheck
+			return true, err
+		}
+		return false, nil
+	})
+}
+
+func (m *mqttClient) publish(batch []batchEntry) error {
+	var buf bytes.Buffer
+	pending := make(map[uint16]bool)
+	for _, e := range batch {
+		topic := strings.NewReplacer(
+			"{subsystem}", mqttTopicLevel(e.ent.LoggerName),
+			"{level}", levelName(e.ent.Level),
+		).Replace(m.topic)
+
+		var body []byte
+		body = appendMQTTString(body, topic)
+		if m.qos > 0 {
+			m.packetID++
+			if m.packetID == 0 {
+				m.packetID = 1
+			}
+			pending[m.packetID] = true
+			body = append(body, byte(m.packetID>>8), byte(m.packetID))
+		}
+		body = append(body, bytes.TrimRight(e.data, "\n")...)
+		writeMQTTPacket(&buf, mqttPublish<<4|m.qos<<1, body)
+	}
+
+	m.conn.SetDeadline(time.Now().Add(httpTimeout)) // nolint:errcheck
+	if _, err := m.conn.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	for len(pending) > 0 {
+		typ, body, err := readMQTTPacket(m.conn)
+		if err != nil {
+			return err
+		}
+		if len(body) < 2 {
+			continue
+		}
+		id := uint16(body[0])<<8 | uint16(body[1])
+		switch typ >> 4 {
+		case mqttPuback, mqttPubcomp:
+			delete(pending, id)
+		case mqttPubrec:
+			var rel bytes.Buffer
+			writeMQTTPacket(&rel, mqttPubrel<<4|0x02, body[:2])
+			if _, err := m.conn.Write(rel.Bytes()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mqttClient) connect() error {
+	conn, err := net.DialTimeout("tcp", m.addr, httpTimeout)
+	if err != nil {
+		return err
+	}
+	if m.tls != nil {
+		conn = tls.Client(conn, m.tls)
+	}
+	conn.SetDeadline(time.Now().Add(httpTimeout)) // nolint:errcheck
+
+	// clean session, no keep alive
+	flags := byte(0x02)
+	if m.user != "" {
+		flags |= 0x80
+	}
+	if m.hasPassword {
+		flags |= 0x40
+	}
+	body := appendMQTTString(nil, "MQTT")
+	body = append(body, 4, flags, 0, 0)
+	body = appendMQTTString(body, m.clientID)
+	if m.user != "" {
+		body = appendMQTTString(body, m.user)
+	}
+	if m.hasPassword {
+		body = appendMQTTString(body, m.password)
+	}
+
+	var buf bytes.Buffer
+	writeMQTTPacket(&buf, mqttConnect<<4, body)
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		conn.Close()
+		return err
+	}
+	typ, ack, err := readMQTTPacket(conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if typ>>4 != mqttConnack || len(ack) != 2 {
+		conn.Close()
+		return fmt.Errorf("unexpected MQTT packet type %d", typ>>4)
+	}
+	if ack[1] != 0 {
+		conn.Close()
+		return &mqttRefusedError{ack[1]}
+	}
+	m.conn = conn
+	return nil
+}
+
+func (m *mqttClient) close() error {
+	if m.conn == nil {
+		return nil
+	}
+	m.conn.Write([]byte{mqttDisconnect << 4, 0}) // nolint:errcheck
+	err := m.conn.Close()
+	m.conn = nil
+	return err
+}
+
+// appendMQTTString appends s prefixed by its length.
+func appendMQTTString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+// writeMQTTPacket writes a control packet with its remaining length.
+func writeMQTTPacket(buf *bytes.Buffer, header byte, body []byte) {
+	buf.WriteByte(header)
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		buf.WriteByte(b)
+		if n == 0 {
+			break
+		}
+	}
+	buf.Write(body)
+}
+
+// readMQTTPacket reads a control packet, returning its first byte and body.
+func readMQTTPacket(r io.Reader) (byte, []byte, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, nil, err
+	}
+	header := b[0]
+
+	n, shift := 0, 0
+	for {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		n |= int(b[0]&0x7f) << shift
+		if b[0]&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return 0, nil, errors.New("invalid MQTT remaining length")
+		}
+	}
+
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
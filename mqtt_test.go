@@ -0,0 +1,80 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMQTTCore(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	type message struct {
+		topic string
+		data  map[string]interface{}
+	}
+	got := make(chan message, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			typ, body, err := readMQTTPacket(conn)
+			if err != nil {
+				return
+			}
+			var reply bytes.Buffer
+			switch typ >> 4 {
+			case mqttConnect:
+				if !bytes.Contains(body, []byte("scooby")) {
+					t.Errorf("got connect packet %q, wanted the user name", body)
+				}
+				writeMQTTPacket(&reply, mqttConnack<<4, []byte{0, 0})
+			case mqttPublish:
+				if qos := typ >> 1 & 0x03; qos != 2 {
+					t.Errorf("got QoS %d", qos)
+				}
+				n := int(body[0])<<8 | int(body[1])
+				msg := message{topic: string(body[2 : 2+n])}
+				json.Unmarshal(body[4+n:], &msg.data)
+				got <- msg
+				writeMQTTPacket(&reply, mqttPubrec<<4, body[2+n:4+n])
+			case mqttPubrel:
+				writeMQTTPacket(&reply, mqttPubcomp<<4, body)
+			}
+			conn.Write(reply.Bytes())
+		}
+	}()
+
+	core, err := newMQTTCore(MQTTConfig{
+		URL: "tcp://scooby:doo@" + l.Addr().String(),
+		QoS: 2,
+	}, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*batchCore).Close()
+
+	zap.New(core).Named("dht").Warn("where are you")
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-got
+	if msg.topic != "logs/dht" {
+		t.Errorf("got topic %q", msg.topic)
+	}
+	if msg.data["msg"] != "where are you" {
+		t.Errorf("got %v, wanted the entry", msg.data)
+	}
+}
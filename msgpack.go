@@ -0,0 +1,291 @@
+package log
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// This file implements the subset of MessagePack needed by the outputs
+// speaking it. See https://github.com/msgpack/msgpack/blob/master/spec.md
+
+func appendMsgpackNil(b []byte) []byte {
+	return append(b, 0xc0)
+}
+
+func appendMsgpackBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xc3)
+	}
+	return append(b, 0xc2)
+}
+
+func appendMsgpackInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return appendMsgpackUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return append(append(b, 0xd1), byte(v>>8), byte(v))
+	case v >= math.MinInt32:
+		return appendUint32(append(b, 0xd2), uint32(v))
+	default:
+		return appendUint64(append(b, 0xd3), uint64(v))
+	}
+}
+
+func appendMsgpackUint(b []byte, v uint64) []byte {
+	switch {
+	case v <= math.MaxInt8:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return append(append(b, 0xcd), byte(v>>8), byte(v))
+	case v <= math.MaxUint32:
+		return appendUint32(append(b, 0xce), uint32(v))
+	default:
+		return appendUint64(append(b, 0xcf), v)
+	}
+}
+
+func appendMsgpackFloat(b []byte, v float64) []byte {
+	return appendUint64(append(b, 0xcb), math.Float64bits(v))
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n <= 31:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = append(append(b, 0xda), byte(n>>8), byte(n))
+	default:
+		b = appendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgpackBin(b []byte, v []byte) []byte {
+	n := len(v)
+	switch {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = append(append(b, 0xc5), byte(n>>8), byte(n))
+	default:
+		b = appendUint32(append(b, 0xc6), uint32(n))
+	}
+	return append(b, v...)
+}
+
+func appendMsgpackArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return append(append(b, 0xdc), byte(n>>8), byte(n))
+	default:
+		return appendUint32(append(b, 0xdd), uint32(n))
+	}
+}
+
+func appendMsgpackMapHeader(b []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return append(append(b, 0xde), byte(n>>8), byte(n))
+	default:
+		return appendUint32(append(b, 0xdf), uint32(n))
+	}
+}
+
+// appendMsgpackExt appends an extension value of type typ.
+func appendMsgpackExt(b []byte, typ int8, data []byte) []byte {
+	switch n := len(data); n {
+	case 1:
+		b = append(b, 0xd4, byte(typ))
+	case 2:
+		b = append(b, 0xd5, byte(typ))
+	case 4:
+		b = append(b, 0xd6, byte(typ))
+	case 8:
+		b = append(b, 0xd7, byte(typ))
+	case 16:
+		b = append(b, 0xd8, byte(typ))
+	default:
+		switch {
+		case n <= math.MaxUint8:
+			b = append(b, 0xc7, byte(n), byte(typ))
+		case n <= math.MaxUint16:
+			b = append(b, 0xc8, byte(n>>8), byte(n), byte(typ))
+		default:
+			b = appendUint32(append(b, 0xc9), uint32(n))
+			b = append(b, byte(typ))
+		}
+	}
+	return append(b, data...)
+}
+
+// appendMsgpackValue appends v, which must be made of the types produced by
+// decoding JSON into an interface{}, or of their integer counterparts. Map
+// keys are sorted so that the output is deterministic.
+func appendMsgpackValue(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return appendMsgpackNil(b)
+	case bool:
+		return appendMsgpackBool(b, v)
+	case int:
+		return appendMsgpackInt(b, int64(v))
+	case int64:
+		return appendMsgpackInt(b, v)
+	case uint64:
+		return appendMsgpackUint(b, v)
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return appendMsgpackInt(b, int64(v))
+		}
+		return appendMsgpackFloat(b, v)
+	case string:
+		return appendMsgpackString(b, v)
+	case []byte:
+		return appendMsgpackBin(b, v)
+	case []interface{}:
+		b = appendMsgpackArrayHeader(b, len(v))
+		for _, e := range v {
+			b = appendMsgpackValue(b, e)
+		}
+		return b
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = appendMsgpackMapHeader(b, len(v))
+		for _, k := range keys {
+			b = appendMsgpackString(b, k)
+			b = appendMsgpackValue(b, v[k])
+		}
+		return b
+	default:
+		return appendMsgpackString(b, fmt.Sprint(v))
+	}
+}
+
+// appendMsgpackTime appends t as a timestamp extension, in its most compact
+// representation.
+func appendMsgpackTime(b []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	switch {
+	case sec >= 0 && sec <= math.MaxUint32 && nsec == 0:
+		return appendMsgpackExt(b, -1, appendUint32(nil, uint32(sec)))
+	case sec >= 0 && sec < 1<<34:
+		return appendMsgpackExt(b, -1, appendUint64(nil, nsec<<34|uint64(sec)))
+	default:
+		return appendMsgpackExt(b, -1, appendUint64(appendUint32(nil, uint32(nsec)), uint64(sec)))
+	}
+}
+
+// msgpackFormat is the binaryFormat of MessagePack.
+type msgpackFormat struct{}
+
+func (msgpackFormat) appendNil(b []byte) []byte               { return appendMsgpackNil(b) }
+func (msgpackFormat) appendBool(b []byte, v bool) []byte      { return appendMsgpackBool(b, v) }
+func (msgpackFormat) appendInt(b []byte, v int64) []byte      { return appendMsgpackInt(b, v) }
+func (msgpackFormat) appendUint(b []byte, v uint64) []byte    { return appendMsgpackUint(b, v) }
+func (msgpackFormat) appendFloat(b []byte, v float64) []byte  { return appendMsgpackFloat(b, v) }
+func (msgpackFormat) appendString(b []byte, v string) []byte  { return appendMsgpackString(b, v) }
+func (msgpackFormat) appendBytes(b []byte, v []byte) []byte   { return appendMsgpackBin(b, v) }
+func (msgpackFormat) appendTime(b []byte, v time.Time) []byte { return appendMsgpackTime(b, v) }
+func (msgpackFormat) appendKey(b []byte, key string) []byte   { return appendMsgpackString(b, key) }
+
+func (msgpackFormat) appendArray(b []byte, n int, body []byte) []byte {
+	return append(appendMsgpackArrayHeader(b, n), body...)
+}
+
+func (msgpackFormat) appendMap(b []byte, n int, body []byte) []byte {
+	return append(appendMsgpackMapHeader(b, n), body...)
+}
+
+// newMsgpackEncoder returns the encoder of FormatMsgpackOutput, writing
+// entries as MessagePack maps with the keys of cfg.
+func newMsgpackEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return newBinaryEncoder(cfg, msgpackFormat{}, false)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
+
+var errMsgpackShort = errors.New("msgpack: unexpected end of data")
+
+// readMsgpackStringMap decodes a map with string keys and values.
+func readMsgpackStringMap(b []byte) (map[string]string, error) {
+	if len(b) == 0 {
+		return nil, errMsgpackShort
+	}
+	var n int
+	switch c := b[0]; {
+	case c&0xf0 == 0x80:
+		n, b = int(c&0x0f), b[1:]
+	case c == 0xde && len(b) >= 3:
+		n, b = int(binary.BigEndian.Uint16(b[1:])), b[3:]
+	default:
+		return nil, fmt.Errorf("msgpack: expected a map, got 0x%x", c)
+	}
+
+	m := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		key, rest, err := readMsgpackString(b)
+		if err != nil {
+			return nil, err
+		}
+		value, rest, err := readMsgpackString(rest)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+		b = rest
+	}
+	return m, nil
+}
+
+func readMsgpackString(b []byte) (string, []byte, error) {
+	if len(b) == 0 {
+		return "", nil, errMsgpackShort
+	}
+	var n int
+	switch c := b[0]; {
+	case c&0xe0 == 0xa0:
+		n, b = int(c&0x1f), b[1:]
+	case c == 0xd9 && len(b) >= 2:
+		n, b = int(b[1]), b[2:]
+	case c == 0xda && len(b) >= 3:
+		n, b = int(binary.BigEndian.Uint16(b[1:])), b[3:]
+	case c == 0xdb && len(b) >= 5:
+		n, b = int(binary.BigEndian.Uint32(b[1:])), b[5:]
+	default:
+		return "", nil, fmt.Errorf("msgpack: expected a string, got 0x%x", c)
+	}
+	if len(b) < n {
+		return "", nil, errMsgpackShort
+	}
+	return string(b[:n]), b[n:], nil
+}
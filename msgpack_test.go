@@ -0,0 +1,139 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// readMsgpack decodes the MessagePack value at the start of b, with maps,
+// arrays, strings, numbers and timestamps.
+func readMsgpack(b []byte) (interface{}, []byte, error) {
+	if len(b) == 0 {
+		return nil, nil, errMsgpackShort
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), b[1:], nil
+	case c >= 0xe0:
+		return int64(int8(c)), b[1:], nil
+	case c&0xf0 == 0x80:
+		return readMsgpackMap(b[1:], int(c&0x0f))
+	case c&0xf0 == 0x90:
+		return readMsgpackArray(b[1:], int(c&0x0f))
+	case c&0xe0 == 0xa0, c == 0xd9, c == 0xda, c == 0xdb:
+		return readMsgpackString(b)
+	}
+	switch c {
+	case 0xc0:
+		return nil, b[1:], nil
+	case 0xc2, 0xc3:
+		return c == 0xc3, b[1:], nil
+	case 0xcb:
+		return math.Float64frombits(binary.BigEndian.Uint64(b[1:])), b[9:], nil
+	case 0xcc:
+		return int64(b[1]), b[2:], nil
+	case 0xcd:
+		return int64(binary.BigEndian.Uint16(b[1:])), b[3:], nil
+	case 0xce:
+		return int64(binary.BigEndian.Uint32(b[1:])), b[5:], nil
+	case 0xcf:
+		return binary.BigEndian.Uint64(b[1:]), b[9:], nil
+	case 0xd0:
+		return int64(int8(b[1])), b[2:], nil
+	case 0xd1:
+		return int64(int16(binary.BigEndian.Uint16(b[1:]))), b[3:], nil
+	case 0xd2:
+		return int64(int32(binary.BigEndian.Uint32(b[1:]))), b[5:], nil
+	case 0xd3:
+		return int64(binary.BigEndian.Uint64(b[1:])), b[9:], nil
+	case 0xde:
+		return readMsgpackMap(b[3:], int(binary.BigEndian.Uint16(b[1:])))
+	case 0xdc:
+		return readMsgpackArray(b[3:], int(binary.BigEndian.Uint16(b[1:])))
+	case 0xd6:
+		return time.Unix(int64(binary.BigEndian.Uint32(b[2:])), 0), b[6:], nil
+	case 0xd7:
+		v := binary.BigEndian.Uint64(b[2:])
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)), b[10:], nil
+	case 0xc7:
+		return time.Unix(int64(binary.BigEndian.Uint64(b[7:])), int64(binary.BigEndian.Uint32(b[3:]))), b[15:], nil
+	}
+	return nil, nil, fmt.Errorf("unsupported msgpack type 0x%x", c)
+}
+
+func readMsgpackMap(b []byte, n int) (interface{}, []byte, error) {
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, rest, err := readMsgpackString(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		if m[key], b, err = readMsgpack(rest); err != nil {
+			return nil, nil, err
+		}
+	}
+	return m, b, nil
+}
+
+func readMsgpackArray(b []byte, n int) (interface{}, []byte, error) {
+	a := make([]interface{}, n)
+	for i := range a {
+		var err error
+		if a[i], b, err = readMsgpack(b); err != nil {
+			return nil, nil, err
+		}
+	}
+	return a, b, nil
+}
+
+func TestMsgpackFormat(t *testing.T) {
+	buf := &bytes.Buffer{}
+	core := newCore(FormatMsgpackOutput, zapcore.AddSync(buf), LevelDebug)
+	logger := zap.New(core).Named("dht").With(zap.String("peer", "QmFoo"), zap.Namespace("query"))
+	logger.Info("scooby", zap.Int("count", -300), zap.Duration("took", time.Second), zap.Strings("keys", []string{"a", "b"}))
+	logger.Warn("doo")
+
+	decoded, rest, err := readMsgpack(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := decoded.(map[string]interface{})
+	if entry["level"] != "info" || entry["logger"] != "dht" || entry["msg"] != "scooby" || entry["peer"] != "QmFoo" {
+		t.Errorf("got %v, wanted the entry keys and the context", entry)
+	}
+	if ts, ok := entry["ts"].(time.Time); !ok || time.Since(ts) > time.Minute {
+		t.Errorf("got time %v, wanted a timestamp", entry["ts"])
+	}
+	query, _ := entry["query"].(map[string]interface{})
+	if query["count"] != int64(-300) || query["took"] != int64(time.Second) || len(query["keys"].([]interface{})) != 2 {
+		t.Errorf("got %v, wanted the fields in the query namespace", query)
+	}
+
+	decoded, rest, err = readMsgpack(rest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := decoded.(map[string]interface{}); entry["msg"] != "doo" || len(rest) != 0 {
+		t.Errorf("got %v and %d trailing bytes, wanted the second entry", entry, len(rest))
+	}
+}
+
+func TestMsgpackTime(t *testing.T) {
+	for _, ts := range []time.Time{time.Unix(1700000000, 0), time.Unix(1700000000, 123456789), time.Unix(1<<35, 5)} {
+		decoded, _, err := readMsgpack(appendMsgpackTime(nil, ts))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := decoded.(time.Time); !got.Equal(ts) {
+			t.Errorf("got %v, wanted %v", got, ts)
+		}
+	}
+}
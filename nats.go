@@ -0,0 +1,329 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// NATSConfig configures publishing entries to NATS, optionally to a
+// JetStream stream.
+type NATSConfig struct {
+	// URL is the server URL, i.e. "nats://localhost:4222". Credentials may be
+	// given as user info, either "user:password" or a token. The tls scheme
+	// requires TLS.
+	URL string
+
+	// Subject is the subject template of the entries, in which "{subsystem}"
+	// and "{level}" are replaced by the subsystem and the level of the entry.
+	// Defaults to "logs.{subsystem}.{level}".
+	Subject string
+
+	// JetStream enables waiting for the acknowledgement of every entry by the
+	// JetStream stream bound to its subject.
+	JetStream bool
+
+	// BatchSize is the maximum number of entries sent at once.
+	// Defaults to 100.
+	BatchSize int
+
+	// FlushInterval is the maximum time an entry waits before being sent.
+	// Defaults to one second.
+	FlushInterval time.Duration
+
+	// TLS configures TLS connections when not nil.
+	TLS *tls.Config
+}
+
+const (
+	defaultNATSSubject = "logs.{subsystem}.{level}"
+	defaultNATSPort    = "4222"
+	natsMaxRetries     = 3
+)
+
+// newNATSCore creates a core publishing JSON entries to NATS.
+func newNATSCore(cfg NATSConfig, level LogLevel) (zapcore.Core, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("missing NATS URL")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "nats" && u.Scheme != "tls" {
+		return nil, fmt.Errorf("unsupported NATS URL scheme %q", u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultNATSPort)
+	}
+	subject := cfg.Subject
+	if subject == "" {
+		subject = defaultNATSSubject
+	}
+
+	n := &natsClient{
+		addr:      addr,
+		subject:   subject,
+		jetStream: cfg.JetStream,
+		tls:       cfg.TLS,
+	}
+	if u.Scheme == "tls" && n.tls == nil {
+		n.tls = &tls.Config{}
+	}
+	if n.tls != nil && n.tls.ServerName == "" {
+		n.tls = n.tls.Clone()
+		n.tls.ServerName = u.Hostname()
+	}
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			n.user, n.password = u.User.Username(), password
+		} else {
+			n.token = u.User.Username()
+		}
+	}
+	if n.jetStream {
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return nil, err
+		}
+		n.inbox = "_INBOX." + hex.EncodeToString(id)
+	}
+
+	encCfg := newEncoderConfig()
+	encCfg.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+
+	return &batchCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          zapcore.NewJSONEncoder(encCfg),
+		batcher:      newBatcher(cfg.BatchSize, cfg.FlushInterval, n.send),
+		close:        n.close,
+	}, nil
+}
+
+// natsSubjectToken returns s made usable as a subject token.
+func natsSubjectToken(s string) string {
+	if s == "" {
+		return "root"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\r', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// natsServerError is an error reported by the server, after which retrying
+// is pointless.
+type natsServerError struct {
+	msg string
+}
+
+func (e *natsServerError) Error() string {
+	return "NATS error: " + e.msg
+}
+
+type natsClient struct {
+	addr      string
+	subject   string
+	jetStream bool
+	inbox     string
+	user      string
+	password  string
+	token     string
+	tls       *tls.Config
+
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (n *natsClient) send(batch []batchEntry) error {
+	var buf bytes.Buffer
+	for i, e := range batch {
+		subject := strings.NewReplacer(
+			"{subsystem}", natsSubjectToken(e.ent.LoggerName),
+			"{level}", levelName(e.ent.Level),
+		).Replace(n.subject)
+		data := bytes.TrimRight(e.data, "\n")
+		if n.jetStream {
+			fmt.Fprintf(&buf, "PUB %s %s.%d %d\r\n", subject, n.inbox, i, len(data))
+		} else {
+			fmt.Fprintf(&buf, "PUB %s %d\r\n", subject, len(data))
+		}
+		buf.Write(data)
+		buf.WriteString("\r\n")
+	}
+	buf.WriteString("PING\r\n")
+
+	return retryWithBackoff(natsMaxRetries, func() (bool, error) {
+		if n.conn == nil {
+			if err := n.connect(); err != nil {
+				_, serverErr := err.(*natsServerError)
+				return !serverErr, err
+			}
+		}
+		acks := 0
+		if n.jetStream {
+			acks = len(batch)
+		}
+
+		n.conn.SetDeadline(time.Now().Add(httpTimeout)) // nolint:errcheck
+		_, err := n.conn.Write(buf.Bytes())
+		if err == nil {
+			err = n.wait(acks)
+		}
+		if err != nil {
+			n.close() // nolint:errcheck
+			_, serverErr := err.(*natsServerError)
+			return !serverErr, err
+		}
+		return false, nil
+	})
+}
+
+func (n *natsClient) connect() error {
+	conn, err := net.DialTimeout("tcp", n.addr, httpTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(httpTimeout)) // nolint:errcheck
+	r := bufio.NewReader(conn)
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("unexpected NATS greeting %q", strings.TrimSpace(line))
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	if err := json.Unmarshal([]byte(line[len("INFO "):]), &info); err != nil {
+		conn.Close()
+		return err
+	}
+	if n.tls != nil || info.TLSRequired {
+		tlsConfig := n.tls
+		if tlsConfig == nil {
+			host, _, _ := net.SplitHostPort(n.addr)
+			tlsConfig = &tls.Config{ServerName: host}
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return err
+		}
+		conn = tlsConn
+		r = bufio.NewReader(conn)
+	}
+
+	options, err := json.Marshal(struct {
+		Verbose  bool   `json:"verbose"`
+		Pedantic bool   `json:"pedantic"`
+		Name     string `json:"name"`
+		Lang     string `json:"lang"`
+		Version  string `json:"version"`
+		Protocol int    `json:"protocol"`
+		User     string `json:"user,omitempty"`
+		Pass     string `json:"pass,omitempty"`
+		Token    string `json:"auth_token,omitempty"`
+	}{false, false, "go-log", "go", "1.0", 1, n.user, n.password, n.token})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	var handshake bytes.Buffer
+	fmt.Fprintf(&handshake, "CONNECT %s\r\n", options)
+	if n.jetStream {
+		fmt.Fprintf(&handshake, "SUB %s.* 1\r\n", n.inbox)
+	}
+	handshake.WriteString("PING\r\n")
+
+	n.conn, n.r = conn, r
+	if _, err := conn.Write(handshake.Bytes()); err != nil {
+		n.close() // nolint:errcheck
+		return err
+	}
+	if err := n.wait(0); err != nil {
+		n.close() // nolint:errcheck
+		return err
+	}
+	return nil
+}
+
+// wait reads from the server until it answers the last PING and the given
+// number of JetStream acknowledgements have been received.
+func (n *natsClient) wait(acks int) error {
+	var ackErr error
+	pong := false
+	for !pong || acks > 0 {
+		line, err := n.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "PING":
+			if _, err := io.WriteString(n.conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case line == "PONG":
+			pong = true
+		case strings.HasPrefix(line, "-ERR"):
+			return &natsServerError{strings.Trim(strings.TrimSpace(line[len("-ERR"):]), "'")}
+		case strings.HasPrefix(line, "MSG "):
+			fields := strings.Fields(line)
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil {
+				return fmt.Errorf("invalid NATS message %q", line)
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(n.r, payload); err != nil {
+				return err
+			}
+			var ack struct {
+				Error *struct {
+					Code        int    `json:"code"`
+					Description string `json:"description"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(payload[:size], &ack); err != nil {
+				return err
+			}
+			if ack.Error != nil && ackErr == nil {
+				ackErr = &natsServerError{fmt.Sprintf("JetStream error %d: %s", ack.Error.Code, ack.Error.Description)}
+			}
+			acks--
+		}
+	}
+	return ackErr
+}
+
+func (n *natsClient) close() error {
+	if n.conn == nil {
+		return nil
+	}
+	err := n.conn.Close()
+	n.conn, n.r = nil, nil
+	return err
+}
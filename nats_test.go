@@ -0,0 +1,87 @@
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestNATSCore(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	type message struct {
+		subject string
+		data    map[string]interface{}
+	}
+	got := make(chan message, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "INFO {\"server_id\":\"test\"}\r\n")
+
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "CONNECT":
+				if !strings.Contains(line, `"auth_token":"secret"`) {
+					t.Errorf("got %s, wanted the token", line)
+				}
+			case "PING":
+				fmt.Fprint(conn, "PONG\r\n")
+			case "PUB":
+				size, _ := strconv.Atoi(fields[len(fields)-1])
+				payload := make([]byte, size+2)
+				io.ReadFull(r, payload)
+
+				msg := message{subject: fields[1]}
+				json.Unmarshal(payload[:size], &msg.data)
+				got <- msg
+
+				// acknowledge like a JetStream stream
+				ack := `{"stream":"LOGS","seq":1}`
+				fmt.Fprintf(conn, "MSG %s 1 %d\r\n%s\r\n", fields[2], len(ack), ack)
+			}
+		}
+	}()
+
+	core, err := newNATSCore(NATSConfig{
+		URL:       "nats://secret@" + l.Addr().String(),
+		JetStream: true,
+	}, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*batchCore).Close()
+
+	zap.New(core).Named("dht.net").Warn("scooby")
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-got
+	if msg.subject != "logs.dht_net.warn" {
+		t.Errorf("got subject %q", msg.subject)
+	}
+	if msg.data["msg"] != "scooby" {
+		t.Errorf("got %v, wanted the entry", msg.data)
+	}
+}
@@ -0,0 +1,359 @@
+package log
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// OTLPConfig configures exporting logs with the OpenTelemetry protocol over
+// gRPC. Unset fields default to the standard OTEL_* environment variables.
+type OTLPConfig struct {
+	// Endpoint is the address of the collector, i.e. "localhost:4317".
+	// Defaults to OTEL_EXPORTER_OTLP_LOGS_ENDPOINT, then to
+	// OTEL_EXPORTER_OTLP_ENDPOINT, then to "localhost:4317".
+	Endpoint string
+
+	// Insecure disables TLS. Endpoints given as http:// URLs are insecure.
+	Insecure bool
+
+	// TLS configures the TLS connection when not nil.
+	TLS *tls.Config
+
+	// Headers are sent with every export request, i.e. for authentication.
+	Headers map[string]string
+
+	// ServiceName is the service.name resource attribute. Defaults to
+	// OTEL_SERVICE_NAME, then to the program name.
+	ServiceName string
+
+	// ResourceAttributes are additional resource attributes, merged with the
+	// ones of OTEL_RESOURCE_ATTRIBUTES.
+	ResourceAttributes map[string]string
+
+	// BatchSize is the maximum number of records exported at once.
+	// Defaults to 100.
+	BatchSize int
+
+	// FlushInterval is the maximum time a record waits before being exported.
+	// Defaults to one second.
+	FlushInterval time.Duration
+}
+
+const defaultOTLPEndpoint = "localhost:4317"
+
+// newOTLPCore creates a core exporting entries as OpenTelemetry log records.
+// Every subsystem is exported as its own instrumentation scope.
+func newOTLPCore(cfg OTLPConfig, level LogLevel) (zapcore.Core, error) {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_LOGS_ENDPOINT")
+	}
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if endpoint == "" {
+		endpoint = defaultOTLPEndpoint
+	}
+	insecure := cfg.Insecure
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OTLP endpoint: %s", err)
+		}
+		insecure = insecure || u.Scheme == "http"
+		endpoint = u.Host
+	}
+
+	var creds grpc.DialOption
+	if insecure {
+		creds = grpc.WithInsecure()
+	} else {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(cfg.TLS))
+	}
+	conn, err := grpc.Dial(endpoint, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &otlpClient{
+		client:   collogspb.NewLogsServiceClient(conn),
+		resource: otlpResource(cfg),
+	}
+	if len(cfg.Headers) > 0 {
+		o.headers = metadata.New(cfg.Headers)
+	}
+
+	return &otlpCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		batcher:      newBatcher(cfg.BatchSize, cfg.FlushInterval, o.export),
+		conn:         conn,
+	}, nil
+}
+
+// otlpResource returns the resource describing this process.
+func otlpResource(cfg OTLPConfig) *resourcepb.Resource {
+	attrs := make(map[string]string)
+	for _, kv := range strings.Split(os.Getenv("OTEL_RESOURCE_ATTRIBUTES"), ",") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 {
+			k, _ := url.QueryUnescape(strings.TrimSpace(parts[0]))
+			v, _ := url.QueryUnescape(strings.TrimSpace(parts[1]))
+			attrs[k] = v
+		}
+	}
+	for k, v := range cfg.ResourceAttributes {
+		attrs[k] = v
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = os.Getenv("OTEL_SERVICE_NAME")
+	}
+	if serviceName == "" {
+		serviceName = attrs["service.name"]
+	}
+	if serviceName == "" {
+		serviceName = filepath.Base(os.Args[0])
+	}
+	attrs["service.name"] = serviceName
+	if hostname, err := os.Hostname(); err == nil && attrs["host.name"] == "" {
+		attrs["host.name"] = hostname
+	}
+
+	resource := &resourcepb.Resource{}
+	for k, v := range attrs {
+		resource.Attributes = append(resource.Attributes, otlpKeyValue(k, v))
+	}
+	resource.Attributes = append(resource.Attributes, otlpKeyValue("process.pid", os.Getpid()))
+	return resource
+}
+
+type otlpCore struct {
+	zapcore.LevelEnabler
+	batcher *batcher
+	conn    *grpc.ClientConn
+	fields  []zapcore.Field
+}
+
+func (c *otlpCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+	return &clone
+}
+
+func (c *otlpCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *otlpCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for i := range c.fields {
+		c.fields[i].AddTo(enc)
+	}
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+	resolveLazyFields(enc.Fields)
+
+	severity, severityText := otlpSeverity(ent.Level)
+	record := &logspb.LogRecord{
+		TimeUnixNano:         uint64(ent.Time.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       severity,
+		SeverityText:         severityText,
+		Body:                 otlpValue(ent.Message),
+	}
+	for k, v := range enc.Fields {
+		record.Attributes = append(record.Attributes, otlpKeyValue(k, v))
+	}
+	if ent.Caller.Defined {
+		record.Attributes = append(record.Attributes,
+			otlpKeyValue("code.filepath", ent.Caller.File),
+			otlpKeyValue("code.lineno", ent.Caller.Line),
+		)
+		if ent.Caller.Function != "" {
+			record.Attributes = append(record.Attributes, otlpKeyValue("code.function", ent.Caller.Function))
+		}
+	}
+	if ent.Stack != "" {
+		record.Attributes = append(record.Attributes, otlpKeyValue("exception.stacktrace", ent.Stack))
+	}
+
+	c.batcher.add(batchEntry{ent: ent, payload: record})
+	return nil
+}
+
+func (c *otlpCore) Sync() error {
+	return c.batcher.Sync()
+}
+
+func (c *otlpCore) Close() error {
+	err := c.batcher.Close()
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (c *otlpCore) shutdown(ctx context.Context) (int, error) {
+	dropped, err := c.batcher.shutdown(ctx)
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return dropped, err
+}
+
+// otlpSeverity maps a log level to an OpenTelemetry severity.
+func otlpSeverity(lvl zapcore.Level) (logspb.SeverityNumber, string) {
+	switch lvl {
+	case zapcore.Level(LevelTrace):
+		return logspb.SeverityNumber_SEVERITY_NUMBER_TRACE, "TRACE"
+	case zapcore.DebugLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG, "DEBUG"
+	case zapcore.InfoLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO, "INFO"
+	case zapcore.WarnLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN, "WARN"
+	case zapcore.ErrorLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, "ERROR"
+	case zapcore.DPanicLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR4, "DPANIC"
+	case zapcore.PanicLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL, "PANIC"
+	case zapcore.FatalLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL4, "FATAL"
+	default:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED, capitalLevelName(lvl)
+	}
+}
+
+func otlpKeyValue(key string, value interface{}) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: otlpValue(value)}
+}
+
+// otlpValue converts a value produced by zapcore.MapObjectEncoder.
+func otlpValue(value interface{}) *commonpb.AnyValue {
+	switch v := value.(type) {
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+	case int:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case int8, int16, int32, int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: toInt64(v)}}
+	case uint, uint8, uint16, uint32, uint64, uintptr:
+		u := toUint64(v)
+		if u > math.MaxInt64 {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: float64(u)}}
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(u)}}
+	case float32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: float64(v)}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v}}
+	case []byte:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: v}}
+	case []interface{}:
+		values := make([]*commonpb.AnyValue, len(v))
+		for i := range v {
+			values[i] = otlpValue(v[i])
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+	case map[string]interface{}:
+		values := make([]*commonpb.KeyValue, 0, len(v))
+		for k := range v {
+			values = append(values, otlpKeyValue(k, v[k]))
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: values}}}
+	case time.Time:
+		return otlpValue(v.Format(time.RFC3339Nano))
+	case nil:
+		return &commonpb.AnyValue{}
+	default:
+		return otlpValue(fmt.Sprint(v))
+	}
+}
+
+func toInt64(v interface{}) int64 {
+	switch v := v.(type) {
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	default:
+		return v.(int64)
+	}
+}
+
+func toUint64(v interface{}) uint64 {
+	switch v := v.(type) {
+	case uint:
+		return uint64(v)
+	case uint8:
+		return uint64(v)
+	case uint16:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case uintptr:
+		return uint64(v)
+	default:
+		return v.(uint64)
+	}
+}
+
+type otlpClient struct {
+	client   collogspb.LogsServiceClient
+	resource *resourcepb.Resource
+	headers  metadata.MD
+}
+
+func (o *otlpClient) export(batch []batchEntry) error {
+	scopes := make(map[string]*logspb.ScopeLogs)
+	resourceLogs := &logspb.ResourceLogs{Resource: o.resource}
+	for _, e := range batch {
+		scope, ok := scopes[e.ent.LoggerName]
+		if !ok {
+			scope = &logspb.ScopeLogs{Scope: &commonpb.InstrumentationScope{Name: e.ent.LoggerName}}
+			scopes[e.ent.LoggerName] = scope
+			resourceLogs.ScopeLogs = append(resourceLogs.ScopeLogs, scope)
+		}
+		scope.LogRecords = append(scope.LogRecords, e.payload.(*logspb.LogRecord))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), httpTimeout)
+	defer cancel()
+	if o.headers != nil {
+		ctx = metadata.NewOutgoingContext(ctx, o.headers)
+	}
+
+	_, err := o.client.Export(ctx, &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{resourceLogs},
+	})
+	return err
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+type testLogsServer struct {
+	collogspb.UnimplementedLogsServiceServer
+	requests chan *collogspb.ExportLogsServiceRequest
+}
+
+func (s *testLogsServer) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	s.requests <- req
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func TestOTLPCore(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	logs := &testLogsServer{requests: make(chan *collogspb.ExportLogsServiceRequest, 1)}
+	collogspb.RegisterLogsServiceServer(srv, logs)
+	go srv.Serve(l)
+	defer srv.Stop()
+
+	core, err := newOTLPCore(OTLPConfig{
+		Endpoint:    "http://" + l.Addr().String(),
+		ServiceName: "myapp",
+	}, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*otlpCore).Close()
+
+	zap.New(core).Named("dht").Warn("scooby", zap.Int("count", 3))
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-logs.requests
+	resourceLogs := req.ResourceLogs[0]
+	var service string
+	for _, kv := range resourceLogs.Resource.Attributes {
+		if kv.Key == "service.name" {
+			service = kv.Value.GetStringValue()
+		}
+	}
+	if service != "myapp" {
+		t.Errorf("got service name %q, wanted myapp", service)
+	}
+
+	scope := resourceLogs.ScopeLogs[0]
+	if scope.Scope.Name != "dht" || len(scope.LogRecords) != 1 {
+		t.Fatalf("got scope %q with %d records", scope.Scope.Name, len(scope.LogRecords))
+	}
+	record := scope.LogRecords[0]
+	if record.SeverityNumber != logspb.SeverityNumber_SEVERITY_NUMBER_WARN || record.Body.GetStringValue() != "scooby" {
+		t.Errorf("got severity %v and body %v", record.SeverityNumber, record.Body)
+	}
+	if len(record.Attributes) != 1 || record.Attributes[0].Key != "count" || record.Attributes[0].Value.GetIntValue() != 3 {
+		t.Errorf("got attributes %v", record.Attributes)
+	}
+}
@@ -21,11 +21,16 @@ func (p *PipeReader) Read(data []byte) (int, error) {
 }
 
 // Close unregisters the reader from the logger.
+//
+// The pipe is closed first, so that Close does not wait on a log entry
+// blocked because the reader stopped reading.
 func (p *PipeReader) Close() error {
+	err := p.closer.Close()
 	if p.core != nil {
 		loggerCore.DeleteCore(p.core)
+		err = multierr.Append(err, p.core.Sync())
 	}
-	return multierr.Append(p.core.Sync(), p.closer.Close())
+	return err
 }
 
 // NewPipeReader creates a new in-memory reader that reads from all loggers
@@ -40,7 +45,7 @@ func (p *PipeReader) Close() error {
 func NewPipeReader(opts ...PipeReaderOption) *PipeReader {
 	opt := pipeReaderOptions{
 		format: FormatJSONOutput,
-		level:  LevelDebug,
+		level:  levelAll,
 	}
 
 	for _, o := range opts {
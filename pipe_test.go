@@ -6,6 +6,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -67,6 +68,7 @@ func TestNewPipeReaderLevel(t *testing.T) {
 		Level:  LevelDebug,
 		Format: FormatPlaintextOutput,
 	})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
 
 	log := getLogger("test")
 
@@ -101,3 +103,30 @@ func TestNewPipeReaderLevel(t *testing.T) {
 	}
 
 }
+
+func TestNewPipeReaderCloseWhileBlocked(t *testing.T) {
+	log := getLogger("test")
+
+	r := NewPipeReader()
+
+	// nothing reads the pipe, so logging blocks
+	logged := make(chan struct{})
+	go func() {
+		log.Error("scooby")
+		close(logged)
+	}()
+
+	closed := make(chan struct{})
+	go func() {
+		r.Close()
+		close(closed)
+	}()
+
+	for _, done := range []chan struct{}{closed, logged} {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("closing the pipe reader did not unblock logging")
+		}
+	}
+}
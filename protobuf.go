@@ -0,0 +1,148 @@
+package log
+
+import (
+	"math"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// This file implements the encoding of entries with the protobuf schema of
+// entry.proto, without generated code.
+
+// Field numbers of entry.proto.
+const (
+	protoEntryTime       = 1
+	protoEntryLevel      = 2
+	protoEntrySubsystem  = 3
+	protoEntryMessage    = 4
+	protoEntryCaller     = 5
+	protoEntryStacktrace = 6
+	protoEntryFields     = 7
+
+	protoValueString = 1
+	protoValueBool   = 2
+	protoValueInt    = 3
+	protoValueUint   = 4
+	protoValueDouble = 5
+	protoValueBytes  = 6
+	protoValueArray  = 7
+	protoValueObject = 8
+	protoValueTime   = 9
+
+	// protoObjectKey and protoValue are the fields of the keys and values
+	// of objects, and of the values of arrays.
+	protoObjectKey = 1
+	protoValue     = 2
+)
+
+// protoFormat is the binaryFormat of the fields of entry.proto, every value
+// being a Value field of an Object or Array.
+type protoFormat struct{}
+
+// appendValue appends a Value made of body.
+func (protoFormat) appendValue(b []byte, body []byte) []byte {
+	return protowire.AppendBytes(protowire.AppendTag(b, protoValue, protowire.BytesType), body)
+}
+
+func (f protoFormat) appendNil(b []byte) []byte {
+	return f.appendValue(b, nil)
+}
+
+func (f protoFormat) appendBool(b []byte, v bool) []byte {
+	body := protowire.AppendTag(nil, protoValueBool, protowire.VarintType)
+	return f.appendValue(b, protowire.AppendVarint(body, protowire.EncodeBool(v)))
+}
+
+func (f protoFormat) appendInt(b []byte, v int64) []byte {
+	body := protowire.AppendTag(nil, protoValueInt, protowire.VarintType)
+	return f.appendValue(b, protowire.AppendVarint(body, protowire.EncodeZigZag(v)))
+}
+
+func (f protoFormat) appendUint(b []byte, v uint64) []byte {
+	body := protowire.AppendTag(nil, protoValueUint, protowire.VarintType)
+	return f.appendValue(b, protowire.AppendVarint(body, v))
+}
+
+func (f protoFormat) appendFloat(b []byte, v float64) []byte {
+	body := protowire.AppendTag(nil, protoValueDouble, protowire.Fixed64Type)
+	return f.appendValue(b, protowire.AppendFixed64(body, math.Float64bits(v)))
+}
+
+func (f protoFormat) appendString(b []byte, v string) []byte {
+	body := protowire.AppendTag(nil, protoValueString, protowire.BytesType)
+	return f.appendValue(b, protowire.AppendString(body, v))
+}
+
+func (f protoFormat) appendBytes(b []byte, v []byte) []byte {
+	body := protowire.AppendTag(nil, protoValueBytes, protowire.BytesType)
+	return f.appendValue(b, protowire.AppendBytes(body, v))
+}
+
+func (f protoFormat) appendTime(b []byte, v time.Time) []byte {
+	body := protowire.AppendTag(nil, protoValueTime, protowire.Fixed64Type)
+	return f.appendValue(b, protowire.AppendFixed64(body, uint64(v.UnixNano())))
+}
+
+func (protoFormat) appendKey(b []byte, key string) []byte {
+	return protowire.AppendString(protowire.AppendTag(b, protoObjectKey, protowire.BytesType), key)
+}
+
+func (f protoFormat) appendArray(b []byte, n int, body []byte) []byte {
+	value := protowire.AppendTag(nil, protoValueArray, protowire.BytesType)
+	return f.appendValue(b, protowire.AppendBytes(value, body))
+}
+
+func (f protoFormat) appendMap(b []byte, n int, body []byte) []byte {
+	value := protowire.AppendTag(nil, protoValueObject, protowire.BytesType)
+	return f.appendValue(b, protowire.AppendBytes(value, body))
+}
+
+// protoEncoder is the encoder of FormatProtobufOutput, writing entries as
+// Entry messages of entry.proto prefixed with their length as a varint.
+type protoEncoder struct {
+	*binaryEncoder
+}
+
+func newProtoEncoder() zapcore.Encoder {
+	// the keys of entries are fields of Entry
+	return &protoEncoder{newBinaryEncoder(zapcore.EncoderConfig{}, protoFormat{}, false)}
+}
+
+func (e *protoEncoder) Clone() zapcore.Encoder {
+	return &protoEncoder{e.binaryEncoder.clone()}
+}
+
+func (e *protoEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	msg := protowire.AppendTag(nil, protoEntryTime, protowire.Fixed64Type)
+	msg = protowire.AppendFixed64(msg, uint64(ent.Time.UnixNano()))
+	if lvl := int64(ent.Level) + 1; lvl > 0 {
+		msg = protowire.AppendVarint(protowire.AppendTag(msg, protoEntryLevel, protowire.VarintType), uint64(lvl))
+	}
+	var caller string
+	if ent.Caller.Defined {
+		caller = callerString(ent.Caller)
+	}
+	for _, f := range []struct {
+		num   protowire.Number
+		value string
+	}{
+		{protoEntrySubsystem, ent.LoggerName},
+		{protoEntryMessage, ent.Message},
+		{protoEntryCaller, caller},
+		{protoEntryStacktrace, ent.Stack},
+	} {
+		if f.value != "" {
+			msg = protowire.AppendString(protowire.AppendTag(msg, f.num, protowire.BytesType), f.value)
+		}
+	}
+	if body, n := e.encodeFields(ent, fields).body(false); n > 0 {
+		msg = protowire.AppendBytes(protowire.AppendTag(msg, protoEntryFields, protowire.BytesType), body)
+	}
+
+	buf := encoderPool.Get()
+	buf.Write(protowire.AppendBytes(nil, msg)) // nolint:errcheck
+	return buf, nil
+}
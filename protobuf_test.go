@@ -0,0 +1,83 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// readProtoFields returns the fields of a message by number, the values of
+// repeated fields being appended.
+func readProtoFields(t *testing.T, b []byte) map[protowire.Number][]interface{} {
+	fields := make(map[protowire.Number][]interface{})
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		b = b[n:]
+		var v interface{}
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		b = b[n:]
+		fields[num] = append(fields[num], v)
+	}
+	return fields
+}
+
+func TestProtobufFormat(t *testing.T) {
+	buf := &bytes.Buffer{}
+	core := newCore(FormatProtobufOutput, zapcore.AddSync(buf), LevelDebug)
+	now := time.Now()
+	zap.New(core).Named("dht").With(zap.String("peer", "QmFoo")).Warn("scooby", zap.Int("count", -3), zap.Ints("ids", []int{1, 2}))
+
+	msg, n := protowire.ConsumeBytes(buf.Bytes())
+	if n != buf.Len() {
+		t.Fatalf("got a message of %d bytes out of %d, wanted a length prefix", n, buf.Len())
+	}
+	entry := readProtoFields(t, msg)
+	if ts := time.Unix(0, int64(entry[protoEntryTime][0].(uint64))); ts.Before(now.Add(-time.Minute)) || ts.After(now.Add(time.Minute)) {
+		t.Errorf("got time %v, wanted about %v", ts, now)
+	}
+	if entry[protoEntryLevel][0] != uint64(2) {
+		t.Errorf("got level %v, wanted WARN", entry[protoEntryLevel])
+	}
+	if string(entry[protoEntrySubsystem][0].([]byte)) != "dht" || string(entry[protoEntryMessage][0].([]byte)) != "scooby" {
+		t.Errorf("got %v, wanted the subsystem and message", entry)
+	}
+
+	object := readProtoFields(t, entry[protoEntryFields][0].([]byte))
+	var keys []string
+	for _, k := range object[protoObjectKey] {
+		keys = append(keys, string(k.([]byte)))
+	}
+	if len(keys) != 3 || keys[0] != "peer" || keys[1] != "count" || keys[2] != "ids" {
+		t.Fatalf("got keys %v, wanted the context and fields", keys)
+	}
+	values := object[protoValue]
+	if s := readProtoFields(t, values[0].([]byte))[protoValueString]; string(s[0].([]byte)) != "QmFoo" {
+		t.Errorf("got peer %v, wanted QmFoo", s)
+	}
+	if i := readProtoFields(t, values[1].([]byte))[protoValueInt]; protowire.DecodeZigZag(i[0].(uint64)) != -3 {
+		t.Errorf("got count %v, wanted -3", i)
+	}
+	array := readProtoFields(t, readProtoFields(t, values[2].([]byte))[protoValueArray][0].([]byte))
+	if len(array[protoValue]) != 2 {
+		t.Errorf("got ids %v, wanted 2 values", array)
+	}
+}
@@ -0,0 +1,138 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// defaultRateLimitSummaryInterval is the default interval of the summaries
+// of the entries suppressed by a rate limit.
+const defaultRateLimitSummaryInterval = 10 * time.Second
+
+// RateLimitConfig limits the rate of the entries of a subsystem with a token
+// bucket: Burst entries may be logged at once, and then Rate entries per
+// second, the others being dropped. The number of entries dropped is logged
+// as a single "suppressed N entries from <subsystem>" entry at LevelWarn at
+// most every SummaryInterval, defaulting to 10 seconds. Entries at
+// LevelDPanic and above are never dropped.
+type RateLimitConfig struct {
+	Rate            float64
+	Burst           int
+	SummaryInterval time.Duration
+}
+
+// parseRateLimit parses a RateLimitConfig written "<rate>:<burst>".
+func parseRateLimit(s string) (RateLimitConfig, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return RateLimitConfig{}, fmt.Errorf("invalid rate limit %q, expected <rate>:<burst>", s)
+	}
+	rate, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || rate < 0 {
+		return RateLimitConfig{}, fmt.Errorf("invalid rate %q", parts[0])
+	}
+	burst, err := strconv.Atoi(parts[1])
+	if err != nil || burst < 0 {
+		return RateLimitConfig{}, fmt.Errorf("invalid burst %q", parts[1])
+	}
+	return RateLimitConfig{Rate: rate, Burst: burst}, nil
+}
+
+// rateLimiter is the token bucket of a subsystem.
+type rateLimiter struct {
+	subsystem string
+	cfg       RateLimitConfig
+
+	mu         sync.Mutex // guards the fields below
+	tokens     float64
+	last       time.Time
+	suppressed int
+	summary    *time.Timer
+}
+
+func newRateLimiter(subsystem string, cfg RateLimitConfig) *rateLimiter {
+	if cfg.SummaryInterval <= 0 {
+		cfg.SummaryInterval = defaultRateLimitSummaryInterval
+	}
+	return &rateLimiter{
+		subsystem: subsystem,
+		cfg:       cfg,
+		tokens:    float64(cfg.Burst),
+		last:      time.Now(),
+	}
+}
+
+// allow reports whether ent is logged, taking a token from the bucket.
+func (l *rateLimiter) allow(ent zapcore.Entry) bool {
+	if ent.Level >= zapcore.DPanicLevel {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.cfg.Rate
+	if max := float64(l.cfg.Burst); l.tokens > max {
+		l.tokens = max
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return true
+	}
+
+	l.suppressed++
+	if l.summary == nil {
+		l.summary = time.AfterFunc(l.cfg.SummaryInterval, l.logSummary)
+	}
+	return false
+}
+
+// logSummary logs the number of entries suppressed since the last summary.
+func (l *rateLimiter) logSummary() {
+	l.mu.Lock()
+	n := l.suppressed
+	l.suppressed = 0
+	l.summary = nil
+	l.mu.Unlock()
+
+	ent := zapcore.Entry{
+		LoggerName: l.subsystem,
+		Time:       time.Now(),
+		Level:      zapcore.WarnLevel,
+		Message:    fmt.Sprintf("suppressed %d entries from %s", n, l.subsystem),
+	}
+	if ce := loggerCore.Check(ent, nil); ce != nil {
+		ce.Write()
+	}
+}
+
+// subsystemRateLimiter returns the value holding the *rateLimiter of a
+// subsystem. loggerMutex must be held.
+func subsystemRateLimiter(name string) *atomic.Value {
+	l, ok := rateLimiters[name]
+	if !ok {
+		l = new(atomic.Value)
+		l.Store((*rateLimiter)(nil))
+		rateLimiters[name] = l
+	}
+	return l
+}
+
+// setRateLimits sets the rate limits of the subsystems of a Config, removing
+// them for the others. loggerMutex must be held.
+func setRateLimits(limits map[string]RateLimitConfig) {
+	for _, l := range rateLimiters {
+		l.Store((*rateLimiter)(nil))
+	}
+	for name, cfg := range limits {
+		subsystemRateLimiter(name).Store(newRateLimiter(name, cfg))
+	}
+}
@@ -0,0 +1,39 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubsystemRateLimit(t *testing.T) {
+	SetupLogging(Config{
+		Level:  LevelInfo,
+		Format: FormatPlaintextOutput,
+		SubsystemRateLimits: map[string]RateLimitConfig{
+			"limited": {Rate: 0, Burst: 3, SummaryInterval: 20 * time.Millisecond},
+		},
+	})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	capture := StartCapture(nil)
+	defer capture.Stop()
+
+	log := Logger("limited")
+	for i := 0; i < 10; i++ {
+		log.Info("retrying")
+	}
+	log.Error("failed")
+	log.DPanic("never dropped")
+	if entries := capture.FilterMessage("retrying"); len(entries) != 3 {
+		t.Errorf("got %d entries, wanted the 3 of the burst", len(entries))
+	}
+	if entries := capture.FilterLevel(LevelDPanic); len(entries) != 1 {
+		t.Errorf("got %v, wanted the DPanic entry", entries)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	entries := capture.FilterMessage("suppressed 8 entries from limited")
+	if len(entries) != 1 || entries[0].Level != LevelWarn || entries[0].Subsystem != "limited" {
+		t.Errorf("got %v, wanted a single summary", capture.Entries())
+	}
+}
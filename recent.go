@@ -0,0 +1,118 @@
+package log
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// recent holds the *recentRing recording the last entries, if enabled by
+// Config.RecentEntries.
+var recent atomic.Value
+
+// recentEntries returns the ring recording the last entries, or nil.
+func recentEntries() *recentRing {
+	r, _ := recent.Load().(*recentRing)
+	return r
+}
+
+// DumpRecent writes the last entries logged, at all levels, to w, oldest
+// first. It writes nothing unless Config.RecentEntries is set.
+func DumpRecent(w io.Writer) error {
+	r := recentEntries()
+	if r == nil {
+		return nil
+	}
+	for _, data := range r.entries() {
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setRecentEntries enables recording the last size entries, keeping the
+// entries already recorded when the size does not change. It returns the
+// core recording them, or nil when size is 0.
+func setRecentEntries(size int, format LogFormat) zapcore.Core {
+	if size <= 0 {
+		recent.Store((*recentRing)(nil))
+		return nil
+	}
+	r := recentEntries()
+	if r == nil || len(r.buf) != size {
+		r = &recentRing{buf: make([][]byte, size)}
+		recent.Store(r)
+	}
+	if format == FormatColorizedOutput {
+		format = FormatPlaintextOutput
+	}
+	return &recentCore{enc: newEncoder(format), ring: r}
+}
+
+// recentRing is a ring buffer of encoded entries.
+type recentRing struct {
+	mu   sync.Mutex
+	buf  [][]byte
+	next int
+	full bool
+}
+
+func (r *recentRing) add(data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buf[r.next] = data
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *recentRing) entries() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([][]byte(nil), r.buf[:r.next]...)
+	}
+	return append(append([][]byte(nil), r.buf[r.next:]...), r.buf[:r.next]...)
+}
+
+// recentCore records every entry in a ring buffer. The loggers pass it the
+// entries below their level too.
+type recentCore struct {
+	enc  zapcore.Encoder
+	ring *recentRing
+}
+
+func (c *recentCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c *recentCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.enc = c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(clone.enc)
+	}
+	return &clone
+}
+
+func (c *recentCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+
+func (c *recentCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	c.ring.add(append([]byte(nil), buf.Bytes()...))
+	buf.Free()
+	return nil
+}
+
+func (c *recentCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,30 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDumpRecent(t *testing.T) {
+	SetupLogging(Config{
+		Level:         LevelError,
+		Format:        FormatPlaintextOutput,
+		RecentEntries: 2,
+	})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	log := getLogger("recent")
+	log.Debug("scooby")
+	log.Debug("doo")
+	log.Info("where are you")
+
+	buf := &bytes.Buffer{}
+	if err := DumpRecent(buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "doo") || !strings.Contains(lines[1], "where are you") {
+		t.Errorf("got %q, wanted the last 2 entries", lines)
+	}
+}
@@ -0,0 +1,282 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RedisConfig configures appending entries to a Redis stream.
+type RedisConfig struct {
+	// URL is the server URL, i.e. "redis://:password@localhost:6379/0", or
+	// with TLS "rediss://localhost:6380".
+	URL string
+
+	// Stream is the key of the stream. Defaults to "logs".
+	Stream string
+
+	// MaxLen is the approximate maximum length the stream is trimmed to.
+	// Defaults to 10000, negative to disable trimming.
+	MaxLen int64
+
+	// BatchSize is the maximum number of entries sent at once.
+	// Defaults to 100.
+	BatchSize int
+
+	// FlushInterval is the maximum time an entry waits before being sent.
+	// Defaults to one second.
+	FlushInterval time.Duration
+
+	// TLS configures TLS connections when not nil.
+	TLS *tls.Config
+}
+
+const (
+	defaultRedisStream = "logs"
+	defaultRedisMaxLen = 10000
+	defaultRedisPort   = "6379"
+	redisMaxRetries    = 3
+)
+
+// newRedisCore creates a core adding JSON entries to a Redis stream. Every
+// stream entry has the level, subsystem and entry fields, the latter holding
+// the JSON encoded log entry.
+func newRedisCore(cfg RedisConfig, level LogLevel) (zapcore.Core, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("missing Redis URL")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("unsupported Redis URL scheme %q", u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultRedisPort)
+	}
+
+	r := &redisClient{
+		addr:   addr,
+		stream: cfg.Stream,
+		maxLen: cfg.MaxLen,
+		tls:    cfg.TLS,
+	}
+	if r.stream == "" {
+		r.stream = defaultRedisStream
+	}
+	if r.maxLen == 0 {
+		r.maxLen = defaultRedisMaxLen
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if _, err := strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid Redis database %q", db)
+		}
+		r.db = db
+	}
+	if u.Scheme == "rediss" && r.tls == nil {
+		r.tls = &tls.Config{}
+	}
+	if r.tls != nil && r.tls.ServerName == "" {
+		r.tls = r.tls.Clone()
+		r.tls.ServerName = u.Hostname()
+	}
+	if u.User != nil {
+		r.user = u.User.Username()
+		r.password, _ = u.User.Password()
+	}
+
+	encCfg := newEncoderConfig()
+	encCfg.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+
+	return &batchCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          zapcore.NewJSONEncoder(encCfg),
+		batcher:      newBatcher(cfg.BatchSize, cfg.FlushInterval, r.send),
+		close:        r.close,
+	}, nil
+}
+
+// redisError is an error reply of the server.
+type redisError struct {
+	msg string
+}
+
+func (e *redisError) Error() string {
+	return "Redis error: " + e.msg
+}
+
+type redisClient struct {
+	addr     string
+	db       string
+	user     string
+	password string
+	stream   string
+	maxLen   int64
+	tls      *tls.Config
+
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (r *redisClient) send(batch []batchEntry) error {
+	var buf bytes.Buffer
+	for _, e := range batch {
+		args := []string{"XADD", r.stream}
+		if r.maxLen > 0 {
+			args = append(args, "MAXLEN", "~", strconv.FormatInt(r.maxLen, 10))
+		}
+		args = append(args, "*",
+			"level", levelName(e.ent.Level),
+			"subsystem", e.ent.LoggerName,
+			"entry", string(bytes.TrimRight(e.data, "\n")),
+		)
+		writeRedisCommand(&buf, args...)
+	}
+
+	return retryWithBackoff(redisMaxRetries, func() (bool, error) {
+		if r.conn == nil {
+			if err := r.connect(); err != nil {
+				_, replied := err.(*redisError)
+				return !replied, err
+			}
+		}
+		err := r.do(buf.Bytes(), len(batch))
+		if _, replied := err.(*redisError); err != nil && !replied {
+			r.close() // nolint:errcheck
+			return true, err
+		}
+		return false, err
+	})
+}
+
+func (r *redisClient) connect() error {
+	conn, err := net.DialTimeout("tcp", r.addr, httpTimeout)
+	if err != nil {
+		return err
+	}
+	if r.tls != nil {
+		conn = tls.Client(conn, r.tls)
+	}
+	r.conn, r.r = conn, bufio.NewReader(conn)
+
+	var buf bytes.Buffer
+	commands := 0
+	if r.password != "" {
+		if r.user != "" {
+			writeRedisCommand(&buf, "AUTH", r.user, r.password)
+		} else {
+			writeRedisCommand(&buf, "AUTH", r.password)
+		}
+		commands++
+	}
+	if r.db != "" {
+		writeRedisCommand(&buf, "SELECT", r.db)
+		commands++
+	}
+	if err := r.do(buf.Bytes(), commands); err != nil {
+		r.close() // nolint:errcheck
+		return err
+	}
+	return nil
+}
+
+// do sends pipelined commands and reads their replies, returning the first
+// error reply.
+func (r *redisClient) do(commands []byte, replies int) error {
+	if replies == 0 {
+		return nil
+	}
+	r.conn.SetDeadline(time.Now().Add(httpTimeout)) // nolint:errcheck
+	if _, err := r.conn.Write(commands); err != nil {
+		return err
+	}
+	var replyErr error
+	for i := 0; i < replies; i++ {
+		if err := readRedisReply(r.r); err != nil {
+			if _, replied := err.(*redisError); !replied {
+				return err
+			}
+			if replyErr == nil {
+				replyErr = err
+			}
+		}
+	}
+	return replyErr
+}
+
+func (r *redisClient) close() error {
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn, r.r = nil, nil
+	return err
+}
+
+// writeRedisCommand writes a command as a RESP array of bulk strings.
+func writeRedisCommand(buf *bytes.Buffer, args ...string) {
+	fmt.Fprintf(buf, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(buf, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+}
+
+// readRedisReply reads and discards a RESP reply, returning a *redisError for
+// error replies.
+func readRedisReply(r *bufio.Reader) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return errors.New("empty Redis reply")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return nil
+	case '-':
+		return &redisError{line[1:]}
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return fmt.Errorf("invalid Redis reply %q", line)
+		}
+		if n >= 0 {
+			_, err = io.CopyN(ioutil.Discard, r, int64(n)+2)
+		}
+		return err
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return fmt.Errorf("invalid Redis reply %q", line)
+		}
+		var replyErr error
+		for i := 0; i < n; i++ {
+			if err := readRedisReply(r); err != nil {
+				if _, replied := err.(*redisError); !replied {
+					return err
+				}
+				replyErr = err
+			}
+		}
+		return replyErr
+	default:
+		return fmt.Errorf("invalid Redis reply %q", line)
+	}
+}
@@ -0,0 +1,96 @@
+package log
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// readRedisCommand reads a command sent by writeRedisCommand.
+func readRedisCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func TestRedisCore(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	got := make(chan []string, 3)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		for {
+			args, err := readRedisCommand(r)
+			if err != nil {
+				return
+			}
+			got <- args
+			switch args[0] {
+			case "XADD":
+				fmt.Fprint(conn, "$15\r\n1526919030474-0\r\n")
+			default:
+				fmt.Fprint(conn, "+OK\r\n")
+			}
+		}
+	}()
+
+	core, err := newRedisCore(RedisConfig{
+		URL:    "redis://:secret@" + l.Addr().String() + "/2",
+		MaxLen: 500,
+	}, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*batchCore).Close()
+
+	zap.New(core).Named("dht").Warn("scooby")
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if args := <-got; strings.Join(args, " ") != "AUTH secret" {
+		t.Errorf("got %q, wanted AUTH", args)
+	}
+	if args := <-got; strings.Join(args, " ") != "SELECT 2" {
+		t.Errorf("got %q, wanted SELECT", args)
+	}
+	args := <-got
+	if strings.Join(args[:10], " ") != "XADD logs MAXLEN ~ 500 * level warn subsystem dht" {
+		t.Errorf("got %q, wanted XADD", args)
+	}
+	if args[10] != "entry" || !strings.Contains(args[11], `"msg":"scooby"`) {
+		t.Errorf("got %q, wanted the JSON entry", args[10:])
+	}
+}
@@ -0,0 +1,113 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"go.uber.org/multierr"
+)
+
+// openFiles are the log files opened by this package, which ReopenFiles
+// reopens.
+var (
+	openFilesMu sync.Mutex
+	openFiles   = make(map[*rotatingFile]struct{})
+)
+
+// ReopenFiles closes and reopens the log files, Config.File and the files of
+// Config.SubsystemFiles, so that entries are written to new files once they
+// have been renamed by an external tool such as logrotate.
+func ReopenFiles() error {
+	openFilesMu.Lock()
+	files := make([]*rotatingFile, 0, len(openFiles))
+	for f := range openFiles {
+		files = append(files, f)
+	}
+	openFilesMu.Unlock()
+
+	var err error
+	for _, f := range files {
+		if rerr := f.reopen(); rerr != nil {
+			err = multierr.Append(err, fmt.Errorf("failed to reopen log file %s: %s", f.path, rerr))
+		}
+	}
+	return err
+}
+
+// stopReopenOnSignal stops reopening the files on SIGHUP when not nil.
+var stopReopenOnSignal func()
+
+// setReopenOnSignal starts or stops reopening the log files on SIGHUP,
+// loggerMutex must be held.
+func setReopenOnSignal(enabled bool) {
+	if stopReopenOnSignal != nil {
+		stopReopenOnSignal()
+		stopReopenOnSignal = nil
+	}
+	if !enabled {
+		return
+	}
+
+	stop, err := notifySIGHUP(func() {
+		if err := ReopenFiles(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to reopen log files on signals, use ReopenFiles: %s\n", err)
+		return
+	}
+	stopReopenOnSignal = stop
+}
+
+// stopReloadOnSignal stops reloading the configuration on SIGHUP when not
+// nil.
+var stopReloadOnSignal func()
+
+// setReloadOnSignal starts or stops setting up the logging again on SIGHUP,
+// loggerMutex must be held.
+func setReloadOnSignal(enabled bool) {
+	if enabled == (stopReloadOnSignal != nil) {
+		return
+	}
+	if !enabled {
+		stopReloadOnSignal()
+		stopReloadOnSignal = nil
+		return
+	}
+
+	stop, err := notifySIGHUP(ReloadConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to reload the logging configuration on signals, use ReloadConfig: %s\n", err)
+		return
+	}
+	stopReloadOnSignal = stop
+}
+
+// configFilePath is the config file of the last SetupLoggingFromFile, read
+// again by ReloadConfig.
+var configFilePath string
+
+// ReloadConfig sets up the logging again from the environment variables and
+// the config file of the last SetupLoggingFromFile, or of GOLOG_CONFIG, i.e.
+// on SIGHUP with Config.ReloadOnSIGHUP. The levels set with SetLogLevel are
+// reset. Invalid config files are reported to stderr and ignored.
+func ReloadConfig() {
+	loggerMutex.RLock()
+	path := configFilePath
+	loggerMutex.RUnlock()
+	if path == "" {
+		path = os.Getenv(envLoggingConfig)
+	}
+
+	cfg := configFromEnv()
+	if path != "" {
+		var err error
+		if cfg, err = configFromFile(cfg, path); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to reload config file, keeping the current configuration: %s\n", err)
+			return
+		}
+	}
+	SetupLogging(cfg)
+}
@@ -0,0 +1,11 @@
+//go:build windows || plan9 || js
+// +build windows plan9 js
+
+package log
+
+import "errors"
+
+// notifySIGHUP fails, there is no SIGHUP on this platform.
+func notifySIGHUP(f func()) (stop func(), err error) {
+	return nil, errors.New("SIGHUP is not supported on this platform")
+}
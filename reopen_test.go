@@ -0,0 +1,44 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReopenFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := openRotatingFile(path, RotationConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write([]byte("scooby\n")); err != nil {
+		t.Fatal(err)
+	}
+	// rotated by an external tool
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("doo\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReopenFiles(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("where are you\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{path + ".1": "scooby\ndoo\n", path: "where are you\n"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("got %q in %s, wanted %q", data, name, want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package log
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifySIGHUP calls f on every SIGHUP, until stop is called.
+func notifySIGHUP(f func()) (stop func(), err error) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-signals:
+				f()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}, nil
+}
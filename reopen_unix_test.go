@@ -0,0 +1,44 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReloadOnSIGHUP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.yaml")
+	if err := ioutil.WriteFile(path, []byte("level: error\nstderr: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv(envLoggingReloadOnSIGHUP, "true")
+	defer os.Unsetenv(envLoggingReloadOnSIGHUP)
+	if err := SetupLoggingFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	log := Logger("reloaded")
+	if err := ioutil.WriteFile(path, []byte("level: error\nsubsystem_levels: {reloaded: info}\nstderr: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if log.Enabled(LevelInfo) {
+		t.Fatal("expected the file to be read again on SIGHUP only")
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !log.Enabled(LevelInfo) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the configuration to be reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,33 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRFC5424Format(t *testing.T) {
+	buf := &bytes.Buffer{}
+	core := newCore(FormatRFC5424Output, zapcore.AddSync(buf), LevelDebug)
+	zap.New(core).Named("dht").With(zap.String("peer", `a"b]`)).Warn("scooby", zap.Int("count", 3), zap.Error(errors.New("doo")))
+
+	line := buf.String()
+	// user (1) * 8 + warning (4)
+	if !strings.HasPrefix(line, "<12>1 ") {
+		t.Errorf("got %q, wanted RFC5424 header with priority 12", line)
+	}
+	want := ` dht [fields@32473 count="3" error="doo" peer="a\"b\]"] scooby` + "\n"
+	if !strings.HasSuffix(line, want) {
+		t.Errorf("got %q, wanted it to end with %q", line, want)
+	}
+
+	buf.Reset()
+	zap.New(core).Info("where")
+	if !strings.HasSuffix(buf.String(), " - - where\n") {
+		t.Errorf("got %q, wanted no message ID nor structured data", buf.String())
+	}
+}
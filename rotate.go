@@ -0,0 +1,810 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotationConfig configures the rotation of log files.
+type RotationConfig struct {
+	// MaxSize is the size in bytes at which a file is rotated. Rotation by
+	// size is disabled when 0.
+	MaxSize int64
+
+	// Interval is the period a file is rotated every, i.e. 24 hours for
+	// daily rotation. Periods start at midnight in local time. Rotated files
+	// are stamped with the start of their period, i.e. "app-2024-05-01.log".
+	// Rotation by time is disabled when 0.
+	Interval time.Duration
+
+	// MaxBackups is the maximum number of rotated files kept, the oldest
+	// ones being deleted. All of them are kept when 0.
+	MaxBackups int
+
+	// MaxAge is the maximum age of the rotated files kept, older ones being
+	// deleted. They are kept regardless of their age when 0.
+	MaxAge time.Duration
+
+	// MaxTotalSize is the maximum total size in bytes of the rotated files
+	// kept, the oldest ones being deleted. Disabled when 0.
+	MaxTotalSize int64
+
+	// NameTemplate is the template of the names of rotated files, in which
+	// "{name}" and "{ext}" are replaced by the name of the file without and
+	// with its extension, "{time}" by the time of the rotation, or the start
+	// of the period with time-based rotation, "{pid}" by the process ID,
+	// "{hostname}" by the host name and "{seq}" by the sequence number of the
+	// files rotated with the same name. Rotated files are kept in the
+	// directory of the file. Defaults to "{name}-{time}{ext}", the sequence
+	// number being inserted before the extension if not 0.
+	NameTemplate string
+
+	// TimeFormat is the format of "{time}" in NameTemplate, a time layout.
+	// Defaults to the rotation time with millisecond precision, or the start
+	// of the period as precise as Interval requires.
+	TimeFormat string
+
+	// Symlink is the path of a symbolic link to the active file, relative to
+	// the directory of the file if not absolute, i.e. "current.log". When
+	// set, the active file is named like the rotated files, and rotating
+	// switches the link to a new file instead of renaming the active one,
+	// so that tail -F and collectors can follow the link.
+	Symlink string
+
+	// Compress enables compressing rotated files with gzip in the
+	// background, only the compressed copies being kept.
+	Compress bool
+}
+
+// enabled reports whether files are rotated.
+func (c RotationConfig) enabled() bool {
+	return c.MaxSize > 0 || c.Interval > 0
+}
+
+// timeFormat returns the format of the time in the names of rotated files:
+// the time of the rotation with size-based rotation only, the start of the
+// period, as precise as the interval requires, with time-based rotation.
+func (c RotationConfig) timeFormat() string {
+	switch {
+	case c.Interval <= 0:
+		return "2006-01-02T15-04-05.000"
+	case c.Interval%(24*time.Hour) == 0:
+		return "2006-01-02"
+	case c.Interval%time.Hour == 0:
+		return "2006-01-02T15"
+	default:
+		return "2006-01-02T15-04"
+	}
+}
+
+// periodStart returns the start of the rotation period including t.
+func (c RotationConfig) periodStart(t time.Time) time.Time {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if c.Interval%(24*time.Hour) == 0 {
+		days := int(c.Interval / (24 * time.Hour))
+		return midnight.AddDate(0, 0, -(t.YearDay()-1)%days)
+	}
+	return midnight.Add(t.Sub(midnight) / c.Interval * c.Interval)
+}
+
+// rotatingFile is a log file which can be reopened, and when rotation is
+// enabled is renamed to a backup and replaced by a new file once it reaches
+// its maximum size, or its rotation period ends.
+type rotatingFile struct {
+	path  string
+	cfg   RotationConfig
+	names *backupNamer
+
+	mu   sync.Mutex // guards active, file, size, period and locked
+	file *os.File
+	size int64
+
+	// locked indicates whether writes hold an exclusive lock on the file, for
+	// it to be shared with other processes.
+	locked bool
+
+	// active is the path of the file written to: path, unless a symlink to
+	// the active file is maintained.
+	active string
+
+	// symlink is the path of the symlink to the active file, if any.
+	symlink string
+
+	// period is the start of the rotation period of the file, when rotating
+	// by time.
+	period time.Time
+
+	// cleanupMu serializes the compression and deletion of rotated files
+	// done in the background, cleanups tracking them.
+	cleanupMu sync.Mutex
+	cleanups  sync.WaitGroup
+}
+
+// openRotatingFile opens the file at path for appending, creating it if
+// needed.
+func openRotatingFile(path string, cfg RotationConfig) (*rotatingFile, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	names, err := newBackupNamer(path, cfg)
+	if err != nil {
+		return nil, err
+	}
+	f := &rotatingFile{path: path, cfg: cfg, names: names, active: path}
+	if cfg.Symlink != "" {
+		f.symlink = cfg.Symlink
+		if !filepath.IsAbs(f.symlink) {
+			f.symlink = filepath.Join(filepath.Dir(path), f.symlink)
+		}
+		// the active file is the one linked to before a restart, if any
+		if f.active = f.linked(); f.active == "" {
+			f.active = f.newActive(time.Now())
+		}
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	if f.symlink != "" {
+		f.link()
+	}
+
+	openFilesMu.Lock()
+	openFiles[f] = struct{}{}
+	openFilesMu.Unlock()
+
+	// the files rotated before a restart may need to be cleaned up
+	f.cleanups.Add(1)
+	go func() {
+		defer f.cleanups.Done()
+		f.cleanup()
+	}()
+	return f, nil
+}
+
+// open opens the active file, f.mu must be held or f not shared yet.
+func (f *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.active), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.active, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	if f.cfg.Interval > 0 {
+		// the entries already in the file were written in the period of
+		// its last modification
+		t := time.Now()
+		if f.size > 0 {
+			t = info.ModTime()
+		}
+		f.period = f.cfg.periodStart(t)
+	}
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.locked {
+		return f.writeLocked(p)
+	}
+	if f.needsRotation(len(p)) {
+		if err := f.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to rotate log file %s: %s\n", f.path, err)
+		}
+	}
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// enableLocking makes writes hold an exclusive advisory lock on the file, so
+// that the processes writing to it don't interleave partial lines, and the
+// file is rotated by one of them only. It fails when the file can't be
+// locked.
+func (f *rotatingFile) enableLocking() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+	if err := lockFile(f.file); err != nil {
+		return err
+	}
+	if err := unlockFile(f.file); err != nil {
+		return err
+	}
+	f.locked = true
+	return nil
+}
+
+// writeLocked writes p holding a lock on the file, reopening it first if
+// another process rotated it, f.mu must be held.
+func (f *rotatingFile) writeLocked(p []byte) (int, error) {
+	rotated := false
+	for {
+		if f.file == nil {
+			if err := f.open(); err != nil {
+				return 0, err
+			}
+		}
+		file := f.file
+		if err := lockFile(file); err != nil {
+			return 0, err
+		}
+		if f.replaced(file) {
+			// the lock is released by closing the file
+			file.Close() // nolint:errcheck
+			f.file = nil
+			continue
+		}
+		// other processes write to the file too
+		if info, err := file.Stat(); err == nil {
+			f.size = info.Size()
+		}
+		if !rotated && f.needsRotation(len(p)) {
+			rotated = true
+			if err := f.rotate(); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to rotate log file %s: %s\n", f.path, err)
+			}
+			if f.file != file {
+				continue
+			}
+		}
+		n, err := file.Write(p)
+		f.size += int64(n)
+		if err := unlockFile(file); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to unlock log file %s: %s\n", f.path, err)
+		}
+		return n, err
+	}
+}
+
+// replaced reports whether file is not the active file anymore, another
+// process having rotated it, and switches to the new active file when
+// maintaining a symlink to it, f.mu must be held.
+func (f *rotatingFile) replaced(file *os.File) bool {
+	if f.symlink != "" {
+		if active := f.linked(); active != "" && active != f.active {
+			f.active = active
+			return true
+		}
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(f.path)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	return !os.SameFile(info, current)
+}
+
+// needsRotation reports whether the file must be rotated before writing n
+// bytes, f.mu must be held.
+func (f *rotatingFile) needsRotation(n int) bool {
+	if f.file == nil || f.size == 0 {
+		return false
+	}
+	if f.cfg.MaxSize > 0 && f.size+int64(n) > f.cfg.MaxSize {
+		return true
+	}
+	return f.cfg.Interval > 0 && !time.Now().Before(f.period.Add(f.cfg.Interval))
+}
+
+func (f *rotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Sync()
+}
+
+// Close closes the file, and waits for the rotated files to be cleaned up.
+func (f *rotatingFile) Close() error {
+	openFilesMu.Lock()
+	delete(openFiles, f)
+	openFilesMu.Unlock()
+
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	f.cleanups.Wait()
+	return err
+}
+
+// reopen closes and reopens the file, so that entries are written to a new
+// file once it has been renamed.
+func (f *rotatingFile) reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	if err := f.file.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to close log file %s: %s\n", f.path, err)
+	}
+	f.file = nil
+	return f.open()
+}
+
+// rotate renames the file to a backup and opens a new one, f.mu must be held.
+func (f *rotatingFile) rotate() error {
+	// the file is closed once replaced, as closing it releases the lock
+	// other processes may be waiting on to write to it: they must see that
+	// it was rotated rather than rotate it again
+	old := f.file
+	f.file = nil
+	if f.symlink != "" {
+		// the active file is named like the rotated files already
+		f.active = f.newActive(time.Now())
+		if err := f.open(); err != nil {
+			old.Close() // nolint:errcheck
+			return err
+		}
+		f.link()
+		if err := old.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to close log file %s: %s\n", f.path, err)
+		}
+	} else {
+		t := time.Now()
+		if f.cfg.Interval > 0 {
+			t = f.period
+		}
+		name := f.unusedName(t)
+		err := os.Rename(f.path, name)
+		if cerr := old.Close(); cerr != nil {
+			fmt.Fprintf(os.Stderr, "failed to close log file %s: %s\n", f.path, cerr)
+		}
+		if err != nil {
+			// open files can't be renamed on some platforms
+			if err = os.Rename(f.path, name); err != nil {
+				return err
+			}
+		}
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+
+	f.cleanups.Add(1)
+	go func() {
+		defer f.cleanups.Done()
+		f.cleanup()
+	}()
+	return nil
+}
+
+// cleanup compresses the rotated files if enabled, and deletes the old ones.
+func (f *rotatingFile) cleanup() {
+	f.cleanupMu.Lock()
+	defer f.cleanupMu.Unlock()
+
+	if f.cfg.Compress {
+		f.compress()
+	}
+	f.prune()
+}
+
+// unusedName returns the name of a file rotated with time t which is not
+// used yet, files rotated with the same time getting distinct sequence
+// numbers.
+func (f *rotatingFile) unusedName(t time.Time) string {
+	for seq := 0; ; seq++ {
+		name := f.names.name(t, seq)
+		if !fileExists(name) && !fileExists(name+compressedExt) {
+			return name
+		}
+	}
+}
+
+// newActive returns the name of a new active file created at t, when a
+// symlink to the active file is maintained.
+func (f *rotatingFile) newActive(t time.Time) string {
+	if f.cfg.Interval > 0 {
+		t = f.cfg.periodStart(t)
+	}
+	return f.unusedName(t)
+}
+
+// linked returns the active file the symlink links to, or "" if there is no
+// such symlink.
+func (f *rotatingFile) linked() string {
+	target, err := os.Readlink(f.symlink)
+	if err != nil {
+		return ""
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(f.symlink), target)
+	}
+	if filepath.Dir(target) != f.names.dir || !fileExists(target) {
+		return ""
+	}
+	if _, ok := f.names.parse(filepath.Base(target)); !ok {
+		return ""
+	}
+	return target
+}
+
+// link points the symlink to the active file, replacing it atomically.
+func (f *rotatingFile) link() {
+	target := f.active
+	if filepath.Dir(f.symlink) == filepath.Dir(target) {
+		target = filepath.Base(target)
+	}
+	tmp := f.symlink + ".tmp"
+	os.Remove(tmp) // nolint:errcheck
+	if err := os.Symlink(target, tmp); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to link %s to log file: %s\n", f.symlink, err)
+		return
+	}
+	if err := os.Rename(tmp, f.symlink); err != nil {
+		os.Remove(tmp) // nolint:errcheck
+		fmt.Fprintf(os.Stderr, "failed to link %s to log file: %s\n", f.symlink, err)
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return !os.IsNotExist(err)
+}
+
+// compressedExt is the extension added to compressed rotated files.
+const compressedExt = ".gz"
+
+// defaultNameTemplate is the template of the names of rotated files.
+const defaultNameTemplate = "{name}-{time}{ext}"
+
+// backupNamer names the rotated files of a file after a template, and parses
+// the names of the rotated files.
+type backupNamer struct {
+	dir      string
+	template string
+	layout   string
+
+	// replacer replaces the placeholders other than {time} and {seq}.
+	replacer *strings.Replacer
+
+	// pattern matches the names of rotated files, timeGroup and seqGroup
+	// being the indexes of their time and sequence number.
+	pattern   *regexp.Regexp
+	timeGroup int
+	seqGroup  int
+}
+
+// newBackupNamer creates a namer for the rotated files of the file at path.
+func newBackupNamer(path string, cfg RotationConfig) (*backupNamer, error) {
+	template := cfg.NameTemplate
+	if template == "" {
+		template = defaultNameTemplate
+	}
+	if strings.ContainsAny(template, `/\`) {
+		return nil, fmt.Errorf("invalid rotated file name template %q: rotated files are in the directory of the file", template)
+	}
+	// files rotated with the same name get a sequence number, before the
+	// extension when the template has none
+	if !strings.Contains(template, "{seq}") {
+		if strings.Contains(template, "{ext}") {
+			template = strings.Replace(template, "{ext}", "{.seq}{ext}", 1)
+		} else {
+			template += "{.seq}"
+		}
+	}
+	layout := cfg.TimeFormat
+	if layout == "" {
+		layout = cfg.timeFormat()
+	}
+
+	ext := filepath.Ext(path)
+	hostname, _ := os.Hostname()
+	n := &backupNamer{
+		dir:      filepath.Dir(path),
+		template: template,
+		layout:   layout,
+		replacer: strings.NewReplacer(
+			"{name}", filepath.Base(strings.TrimSuffix(path, ext)),
+			"{ext}", ext,
+			"{pid}", strconv.Itoa(os.Getpid()),
+			"{hostname}", hostname,
+		),
+	}
+
+	// the formatted times have the length of the layout once formatted
+	timePattern := "(.{" + strconv.Itoa(len(time.Time{}.Format(layout))) + "})"
+	pattern := regexp.QuoteMeta(template)
+	var groups []string
+	for _, ph := range []struct{ placeholder, pattern string }{
+		{"{name}", regexp.QuoteMeta(filepath.Base(strings.TrimSuffix(path, ext)))},
+		{"{ext}", regexp.QuoteMeta(ext)},
+		{"{pid}", `\d+`},
+		{"{hostname}", regexp.QuoteMeta(hostname)},
+		{"{time}", timePattern},
+		{"{seq}", `(\d+)`},
+		{"{.seq}", `(?:\.(\d+))?`},
+	} {
+		quoted := regexp.QuoteMeta(ph.placeholder)
+		if strings.Contains(pattern, quoted) && strings.HasPrefix(ph.pattern, "(") {
+			groups = append(groups, ph.placeholder)
+		}
+		pattern = strings.Replace(pattern, quoted, ph.pattern, -1)
+	}
+	var err error
+	if n.pattern, err = regexp.Compile("^" + pattern + "(" + regexp.QuoteMeta(compressedExt) + ")?$"); err != nil {
+		return nil, err
+	}
+	// the groups are numbered in the order of their placeholders in the
+	// template
+	sort.Slice(groups, func(i, j int) bool {
+		return strings.Index(template, groups[i]) < strings.Index(template, groups[j])
+	})
+	for i, placeholder := range groups {
+		switch placeholder {
+		case "{time}":
+			n.timeGroup = i + 1
+		default:
+			n.seqGroup = i + 1
+		}
+	}
+	return n, nil
+}
+
+// name returns the path of the file rotated with time t and sequence number
+// seq, i.e. "app-<time>.log" and "app-<time>.1.log" by default.
+func (n *backupNamer) name(t time.Time, seq int) string {
+	dotSeq := ""
+	if seq > 0 {
+		dotSeq = "." + strconv.Itoa(seq)
+	}
+	name := strings.NewReplacer(
+		"{time}", t.Format(n.layout),
+		"{seq}", strconv.Itoa(seq),
+		"{.seq}", dotSeq,
+	).Replace(n.replacer.Replace(n.template))
+	return filepath.Join(n.dir, name)
+}
+
+// parse parses the name of a rotated file.
+func (n *backupNamer) parse(name string) (backup, bool) {
+	m := n.pattern.FindStringSubmatch(name)
+	if m == nil {
+		return backup{}, false
+	}
+	b := backup{
+		path:       filepath.Join(n.dir, name),
+		compressed: m[len(m)-1] != "",
+	}
+	if n.timeGroup > 0 {
+		t, err := time.ParseInLocation(n.layout, m[n.timeGroup], time.Local)
+		if err != nil {
+			return backup{}, false
+		}
+		b.time = t
+	} else if info, err := os.Lstat(b.path); err == nil {
+		// without time in their names, rotated files are as old as their
+		// last modification
+		b.time = info.ModTime()
+	}
+	if n.seqGroup > 0 && m[n.seqGroup] != "" {
+		b.seq, _ = strconv.Atoi(m[n.seqGroup])
+	}
+	return b, true
+}
+
+// backup is a rotated file.
+type backup struct {
+	path       string
+	time       time.Time
+	seq        int
+	compressed bool
+}
+
+// activePath returns the path of the active file.
+func (f *rotatingFile) activePath() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.active
+}
+
+// backups returns the rotated files, the newest first.
+func (f *rotatingFile) backups() ([]backup, error) {
+	entries, err := os.ReadDir(f.names.dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []backup
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if b, ok := f.names.parse(entry.Name()); ok && b.path != f.activePath() {
+			backups = append(backups, b)
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].time.Equal(backups[j].time) {
+			return backups[i].seq > backups[j].seq
+		}
+		return backups[i].time.After(backups[j].time)
+	})
+	return backups, nil
+}
+
+// compress compresses the rotated files not compressed yet, including the
+// ones left by an interrupted compression.
+func (f *rotatingFile) compress() {
+	backups, err := f.backups()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to list rotated log files: %s\n", err)
+		return
+	}
+	for _, b := range backups {
+		if b.compressed {
+			continue
+		}
+		if err := gzipFile(b.path); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to compress rotated log file: %s\n", err)
+		}
+	}
+}
+
+// gzipFile replaces the file at path by its compressed copy.
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+compressedExt, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
+
+// prune deletes the rotated files beyond MaxBackups, older than MaxAge and
+// the oldest ones exceeding MaxTotalSize.
+func (f *rotatingFile) prune() {
+	if f.cfg.MaxBackups <= 0 && f.cfg.MaxAge <= 0 && f.cfg.MaxTotalSize <= 0 {
+		return
+	}
+	backups, err := f.backups()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to list rotated log files: %s\n", err)
+		return
+	}
+
+	now := time.Now()
+	var total int64
+	for i, b := range backups {
+		remove := f.cfg.MaxBackups > 0 && i >= f.cfg.MaxBackups
+		if f.cfg.MaxAge > 0 {
+			// files rotated by time contain the entries up to the end of
+			// their period
+			end := b.time
+			if f.cfg.Interval > 0 {
+				end = end.Add(f.cfg.Interval)
+			}
+			remove = remove || now.Sub(end) > f.cfg.MaxAge
+		}
+		if f.cfg.MaxTotalSize > 0 && !remove {
+			info, err := os.Lstat(b.path)
+			if err != nil {
+				continue
+			}
+			total += info.Size()
+			remove = total > f.cfg.MaxTotalSize
+		}
+		if !remove {
+			continue
+		}
+		if err := os.Remove(b.path); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to delete rotated log file: %s\n", err)
+		}
+	}
+}
+
+// parseRotationInterval parses a rotation interval, either "daily", "hourly"
+// or a duration.
+func parseRotationInterval(s string) (time.Duration, error) {
+	switch s {
+	case "daily":
+		return 24 * time.Hour, nil
+	case "hourly":
+		return time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid rotation interval %q", s)
+	}
+	return d, nil
+}
+
+// parseMaxAge parses a duration, which may be given in days with the d unit,
+// i.e. "7d".
+func parseMaxAge(s string) (time.Duration, error) {
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+// parseByteSize parses a size in bytes with an optional K, M or G suffix,
+// i.e. "100M".
+func parseByteSize(s string) (int64, error) {
+	s = strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	var unit int64 = 1
+	switch {
+	case strings.HasSuffix(s, "K"):
+		unit = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		unit = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		unit = 1 << 30
+	}
+	if unit > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * unit, nil
+}
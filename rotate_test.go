@@ -0,0 +1,402 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := openRotatingFile(path, RotationConfig{MaxSize: 100, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	line := strings.Repeat("x", 39) + "\n"
+	for i := 0; i < 10; i++ {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2*len(line) {
+		t.Errorf("got a file of %d bytes, wanted %d", len(data), 2*len(line))
+	}
+	f.cleanups.Wait()
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("got %d backups, wanted 2", len(backups))
+	}
+	if backups[0].time.Before(backups[1].time) ||
+		backups[0].time.Equal(backups[1].time) && backups[0].seq <= backups[1].seq {
+		t.Error("backups should be sorted newest first")
+	}
+}
+
+func TestSetupLoggingRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	SetupLogging(Config{
+		Level:    LevelInfo,
+		Format:   FormatPlaintextOutput,
+		File:     path,
+		Rotation: RotationConfig{MaxSize: 200},
+	})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	log := getLogger("rotation")
+	SetLogLevel("rotation", "info")
+	for i := 0; i < 10; i++ {
+		log.Info("scooby doo, where are you?")
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) == 0 {
+		t.Error("expected the log file to be rotated")
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for s, want := range map[string]int64{"100": 100, "10K": 10 << 10, "100MB": 100 << 20, "1g": 1 << 30} {
+		if got, err := parseByteSize(s); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v, wanted %d", s, got, err, want)
+		}
+	}
+	if _, err := parseByteSize("lots"); err == nil {
+		t.Error("expected an error for an invalid size")
+	}
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := openRotatingFile(path, RotationConfig{Interval: 24 * time.Hour, MaxSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write([]byte("monday\n")); err != nil {
+		t.Fatal(err)
+	}
+	// the period of the file ended
+	yesterday := f.period.AddDate(0, 0, -1)
+	f.period = yesterday
+	if _, err := f.Write([]byte("tuesday\n")); err != nil {
+		t.Fatal(err)
+	}
+	// and it is rotated again by size within the period
+	if _, err := f.Write([]byte(strings.Repeat("x", 100) + "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	f.cleanups.Wait()
+	stamp := yesterday.Format("2006-01-02")
+	data, err := os.ReadFile(filepath.Join(dir, "app-"+stamp+".log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "monday\n" {
+		t.Errorf("got %q in the rotated file", data)
+	}
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || backups[1].time != yesterday {
+		t.Errorf("got backups %+v", backups)
+	}
+}
+
+func TestRotationPeriodStart(t *testing.T) {
+	at := time.Date(2024, 5, 1, 13, 42, 7, 0, time.Local)
+	for interval, want := range map[time.Duration]time.Time{
+		24 * time.Hour:   time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local),
+		time.Hour:        time.Date(2024, 5, 1, 13, 0, 0, 0, time.Local),
+		15 * time.Minute: time.Date(2024, 5, 1, 13, 30, 0, 0, time.Local),
+	} {
+		if got := (RotationConfig{Interval: interval}).periodStart(at); !got.Equal(want) {
+			t.Errorf("got period start %s for %s, wanted %s", got, interval, want)
+		}
+	}
+}
+
+func TestRotatingFileCompress(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := openRotatingFile(path, RotationConfig{MaxSize: 10, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"scooby\n", "doo\n", "where\n", "are you\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("got %d backups, wanted 2", len(backups))
+	}
+	for _, b := range backups {
+		if !b.compressed || filepath.Ext(b.path) != ".gz" {
+			t.Errorf("backup %s is not compressed", b.path)
+		}
+	}
+
+	zf, err := os.Open(backups[0].path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zf.Close()
+	zr, err := gzip.NewReader(zf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "doo\nwhere\n" {
+		t.Errorf("got %q in the newest backup", data)
+	}
+}
+
+func TestRotatingFileRetention(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	cfg := RotationConfig{MaxSize: 1 << 20, MaxAge: 24 * time.Hour, MaxTotalSize: 15}
+
+	// rotated files left by a previous run
+	names, err := newBackupNamer(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, age := range []time.Duration{time.Minute, time.Hour, 2 * time.Hour, 48 * time.Hour} {
+		name := names.name(now.Add(-age), 0)
+		if err := os.WriteFile(name, []byte(strings.Repeat("x", 5+i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := openRotatingFile(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the 2 days old file is too old, and the third one exceeds the total size
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("got %d backups, wanted 2", len(backups))
+	}
+	for i, b := range backups {
+		info, err := os.Stat(b.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != int64(5+i) {
+			t.Errorf("kept backup %s of %d bytes", b.path, info.Size())
+		}
+	}
+}
+
+func TestParseMaxAge(t *testing.T) {
+	for s, want := range map[string]time.Duration{"7d": 7 * 24 * time.Hour, "12h": 12 * time.Hour} {
+		if got, err := parseMaxAge(s); err != nil || got != want {
+			t.Errorf("parseMaxAge(%q) = %s, %v, wanted %s", s, got, err, want)
+		}
+	}
+}
+
+func TestRotatingFileNameTemplate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := openRotatingFile(path, RotationConfig{
+		MaxSize:      10,
+		NameTemplate: "{name}{ext}.{hostname}.{pid}.{time}.{seq}",
+		TimeFormat:   "20060102",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"scooby\n", "doo\n", "where\n", "are you\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	hostname, _ := os.Hostname()
+	prefix := fmt.Sprintf("app.log.%s.%d.%s.", hostname, os.Getpid(), time.Now().Format("20060102"))
+	for seq, want := range []string{"scooby\n", "doo\nwhere\n"} {
+		data, err := os.ReadFile(filepath.Join(dir, prefix+strconv.Itoa(seq)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("got %q in rotated file %d, wanted %q", data, seq, want)
+		}
+	}
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || backups[0].seq != 1 {
+		t.Errorf("got backups %+v", backups)
+	}
+}
+
+func TestRotatingFileSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require privileges on Windows")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	cfg := RotationConfig{MaxSize: 10, Symlink: "current.log"}
+	f, err := openRotatingFile(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "current.log")
+	for _, line := range []string{"scooby\n", "doo\n", "where\n", "are you\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "are you\n" {
+		t.Errorf("got %q through the symlink", data)
+	}
+	if fileExists(path) {
+		t.Error("the active file should be named like the rotated files")
+	}
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Errorf("got %d backups, wanted 2", len(backups))
+	}
+
+	// the linked file is appended to after a restart
+	f, err = openRotatingFile(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("!\n")); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if data, _ := os.ReadFile(link); string(data) != "are you\n!\n" {
+		t.Errorf("got %q through the symlink after a restart", data)
+	}
+}
+
+func TestRotatingFileLocking(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	// two files opened separately are locked like in distinct processes
+	var files [2]*rotatingFile
+	for i := range files {
+		f, err := openRotatingFile(path, RotationConfig{MaxSize: 4000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := f.enableLocking(); err != nil {
+			t.Skip(err)
+		}
+		files[i] = f
+	}
+
+	const n = 2000
+	done := make(chan error, len(files))
+	for i, f := range files {
+		go func(i int, f *rotatingFile) {
+			for j := 0; j < n; j++ {
+				line := fmt.Sprintf("%d %s\n", i, strings.Repeat(strconv.Itoa(i), 100))
+				if _, err := f.Write([]byte(line)); err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}(i, f)
+	}
+	for range files {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := 0
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > 4000 {
+			t.Errorf("%s has %d bytes, more than the maximum size", e.Name(), len(data))
+		}
+		// a backup rotated twice, once by each file, is not full
+		if e.Name() != "app.log" && len(data)+102 <= 4000 {
+			t.Errorf("%s has %d bytes only, wanted it rotated once full", e.Name(), len(data))
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+			if len(line) != 102 || strings.Trim(line[2:], line[:1]) != "" {
+				t.Fatalf("got interleaved line %q in %s", line, e.Name())
+			}
+			lines++
+		}
+	}
+	if lines != len(files)*n {
+		t.Errorf("got %d lines, wanted %d", lines, len(files)*n)
+	}
+}
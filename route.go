@@ -0,0 +1,148 @@
+package log
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newSplitCores returns the cores writing the entries at Info and below to
+// stdout, and the entries at Warn and above to stderr.
+func newSplitCores(format LogFormat) []zapcore.Core {
+	return []zapcore.Core{
+		zapcore.NewCore(newEncoder(format), zapcore.Lock(os.Stdout), zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+			return lvl < zapcore.WarnLevel
+		})),
+		zapcore.NewCore(newEncoder(format), zapcore.Lock(os.Stderr), zapcore.WarnLevel),
+	}
+}
+
+// newLevelOutputCores returns a core for every level range of outputs, each
+// range starting at its level and ending before the next one.
+func newLevelOutputCores(outputs map[LogLevel][]string, format LogFormat) []zapcore.Core {
+	levels := make([]LogLevel, 0, len(outputs))
+	for lvl := range outputs {
+		levels = append(levels, lvl)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+
+	var cores []zapcore.Core
+	for i, lvl := range levels {
+		paths := make([]string, 0, len(outputs[lvl]))
+		for _, path := range outputs[lvl] {
+			if path != "stdout" && path != "stderr" && !strings.Contains(path, "://") {
+				abs, err := normalizePath(path)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "failed to resolve log path '%q': %s\n", path, err)
+					continue
+				}
+				path = abs
+			}
+			paths = append(paths, path)
+		}
+		ws, closeOutputs, err := zap.Open(paths...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open %s outputs: %s\n", capitalLevelName(zapcore.Level(lvl)), err)
+			continue
+		}
+
+		// the last range is open-ended, so that it gets the custom levels
+		// above Fatal too
+		min, max := zapcore.Level(lvl), zapcore.Level(math.MaxInt8)
+		if i+1 < len(levels) {
+			max = zapcore.Level(levels[i+1]) - 1
+		}
+		enabler := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+			return lvl >= min && lvl <= max
+		})
+		cores = append(cores, &outputCore{
+			Core:  zapcore.NewCore(newEncoder(format), ws, enabler),
+			close: closeOutputs,
+		})
+	}
+	return cores
+}
+
+// outputCore is a core closing its outputs on Close.
+type outputCore struct {
+	zapcore.Core
+	close func()
+}
+
+func (c *outputCore) With(fields []zapcore.Field) zapcore.Core {
+	return &outputCore{Core: c.Core.With(fields), close: c.close}
+}
+
+func (c *outputCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *outputCore) Close() error {
+	c.close()
+	return nil
+}
+
+// subsystemCore passes the entries of a subsystem, and of the loggers named
+// after it, to the wrapped core.
+type subsystemCore struct {
+	zapcore.Core
+	subsystem string
+
+	// close, if set, releases the output of the wrapped core.
+	close func()
+}
+
+// newSubsystemFileCore creates a core writing the entries of subsystem to the
+// file at path, rotated according to rotation.
+func newSubsystemFileCore(subsystem, path string, format LogFormat, rotation RotationConfig) (zapcore.Core, error) {
+	file, err := openRotatingFile(path, rotation)
+	if err != nil {
+		return nil, err
+	}
+	return &subsystemCore{
+		Core:      newCore(format, file, levelAll),
+		subsystem: subsystem,
+		close: func() {
+			file.Close() // nolint:errcheck
+		},
+	}, nil
+}
+
+func (c *subsystemCore) matches(name string) bool {
+	return name == c.subsystem || strings.HasPrefix(name, c.subsystem+".")
+}
+
+func (c *subsystemCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.Core = c.Core.With(fields)
+	return &clone
+}
+
+func (c *subsystemCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.matches(ent.LoggerName) {
+		return c.Core.Check(ent, ce)
+	}
+	return ce
+}
+
+func (c *subsystemCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if c.matches(ent.LoggerName) {
+		return c.Core.Write(ent, fields)
+	}
+	return nil
+}
+
+func (c *subsystemCore) Close() error {
+	if c.close != nil {
+		c.close()
+	}
+	return nil
+}
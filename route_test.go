@@ -0,0 +1,126 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSubsystemFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dht.log")
+	SetupLogging(Config{
+		Level:          LevelDebug,
+		Format:         FormatJSONOutput,
+		SubsystemFiles: map[string]string{"dht": path},
+	})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	getLogger("dht").Info("scooby")
+	getLogger("dht").Named("net").Info("doo")
+	getLogger("dhtx").Info("ignored")
+	getLogger("other").Info("ignored")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "scooby") || !strings.Contains(lines[1], `"logger":"dht.net"`) {
+		t.Errorf("got %q, wanted the dht entries only", lines)
+	}
+}
+
+func TestSubsystemRotations(t *testing.T) {
+	dir := t.TempDir()
+	SetupLogging(Config{
+		Level:  LevelDebug,
+		Format: FormatJSONOutput,
+		SubsystemFiles: map[string]string{
+			"dht":     filepath.Join(dir, "dht.log"),
+			"bitswap": filepath.Join(dir, "bitswap.log"),
+		},
+		SubsystemRotations: map[string]RotationConfig{
+			"dht": {MaxSize: 100, MaxBackups: 1},
+		},
+	})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	for i := 0; i < 10; i++ {
+		getLogger("dht").Info("scooby doo, where are you?")
+		getLogger("bitswap").Info("scooby doo, where are you?")
+	}
+	// closing the files waits for the rotated files to be deleted
+	SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	for pattern, want := range map[string]int{"dht-*.log": 1, "bitswap-*.log": 0} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != want {
+			t.Errorf("got %d files matching %s, wanted %d", len(matches), pattern, want)
+		}
+	}
+}
+
+func TestSplitOutput(t *testing.T) {
+	os.Setenv(envLoggingOutput, "split")
+	defer os.Unsetenv(envLoggingOutput)
+
+	cfg := configFromEnv()
+	if !cfg.Split || cfg.Stderr || cfg.Stdout {
+		t.Fatalf("got split %t, stderr %t and stdout %t, wanted split only", cfg.Split, cfg.Stderr, cfg.Stdout)
+	}
+
+	cores := newSplitCores(FormatJSONOutput)
+	if !cores[0].Enabled(zapcore.InfoLevel) || cores[0].Enabled(zapcore.WarnLevel) {
+		t.Error("wanted Info and below on stdout")
+	}
+	if cores[1].Enabled(zapcore.InfoLevel) || !cores[1].Enabled(zapcore.WarnLevel) {
+		t.Error("wanted Warn and above on stderr")
+	}
+}
+
+func TestLevelOutputs(t *testing.T) {
+	dir := t.TempDir()
+	SetupLogging(Config{
+		Level:  LevelDebug,
+		Format: FormatJSONOutput,
+		LevelOutputs: map[LogLevel][]string{
+			LevelDebug: {filepath.Join(dir, "debug.log")},
+			LevelError: {filepath.Join(dir, "error.log")},
+		},
+	})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	log := getLogger("test")
+	log.Debug("scooby")
+	log.Warn("doo")
+	log.Error("where are you")
+	// custom levels above Fatal are in the last range
+	Logger("test").Log(LevelFatal+1, "jinkies")
+
+	for file, want := range map[string][]string{
+		"debug.log": {"scooby", "doo"},
+		"error.log": {"where are you", "jinkies"},
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(lines) != len(want) {
+			t.Errorf("got %q in %s, wanted %q", lines, file, want)
+			continue
+		}
+		for i, msg := range want {
+			if !strings.Contains(lines[i], msg) {
+				t.Errorf("got %q in %s, wanted %q", lines[i], file, msg)
+			}
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// S3Config configures uploading gzipped NDJSON chunks of entries to S3 or
+// S3-compatible object storage.
+type S3Config struct {
+	// Bucket is the bucket chunks are uploaded to.
+	Bucket string
+
+	// Prefix is prepended to the object keys, which are made of the upload
+	// time, the host name and a random suffix. Defaults to "logs/".
+	Prefix string
+
+	// Region is the AWS region. Defaults to the AWS_REGION environment variable.
+	Region string
+
+	// AccessKeyID, SecretAccessKey and SessionToken are static credentials.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// ChunkSize is the uncompressed size in bytes a chunk is uploaded at.
+	// Defaults to 5 MiB.
+	ChunkSize int
+
+	// FlushInterval is the maximum age of a chunk before it is uploaded.
+	// Defaults to one minute.
+	FlushInterval time.Duration
+
+	// SpillDir is the directory chunks failing to upload are saved to. They
+	// are uploaded again after the next successful upload. Defaults to a
+	// go-log-s3 directory in the temporary directory.
+	SpillDir string
+
+	// Endpoint overrides the S3 endpoint of the region, for S3-compatible
+	// storage. Objects are then addressed with path-style URLs.
+	Endpoint string
+}
+
+const (
+	defaultS3Prefix        = "logs/"
+	defaultS3ChunkSize     = 5 << 20
+	defaultS3FlushInterval = time.Minute
+	s3MaxRetries           = 3
+)
+
+// newS3Core creates a core uploading chunks of entries to S3.
+func newS3Core(cfg S3Config, level LogLevel) (zapcore.Core, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("missing S3 bucket")
+	}
+	region := awsRegion(cfg.Region)
+	if region == "" {
+		if cfg.Endpoint == "" {
+			return nil, errors.New("missing AWS region")
+		}
+		region = "us-east-1"
+	}
+
+	s := &s3Uploader{
+		region:    region,
+		prefix:    cfg.Prefix,
+		chunkSize: cfg.ChunkSize,
+		interval:  cfg.FlushInterval,
+		spillDir:  cfg.SpillDir,
+		creds:     newAWSCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, cfg.SessionToken),
+		client:    newHTTPClient(nil),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	if cfg.Endpoint != "" {
+		s.url = strings.TrimSuffix(cfg.Endpoint, "/") + "/" + cfg.Bucket + "/"
+	} else {
+		s.url = "https://" + cfg.Bucket + ".s3." + region + ".amazonaws.com/"
+	}
+	if s.prefix == "" {
+		s.prefix = defaultS3Prefix
+	}
+	if s.chunkSize <= 0 {
+		s.chunkSize = defaultS3ChunkSize
+	}
+	if s.interval <= 0 {
+		s.interval = defaultS3FlushInterval
+	}
+	if s.spillDir == "" {
+		s.spillDir = filepath.Join(os.TempDir(), "go-log-s3")
+	}
+	s.hostname, _ = os.Hostname()
+	go s.loop()
+
+	encCfg := newEncoderConfig()
+	encCfg.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+
+	return &batchCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          zapcore.NewJSONEncoder(encCfg),
+		batcher:      newBatcher(0, 0, s.add),
+		sync:         s.upload,
+		close:        s.close,
+	}, nil
+}
+
+// s3Uploader compresses the entries into a chunk, uploaded once it is large
+// or old enough.
+type s3Uploader struct {
+	url       string
+	region    string
+	prefix    string
+	hostname  string
+	chunkSize int
+	interval  time.Duration
+	spillDir  string
+	creds     *awsCredentialsProvider
+	client    *http.Client
+
+	mu      sync.Mutex // guards the chunk
+	chunk   bytes.Buffer
+	zw      *gzip.Writer
+	size    int
+	started time.Time
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func (s *s3Uploader) add(batch []batchEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.zw == nil {
+		s.chunk.Reset()
+		s.zw = gzip.NewWriter(&s.chunk)
+		s.size = 0
+		s.started = time.Now()
+	}
+	for _, e := range batch {
+		if _, err := s.zw.Write(e.data); err != nil {
+			return err
+		}
+		s.size += len(e.data)
+	}
+	if s.size >= s.chunkSize {
+		return s.uploadLocked()
+	}
+	return nil
+}
+
+// loop uploads the chunk once it is older than the flush interval.
+func (s *s3Uploader) loop() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.interval / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.done:
+			return
+		}
+		s.mu.Lock()
+		var err error
+		if s.zw != nil && time.Since(s.started) >= s.interval {
+			err = s.uploadLocked()
+		}
+		s.mu.Unlock()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to upload log chunk: %s\n", err)
+		}
+	}
+}
+
+// upload uploads the current chunk, if any.
+func (s *s3Uploader) upload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.zw == nil {
+		return nil
+	}
+	return s.uploadLocked()
+}
+
+func (s *s3Uploader) uploadLocked() error {
+	if err := s.zw.Close(); err != nil {
+		return err
+	}
+	s.zw = nil
+	data := append([]byte(nil), s.chunk.Bytes()...)
+
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	key := s.prefix + time.Now().UTC().Format("2006/01/02/20060102T150405Z") +
+		"-" + s.hostname + "-" + hex.EncodeToString(id) + ".ndjson.gz"
+
+	if err := s.put(key, data); err != nil {
+		if spillErr := s.spill(key, data); spillErr != nil {
+			return multierr.Append(err, spillErr)
+		}
+		return fmt.Errorf("saved log chunk to %s: %s", s.spillDir, err)
+	}
+	return s.retrySpilled()
+}
+
+// spill saves a chunk that failed to upload to the spill directory.
+func (s *s3Uploader) spill(key string, data []byte) error {
+	if err := os.MkdirAll(s.spillDir, 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(s.spillDir, url.PathEscape(key)), data, 0600)
+}
+
+// retrySpilled uploads the chunks saved to the spill directory.
+func (s *s3Uploader) retrySpilled() error {
+	files, err := ioutil.ReadDir(s.spillDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, f := range files {
+		key, err := url.PathUnescape(f.Name())
+		if err != nil || f.IsDir() {
+			continue
+		}
+		path := filepath.Join(s.spillDir, f.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := s.put(key, data); err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *s3Uploader) put(key string, data []byte) error {
+	return retryWithBackoff(s3MaxRetries, func() (bool, error) {
+		creds, err := s.creds.credentials()
+		if err != nil {
+			return false, err
+		}
+		req, err := http.NewRequest(http.MethodPut, s.url+key, bytes.NewReader(data))
+		if err != nil {
+			return false, err
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		req.Header.Set("Content-Encoding", "gzip")
+		signAWSRequest(req, data, creds, s.region, "s3", time.Now())
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return true, err
+		}
+		err = checkHTTPResponse(resp)
+		return isRetryableHTTPError(err), err
+	})
+}
+
+func (s *s3Uploader) close() error {
+	close(s.done)
+	<-s.stopped
+	return s.upload()
+}
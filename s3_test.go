@@ -0,0 +1,81 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestS3Core(t *testing.T) {
+	defer func(backoff time.Duration) { retryBackoff = backoff }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	failing := true
+	uploads := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Method != http.MethodPut || !strings.HasPrefix(r.URL.Path, "/bucket/app/") {
+			t.Errorf("got %s %s", r.Method, r.URL.Path)
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			t.Errorf("got unsigned request")
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(zr)
+		uploads[r.URL.Path] = string(data)
+	}))
+	defer srv.Close()
+
+	core, err := newS3Core(S3Config{
+		Bucket:          "bucket",
+		Prefix:          "app/",
+		AccessKeyID:     "id",
+		SecretAccessKey: "secret",
+		SpillDir:        t.TempDir(),
+		Endpoint:        srv.URL,
+	}, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*batchCore).Close()
+
+	logger := zap.New(core)
+	logger.Info("scooby")
+	if err := core.Sync(); err == nil {
+		t.Fatal("wanted the upload to fail")
+	}
+
+	// the spilled chunk is uploaded along with the next one
+	failing = false
+	logger.Info("doo")
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(uploads) != 2 {
+		t.Fatalf("got %d uploads, wanted 2", len(uploads))
+	}
+	var all bytes.Buffer
+	for _, data := range uploads {
+		if strings.Count(data, "\n") != 1 {
+			t.Errorf("got chunk %q, wanted one entry", data)
+		}
+		all.WriteString(data)
+	}
+	if !strings.Contains(all.String(), `"msg":"scooby"`) || !strings.Contains(all.String(), `"msg":"doo"`) {
+		t.Errorf("got %q, wanted both entries", all.String())
+	}
+}
@@ -0,0 +1,95 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SamplingConfig throttles the entries of a subsystem with the sampler of
+// zapcore: every second, the first Initial entries with a given level and
+// message are logged, and then every Thereafter-th of them, the others being
+// dropped. All of them are dropped after the first Initial when Thereafter
+// is 0. Entries at LevelDPanic and above are never dropped.
+type SamplingConfig struct {
+	Initial    int
+	Thereafter int
+}
+
+// parseSampling parses a SamplingConfig written "<initial>:<thereafter>".
+func parseSampling(s string) (SamplingConfig, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return SamplingConfig{}, fmt.Errorf("invalid sampling %q, expected <initial>:<thereafter>", s)
+	}
+	initial, err := strconv.Atoi(parts[0])
+	if err != nil || initial < 0 {
+		return SamplingConfig{}, fmt.Errorf("invalid initial entries %q", parts[0])
+	}
+	thereafter, err := strconv.Atoi(parts[1])
+	if err != nil || thereafter < 0 {
+		return SamplingConfig{}, fmt.Errorf("invalid sampling interval %q", parts[1])
+	}
+	return SamplingConfig{Initial: initial, Thereafter: thereafter}, nil
+}
+
+// sampler decides which entries of a subsystem are logged.
+type sampler struct {
+	core zapcore.Core
+}
+
+func newSampler(cfg SamplingConfig) *sampler {
+	return &sampler{core: zapcore.NewSamplerWithOptions(sampledCore{zapcore.NewNopCore()}, time.Second, cfg.Initial, cfg.Thereafter)}
+}
+
+// sampled reports whether ent is logged.
+func (s *sampler) sampled(ent zapcore.Entry) bool {
+	if ent.Level >= zapcore.DPanicLevel {
+		return true
+	}
+	return s.core.Check(ent, nil) == sampledEntry
+}
+
+// sampledEntry is returned by sampledCore to mark the entries passing the
+// sampler.
+var sampledEntry = new(zapcore.CheckedEntry)
+
+// sampledCore is the core wrapped by the sampler of zapcore in a sampler.
+type sampledCore struct {
+	zapcore.Core
+}
+
+func (sampledCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (sampledCore) Check(zapcore.Entry, *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return sampledEntry
+}
+
+// subsystemSampler returns the value holding the *sampler of a subsystem.
+// loggerMutex must be held.
+func subsystemSampler(name string) *atomic.Value {
+	s, ok := samplers[name]
+	if !ok {
+		s = new(atomic.Value)
+		s.Store((*sampler)(nil))
+		samplers[name] = s
+	}
+	return s
+}
+
+// setSampling sets the sampling of the subsystems of a Config, disabling it
+// for the others. loggerMutex must be held.
+func setSampling(sampling map[string]SamplingConfig) {
+	for _, s := range samplers {
+		s.Store((*sampler)(nil))
+	}
+	for name, cfg := range sampling {
+		subsystemSampler(name).Store(newSampler(cfg))
+	}
+}
@@ -0,0 +1,54 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSubsystemSampling(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{
+		Level:             LevelInfo,
+		Format:            FormatJSONOutput,
+		File:              path,
+		SubsystemSampling: map[string]SamplingConfig{"chatty": {Initial: 2, Thereafter: 3}},
+	})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	chatty := Logger("chatty")
+	quiet := Logger("quiet")
+	for i := 0; i < 10; i++ {
+		chatty.Info("scooby")
+		quiet.Info("doo")
+	}
+	chatty.Info("where are you")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	// the first 2 entries, and then the 5th and the 8th
+	if n := strings.Count(out, "scooby"); n != 4 {
+		t.Errorf("got %d sampled entries, wanted 4", n)
+	}
+	if n := strings.Count(out, "doo"); n != 10 {
+		t.Errorf("got %d entries of the subsystem not sampled, wanted 10", n)
+	}
+	if !strings.Contains(out, "where are you") {
+		t.Error("expected the entries of other messages to be sampled separately")
+	}
+}
+
+func TestSamplingFromEnv(t *testing.T) {
+	os.Setenv(envLoggingSampling, "dht=100:10,bitswap=oops")
+	defer os.Unsetenv(envLoggingSampling)
+
+	cfg := configFromEnv()
+	if len(cfg.SubsystemSampling) != 1 || cfg.SubsystemSampling["dht"] != (SamplingConfig{Initial: 100, Thereafter: 10}) {
+		t.Errorf("got %v, wanted the sampling of dht", cfg.SubsystemSampling)
+	}
+}
@@ -0,0 +1,295 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// SentryConfig configures reporting Error, DPanic, Panic and Fatal entries
+// to Sentry as events, with their fields as extra data and a stack trace.
+type SentryConfig struct {
+	// DSN is the Sentry DSN, i.e. "https://key@o0.ingest.sentry.io/0".
+	DSN string
+
+	// Environment and Release are attached to every event. They default to
+	// the SENTRY_ENVIRONMENT and SENTRY_RELEASE environment variables.
+	Environment string
+	Release     string
+}
+
+// sentryClientName identifies this package in the requests to Sentry.
+const sentryClientName = "go-log/1.0"
+
+// newSentryCore creates a core sending error entries to Sentry. Labels are
+// sent as event tags.
+func newSentryCore(cfg SentryConfig, labels map[string]string) (zapcore.Core, error) {
+	dsn, err := url.Parse(cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sentry DSN: %s", err)
+	}
+	if dsn.User == nil || dsn.User.Username() == "" {
+		return nil, errors.New("invalid Sentry DSN: missing public key")
+	}
+	i := strings.LastIndexByte(dsn.Path, '/')
+	projectID := dsn.Path[i+1:]
+	if projectID == "" {
+		return nil, errors.New("invalid Sentry DSN: missing project ID")
+	}
+
+	s := &sentryClient{
+		url: dsn.Scheme + "://" + dsn.Host + dsn.Path[:i] + "/api/" + projectID + "/envelope/",
+		auth: "Sentry sentry_version=7, sentry_client=" + sentryClientName +
+			", sentry_key=" + dsn.User.Username(),
+		client: newHTTPClient(nil),
+	}
+
+	environment := cfg.Environment
+	if environment == "" {
+		environment = os.Getenv("SENTRY_ENVIRONMENT")
+	}
+	release := cfg.Release
+	if release == "" {
+		release = os.Getenv("SENTRY_RELEASE")
+	}
+	hostname, _ := os.Hostname()
+
+	return &sentryCore{
+		LevelEnabler: zapcore.ErrorLevel,
+		batcher:      newBatcher(0, 0, s.send),
+		environment:  environment,
+		release:      release,
+		serverName:   hostname,
+		labels:       labels,
+	}, nil
+}
+
+type sentryCore struct {
+	zapcore.LevelEnabler
+	batcher     *batcher
+	environment string
+	release     string
+	serverName  string
+	labels      map[string]string
+	fields      []zapcore.Field
+}
+
+func (c *sentryCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+	return &clone
+}
+
+func (c *sentryCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+type sentryEvent struct {
+	EventID     string                 `json:"event_id"`
+	Timestamp   string                 `json:"timestamp"`
+	Platform    string                 `json:"platform"`
+	Level       string                 `json:"level"`
+	Logger      string                 `json:"logger,omitempty"`
+	Message     string                 `json:"message"`
+	ServerName  string                 `json:"server_name,omitempty"`
+	Environment string                 `json:"environment,omitempty"`
+	Release     string                 `json:"release,omitempty"`
+	Tags        map[string]string      `json:"tags,omitempty"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+	Exception   struct {
+		Values []sentryException `json:"values"`
+	} `json:"exception"`
+}
+
+type sentryException struct {
+	Type       string `json:"type"`
+	Value      string `json:"value"`
+	Stacktrace struct {
+		Frames []sentryFrame `json:"frames"`
+	} `json:"stacktrace"`
+}
+
+type sentryFrame struct {
+	Function string `json:"function"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+}
+
+func (c *sentryCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+
+	event := sentryEvent{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   ent.Time.UTC().Format(time.RFC3339Nano),
+		Platform:    "go",
+		Level:       "error",
+		Logger:      ent.LoggerName,
+		Message:     ent.Message,
+		ServerName:  c.serverName,
+		Environment: c.environment,
+		Release:     c.release,
+		Tags:        make(map[string]string, len(c.labels)+1),
+	}
+	if ent.Level > zapcore.ErrorLevel {
+		event.Level = "fatal"
+	}
+	for k, v := range c.labels {
+		event.Tags[k] = v
+	}
+	if ent.LoggerName != "" {
+		event.Tags["subsystem"] = ent.LoggerName
+	}
+
+	exception := sentryException{Type: capitalLevelName(ent.Level), Value: ent.Message}
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range append(c.fields[:len(c.fields):len(c.fields)], fields...) {
+		if err, ok := f.Interface.(error); ok && f.Type == zapcore.ErrorType {
+			exception.Type = fmt.Sprintf("%T", err)
+			exception.Value = err.Error()
+		}
+		f.AddTo(enc)
+	}
+	event.Extra = enc.Fields
+
+	if ent.Stack != "" {
+		exception.Stacktrace.Frames = parseSentryFrames(ent.Stack)
+	} else {
+		exception.Stacktrace.Frames = captureSentryFrames()
+	}
+	event.Exception.Values = []sentryException{exception}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	c.batcher.add(batchEntry{ent: ent, data: data})
+	return nil
+}
+
+func (c *sentryCore) Sync() error {
+	return c.batcher.Sync()
+}
+
+func (c *sentryCore) Close() error {
+	return c.batcher.Close()
+}
+
+func (c *sentryCore) shutdown(ctx context.Context) (int, error) {
+	return c.batcher.shutdown(ctx)
+}
+
+// parseSentryFrames parses a stack trace formatted by zap into Sentry frames,
+// ordered from the outermost call as Sentry expects.
+func parseSentryFrames(stack string) []sentryFrame {
+	lines := strings.Split(stack, "\n")
+	var frames []sentryFrame
+	for i := 0; i+1 < len(lines); i += 2 {
+		location := strings.TrimPrefix(lines[i+1], "\t")
+		sep := strings.LastIndexByte(location, ':')
+		if sep < 0 {
+			continue
+		}
+		line, _ := strconv.Atoi(location[sep+1:])
+		frames = append([]sentryFrame{{
+			Function: lines[i],
+			AbsPath:  location[:sep],
+			Lineno:   line,
+		}}, frames...)
+	}
+	return frames
+}
+
+// captureSentryFrames captures the stack of the caller of the logger.
+func captureSentryFrames() []sentryFrame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	iter := runtime.CallersFrames(pcs[:n])
+
+	var frames []sentryFrame
+	for {
+		frame, more := iter.Next()
+		if !isLoggingFrame(frame.Function) {
+			frames = append([]sentryFrame{{
+				Function: frame.Function,
+				AbsPath:  frame.File,
+				Lineno:   frame.Line,
+			}}, frames...)
+		}
+		if !more {
+			return frames
+		}
+	}
+}
+
+// isLoggingFrame reports whether function belongs to the logging machinery
+// rather than to the code that logged.
+func isLoggingFrame(function string) bool {
+	return strings.HasPrefix(function, "go.uber.org/zap") ||
+		strings.HasPrefix(function, "github.com/jianbo-zh/go-log.(*lockedMultiCore)") ||
+		strings.HasPrefix(function, "github.com/jianbo-zh/go-log.(*sentryCore)") ||
+		strings.HasPrefix(function, "github.com/jianbo-zh/go-log.captureSentryFrames")
+}
+
+type sentryClient struct {
+	url    string
+	auth   string
+	client *http.Client
+}
+
+func (s *sentryClient) send(batch []batchEntry) error {
+	var err error
+	for _, e := range batch {
+		err = multierr.Append(err, s.sendEvent(e.data))
+	}
+	return err
+}
+
+func (s *sentryClient) sendEvent(event []byte) error {
+	var id struct {
+		EventID string `json:"event_id"`
+	}
+	if err := json.Unmarshal(event, &id); err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, `{"event_id":%q,"sent_at":%q}`+"\n", id.EventID, time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(&body, `{"type":"event","length":%d}`+"\n", len(event))
+	body.Write(event)
+	body.WriteByte('\n')
+
+	req, err := http.NewRequest(http.MethodPost, s.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", s.auth)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return checkHTTPResponse(resp)
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestSentryCore(t *testing.T) {
+	var events []sentryEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/42/envelope/" {
+			t.Errorf("got path %q", r.URL.Path)
+		}
+		if auth := r.Header.Get("X-Sentry-Auth"); !strings.Contains(auth, "sentry_key=public") {
+			t.Errorf("got auth %q", auth)
+		}
+		scanner := bufio.NewScanner(r.Body)
+		var lines []string
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if len(lines) != 3 {
+			t.Fatalf("got %d envelope lines, wanted 3", len(lines))
+		}
+		var event sentryEvent
+		if err := json.Unmarshal([]byte(lines[2]), &event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}))
+	defer srv.Close()
+
+	dsn := strings.Replace(srv.URL, "http://", "http://public@", 1) + "/42"
+	core, err := newSentryCore(SentryConfig{DSN: dsn}, map[string]string{"app": "example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*sentryCore).Close()
+
+	logger := zap.New(core).Named("dht")
+	logger.Info("not reported")
+	logger.Error("scooby", zap.Error(errors.New("boom")), zap.Int("count", 3))
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("got %d events, wanted 1", len(events))
+	}
+	event := events[0]
+	if event.Message != "scooby" || event.Tags["subsystem"] != "dht" || event.Tags["app"] != "example" {
+		t.Errorf("got message %q and tags %v", event.Message, event.Tags)
+	}
+	if event.Extra["count"] != float64(3) {
+		t.Errorf("got extra %v, wanted it to contain the fields", event.Extra)
+	}
+	exception := event.Exception.Values[0]
+	if exception.Value != "boom" {
+		t.Errorf("got exception value %q, wanted the error", exception.Value)
+	}
+	frames := exception.Stacktrace.Frames
+	if len(frames) == 0 || !strings.HasSuffix(frames[len(frames)-1].Function, "TestSentryCore") {
+		t.Errorf("got frames %v, wanted the innermost frame to be the caller", frames)
+	}
+}
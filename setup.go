@@ -1,13 +1,23 @@
 package log
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/mattn/go-isatty"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -16,11 +26,120 @@ const (
 	envLoggingLvl = "GOLOG_LOG_LEVEL"
 	envLoggingFmt = "GOLOG_LOG_FMT"
 
+	envLoggingConfig      = "GOLOG_CONFIG"       // /path/to/config.yaml, .json or .toml overriding the other variables
+	envLoggingConfigWatch = "GOLOG_CONFIG_WATCH" // interval GOLOG_CONFIG is polled at for changes, i.e. "5s"
+
+	envNoColor    = "NO_COLOR"    // disables colors when not empty, see https://no-color.org
+	envForceColor = "FORCE_COLOR" // enables colors when not empty, even without a TTY, and overrides NO_COLOR
+
+	envLoggingStacktraceLevel = "GOLOG_STACKTRACE_LEVEL" // level from which stack traces are attached, or "never"
+
+	envLoggingTimeFormat = "GOLOG_TIME_FORMAT" // iso8601, rfc3339, rfc3339nano, epoch, epoch_millis, epoch_nanos or a Go time layout
+	envLoggingJSONKeys   = "GOLOG_JSON_KEYS"   // comma-separated renamed JSON keys, i.e. "ts=time,level=severity,logger=component"
+	envLoggingColors     = "GOLOG_COLORS"      // color theme default|high-contrast|colorblind, and colors, i.e. "colorblind,error=1;31,subsystems=36:35"
+	envLoggingMultiline  = "GOLOG_MULTILINE"   // line breaks of messages in console formats: escape|indent|raw
+	envLoggingCaller     = "GOLOG_CALLER"      // caller format short|full|relative|function|none, per subsystem too, i.e. "relative,dht=none"
+
 	envLoggingFile = "GOLOG_FILE" // /path/to/file
 	envLoggingURL  = "GOLOG_URL"  // url that will be processed by sink in the zap
 
-	envLoggingOutput = "GOLOG_OUTPUT"     // possible values: stdout|stderr|file combine multiple values with '+'
+	envLoggingFileMaxSize      = "GOLOG_FILE_MAX_SIZE"       // size the file is rotated at, i.e. "100M"
+	envLoggingFileMaxBackups   = "GOLOG_FILE_MAX_BACKUPS"    // number of rotated files kept
+	envLoggingFileRotation     = "GOLOG_FILE_ROTATION"       // interval the file is rotated every: daily, hourly or a duration
+	envLoggingFileCompress     = "GOLOG_FILE_COMPRESS"       // compress rotated files when true
+	envLoggingFileMaxAge       = "GOLOG_FILE_MAX_AGE"        // age rotated files are deleted at, i.e. "7d" or "12h"
+	envLoggingFileMaxTotalSize = "GOLOG_FILE_MAX_TOTAL_SIZE" // total size of the rotated files kept, i.e. "1G"
+	envLoggingFileNameTemplate = "GOLOG_FILE_NAME_TEMPLATE"  // template of the names of rotated files, i.e. "{name}-{hostname}-{time}{ext}"
+	envLoggingFileTimeFormat   = "GOLOG_FILE_TIME_FORMAT"    // time layout of {time} in the names of rotated files
+	envLoggingFileMinFreeSpace = "GOLOG_FILE_MIN_FREE_SPACE" // free disk space below which only warnings and above are written to the file, i.e. "1G"
+	envLoggingFileSymlink      = "GOLOG_FILE_SYMLINK"        // symlink to the active file maintained when rotating, i.e. "current.log"
+	envLoggingFileLock         = "GOLOG_FILE_LOCK"           // whether to lock the file while writing, for processes sharing it
+
+	envLoggingReopenOnSIGHUP = "GOLOG_REOPEN_ON_SIGHUP" // reopen the log files on SIGHUP when true, for logrotate
+	envLoggingReloadOnSIGHUP = "GOLOG_RELOAD_ON_SIGHUP" // set up the logging again from the variables and GOLOG_CONFIG on SIGHUP when true
+	envLoggingDebugOnSIGUSR1 = "GOLOG_DEBUG_ON_SIGUSR1" // set all subsystems to debug on SIGUSR1 and restore their levels on SIGUSR2 when true
+
+	envLoggingAuditFile = "GOLOG_AUDIT_FILE" // /path/to/file the audit events are written to
+
+	envLoggingLevelOutputs = "GOLOG_LEVEL_OUTPUTS" // outputs of level ranges, i.e. "debug=/path/to/file,error=stderr+udp://host:514"
+
+	envLoggingRecentEntries = "GOLOG_RECENT_ENTRIES" // number of last entries kept in memory for DumpRecent
+
+	envLoggingSubsystemFilePrefix = "GOLOG_FILE_" // GOLOG_FILE_<SUBSYSTEM>=/path/to/file, the subsystem name being lower-cased
+
+	envLoggingOutput = "GOLOG_OUTPUT"     // possible values: stdout|stderr|split|file|url|syslog|journald|logcat|loki|elasticsearch|kafka|cloudwatch|cloudlogging|azure|datadog|otlp|http|nats|mqtt|redis|zeromq|s3|sqlite|clickhouse combine multiple values with '+'
 	envLoggingLabels = "GOLOG_LOG_LABELS" // comma-separated key-value pairs, i.e. "app=example_app,dc=sjc-1"
+
+	envLoggingProcessFields = "GOLOG_PROCESS_FIELDS" // comma-separated fields added to all entries: hostname|pid|executable
+
+	envLoggingSubsystemOutputs = "GOLOG_SUBSYSTEM_OUTPUTS" // comma-separated subsystem-output pairs, i.e. "noisy-lib=none"
+
+	envLoggingSampling   = "GOLOG_SAMPLING"   // comma-separated subsystem-sampling pairs, i.e. "dht=100:10" for the first 100 entries per second, then every 10th
+	envLoggingRateLimits = "GOLOG_RATE_LIMIT" // comma-separated subsystem-limit pairs, i.e. "dht=10:50" for 10 entries per second in bursts of 50
+
+	envLoggingDeduplicate = "GOLOG_DEDUPLICATE" // window the repeated entries are collapsed within, i.e. "10s"
+
+	envLoggingSyslogAddr     = "GOLOG_SYSLOG_ADDR"     // remote syslog server, i.e. "udp://localhost:514"; local daemon when empty
+	envLoggingSyslogFacility = "GOLOG_SYSLOG_FACILITY" // syslog facility name, i.e. "daemon"
+	envLoggingSyslogTag      = "GOLOG_SYSLOG_TAG"      // syslog tag, defaults to the program name
+
+	envLoggingLokiURL = "GOLOG_LOKI_URL" // Loki base URL, credentials may be given as user info
+
+	envLoggingElasticsearchURL   = "GOLOG_ELASTICSEARCH_URL"   // Elasticsearch base URL, credentials may be given as user info
+	envLoggingElasticsearchIndex = "GOLOG_ELASTICSEARCH_INDEX" // index name template, i.e. "logs-{2006.01.02}"
+
+	envLoggingKafkaBrokers = "GOLOG_KAFKA_BROKERS" // comma-separated broker addresses
+	envLoggingKafkaTopic   = "GOLOG_KAFKA_TOPIC"
+	envLoggingKafkaKey     = "GOLOG_KAFKA_KEY" // partition key: subsystem|level|<field name>
+
+	envLoggingCloudWatchGroup  = "GOLOG_CLOUDWATCH_GROUP"
+	envLoggingCloudWatchStream = "GOLOG_CLOUDWATCH_STREAM"
+
+	envLoggingCloudLoggingLogName = "GOLOG_CLOUDLOGGING_LOG_NAME" // Cloud Logging log name, defaults to the program name
+
+	envLoggingAzureWorkspaceID = "GOLOG_AZURE_WORKSPACE_ID"
+	envLoggingAzureSharedKey   = "GOLOG_AZURE_SHARED_KEY"
+	envLoggingAzureLogType     = "GOLOG_AZURE_LOG_TYPE"
+
+	envLoggingSentryDSN = "GOLOG_SENTRY_DSN" // report errors to Sentry when set
+
+	envLoggingPagerDutyRoutingKey = "GOLOG_PAGERDUTY_ROUTING_KEY" // trigger PagerDuty incidents on crashes when set
+	envLoggingOpsgenieAPIKey      = "GOLOG_OPSGENIE_API_KEY"      // create Opsgenie alerts on crashes when set
+
+	envLoggingSMTPAddr     = "GOLOG_SMTP_ADDR" // send digests of errors by email when set, i.e. "smtp.example.com:587"
+	envLoggingSMTPUsername = "GOLOG_SMTP_USERNAME"
+	envLoggingSMTPPassword = "GOLOG_SMTP_PASSWORD"
+	envLoggingSMTPFrom     = "GOLOG_SMTP_FROM"
+	envLoggingSMTPTo       = "GOLOG_SMTP_TO" // comma-separated recipients
+
+	envLoggingWebhookURL    = "GOLOG_WEBHOOK_URL"    // post error alerts to a chat webhook when set
+	envLoggingWebhookFormat = "GOLOG_WEBHOOK_FORMAT" // slack|discord|teams
+
+	envLoggingHTTPURL     = "GOLOG_HTTP_URL"
+	envLoggingHTTPHeaders = "GOLOG_HTTP_HEADERS" // comma-separated headers, i.e. "Authorization=Bearer xyz,X-Source=app"
+
+	envLoggingNATSURL       = "GOLOG_NATS_URL"
+	envLoggingNATSSubject   = "GOLOG_NATS_SUBJECT"   // subject template, i.e. "logs.{subsystem}.{level}"
+	envLoggingNATSJetStream = "GOLOG_NATS_JETSTREAM" // "true" to wait for JetStream acknowledgements
+
+	envLoggingMQTTURL   = "GOLOG_MQTT_URL"
+	envLoggingMQTTTopic = "GOLOG_MQTT_TOPIC" // topic template, i.e. "logs/{subsystem}"
+	envLoggingMQTTQoS   = "GOLOG_MQTT_QOS"   // 0, 1 or 2
+
+	envLoggingRedisURL    = "GOLOG_REDIS_URL"
+	envLoggingRedisStream = "GOLOG_REDIS_STREAM"
+	envLoggingRedisMaxLen = "GOLOG_REDIS_MAXLEN" // approximate maximum length of the stream
+
+	envLoggingS3Bucket   = "GOLOG_S3_BUCKET"
+	envLoggingS3Prefix   = "GOLOG_S3_PREFIX"
+	envLoggingS3Endpoint = "GOLOG_S3_ENDPOINT" // S3-compatible storage endpoint
+
+	envLoggingZeroMQEndpoint = "GOLOG_ZEROMQ_ENDPOINT" // PUB socket endpoint to bind, i.e. "tcp://*:5556"
+
+	envLoggingSQLitePath = "GOLOG_SQLITE_PATH" // /path/to/logs.db, a SQLite driver must be imported
+
+	envLoggingClickHouseURL   = "GOLOG_CLICKHOUSE_URL" // HTTP interface URL, credentials may be given as user info
+	envLoggingClickHouseTable = "GOLOG_CLICKHOUSE_TABLE"
 )
 
 // ErrNoSuchLogger is returned when the util pkg is asked for a non existant logger
@@ -32,6 +151,28 @@ var loggerMutex sync.RWMutex // guards access to global logger state
 var loggers = make(map[string]*zap.SugaredLogger)
 var levels = make(map[string]zap.AtomicLevel)
 
+// loggerRefs counts the references to the subsystems acquired by AcquireLogger
+var loggerRefs = make(map[string]int)
+
+// stacktraceLevel is the level from which the loggers attach stack traces,
+// stacktraceNever when never
+var stacktraceLevel = zap.NewAtomicLevelAt(stacktraceNever)
+
+// stacktraceNever is above all levels, including the registered ones.
+const stacktraceNever = zapcore.Level(math.MaxInt8)
+
+// discards are the flags set when the entries of a subsystem are discarded
+var discards = make(map[string]*uint32)
+
+// subsystemLabels hold the []zapcore.Field of the labels of each subsystem
+var subsystemLabels = make(map[string]*atomic.Value)
+
+// samplers hold the *sampler of each subsystem, nil when not sampled
+var samplers = make(map[string]*atomic.Value)
+
+// rateLimiters hold the *rateLimiter of each subsystem, nil when not limited
+var rateLimiters = make(map[string]*atomic.Value)
+
 // primaryFormat is the format of the primary core used for logging
 var primaryFormat LogFormat = FormatColorizedOutput
 
@@ -41,11 +182,74 @@ var defaultLevel LogLevel = LevelError
 // primaryCore is the primary logging core
 var primaryCore zapcore.Core
 
+// secondaryCores are the cores configured by SetupLogging next to the primary core
+var secondaryCores []zapcore.Core
+
+// unlabeledPrimaryCore and unlabeledSecondaryCores are the cores set up by
+// SetupLogging before adding the labels and the fields of the process, the
+// primary one being nil once replaced by SetPrimaryCore
+var unlabeledPrimaryCore zapcore.Core
+var unlabeledSecondaryCores []zapcore.Core
+
+// globalLabels are the labels added to the entries of all loggers, from
+// Config.Labels and SetGlobalLabel
+var globalLabels map[string]string
+
+// globalFields are the fields of the process added to all entries
+var globalFields []zap.Field
+
+// pendingSinkConfig is the configuration set up without its URL output,
+// waiting for a sink for pendingSinkScheme to be registered
+var pendingSinkConfig *Config
+var pendingSinkScheme string
+
+// pendingFormatConfig is the configuration set up from the environment,
+// waiting for the format pendingFormatName to be registered
+var pendingFormatConfig *Config
+var pendingFormatName string
+
+// primaryFile is the rotating file written by the primary core, if any
+var primaryFile *rotatingFile
+
+// closePrimaryOutputs closes the outputs of the primary core opened by zap
+var closePrimaryOutputs func()
+
+// fileOptionEnvs are the environment variables configuring the file output,
+// which are not subsystem files despite their prefix
+var fileOptionEnvs = map[string]bool{
+	envLoggingFileMaxSize:      true,
+	envLoggingFileMaxBackups:   true,
+	envLoggingFileRotation:     true,
+	envLoggingFileCompress:     true,
+	envLoggingFileMaxAge:       true,
+	envLoggingFileMaxTotalSize: true,
+	envLoggingFileNameTemplate: true,
+	envLoggingFileTimeFormat:   true,
+	envLoggingFileMinFreeSpace: true,
+	envLoggingFileSymlink:      true,
+	envLoggingFileLock:         true,
+}
+
 // loggerCore is the base for all loggers created by this package
 var loggerCore = &lockedMultiCore{}
 
 func init() {
-	SetupLogging(configFromEnv())
+	registerSinks()
+	cfg := configFromEnvAndFile()
+	SetupLogging(cfg)
+	loggerMutex.Lock()
+	if pendingFormatName != "" {
+		pendingFormatConfig = &cfg
+	}
+	loggerMutex.Unlock()
+	if path, watch := os.Getenv(envLoggingConfig), os.Getenv(envLoggingConfigWatch); path != "" && watch != "" {
+		interval, err := time.ParseDuration(watch)
+		if err != nil || interval <= 0 {
+			fmt.Fprintf(os.Stderr, "ignoring invalid %s value '%s'\n", envLoggingConfigWatch, watch)
+		} else if _, err := WatchConfigFile(path, interval); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to watch config file: %s\n", err)
+		}
+	}
 }
 
 // setupLogging will initialize the logger backend and set the flags.
@@ -56,8 +260,19 @@ func SetupLogging(cfg Config) {
 	loggerMutex.Lock()
 	defer loggerMutex.Unlock()
 
+	setupLogging(cfg)
+}
+
+// setupLogging implements SetupLogging, loggerMutex must be held.
+func setupLogging(cfg Config) {
+	pendingSinkConfig = nil
+	pendingFormatConfig = nil
+
 	primaryFormat = cfg.Format
 	defaultLevel = cfg.Level
+	setStacktraceLevel(cfg.StacktraceLevel)
+	setFatalBehavior(cfg.FatalBehavior)
+	setEncoderOptions(cfg)
 
 	outputPaths := []string{}
 
@@ -69,29 +284,79 @@ func SetupLogging(cfg Config) {
 	}
 
 	// check if we log to a file
+	var file *rotatingFile
 	if len(cfg.File) > 0 {
-		if path, err := normalizePath(cfg.File); err != nil {
-			fmt.Fprintf(os.Stderr, "failed to resolve log path '%q', logging to %s\n", cfg.File, outputPaths)
-		} else {
-			outputPaths = append(outputPaths, path)
+		var err error
+		if file, err = openRotatingFile(cfg.File, cfg.Rotation); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open log file '%s', logging to %s: %s\n", cfg.File, outputPaths, err)
+		} else if cfg.FileLock {
+			if err := file.enableLocking(); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to lock log file '%s', writing without locking: %s\n", cfg.File, err)
+			}
 		}
 	}
 	if len(cfg.URL) > 0 {
-		outputPaths = append(outputPaths, cfg.URL)
+		if _, _, ok := coreURL(cfg.URL); !ok {
+			outputPaths = append(outputPaths, cfg.URL)
+		}
 	}
 
-	outputs, _, err := zap.Open(outputPaths...)
+	outputs, closeOutputs, err := zap.Open(outputPaths...)
+	if err != nil && len(outputPaths) > 0 && outputPaths[len(outputPaths)-1] == cfg.URL {
+		// the sink of the URL may be registered with RegisterSink after the
+		// logging has been set up from the environment
+		if scheme, ok := unknownSinkScheme(cfg.URL); ok {
+			if outputs, closeOutputs, err = zap.Open(outputPaths[:len(outputPaths)-1]...); err == nil {
+				pendingSinkConfig = &cfg
+				pendingSinkScheme = scheme
+			}
+		}
+	}
 	if err != nil {
 		panic(fmt.Sprintf("unable to open logging output: %v", err))
 	}
+	if file != nil && cfg.MinFreeDiskSpace <= 0 {
+		outputs = zap.CombineWriteSyncers(outputs, file)
+	}
 
-	newPrimaryCore := newCore(primaryFormat, outputs, LevelDebug) // the main core needs to log everything.
+	newPrimaryCore := newCore(primaryFormat, outputs, levelAll) // the main core needs to log everything.
+	if file != nil && cfg.MinFreeDiskSpace > 0 {
+		// the file only gets warnings and above when the disk is almost full
+		fileCore := zapcore.NewCore(newEncoder(primaryFormat), file, newDiskSpaceEnabler(file.path, cfg.MinFreeDiskSpace))
+		newPrimaryCore = zapcore.NewTee(newPrimaryCore, fileCore)
+	}
+
+	newSecondaryCores := secondaryCoresFromConfig(cfg)
 
+	globalLabels = make(map[string]string, len(cfg.Labels))
 	for k, v := range cfg.Labels {
-		newPrimaryCore = newPrimaryCore.With([]zap.Field{zap.String(k, v)})
+		globalLabels[k] = v
+	}
+	globalFields = processFields(cfg)
+	unlabeledPrimaryCore = newPrimaryCore
+	unlabeledSecondaryCores = newSecondaryCores
+	newPrimaryCore = labelCore(newPrimaryCore, primaryFormat == FormatGCPOutput)
+	newSecondaryCores = make([]zapcore.Core, len(unlabeledSecondaryCores))
+	for i, core := range unlabeledSecondaryCores {
+		newSecondaryCores[i] = labelCore(core, false)
 	}
 
 	setPrimaryCore(newPrimaryCore)
+	setSecondaryCores(newSecondaryCores)
+	if primaryFile != nil {
+		primaryFile.Close() // nolint:errcheck
+	}
+	primaryFile = file
+	if closePrimaryOutputs != nil {
+		closePrimaryOutputs()
+	}
+	closePrimaryOutputs = closeOutputs
+	setAuditFile(cfg.AuditFile, cfg.AuditRotation)
+	// reloading opens the files again too
+	setReopenOnSignal(cfg.ReopenOnSIGHUP && !cfg.ReloadOnSIGHUP)
+	setReloadOnSignal(cfg.ReloadOnSIGHUP)
+	setDebugOnSignal(cfg.DebugOnSIGUSR1)
+	debugSavedLevels = nil
 	setAllLoggerLevel(defaultLevel)
 
 	for name, level := range cfg.SubsystemLevels {
@@ -101,6 +366,293 @@ func SetupLogging(cfg Config) {
 			levels[name] = zap.NewAtomicLevelAt(zapcore.Level(level))
 		}
 	}
+	setSampling(cfg.SubsystemSampling)
+	setRateLimits(cfg.SubsystemRateLimits)
+	setDeduplicate(cfg.Deduplicate)
+	for name, output := range cfg.SubsystemOutputs {
+		if err := setSubsystemOutput(name, output); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set output of %s: %s\n", name, err)
+		}
+	}
+}
+
+// labelCore returns the core adding globalLabels and globalFields to the
+// entries, the labels being grouped in an object for Cloud Logging when gcp.
+func labelCore(core zapcore.Core, gcp bool) zapcore.Core {
+	if !gcp {
+		for k, v := range globalLabels {
+			core = core.With([]zap.Field{zap.String(k, v)})
+		}
+	}
+	if len(globalFields) > 0 {
+		core = core.With(globalFields)
+	}
+	if gcp && len(globalLabels) > 0 {
+		// Cloud Logging reads the labels of entries from a dedicated object
+		labels := make(gcpLabels, len(globalLabels))
+		for k, v := range globalLabels {
+			labels[k] = v
+		}
+		core = core.With([]zap.Field{zap.Object(gcpLabelsKey, labels)})
+	}
+	return core
+}
+
+// relabelCores replaces the cores set up by setupLogging with cores adding
+// the current globalLabels, keeping their outputs open.
+func relabelCores() {
+	if unlabeledPrimaryCore != nil {
+		setPrimaryCore(labelCore(unlabeledPrimaryCore, primaryFormat == FormatGCPOutput))
+	}
+	for i, core := range unlabeledSecondaryCores {
+		labeled := labelCore(core, false)
+		loggerCore.ReplaceCore(secondaryCores[i], labeled)
+		secondaryCores[i] = labeled
+	}
+}
+
+// processFields returns the fields of the process enabled by cfg.
+func processFields(cfg Config) []zap.Field {
+	var fields []zap.Field
+	if cfg.Hostname {
+		if hostname, err := os.Hostname(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to get hostname: %s\n", err)
+		} else {
+			fields = append(fields, zap.String("hostname", hostname))
+		}
+	}
+	if cfg.PID {
+		fields = append(fields, zap.Int("pid", os.Getpid()))
+	}
+	if cfg.Executable {
+		if exe, err := os.Executable(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to get executable: %s\n", err)
+		} else {
+			fields = append(fields, zap.String("executable", filepath.Base(exe)))
+		}
+	}
+	return fields
+}
+
+// urlCores are the URL schemes served by dedicated cores rather than by zap
+// sinks, because they need the entries themselves rather than their encoding.
+var urlCores = map[string]func(*url.URL, LogLevel) (zapcore.Core, error){
+	"fluent":      newFluentCore,
+	"logstash":    newLogstashCore,
+	"splunk":      newSplunkURLCore,
+	"splunk+http": newSplunkURLCore,
+}
+
+// coreURL parses rawURL if its scheme is served by a dedicated core.
+func coreURL(rawURL string) (*url.URL, func(*url.URL, LogLevel) (zapcore.Core, error), bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, false
+	}
+	newCore, ok := urlCores[u.Scheme]
+	return u, newCore, ok
+}
+
+// secondaryCoresFromConfig creates the cores for the outputs of cfg that are
+// not handled by the primary core. Like the primary core, they log everything.
+func secondaryCoresFromConfig(cfg Config) []zapcore.Core {
+	var cores []zapcore.Core
+
+	if core := setRecentEntries(cfg.RecentEntries, cfg.Format); core != nil {
+		cores = append(cores, core)
+	}
+	if cfg.Split {
+		cores = append(cores, newSplitCores(cfg.Format)...)
+	}
+	cores = append(cores, newLevelOutputCores(cfg.LevelOutputs, cfg.Format)...)
+
+	subsystems := make([]string, 0, len(cfg.SubsystemFiles))
+	for subsystem := range cfg.SubsystemFiles {
+		subsystems = append(subsystems, subsystem)
+	}
+	sort.Strings(subsystems)
+	for _, subsystem := range subsystems {
+		path := cfg.SubsystemFiles[subsystem]
+		if core, err := newSubsystemFileCore(subsystem, path, cfg.Format, cfg.SubsystemRotations[subsystem]); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up log file '%s' for %s: %s\n", path, subsystem, err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+
+	if cfg.Syslog != nil {
+		if core, err := newSyslogCore(*cfg.Syslog, cfg.Format, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up syslog output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.Journald {
+		cores = append(cores, newJournaldCore(journaldSocket, levelAll))
+	}
+	if cfg.Logcat {
+		if core, err := newLogcatCore(levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up logcat output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.Loki != nil {
+		if core, err := newLokiCore(*cfg.Loki, cfg.Labels, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up Loki output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.Elasticsearch != nil {
+		if core, err := newElasticsearchCore(*cfg.Elasticsearch, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up Elasticsearch output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if u, newURLCore, ok := coreURL(cfg.URL); ok {
+		if core, err := newURLCore(u, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up %s output: %s\n", u.Scheme, err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.Splunk != nil {
+		if core, err := newSplunkCore(*cfg.Splunk, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up Splunk output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.Kafka != nil {
+		if core, err := newKafkaCore(*cfg.Kafka, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up Kafka output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.CloudWatch != nil {
+		if core, err := newCloudWatchCore(*cfg.CloudWatch, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up CloudWatch output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.CloudLogging != nil {
+		if core, err := newCloudLoggingCore(*cfg.CloudLogging, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up Cloud Logging output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.AzureMonitor != nil {
+		if core, err := newAzureMonitorCore(*cfg.AzureMonitor, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up Azure Monitor output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.Datadog != nil {
+		if core, err := newDatadogCore(*cfg.Datadog, cfg.Labels, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up Datadog output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.OTLP != nil {
+		if core, err := newOTLPCore(*cfg.OTLP, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up OTLP output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.Sentry != nil {
+		if core, err := newSentryCore(*cfg.Sentry, cfg.Labels); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up Sentry reporting: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.PagerDuty != nil || cfg.Opsgenie != nil {
+		if core, err := newIncidentCore(cfg.PagerDuty, cfg.Opsgenie); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up incident reporting: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.Email != nil {
+		if core, err := newEmailCore(*cfg.Email, cfg.Format); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up email digests: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.Webhook != nil {
+		if core, err := newWebhookCore(*cfg.Webhook); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up webhook alerts: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.HTTP != nil {
+		if core, err := newHTTPCore(*cfg.HTTP, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up HTTP output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.NATS != nil {
+		if core, err := newNATSCore(*cfg.NATS, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up NATS output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.MQTT != nil {
+		if core, err := newMQTTCore(*cfg.MQTT, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up MQTT output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.ZeroMQ != nil {
+		if core, err := newZeroMQCore(*cfg.ZeroMQ, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up ZeroMQ output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.Redis != nil {
+		if core, err := newRedisCore(*cfg.Redis, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up Redis output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.SQLite != nil {
+		if core, err := newSQLiteCore(*cfg.SQLite, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up SQLite output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.ClickHouse != nil {
+		if core, err := newClickHouseCore(*cfg.ClickHouse, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up ClickHouse output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+	if cfg.S3 != nil {
+		if core, err := newS3Core(*cfg.S3, levelAll); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set up S3 output: %s\n", err)
+		} else {
+			cores = append(cores, core)
+		}
+	}
+
+	return cores
 }
 
 // configFromEnv returns a Config with defaults populated using environment variables.
@@ -110,27 +662,67 @@ func configFromEnv() Config {
 		Stderr:          true,
 		Level:           LevelError,
 		SubsystemLevels: map[string]LogLevel{},
+		SubsystemFiles:  map[string]string{},
 		Labels:          map[string]string{},
+		Logcat:          logcatAvailable, // stderr is discarded on Android
 	}
 
 	format := os.Getenv(envLoggingFmt)
 
 	var noExplicitFormat bool
 
-	switch format {
-	case "color":
-		cfg.Format = FormatColorizedOutput
-	case "nocolor":
-		cfg.Format = FormatPlaintextOutput
-	case "json":
-		cfg.Format = FormatJSONOutput
-	default:
+	if f, ok := formatFromString(format); ok {
+		cfg.Format = f
+	} else {
 		if format != "" {
-			fmt.Fprintf(os.Stderr, "ignoring unrecognized log format '%s'\n", format)
+			fmt.Fprintf(os.Stderr, "unrecognized log format '%s', using the default format until it is registered\n", format)
 		}
+		// the format may be registered by the application later
+		loggerMutex.Lock()
+		pendingFormatName = format
+		loggerMutex.Unlock()
 		noExplicitFormat = true
 	}
 
+	cfg.TimeFormat = os.Getenv(envLoggingTimeFormat)
+	if keys := os.Getenv(envLoggingJSONKeys); keys != "" {
+		cfg.JSONKeys = make(map[string]string)
+		for _, pair := range strings.Split(keys, ",") {
+			kv := strings.Split(pair, "=")
+			if len(kv) != 2 {
+				fmt.Fprintf(os.Stderr, "invalid renamed JSON key %q\n", pair)
+				continue
+			}
+			cfg.JSONKeys[kv[0]] = kv[1]
+		}
+	}
+
+	if colors := os.Getenv(envLoggingColors); colors != "" {
+		theme, err := parseColorTheme(colors)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring color theme: %s\n", err)
+		} else {
+			cfg.ColorTheme = theme
+		}
+	}
+
+	cfg.Multiline = os.Getenv(envLoggingMultiline)
+
+	if caller := os.Getenv(envLoggingCaller); caller != "" {
+		for _, kvs := range strings.Split(caller, ",") {
+			kv := strings.SplitN(kvs, "=", 2)
+			switch len(kv) {
+			case 1:
+				cfg.Caller = kv[0]
+			case 2:
+				if cfg.SubsystemCallers == nil {
+					cfg.SubsystemCallers = make(map[string]string)
+				}
+				cfg.SubsystemCallers[kv[0]] = kv[1]
+			}
+		}
+	}
+
 	lvl := os.Getenv(envLoggingLvl)
 	if lvl != "" {
 		for _, kvs := range strings.Split(lvl, ",") {
@@ -149,12 +741,118 @@ func configFromEnv() Config {
 		}
 	}
 
+	cfg.StacktraceLevel = os.Getenv(envLoggingStacktraceLevel)
+
 	cfg.File = os.Getenv(envLoggingFile)
+	cfg.AuditFile = os.Getenv(envLoggingAuditFile)
 	// Disable stderr logging when a file is specified
 	// https://github.com/ipfs/go-log/issues/83
 	if cfg.File != "" {
 		cfg.Stderr = false
 	}
+	if size := os.Getenv(envLoggingFileMaxSize); size != "" {
+		var err error
+		if cfg.Rotation.MaxSize, err = parseByteSize(size); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring invalid log file size: %s\n", err)
+		}
+	}
+	if interval := os.Getenv(envLoggingFileRotation); interval != "" {
+		var err error
+		if cfg.Rotation.Interval, err = parseRotationInterval(interval); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring %s\n", err)
+		}
+	}
+	if age := os.Getenv(envLoggingFileMaxAge); age != "" {
+		var err error
+		if cfg.Rotation.MaxAge, err = parseMaxAge(age); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring invalid log file age: %s\n", err)
+		}
+	}
+	if size := os.Getenv(envLoggingFileMaxTotalSize); size != "" {
+		var err error
+		if cfg.Rotation.MaxTotalSize, err = parseByteSize(size); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring invalid log files total size: %s\n", err)
+		}
+	}
+	if size := os.Getenv(envLoggingFileMinFreeSpace); size != "" {
+		var err error
+		if cfg.MinFreeDiskSpace, err = parseByteSize(size); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring invalid minimum free disk space: %s\n", err)
+		}
+	}
+	cfg.Rotation.NameTemplate = os.Getenv(envLoggingFileNameTemplate)
+	cfg.Rotation.TimeFormat = os.Getenv(envLoggingFileTimeFormat)
+	cfg.Rotation.Symlink = os.Getenv(envLoggingFileSymlink)
+	if compress := os.Getenv(envLoggingFileCompress); compress != "" {
+		var err error
+		if cfg.Rotation.Compress, err = strconv.ParseBool(compress); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring invalid %s value '%s'\n", envLoggingFileCompress, compress)
+		}
+	}
+	if n := os.Getenv(envLoggingFileMaxBackups); n != "" {
+		var err error
+		if cfg.Rotation.MaxBackups, err = strconv.Atoi(n); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring invalid number of log file backups '%s'\n", n)
+		}
+	}
+
+	if lock := os.Getenv(envLoggingFileLock); lock != "" {
+		var err error
+		if cfg.FileLock, err = strconv.ParseBool(lock); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring invalid %s value '%s'\n", envLoggingFileLock, lock)
+		}
+	}
+
+	if reopen := os.Getenv(envLoggingReopenOnSIGHUP); reopen != "" {
+		var err error
+		if cfg.ReopenOnSIGHUP, err = strconv.ParseBool(reopen); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring invalid %s value '%s'\n", envLoggingReopenOnSIGHUP, reopen)
+		}
+	}
+	if reload := os.Getenv(envLoggingReloadOnSIGHUP); reload != "" {
+		var err error
+		if cfg.ReloadOnSIGHUP, err = strconv.ParseBool(reload); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring invalid %s value '%s'\n", envLoggingReloadOnSIGHUP, reload)
+		}
+	}
+	if debug := os.Getenv(envLoggingDebugOnSIGUSR1); debug != "" {
+		var err error
+		if cfg.DebugOnSIGUSR1, err = strconv.ParseBool(debug); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring invalid %s value '%s'\n", envLoggingDebugOnSIGUSR1, debug)
+		}
+	}
+
+	if n := os.Getenv(envLoggingRecentEntries); n != "" {
+		var err error
+		if cfg.RecentEntries, err = strconv.Atoi(n); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring invalid number of recent entries '%s'\n", n)
+		}
+	}
+
+	if outputs := os.Getenv(envLoggingLevelOutputs); outputs != "" {
+		cfg.LevelOutputs = map[LogLevel][]string{}
+		for _, kvs := range strings.Split(outputs, ",") {
+			kv := strings.SplitN(kvs, "=", 2)
+			if len(kv) != 2 {
+				fmt.Fprintf(os.Stderr, "invalid level outputs %q\n", kvs)
+				continue
+			}
+			lvl, err := LevelFromString(kv[0])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error setting level outputs %q: %s\n", kvs, err)
+				continue
+			}
+			cfg.LevelOutputs[lvl] = strings.Split(kv[1], "+")
+		}
+	}
+
+	for _, kv := range os.Environ() {
+		kv := strings.SplitN(kv, "=", 2)
+		if len(kv) == 2 && kv[1] != "" && strings.HasPrefix(kv[0], envLoggingSubsystemFilePrefix) && !fileOptionEnvs[kv[0]] {
+			subsystem := strings.ToLower(strings.TrimPrefix(kv[0], envLoggingSubsystemFilePrefix))
+			cfg.SubsystemFiles[subsystem] = kv[1]
+		}
+	}
 
 	cfg.URL = os.Getenv(envLoggingURL)
 	output := os.Getenv(envLoggingOutput)
@@ -165,6 +863,8 @@ func configFromEnv() Config {
 			cfg.Stdout = true
 		case "stderr":
 			cfg.Stderr = true
+		case "split":
+			cfg.Split = true
 		case "file":
 			if cfg.File == "" {
 				fmt.Fprint(os.Stderr, "please specify a GOLOG_FILE value to write to")
@@ -173,14 +873,143 @@ func configFromEnv() Config {
 			if cfg.URL == "" {
 				fmt.Fprint(os.Stderr, "please specify a GOLOG_URL value to write to")
 			}
+		case "syslog":
+			cfg.Syslog = syslogConfigFromEnv()
+		case "journald":
+			cfg.Journald = true
+		case "logcat":
+			cfg.Logcat = true
+		case "loki":
+			cfg.Loki = lokiConfigFromEnv()
+		case "elasticsearch":
+			cfg.Elasticsearch = elasticsearchConfigFromEnv()
+		case "kafka":
+			cfg.Kafka = &KafkaConfig{
+				Topic:    os.Getenv(envLoggingKafkaTopic),
+				KeyField: os.Getenv(envLoggingKafkaKey),
+			}
+			if brokers := os.Getenv(envLoggingKafkaBrokers); brokers != "" {
+				cfg.Kafka.Brokers = strings.Split(brokers, ",")
+			}
+		case "cloudlogging":
+			cfg.CloudLogging = &CloudLoggingConfig{
+				LogName: os.Getenv(envLoggingCloudLoggingLogName),
+			}
+		case "azure":
+			cfg.AzureMonitor = &AzureMonitorConfig{
+				WorkspaceID: os.Getenv(envLoggingAzureWorkspaceID),
+				SharedKey:   os.Getenv(envLoggingAzureSharedKey),
+				LogType:     os.Getenv(envLoggingAzureLogType),
+			}
+		case "datadog":
+			cfg.Datadog = &DatadogConfig{}
+		case "otlp":
+			cfg.OTLP = &OTLPConfig{}
+		case "http":
+			cfg.HTTP = httpConfigFromEnv()
+		case "nats":
+			cfg.NATS = &NATSConfig{
+				URL:       os.Getenv(envLoggingNATSURL),
+				Subject:   os.Getenv(envLoggingNATSSubject),
+				JetStream: os.Getenv(envLoggingNATSJetStream) == "true",
+			}
+		case "mqtt":
+			cfg.MQTT = &MQTTConfig{
+				URL:   os.Getenv(envLoggingMQTTURL),
+				Topic: os.Getenv(envLoggingMQTTTopic),
+			}
+			if qos := os.Getenv(envLoggingMQTTQoS); qos != "" {
+				n, err := strconv.Atoi(qos)
+				if err != nil || n < 0 || n > 2 {
+					fmt.Fprintf(os.Stderr, "ignoring invalid MQTT QoS '%s'\n", qos)
+				} else {
+					cfg.MQTT.QoS = byte(n)
+				}
+			}
+		case "redis":
+			cfg.Redis = &RedisConfig{
+				URL:    os.Getenv(envLoggingRedisURL),
+				Stream: os.Getenv(envLoggingRedisStream),
+			}
+			if maxLen := os.Getenv(envLoggingRedisMaxLen); maxLen != "" {
+				n, err := strconv.ParseInt(maxLen, 10, 64)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "ignoring invalid Redis stream max length '%s'\n", maxLen)
+				} else {
+					cfg.Redis.MaxLen = n
+				}
+			}
+		case "zeromq":
+			cfg.ZeroMQ = &ZeroMQConfig{Endpoint: os.Getenv(envLoggingZeroMQEndpoint)}
+		case "sqlite":
+			cfg.SQLite = &SQLiteConfig{Path: os.Getenv(envLoggingSQLitePath)}
+		case "clickhouse":
+			cfg.ClickHouse = &ClickHouseConfig{
+				URL:   os.Getenv(envLoggingClickHouseURL),
+				Table: os.Getenv(envLoggingClickHouseTable),
+			}
+		case "s3":
+			cfg.S3 = &S3Config{
+				Bucket:   os.Getenv(envLoggingS3Bucket),
+				Prefix:   os.Getenv(envLoggingS3Prefix),
+				Endpoint: os.Getenv(envLoggingS3Endpoint),
+			}
+		case "cloudwatch":
+			cfg.CloudWatch = &CloudWatchConfig{
+				LogGroup:  os.Getenv(envLoggingCloudWatchGroup),
+				LogStream: os.Getenv(envLoggingCloudWatchStream),
+			}
+		}
+	}
+
+	// split replaces the stdout and stderr outputs
+	if cfg.Split {
+		cfg.Stderr = false
+		cfg.Stdout = false
+	}
+
+	if dsn := os.Getenv(envLoggingSentryDSN); dsn != "" {
+		cfg.Sentry = &SentryConfig{DSN: dsn}
+	}
+	if key := os.Getenv(envLoggingPagerDutyRoutingKey); key != "" {
+		cfg.PagerDuty = &PagerDutyConfig{RoutingKey: key}
+	}
+	if key := os.Getenv(envLoggingOpsgenieAPIKey); key != "" {
+		cfg.Opsgenie = &OpsgenieConfig{APIKey: key}
+	}
+	if addr := os.Getenv(envLoggingSMTPAddr); addr != "" {
+		cfg.Email = &EmailConfig{
+			Addr:     addr,
+			Username: os.Getenv(envLoggingSMTPUsername),
+			Password: os.Getenv(envLoggingSMTPPassword),
+			From:     os.Getenv(envLoggingSMTPFrom),
+		}
+		if to := os.Getenv(envLoggingSMTPTo); to != "" {
+			cfg.Email.To = strings.Split(to, ",")
+		}
+	}
+	if webhookURL := os.Getenv(envLoggingWebhookURL); webhookURL != "" {
+		cfg.Webhook = &WebhookConfig{
+			URL:    webhookURL,
+			Format: os.Getenv(envLoggingWebhookFormat),
 		}
 	}
 
-	// Check that neither of the requested Std* nor the file are TTYs
+	forceColor := os.Getenv(envForceColor) != ""
+	noColor := os.Getenv(envNoColor) != "" && !forceColor
+	if noColor && cfg.ColorTheme == nil {
+		cfg.ColorTheme = &ColorTheme{}
+	}
+
+	// Check that neither of the requested Std* nor the file are TTYs, unless
+	// colors are forced.
 	// At this stage (configFromEnv) we do not have a uniform list to examine yet
-	if noExplicitFormat &&
+	if noExplicitFormat && noColor {
+		cfg.Format = FormatPlaintextOutput
+	} else if noExplicitFormat && !forceColor &&
 		!(cfg.Stdout && isTerm(os.Stdout)) &&
 		!(cfg.Stderr && isTerm(os.Stderr)) &&
+		!(cfg.Split && (isTerm(os.Stdout) || isTerm(os.Stderr))) &&
 		// check this last: expensive
 		!(cfg.File != "" && pathIsTerm(cfg.File)) {
 		cfg.Format = FormatPlaintextOutput
@@ -199,9 +1028,162 @@ func configFromEnv() Config {
 		}
 	}
 
+	if fields := os.Getenv(envLoggingProcessFields); fields != "" {
+		for _, field := range strings.Split(fields, ",") {
+			switch field {
+			case "hostname":
+				cfg.Hostname = true
+			case "pid":
+				cfg.PID = true
+			case "executable":
+				cfg.Executable = true
+			default:
+				fmt.Fprintf(os.Stderr, "ignoring unknown process field '%s'\n", field)
+			}
+		}
+	}
+
+	if outputs := os.Getenv(envLoggingSubsystemOutputs); outputs != "" {
+		cfg.SubsystemOutputs = make(map[string]string)
+		for _, pair := range strings.Split(outputs, ",") {
+			kv := strings.Split(pair, "=")
+			if len(kv) != 2 {
+				fmt.Fprintf(os.Stderr, "invalid subsystem output %q\n", pair)
+				continue
+			}
+			cfg.SubsystemOutputs[kv[0]] = kv[1]
+		}
+	}
+	if sampling := os.Getenv(envLoggingSampling); sampling != "" {
+		cfg.SubsystemSampling = make(map[string]SamplingConfig)
+		for _, pair := range strings.Split(sampling, ",") {
+			kv := strings.Split(pair, "=")
+			if len(kv) != 2 {
+				fmt.Fprintf(os.Stderr, "invalid subsystem sampling %q\n", pair)
+				continue
+			}
+			s, err := parseSampling(kv[1])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid sampling of %s: %s\n", kv[0], err)
+				continue
+			}
+			cfg.SubsystemSampling[kv[0]] = s
+		}
+	}
+	if limits := os.Getenv(envLoggingRateLimits); limits != "" {
+		cfg.SubsystemRateLimits = make(map[string]RateLimitConfig)
+		for _, pair := range strings.Split(limits, ",") {
+			kv := strings.Split(pair, "=")
+			if len(kv) != 2 {
+				fmt.Fprintf(os.Stderr, "invalid subsystem rate limit %q\n", pair)
+				continue
+			}
+			l, err := parseRateLimit(kv[1])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid rate limit of %s: %s\n", kv[0], err)
+				continue
+			}
+			cfg.SubsystemRateLimits[kv[0]] = l
+		}
+	}
+	if window := os.Getenv(envLoggingDeduplicate); window != "" {
+		var err error
+		if cfg.Deduplicate, err = time.ParseDuration(window); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid deduplication window %q: %s\n", window, err)
+		}
+	}
+
+	return cfg
+}
+
+// syslogConfigFromEnv returns a SyslogConfig populated using environment variables.
+func syslogConfigFromEnv() *SyslogConfig {
+	cfg := &SyslogConfig{
+		Facility: os.Getenv(envLoggingSyslogFacility),
+		Tag:      os.Getenv(envLoggingSyslogTag),
+	}
+
+	if addr := os.Getenv(envLoggingSyslogAddr); addr != "" {
+		parts := strings.SplitN(addr, "://", 2)
+		if len(parts) != 2 {
+			fmt.Fprintf(os.Stderr, "invalid syslog address %q, logging to the local daemon\n", addr)
+		} else {
+			cfg.Network = parts[0]
+			cfg.Address = parts[1]
+		}
+	}
+
+	return cfg
+}
+
+// lokiConfigFromEnv returns a LokiConfig populated using environment variables.
+func lokiConfigFromEnv() *LokiConfig {
+	u, username, password, ok := credentialsURLFromEnv(envLoggingLokiURL)
+	if !ok {
+		return nil
+	}
+	return &LokiConfig{
+		URL:      u,
+		Username: username,
+		Password: password,
+	}
+}
+
+// elasticsearchConfigFromEnv returns an ElasticsearchConfig populated using
+// environment variables.
+func elasticsearchConfigFromEnv() *ElasticsearchConfig {
+	u, username, password, ok := credentialsURLFromEnv(envLoggingElasticsearchURL)
+	if !ok {
+		return nil
+	}
+	return &ElasticsearchConfig{
+		URL:      u,
+		Index:    os.Getenv(envLoggingElasticsearchIndex),
+		Username: username,
+		Password: password,
+	}
+}
+
+// httpConfigFromEnv returns an HTTPConfig populated using environment variables.
+func httpConfigFromEnv() *HTTPConfig {
+	cfg := &HTTPConfig{
+		URL:     os.Getenv(envLoggingHTTPURL),
+		Headers: map[string]string{},
+	}
+	if headers := os.Getenv(envLoggingHTTPHeaders); headers != "" {
+		for _, header := range strings.Split(headers, ",") {
+			kv := strings.SplitN(header, "=", 2)
+			if len(kv) != 2 {
+				fmt.Fprint(os.Stderr, "invalid header k=v: ", header)
+				continue
+			}
+			cfg.Headers[kv[0]] = kv[1]
+		}
+	}
 	return cfg
 }
 
+// credentialsURLFromEnv reads the URL in the environment variable env,
+// splitting off the credentials given as user info.
+func credentialsURLFromEnv(env string) (u, username, password string, ok bool) {
+	rawURL := os.Getenv(env)
+	if rawURL == "" {
+		fmt.Fprintf(os.Stderr, "please specify a %s value to write to", env)
+		return "", "", "", false
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid %s value %q: %s\n", env, rawURL, err)
+		return "", "", "", false
+	}
+	if parsed.User != nil {
+		username = parsed.User.Username()
+		password, _ = parsed.User.Password()
+		parsed.User = nil
+	}
+	return parsed.String(), username, password, true
+}
+
 func isTerm(f *os.File) bool {
 	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
 }
@@ -224,6 +1206,136 @@ func setPrimaryCore(core zapcore.Core) {
 	primaryCore = core
 }
 
+// setSecondaryCores replaces the secondary cores, closing the previous ones.
+func setSecondaryCores(cores []zapcore.Core) {
+	for _, core := range secondaryCores {
+		loggerCore.DeleteCore(core)
+		core.Sync() // nolint:errcheck
+		if closer, ok := core.(io.Closer); ok {
+			closer.Close() // nolint:errcheck
+		}
+	}
+	for _, core := range cores {
+		loggerCore.AddCore(core)
+	}
+	secondaryCores = cores
+}
+
+// closeOutputs flushes and closes the outputs set up by setupLogging until ctx
+// is done, replacing the primary core with a no-op core. The error is a
+// *DroppedEntriesError when ctx is done first.
+func closeOutputs(ctx context.Context) error {
+	for _, core := range secondaryCores {
+		loggerCore.DeleteCore(core)
+	}
+	var mu sync.Mutex // guards err and dropped
+	var wg sync.WaitGroup
+	var err error
+	var dropped *DroppedEntriesError
+	for _, core := range secondaryCores {
+		wg.Add(1)
+		go func(core zapcore.Core) {
+			defer wg.Done()
+			n, cerr := shutdownCore(ctx, core)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case cerr != nil && cerr == ctx.Err():
+				if dropped == nil {
+					dropped = &DroppedEntriesError{Err: cerr}
+				}
+				dropped.Dropped += n
+			default:
+				err = multierr.Append(err, cerr)
+			}
+		}(core)
+	}
+	wg.Wait()
+	secondaryCores = nil
+	unlabeledPrimaryCore = nil
+	unlabeledSecondaryCores = nil
+
+	err = multierr.Append(err, ignoreSyncErrors(primaryCore.Sync()))
+	setPrimaryCore(zapcore.NewNopCore())
+	if closePrimaryOutputs != nil {
+		// the outputs streaming entries in the background, like tcp://,
+		// flush them on close
+		closed := make(chan struct{})
+		go func(closeAll func()) {
+			closeAll()
+			close(closed)
+		}(closePrimaryOutputs)
+		select {
+		case <-closed:
+		case <-ctx.Done():
+			if dropped == nil {
+				dropped = &DroppedEntriesError{Err: ctx.Err()}
+			}
+		}
+		closePrimaryOutputs = nil
+	}
+	if dropped != nil {
+		err = multierr.Append(err, dropped)
+	}
+	if primaryFile != nil {
+		err = multierr.Append(err, primaryFile.Close())
+		primaryFile = nil
+	}
+	setAuditFile("", RotationConfig{})
+	removeTailCore()
+	return err
+}
+
+// shutdownCore flushes and closes core until ctx is done, returning ctx.Err()
+// and the number of entries dropped then if known.
+func shutdownCore(ctx context.Context, core zapcore.Core) (int, error) {
+	if s, ok := core.(interface {
+		shutdown(ctx context.Context) (int, error)
+	}); ok {
+		return s.shutdown(ctx)
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		err := core.Sync()
+		if closer, ok := core.(io.Closer); ok {
+			err = multierr.Append(err, closer.Close())
+		}
+		closed <- err
+	}()
+	select {
+	case err := <-closed:
+		return 0, err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// ignoreSyncErrors returns the errors of syncing the cores, but of the
+// outputs which cannot be synced, like stderr when it is a terminal or a
+// pipe.
+func ignoreSyncErrors(err error) error {
+	var errs error
+	for _, err := range multierr.Errors(err) {
+		if !isUnsyncableError(err) {
+			errs = multierr.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+// setStacktraceLevel sets the level from which stack traces are attached to a
+// Config.StacktraceLevel.
+func setStacktraceLevel(level string) {
+	if level == "" {
+		level = "never"
+	}
+	if err := SetStacktraceLevel(level); err != nil {
+		fmt.Fprintf(os.Stderr, "error setting stacktrace level %q: %s\n", level, err)
+	}
+}
+
 func setAllLoggerLevel(lvl LogLevel) {
 	for _, l := range levels {
 		l.SetLevel(zapcore.Level(lvl))
@@ -0,0 +1,144 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Sink is the interface of the outputs created by the factories given to
+// RegisterSink.
+type Sink = zap.Sink
+
+// sinkSchemes is the set of schemes registered by this package.
+var sinkSchemes = map[string]bool{"file": true}
+
+// RegisterSink registers a factory creating the outputs of the URLs with the
+// given scheme, so that they can be used as Config.URL or GOLOG_URL.
+//
+// The logging set up from the environment when this package is initialized
+// does not wait for the sinks of the application: when GOLOG_URL uses an
+// unknown scheme, the URL output is added once RegisterSink is called for its
+// scheme.
+func RegisterSink(scheme string, factory func(*url.URL) (Sink, error)) error {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+
+	if err := zap.RegisterSink(scheme, factory); err != nil {
+		return err
+	}
+	sinkSchemes[strings.ToLower(scheme)] = true
+
+	if pendingSinkConfig != nil && pendingSinkScheme == strings.ToLower(scheme) {
+		setupLogging(*pendingSinkConfig)
+	}
+	return nil
+}
+
+// unknownSinkScheme returns the scheme of rawURL if no sink was registered for
+// it by this package.
+func unknownSinkScheme(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || len(u.Scheme) == 1 {
+		// a single letter is a Windows drive
+		return "", false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return scheme, !sinkSchemes[scheme]
+}
+
+// registerSinks registers the URL schemes implemented by this package with
+// zap, so that they can be used as Config.URL.
+func registerSinks() {
+	sinks := map[string]func(*url.URL) (zap.Sink, error){
+		"tcp":      newTCPSink,
+		"udp":      newUDPSink,
+		"unix":     newUnixSink,
+		"unixgram": newUnixSink,
+	}
+	for scheme, factory := range sinks {
+		if err := zap.RegisterSink(scheme, factory); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to register %s sink: %s\n", scheme, err)
+		} else {
+			sinkSchemes[scheme] = true
+		}
+	}
+}
+
+// newUDPSink creates a sink sending every entry as a datagram. It is
+// configured by a URL of the form
+//
+//	udp://host:port?max_size=1400
+//
+// Entries longer than max_size bytes are truncated.
+func newUDPSink(u *url.URL) (zap.Sink, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("missing address in %s", u)
+	}
+	var maxSize int
+	if s := u.Query().Get("max_size"); s != "" {
+		var err error
+		if maxSize, err = strconv.Atoi(s); err != nil || maxSize <= 0 {
+			return nil, fmt.Errorf("invalid max_size %q", s)
+		}
+	}
+
+	conn, err := net.Dial("udp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+	return &datagramSink{conn: conn, maxSize: maxSize}, nil
+}
+
+// newUnixSink creates a sink writing to a unix domain socket, configured by a
+// URL of the form unix:///path/to/socket for stream sockets, or
+// unixgram:///path/to/socket for datagram sockets. Stream sockets reconnect
+// like tcp:// sinks, and accept the same buffer parameter.
+func newUnixSink(u *url.URL) (zap.Sink, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, fmt.Errorf("unix socket URL %s must not have a host", u)
+	}
+	if u.Path == "" {
+		return nil, fmt.Errorf("missing socket path in %s", u)
+	}
+	if u.Scheme == "unix" {
+		return newStreamSink("unix", u.Path, u.Query())
+	}
+
+	conn, err := net.Dial("unixgram", u.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &datagramSink{conn: conn}, nil
+}
+
+// datagramSink writes every entry as a datagram, truncated to maxSize bytes
+// when maxSize is positive.
+type datagramSink struct {
+	conn    net.Conn
+	maxSize int
+}
+
+func (s *datagramSink) Write(p []byte) (int, error) {
+	n := len(p)
+	if s.maxSize > 0 && n > s.maxSize {
+		p = p[:s.maxSize]
+	}
+	if _, err := s.conn.Write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *datagramSink) Sync() error {
+	return nil
+}
+
+func (s *datagramSink) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,174 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestUDPSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ws, closeSink, err := zap.Open("udp://" + conn.LocalAddr().String() + "?max_size=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeSink()
+
+	if _, err := ws.Write([]byte("scooby\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "scoob" {
+		t.Errorf("got %q, wanted the entry truncated to 5 bytes", got)
+	}
+}
+
+func TestTCPSinkReconnects(t *testing.T) {
+	defer func(backoff time.Duration) { retryBackoff = backoff }(retryBackoff)
+	retryBackoff = 10 * time.Millisecond
+
+	// reserve an address with nothing listening on it yet
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	ws, closeSink, err := zap.Open("tcp://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeSink()
+
+	// the entry is buffered until the collector comes up
+	if _, err := ws.Write([]byte("scooby\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "scooby\n" {
+		t.Errorf("got %q, wanted the buffered entry", got)
+	}
+}
+
+func TestUnixSink(t *testing.T) {
+	dir := t.TempDir()
+
+	stream, err := net.Listen("unix", filepath.Join(dir, "stream.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	dgram, err := net.ListenPacket("unixgram", filepath.Join(dir, "dgram.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dgram.Close()
+
+	ws, closeSink, err := zap.Open(
+		"unix://"+filepath.Join(dir, "stream.sock"),
+		"unixgram://"+filepath.Join(dir, "dgram.sock"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeSink()
+
+	if _, err := ws.Write([]byte("scooby\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1024)
+	dgram.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := dgram.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "scooby\n" {
+		t.Errorf("got datagram %q", got)
+	}
+
+	conn, err := stream.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err = conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "scooby\n" {
+		t.Errorf("got stream data %q", got)
+	}
+}
+
+type bufferSink struct {
+	bytes.Buffer
+}
+
+func (s *bufferSink) Sync() error  { return nil }
+func (s *bufferSink) Close() error { return nil }
+
+// testSinkSchemes makes the schemes registered by the tests unique, as a
+// scheme cannot be registered twice, i.e. with -count.
+var testSinkSchemes int32
+
+func TestRegisterSinkAfterSetup(t *testing.T) {
+	scheme := fmt.Sprintf("scooby%d", atomic.AddInt32(&testSinkSchemes, 1))
+	SetupLogging(Config{
+		Level:  LevelDebug,
+		Format: FormatPlaintextOutput,
+		URL:    scheme + "://doo",
+	})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	sink := &bufferSink{}
+	err := RegisterSink(scheme, func(*url.URL) (Sink, error) {
+		return sink, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getLogger("test").Info("where are you")
+	if !strings.Contains(sink.String(), "where are you") {
+		t.Errorf("got %q, wanted the entry written to the registered sink", sink.String())
+	}
+}
@@ -0,0 +1,233 @@
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SlogHandler returns a slog.Handler writing the records to the logger of
+// system, so that they are enabled by the level of system and written to the
+// outputs and in the format of the entries of this package.
+func SlogHandler(system string) slog.Handler {
+	return &slogHandler{
+		core: getLogger(system).Desugar().Core(),
+		name: system,
+	}
+}
+
+type slogHandler struct {
+	core zapcore.Core
+	name string
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.core.Enabled(zapLevelOfSlog(level))
+}
+
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	ent := zapcore.Entry{
+		LoggerName: h.name,
+		Time:       r.Time,
+		Level:      zapLevelOfSlog(r.Level),
+		Message:    r.Message,
+	}
+	if ent.Time.IsZero() {
+		ent.Time = time.Now()
+	}
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		ent.Caller = zapcore.EntryCaller{
+			Defined:  true,
+			PC:       frame.PC,
+			File:     frame.File,
+			Line:     frame.Line,
+			Function: frame.Function,
+		}
+	}
+	ce := h.core.Check(ent, nil)
+	if ce == nil {
+		return nil
+	}
+	fields := make([]zapcore.Field, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendSlogAttr(fields, a)
+		return true
+	})
+	ce.Write(fields...)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var fields []zapcore.Field
+	for _, a := range attrs {
+		fields = appendSlogAttr(fields, a)
+	}
+	return &slogHandler{core: h.core.With(fields), name: h.name}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{core: h.core.With([]zapcore.Field{zap.Namespace(name)}), name: h.name}
+}
+
+// zapLevelOfSlog returns the level of entries of a slog level, levels
+// between the slog levels being rounded down.
+func zapLevelOfSlog(level slog.Level) zapcore.Level {
+	switch {
+	case level < slog.LevelDebug:
+		return zapcore.Level(LevelTrace)
+	case level < slog.LevelInfo:
+		return zapcore.DebugLevel
+	case level < slog.LevelWarn:
+		return zapcore.InfoLevel
+	case level < slog.LevelError:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+// slogLevelOfZap returns the slog level of a level of entries, the levels
+// above Error being multiples of 4 above slog.LevelError.
+func slogLevelOfZap(level zapcore.Level) slog.Level {
+	return slog.Level(4 * int(level))
+}
+
+// appendSlogAttr appends the fields of a, which are none for an empty
+// attribute or group, and the fields of its attributes for a group without a
+// key.
+func appendSlogAttr(fields []zapcore.Field, a slog.Attr) []zapcore.Field {
+	a.Value = a.Value.Resolve()
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return fields
+		}
+		if a.Key == "" {
+			for _, attr := range attrs {
+				fields = appendSlogAttr(fields, attr)
+			}
+			return fields
+		}
+		return append(fields, zap.Object(a.Key, slogGroup(attrs)))
+	case slog.KindString:
+		return append(fields, zap.String(a.Key, a.Value.String()))
+	case slog.KindInt64:
+		return append(fields, zap.Int64(a.Key, a.Value.Int64()))
+	case slog.KindUint64:
+		return append(fields, zap.Uint64(a.Key, a.Value.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, zap.Float64(a.Key, a.Value.Float64()))
+	case slog.KindBool:
+		return append(fields, zap.Bool(a.Key, a.Value.Bool()))
+	case slog.KindDuration:
+		return append(fields, zap.Duration(a.Key, a.Value.Duration()))
+	case slog.KindTime:
+		return append(fields, zap.Time(a.Key, a.Value.Time()))
+	}
+	if a.Key == "" && a.Value.Any() == nil {
+		return fields
+	}
+	if err, ok := a.Value.Any().(error); ok {
+		return append(fields, zap.NamedError(a.Key, err))
+	}
+	return append(fields, zap.Any(a.Key, a.Value.Any()))
+}
+
+// slogGroup marshals the attributes of a group.
+type slogGroup []slog.Attr
+
+func (g slogGroup) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	var fields []zapcore.Field
+	for _, a := range g {
+		fields = appendSlogAttr(fields, a)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return nil
+}
+
+// ForwardSlog forwards the entries of all subsystems to handler, as records
+// with the "logger" attribute set to their subsystem. Like Subscribe, handler
+// receives everything enabled by SetLogLevel, and by handler itself.
+//
+// cancel stops the forwarding.
+func ForwardSlog(handler slog.Handler) (cancel func()) {
+	core := &slogCore{handler: handler}
+	loggerCore.AddCore(core)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			loggerCore.DeleteCore(core)
+		})
+	}
+}
+
+// slogCore writes entries to a slog.Handler.
+type slogCore struct {
+	handler slog.Handler
+	fields  []zapcore.Field
+}
+
+func (c *slogCore) Enabled(level zapcore.Level) bool {
+	return c.handler.Enabled(context.Background(), slogLevelOfZap(level))
+}
+
+func (c *slogCore) With(fields []zapcore.Field) zapcore.Core {
+	return &slogCore{
+		handler: c.handler,
+		fields:  append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+func (c *slogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *slogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	resolveLazyFields(enc.Fields)
+	keys := make([]string, 0, len(enc.Fields))
+	for key := range enc.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	r := slog.NewRecord(ent.Time, slogLevelOfZap(ent.Level), ent.Message, ent.Caller.PC)
+	r.AddAttrs(slog.String("logger", ent.LoggerName))
+	for _, key := range keys {
+		r.AddAttrs(slog.Any(key, enc.Fields[key]))
+	}
+	if ent.Stack != "" {
+		r.AddAttrs(slog.String("stacktrace", ent.Stack))
+	}
+	return c.handler.Handle(context.Background(), r)
+}
+
+func (c *slogCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,87 @@
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelWarn, Format: FormatJSONOutput, File: path})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	logger := slog.New(SlogHandler("slog")).With("peer", "Qm").WithGroup("req")
+	logger.Info("filtered")
+	logger.Warn("scooby", "id", 3, slog.Group("user", "name", "doo"), "err", errors.New("where are you"))
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d entries, wanted the warning only", len(lines))
+	}
+	var entry struct {
+		Level  string `json:"level"`
+		Logger string `json:"logger"`
+		Caller string `json:"caller"`
+		Msg    string `json:"msg"`
+		Peer   string `json:"peer"`
+		Req    struct {
+			ID   int               `json:"id"`
+			User map[string]string `json:"user"`
+			Err  string            `json:"err"`
+		} `json:"req"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Level != "warn" || entry.Logger != "slog" || entry.Msg != "scooby" || entry.Peer != "Qm" {
+		t.Errorf("got %s, wanted the record", lines[0])
+	}
+	if entry.Req.ID != 3 || entry.Req.User["name"] != "doo" || entry.Req.Err != "where are you" {
+		t.Errorf("got %s, wanted the attributes in the group", lines[0])
+	}
+	if !strings.Contains(entry.Caller, "slog_test.go") {
+		t.Errorf("got caller %s, wanted the test", entry.Caller)
+	}
+}
+
+func TestForwardSlog(t *testing.T) {
+	var buf bytes.Buffer
+	cancel := ForwardSlog(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	log := getLogger("forward")
+	SetLogLevel("forward", "debug")
+	defer SetLogLevel("forward", "error")
+	log.Debug("filtered")
+	log.With("peer", "Qm").Warnw("scooby", "id", 3)
+	cancel()
+	log.Warn("cancelled")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("got %q: %s", buf.String(), err)
+	}
+	for key, want := range map[string]interface{}{
+		"level":  "WARN",
+		"msg":    "scooby",
+		"logger": "forward",
+		"peer":   "Qm",
+		"id":     float64(3),
+	} {
+		if record[key] != want {
+			t.Errorf("got %s %v, wanted %v", key, record[key], want)
+		}
+	}
+}
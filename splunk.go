@@ -0,0 +1,172 @@
+package log
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SplunkConfig configures sending logs to a Splunk HTTP Event Collector.
+type SplunkConfig struct {
+	// URL is the base URL of the HTTP Event Collector, i.e.
+	// "https://splunk.example.com:8088".
+	URL string
+
+	// Token is the HTTP Event Collector token.
+	Token string
+
+	// Index, SourceType and Source set the corresponding metadata of every
+	// event when not empty. Otherwise, the defaults of the token are used.
+	Index      string
+	SourceType string
+	Source     string
+
+	// BatchSize is the maximum number of events sent in one request.
+	// Defaults to 100.
+	BatchSize int
+
+	// FlushInterval is the maximum time an event waits before being sent.
+	// Defaults to one second.
+	FlushInterval time.Duration
+
+	// MaxRetries is the number of times a request failing because of the
+	// network or an overloaded collector is retried. Defaults to 3.
+	MaxRetries int
+
+	// TLS configures HTTPS connections when not nil.
+	TLS *tls.Config
+}
+
+const (
+	splunkEventPath         = "/services/collector/event"
+	defaultSplunkMaxRetries = 3
+)
+
+// newSplunkCore creates a core sending gzipped batches of events to a Splunk
+// HTTP Event Collector.
+func newSplunkCore(cfg SplunkConfig, level LogLevel) (zapcore.Core, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("missing Splunk URL")
+	}
+	if cfg.Token == "" {
+		return nil, errors.New("missing Splunk token")
+	}
+	maxRetries := cfg.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultSplunkMaxRetries
+	}
+	host, _ := os.Hostname()
+
+	s := &splunkClient{
+		url:        strings.TrimSuffix(cfg.URL, "/") + splunkEventPath,
+		token:      cfg.Token,
+		host:       host,
+		index:      cfg.Index,
+		sourceType: cfg.SourceType,
+		source:     cfg.Source,
+		maxRetries: maxRetries,
+		client:     newHTTPClient(cfg.TLS),
+	}
+
+	// the time is part of the event metadata
+	encCfg := newEncoderConfig()
+	encCfg.TimeKey = ""
+
+	return &batchCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          zapcore.NewJSONEncoder(encCfg),
+		batcher:      newBatcher(cfg.BatchSize, cfg.FlushInterval, s.send),
+	}, nil
+}
+
+// newSplunkURLCore creates a Splunk core configured by a URL of the form
+//
+//	splunk://token@host:8088?index=main&sourcetype=app&source=myapp
+//
+// The splunk scheme connects with HTTPS, the splunk+http scheme without TLS.
+func newSplunkURLCore(u *url.URL, level LogLevel) (zapcore.Core, error) {
+	if u.User == nil {
+		return nil, errors.New("missing Splunk token")
+	}
+	scheme := "https"
+	if u.Scheme == "splunk+http" {
+		scheme = "http"
+	}
+	q := u.Query()
+	return newSplunkCore(SplunkConfig{
+		URL:        scheme + "://" + u.Host + u.Path,
+		Token:      u.User.Username(),
+		Index:      q.Get("index"),
+		SourceType: q.Get("sourcetype"),
+		Source:     q.Get("source"),
+	}, level)
+}
+
+type splunkClient struct {
+	url        string
+	token      string
+	host       string
+	index      string
+	sourceType string
+	source     string
+	maxRetries int
+	client     *http.Client
+}
+
+type splunkEvent struct {
+	Time       string          `json:"time"`
+	Host       string          `json:"host,omitempty"`
+	Index      string          `json:"index,omitempty"`
+	SourceType string          `json:"sourcetype,omitempty"`
+	Source     string          `json:"source,omitempty"`
+	Event      json.RawMessage `json:"event"`
+}
+
+func (s *splunkClient) send(batch []batchEntry) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, e := range batch {
+		err := enc.Encode(splunkEvent{
+			Time:       fmt.Sprintf("%d.%03d", e.ent.Time.Unix(), e.ent.Time.Nanosecond()/int(time.Millisecond)),
+			Host:       s.host,
+			Index:      s.index,
+			SourceType: s.sourceType,
+			Source:     s.source,
+			Event:      bytes.TrimRight(e.data, "\n"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	data, err := gzipBytes(body.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return retryWithBackoff(s.maxRetries, func() (bool, error) {
+		req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(data))
+		if err != nil {
+			return false, err
+		}
+		req.Header.Set("Authorization", "Splunk "+s.token)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return true, err
+		}
+		err = checkHTTPResponse(resp)
+		return isRetryableHTTPError(err), err
+	})
+}
@@ -0,0 +1,70 @@
+package log
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestSplunkCore(t *testing.T) {
+	defer func(d time.Duration) { retryBackoff = d }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	var attempts int
+	var got []splunkEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Splunk secret" {
+			t.Errorf("got authorization %q", auth)
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec := json.NewDecoder(zr)
+		for dec.More() {
+			var ev splunkEvent
+			if err := dec.Decode(&ev); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, ev)
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(strings.Replace(srv.URL, "http://", "splunk+http://secret@", 1) + "?index=main&sourcetype=app")
+	core, err := newSplunkURLCore(u, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*batchCore).Close()
+
+	zap.New(core).Error("scooby")
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts != 2 {
+		t.Errorf("got %d attempts, wanted the failed request to be retried", attempts)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d events, wanted 1", len(got))
+	}
+	if got[0].Index != "main" || got[0].SourceType != "app" {
+		t.Errorf("got index %q and sourcetype %q", got[0].Index, got[0].SourceType)
+	}
+	if !strings.Contains(string(got[0].Event), `"msg":"scooby"`) {
+		t.Errorf("got event %s, wanted it to contain the message", got[0].Event)
+	}
+}
@@ -0,0 +1,165 @@
+package log
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SQLiteConfig configures writing entries to a local SQLite database, in a
+// table with the columns id, time, level, subsystem, message and fields.
+//
+// The database is opened with database/sql, the application must import a
+// SQLite driver, i.e. github.com/mattn/go-sqlite3.
+type SQLiteConfig struct {
+	// Path is the path of the database file.
+	Path string
+
+	// Driver is the name of the database/sql driver. Defaults to "sqlite3".
+	Driver string
+
+	// Table is the name of the table. Defaults to "logs".
+	Table string
+
+	// MaxRows is the number of most recent entries kept, older entries being
+	// deleted. Defaults to 100000, negative to keep all the entries.
+	MaxRows int
+
+	// BatchSize is the maximum number of entries inserted in one transaction.
+	// Defaults to 100.
+	BatchSize int
+
+	// FlushInterval is the maximum time an entry waits before being inserted.
+	// Defaults to one second.
+	FlushInterval time.Duration
+}
+
+const (
+	defaultSQLiteDriver  = "sqlite3"
+	defaultSQLiteTable   = "logs"
+	defaultSQLiteMaxRows = 100000
+
+	// sqliteTimeLayout is understood by the SQLite date and time functions.
+	sqliteTimeLayout = "2006-01-02 15:04:05.000"
+)
+
+// newSQLiteCore creates a core inserting entries into a SQLite table, created
+// if needed. The fields of the entries are stored as a JSON object.
+func newSQLiteCore(cfg SQLiteConfig, level LogLevel) (zapcore.Core, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("missing SQLite database path")
+	}
+	driver := cfg.Driver
+	if driver == "" {
+		driver = defaultSQLiteDriver
+	}
+	table := cfg.Table
+	if table == "" {
+		table = defaultSQLiteTable
+	}
+	for _, c := range table {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return nil, fmt.Errorf("invalid SQLite table name %q", table)
+		}
+	}
+	maxRows := cfg.MaxRows
+	if maxRows == 0 {
+		maxRows = defaultSQLiteMaxRows
+	}
+
+	db, err := sql.Open(driver, cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS ` + table + ` (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			time TEXT NOT NULL,
+			level TEXT NOT NULL,
+			subsystem TEXT NOT NULL,
+			message TEXT NOT NULL,
+			fields TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + table + `_time ON ` + table + ` (time)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	s := &sqliteClient{
+		db:      db,
+		insert:  `INSERT INTO ` + table + ` (time, level, subsystem, message, fields) VALUES (?, ?, ?, ?, ?)`,
+		maxRows: maxRows,
+	}
+	if maxRows > 0 {
+		s.prune = `DELETE FROM ` + table + ` WHERE id <= (SELECT MAX(id) FROM ` + table + `) - ?`
+	}
+
+	return &batchCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          newFieldsEncoder(),
+		batcher:      newBatcher(cfg.BatchSize, cfg.FlushInterval, s.insertBatch),
+		close:        db.Close,
+	}, nil
+}
+
+// newFieldsEncoder returns a JSON encoder encoding entries to an object of
+// their fields only, for the outputs storing the entry metadata separately.
+func newFieldsEncoder() zapcore.Encoder {
+	return zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+	})
+}
+
+type sqliteClient struct {
+	db      *sql.DB
+	insert  string
+	prune   string
+	maxRows int
+}
+
+func (s *sqliteClient) insertBatch(batch []batchEntry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(s.insert)
+	if err != nil {
+		tx.Rollback() // nolint:errcheck
+		return err
+	}
+	defer stmt.Close()
+
+	for _, e := range batch {
+		_, err := stmt.Exec(
+			e.ent.Time.UTC().Format(sqliteTimeLayout),
+			levelName(e.ent.Level),
+			e.ent.LoggerName,
+			e.ent.Message,
+			string(bytes.TrimRight(e.data, "\n")),
+		)
+		if err != nil {
+			tx.Rollback() // nolint:errcheck
+			return err
+		}
+	}
+	if s.prune != "" {
+		if _, err := tx.Exec(s.prune, s.maxRows); err != nil {
+			tx.Rollback() // nolint:errcheck
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,106 @@
+package log
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// recordingDriver is a database/sql driver recording the statements executed.
+type recordingDriver struct {
+	mu    sync.Mutex
+	execs []recordedExec
+}
+
+type recordedExec struct {
+	query string
+	args  []driver.Value
+}
+
+func (d *recordingDriver) Open(string) (driver.Conn, error) {
+	return &recordingConn{d}, nil
+}
+
+type recordingConn struct {
+	d *recordingDriver
+}
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordingStmt{c.d, query}, nil
+}
+
+func (c *recordingConn) Close() error              { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *recordingConn) Commit() error             { return nil }
+func (c *recordingConn) Rollback() error           { return errors.New("unexpected rollback") }
+
+type recordingStmt struct {
+	d     *recordingDriver
+	query string
+}
+
+func (s *recordingStmt) Close() error  { return nil }
+func (s *recordingStmt) NumInput() int { return -1 }
+
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.execs = append(s.d.execs, recordedExec{s.query, args})
+	return driver.RowsAffected(1), nil
+}
+
+func (s *recordingStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("unexpected query")
+}
+
+// sqliteTestDriver is registered once, as sql.Register panics when a driver
+// is registered twice.
+var sqliteTestDriver = &recordingDriver{}
+
+func init() {
+	sql.Register("sqlite-test", sqliteTestDriver)
+}
+
+func TestSQLiteCore(t *testing.T) {
+	d := sqliteTestDriver
+	d.mu.Lock()
+	d.execs = nil
+	d.mu.Unlock()
+
+	core, err := newSQLiteCore(SQLiteConfig{
+		Path:    "logs.db",
+		Driver:  "sqlite-test",
+		MaxRows: 10,
+	}, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*batchCore).Close()
+
+	zap.New(core).Named("dht").Warn("scooby", zap.Int("count", 3))
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.execs) != 4 {
+		t.Fatalf("got %d statements, wanted table and index creation, insertion and pruning", len(d.execs))
+	}
+	insert := d.execs[2]
+	if !strings.HasPrefix(insert.query, "INSERT INTO logs ") {
+		t.Errorf("got query %q", insert.query)
+	}
+	if insert.args[1] != "warn" || insert.args[2] != "dht" || insert.args[3] != "scooby" || insert.args[4] != `{"count":3}` {
+		t.Errorf("got values %v", insert.args)
+	}
+	prune := d.execs[3]
+	if !strings.HasPrefix(prune.query, "DELETE FROM logs ") || prune.args[0] != int64(10) {
+		t.Errorf("got pruning %q with %v", prune.query, prune.args)
+	}
+}
@@ -0,0 +1,24 @@
+package log
+
+import (
+	"fmt"
+	stdlog "log"
+	"os"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// StdLogger returns a standard library logger writing its lines as entries of
+// the logger of system at level, for libraries only accepting one, i.e. as
+// the ErrorLog of a net/http.Server. Like with the other loggers, lines at
+// LevelPanic and LevelFatal panic and exit.
+func StdLogger(system string, level LogLevel) *stdlog.Logger {
+	logger := getLogger(system).Desugar()
+	std, err := zap.NewStdLogAt(logger, zapcore.Level(level))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unknown level of standard logger %s, using info: %s\n", system, err)
+		return zap.NewStdLog(logger)
+	}
+	return std
+}
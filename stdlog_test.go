@@ -0,0 +1,33 @@
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStdLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelWarn, Format: FormatJSONOutput, File: path})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	StdLogger("std", LevelInfo).Print("filtered")
+	StdLogger("std", LevelWarn).Printf("http: TLS handshake error from %s", "127.0.0.1")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "warn" || entry["logger"] != "std" || entry["msg"] != "http: TLS handshake error from 127.0.0.1" {
+		t.Errorf("got %s, wanted the warning only", data)
+	}
+	if caller, _ := entry["caller"].(string); !strings.Contains(caller, "stdlog_test.go") {
+		t.Errorf("got caller %s, wanted the test", caller)
+	}
+}
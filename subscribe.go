@@ -0,0 +1,138 @@
+package log
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// subscriptionBuffer is the number of entries buffered for a subscriber
+// before newer entries are dropped.
+const subscriptionBuffer = 256
+
+// Entry is a log entry delivered to subscribers.
+type Entry struct {
+	Time      time.Time
+	Level     LogLevel
+	Subsystem string
+	Message   string
+	Caller    string
+
+	// Fields are the structured fields of the entry, decoded like by the
+	// JSON output.
+	Fields map[string]interface{}
+}
+
+// Subscribe returns a channel receiving the entries matching filter, or all
+// of them when filter is nil. Like PipeReader, the subscription receives
+// everything enabled by SetLogLevel. Entries are dropped rather than blocking
+// the loggers when the channel is full.
+//
+// cancel stops the subscription and closes the channel.
+func Subscribe(filter func(Entry) bool) (<-chan Entry, func()) {
+	s := &subscription{
+		filter:  filter,
+		entries: make(chan Entry, subscriptionBuffer),
+	}
+	core := &subscriptionCore{sub: s}
+	loggerCore.AddCore(core)
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			loggerCore.DeleteCore(core)
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.closed = true
+			close(s.entries)
+		})
+	}
+	return s.entries, cancel
+}
+
+type subscription struct {
+	filter func(Entry) bool
+
+	mu      sync.Mutex // guards closing entries
+	closed  bool
+	entries chan Entry
+}
+
+func (s *subscription) publish(e Entry) {
+	if s.filter != nil && !s.filter(e) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.entries <- e:
+	default:
+	}
+}
+
+// entrySink receives the entries decoded by a subscriptionCore.
+type entrySink interface {
+	publish(e Entry)
+}
+
+// subscriptionCore decodes the entries for a subscription or a capture.
+type subscriptionCore struct {
+	sub    entrySink
+	fields []zapcore.Field
+}
+
+func (c *subscriptionCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c *subscriptionCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+	return &clone
+}
+
+func (c *subscriptionCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+
+func (c *subscriptionCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.sub.publish(entryOf(ent, c.fields, fields))
+	return nil
+}
+
+// entryOf returns the Entry of ent with the fields of the logger and of the
+// entry.
+func entryOf(ent zapcore.Entry, loggerFields, fields []zapcore.Field) Entry {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range loggerFields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	resolveLazyFields(enc.Fields)
+
+	e := Entry{
+		Time:      ent.Time,
+		Level:     LogLevel(ent.Level),
+		Subsystem: ent.LoggerName,
+		Message:   ent.Message,
+		Fields:    enc.Fields,
+	}
+	if ent.Caller.Defined {
+		e.Caller = ent.Caller.TrimmedPath()
+	}
+	return e
+}
+
+func (c *subscriptionCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,34 @@
+package log
+
+import (
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSubscribe(t *testing.T) {
+	log := getLogger("subscribe")
+	SetLogLevel("subscribe", "debug")
+	defer SetLogLevel("subscribe", "error")
+
+	entries, cancel := Subscribe(func(e Entry) bool {
+		return e.Subsystem == "subscribe" && e.Level >= LevelInfo
+	})
+	defer cancel()
+
+	log.Debug("filtered")
+	log.With("user", "scooby").Infow("where are you", "count", 3)
+
+	e := <-entries
+	if e.Message != "where are you" || e.Level != LevelInfo {
+		t.Errorf("got %q at %s, wanted the info entry", e.Message, zapcore.Level(e.Level))
+	}
+	if e.Fields["user"] != "scooby" || e.Fields["count"] != int64(3) {
+		t.Errorf("got fields %v", e.Fields)
+	}
+
+	cancel()
+	if _, ok := <-entries; ok {
+		t.Error("wanted the channel closed by cancel")
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !plan9
+// +build !plan9
+
+package log
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isUnsyncableError reports whether err is the error of syncing an output
+// which cannot be synced, like a terminal or a pipe.
+func isUnsyncableError(err error) bool {
+	return errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTTY)
+}
@@ -0,0 +1,10 @@
+//go:build plan9
+// +build plan9
+
+package log
+
+// isUnsyncableError reports whether err is the error of syncing an output
+// which cannot be synced, none on this platform.
+func isUnsyncableError(err error) bool {
+	return false
+}
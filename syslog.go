@@ -0,0 +1,298 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SyslogConfig configures logging to a syslog daemon.
+type SyslogConfig struct {
+	// Network is the network used to reach a remote syslog server: "udp" or
+	// "tcp". When empty, messages are sent to the local syslog daemon.
+	Network string
+
+	// Address is the address of the remote syslog server, i.e. "localhost:514".
+	Address string
+
+	// Facility is the syslog facility name, i.e. "daemon" or "local0".
+	// Defaults to "user".
+	Facility string
+
+	// Tag identifies the program in every message. Defaults to the program name.
+	Tag string
+}
+
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// syslogSeverity maps a log level to a syslog severity.
+func syslogSeverity(lvl zapcore.Level) int {
+	switch {
+	case lvl <= zapcore.DebugLevel:
+		return 7
+	case lvl == zapcore.InfoLevel:
+		return 6
+	case lvl == zapcore.WarnLevel:
+		return 4
+	case lvl == zapcore.ErrorLevel:
+		return 3
+	case lvl == zapcore.DPanicLevel:
+		return 2
+	case lvl == zapcore.PanicLevel:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// newSyslogCore creates a core writing every entry as a syslog message.
+// Messages to a remote server follow RFC5424, messages to the local daemon
+// use the traditional BSD format understood by all local daemons.
+func newSyslogCore(cfg SyslogConfig, format LogFormat, level LogLevel) (zapcore.Core, error) {
+	facility := syslogFacilities["user"]
+	if cfg.Facility != "" {
+		f, ok := syslogFacilities[strings.ToLower(cfg.Facility)]
+		if !ok {
+			return nil, fmt.Errorf("unknown syslog facility %q", cfg.Facility)
+		}
+		facility = f
+	}
+
+	switch cfg.Network {
+	case "", "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network %q", cfg.Network)
+	}
+	if cfg.Network != "" && cfg.Address == "" {
+		return nil, errors.New("missing syslog address")
+	}
+
+	tag := cfg.Tag
+	if tag == "" {
+		tag = filepath.Base(os.Args[0])
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	// time and level are carried by the syslog header
+	encCfg := newEncoderConfig()
+	encCfg.TimeKey = ""
+	encCfg.LevelKey = ""
+
+	var encoder zapcore.Encoder
+	if format == FormatJSONOutput {
+		encoder = zapcore.NewJSONEncoder(encCfg)
+	} else {
+		encoder = zapcore.NewConsoleEncoder(encCfg)
+	}
+
+	w := &syslogWriter{network: cfg.Network, address: cfg.Address}
+	if strings.HasPrefix(cfg.Network, "tcp") {
+		// remote stream servers are written in the background, so that an
+		// unreachable or stuck server doesn't block the loggers
+		if w.sink, err = newStreamSink(cfg.Network, cfg.Address, url.Values{}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &syslogCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          encoder,
+		w:            w,
+		facility:     facility,
+		tag:          tag,
+		hostname:     hostname,
+		pid:          os.Getpid(),
+	}, nil
+}
+
+type syslogCore struct {
+	zapcore.LevelEnabler
+	enc      zapcore.Encoder
+	w        *syslogWriter
+	facility int
+	tag      string
+	hostname string
+	pid      int
+}
+
+func (c *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.enc = c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(clone.enc)
+	}
+	return &clone
+}
+
+func (c *syslogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *syslogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	msg := bytes.TrimRight(buf.Bytes(), "\n")
+	pri := c.facility*8 + syslogSeverity(ent.Level)
+
+	var out []byte
+	if c.w.network == "" {
+		out = []byte(fmt.Sprintf("<%d>%s %s[%d]: %s",
+			pri, ent.Time.Format(time.Stamp), c.tag, c.pid, msg))
+	} else {
+		header := rfc5424Header(pri, ent.Time, c.hostname, c.tag, c.pid, ent.LoggerName)
+		out = []byte(fmt.Sprintf("%s - %s", header, msg))
+	}
+	return c.w.writeMessage(out)
+}
+
+func (c *syslogCore) Sync() error {
+	if c.w.sink != nil {
+		return c.w.sink.Sync()
+	}
+	return nil
+}
+
+func (c *syslogCore) Close() error {
+	return c.w.Close()
+}
+
+// syslogWriter sends messages to a syslog daemon, (re)connecting lazily so
+// that logging does not fail when the daemon becomes available later.
+type syslogWriter struct {
+	mu      sync.Mutex
+	network string
+	address string
+	conn    net.Conn
+	stream  bool
+
+	// sink writes the messages to a remote stream server, when set.
+	sink zap.Sink
+}
+
+// syslogTimeout bounds connecting and writing to the daemon or the remote
+// datagram server.
+const syslogTimeout = time.Second
+
+func (w *syslogWriter) connect() error {
+	if w.network != "" {
+		conn, err := net.DialTimeout(w.network, w.address, syslogTimeout)
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+		w.stream = strings.HasPrefix(w.network, "tcp")
+		return nil
+	}
+
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+			conn, err := net.DialTimeout(network, path, syslogTimeout)
+			if err == nil {
+				w.conn = conn
+				w.stream = network == "unix"
+				return nil
+			}
+		}
+	}
+	return errors.New("unix syslog delivery error")
+}
+
+func (w *syslogWriter) writeMessage(msg []byte) error {
+	if w.sink != nil {
+		// RFC6587 octet counting
+		_, err := w.sink.Write(append([]byte(strconv.Itoa(len(msg))+" "), msg...))
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil {
+			if err = w.connect(); err != nil {
+				return err
+			}
+		}
+		if err = w.write(msg); err == nil {
+			return nil
+		}
+		w.conn.Close() // nolint:errcheck
+		w.conn = nil
+	}
+	return err
+}
+
+func (w *syslogWriter) write(msg []byte) error {
+	w.conn.SetWriteDeadline(time.Now().Add(syslogTimeout)) // nolint:errcheck
+	var err error
+	switch {
+	case !w.stream:
+		_, err = w.conn.Write(msg)
+	case w.network == "":
+		// local stream sockets are newline delimited
+		_, err = w.conn.Write(append(msg, '\n'))
+	default:
+		// remote stream servers are written by the sink
+		err = errors.New("unexpected remote stream syslog connection")
+	}
+	return err
+}
+
+func (w *syslogWriter) Close() error {
+	if w.sink != nil {
+		return w.sink.Close()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
@@ -0,0 +1,91 @@
+package log
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestSyslogCoreUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	core, err := newSyslogCore(SyslogConfig{
+		Network:  "udp",
+		Address:  conn.LocalAddr().String(),
+		Facility: "local0",
+		Tag:      "myapp",
+	}, FormatPlaintextOutput, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*syslogCore).Close()
+
+	zap.New(core).Named("test").Error("scooby")
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+
+	// local0 (16) * 8 + err (3)
+	if !strings.HasPrefix(msg, "<131>1 ") {
+		t.Errorf("got %q, wanted RFC5424 header with priority 131", msg)
+	}
+	if !strings.Contains(msg, " myapp ") || !strings.Contains(msg, " test - ") {
+		t.Errorf("got %q, wanted it to contain the tag and subsystem", msg)
+	}
+	if !strings.HasSuffix(msg, "scooby") {
+		t.Errorf("got %q, wanted it to end with the log message", msg)
+	}
+}
+
+func TestSyslogCoreFacility(t *testing.T) {
+	if _, err := newSyslogCore(SyslogConfig{Facility: "nope"}, FormatPlaintextOutput, LevelDebug); err == nil {
+		t.Error("expected an error for an unknown facility")
+	}
+}
+
+func TestSyslogCoreTCPNotAccepting(t *testing.T) {
+	// the connections are queued by the kernel but never accepted nor read
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	core, err := newSyslogCore(SyslogConfig{
+		Network: "tcp",
+		Address: l.Addr().String(),
+	}, FormatPlaintextOutput, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*syslogCore).Close()
+	// closing the listener resets the connection, for Close not to wait
+	defer l.Close()
+
+	// more than the socket buffers hold
+	logger := zap.New(core)
+	msg := strings.Repeat("scooby", 10000)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 500; i++ {
+			logger.Error(msg)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the server not to block the loggers")
+	}
+}
@@ -0,0 +1,268 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// tailHistorySize is the number of recent entries replayed to new tail
+	// connections.
+	tailHistorySize = 1000
+
+	// tailQueueSize is the number of entries queued for a slow tail
+	// connection before newer entries are dropped.
+	tailQueueSize = 256
+)
+
+var (
+	// tail and tailCoreAdded are guarded by loggerMutex
+	tail          *tailHub
+	tailCoreAdded *tailCore
+)
+
+// ServeTail serves the recent and live log entries over WebSocket on addr,
+// as JSON, one entry per message. It blocks like http.ListenAndServe.
+//
+// See TailHandler for the supported filters.
+func ServeTail(addr string) error {
+	return http.ListenAndServe(addr, TailHandler())
+}
+
+// TailHandler returns a handler streaming the recent and live log entries
+// over WebSocket, as JSON, one entry per message. Each connection can filter
+// the entries with query parameters:
+//
+//   - level: the minimum level, i.e. "warn".
+//   - subsystem: a comma separated list of subsystems.
+//   - recent: the maximum number of recent entries to replay, 0 to only
+//     stream live entries.
+//
+// Like PipeReader, the tail receives everything enabled by SetLogLevel. Live
+// entries are dropped rather than blocking the loggers when a connection
+// cannot keep up. The entries are collected until Close or Shutdown, and
+// again once TailHandler is called after them.
+//
+// Connections from web pages of another origin than the handler are refused,
+// so that a page opened by an operator cannot read the logs.
+func TailHandler() http.Handler {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+
+	if tail == nil {
+		tail = &tailHub{subscribers: make(map[*tailSubscriber]struct{})}
+	}
+	if tailCoreAdded == nil {
+		encCfg := newEncoderConfig()
+		encCfg.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+		tailCoreAdded = &tailCore{
+			LevelEnabler: zapcore.Level(levelAll),
+			enc:          zapcore.NewJSONEncoder(encCfg),
+			hub:          tail,
+		}
+		loggerCore.AddCore(tailCoreAdded)
+	}
+	return websocket.Server{Handler: tail.serve, Handshake: checkTailOrigin}
+}
+
+// checkTailOrigin refuses the connections with an Origin other than the host
+// of the request. Clients other than browsers may not send an Origin.
+func checkTailOrigin(config *websocket.Config, req *http.Request) error {
+	origin, err := websocket.Origin(config, req)
+	if err != nil {
+		return err
+	}
+	if origin != nil && !strings.EqualFold(origin.Host, req.Host) {
+		return fmt.Errorf("cross-origin tail connection from %s", origin)
+	}
+	config.Origin = origin
+	return nil
+}
+
+// removeTailCore stops collecting the entries for the tail.
+func removeTailCore() {
+	if tailCoreAdded != nil {
+		loggerCore.DeleteCore(tailCoreAdded)
+		tailCoreAdded = nil
+	}
+}
+
+type tailEntry struct {
+	level     zapcore.Level
+	subsystem string
+	data      []byte
+}
+
+type tailSubscriber struct {
+	level      zapcore.Level
+	subsystems map[string]bool
+	entries    chan []byte
+}
+
+func (s *tailSubscriber) match(e tailEntry) bool {
+	return e.level >= s.level && (s.subsystems == nil || s.subsystems[e.subsystem])
+}
+
+// tailHub keeps the recent entries and the connected subscribers.
+type tailHub struct {
+	mu          sync.Mutex
+	history     []tailEntry
+	next        int
+	subscribers map[*tailSubscriber]struct{}
+}
+
+func (h *tailHub) publish(e tailEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.history) < tailHistorySize {
+		h.history = append(h.history, e)
+	} else {
+		h.history[h.next] = e
+		h.next = (h.next + 1) % tailHistorySize
+	}
+	for s := range h.subscribers {
+		if s.match(e) {
+			select {
+			case s.entries <- e.data:
+			default:
+			}
+		}
+	}
+}
+
+// subscribe registers s and returns the last recent entries it matches.
+func (h *tailHub) subscribe(s *tailSubscriber, recent int) [][]byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var replay [][]byte
+	for i := len(h.history) - 1; i >= 0 && len(replay) < recent; i-- {
+		e := h.history[(h.next+i)%len(h.history)]
+		if s.match(e) {
+			replay = append(replay, e.data)
+		}
+	}
+	for i, j := 0, len(replay)-1; i < j; i, j = i+1, j-1 {
+		replay[i], replay[j] = replay[j], replay[i]
+	}
+	h.subscribers[s] = struct{}{}
+	return replay
+}
+
+func (h *tailHub) unsubscribe(s *tailSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers, s)
+}
+
+func (h *tailHub) serve(ws *websocket.Conn) {
+	defer ws.Close()
+
+	query := ws.Request().URL.Query()
+	s := &tailSubscriber{
+		level:   zapcore.Level(levelAll),
+		entries: make(chan []byte, tailQueueSize),
+	}
+	if l := query.Get("level"); l != "" {
+		lvl, err := LevelFromString(l)
+		if err != nil {
+			websocket.Message.Send(ws, "invalid level: "+err.Error()) // nolint:errcheck
+			return
+		}
+		s.level = zapcore.Level(lvl)
+	}
+	if subsystems := query.Get("subsystem"); subsystems != "" {
+		s.subsystems = make(map[string]bool)
+		for _, name := range strings.Split(subsystems, ",") {
+			s.subsystems[strings.TrimSpace(name)] = true
+		}
+	}
+	recent := tailHistorySize
+	if r := query.Get("recent"); r != "" {
+		n, err := strconv.Atoi(r)
+		if err != nil || n < 0 {
+			websocket.Message.Send(ws, "invalid recent: "+r) // nolint:errcheck
+			return
+		}
+		recent = n
+	}
+
+	replay := h.subscribe(s, recent)
+	defer h.unsubscribe(s)
+
+	// the client is not expected to send anything, reading only detects when
+	// it goes away
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(ioutil.Discard, ws) // nolint:errcheck
+		close(closed)
+	}()
+
+	for _, data := range replay {
+		if err := websocket.Message.Send(ws, string(data)); err != nil {
+			return
+		}
+	}
+	for {
+		select {
+		case data := <-s.entries:
+			if err := websocket.Message.Send(ws, string(data)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// tailCore publishes the entries to the tail hub.
+type tailCore struct {
+	zapcore.LevelEnabler
+	enc zapcore.Encoder
+	hub *tailHub
+}
+
+func (c *tailCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.enc = c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(clone.enc)
+	}
+	return &clone
+}
+
+func (c *tailCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *tailCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	data := bytes.TrimRight(buf.Bytes(), "\n")
+	c.hub.publish(tailEntry{
+		level:     ent.Level,
+		subsystem: ent.LoggerName,
+		data:      append([]byte(nil), data...),
+	})
+	buf.Free()
+	return nil
+}
+
+func (c *tailCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,67 @@
+package log
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestTail(t *testing.T) {
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+	// stops collecting the entries for the tail
+	defer Close() // nolint:errcheck
+
+	srv := httptest.NewServer(TailHandler())
+	defer srv.Close()
+
+	logger := Logger("tail")
+	Logger("other")
+	SetLogLevel("tail", "debug")
+	SetLogLevel("other", "debug")
+	logger.Info("scooby")
+	logger.Warn("doo")
+	Logger("other").Warn("ignored")
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/?level=warn&subsystem=tail"
+	ws, err := websocket.Dial(url, "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var msg string
+	if err := websocket.Message.Receive(ws, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(msg, `"msg":"doo"`) {
+		t.Errorf("got %s, wanted the recent warning", msg)
+	}
+
+	// the connection is subscribed to live entries before the replay is sent
+	logger.Info("filtered")
+	logger.Error("where are you")
+	if err := websocket.Message.Receive(ws, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(msg, `"msg":"where are you"`) || !strings.Contains(msg, `"logger":"tail"`) {
+		t.Errorf("got %s, wanted the live error", msg)
+	}
+}
+
+func TestTailCrossOrigin(t *testing.T) {
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+	defer Close() // nolint:errcheck
+
+	srv := httptest.NewServer(TailHandler())
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/"
+	if ws, err := websocket.Dial(url, "", "http://example.com"); err == nil {
+		ws.Close()
+		t.Error("expected the cross-origin connection to be refused")
+	}
+}
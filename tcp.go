@@ -0,0 +1,202 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultTCPBufferSize = 1000
+	maxTCPBackoff        = 30 * time.Second
+)
+
+var errTCPSinkTimeout = errors.New("timed out flushing tcp sink")
+
+// newTCPSink creates a sink streaming entries over a persistent TCP
+// connection. It is configured by a URL of the form
+//
+//	tcp://host:port?buffer=1000
+//
+// Entries are written in the background: while the collector is unreachable,
+// the sink reconnects with backoff and keeps up to buffer entries, dropping
+// newer ones rather than blocking the application.
+func newTCPSink(u *url.URL) (zap.Sink, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("missing address in %s", u)
+	}
+	return newStreamSink("tcp", u.Host, u.Query())
+}
+
+// newStreamSink creates a tcpSink connecting to addr on a stream network.
+func newStreamSink(network, addr string, query url.Values) (zap.Sink, error) {
+	size := defaultTCPBufferSize
+	if s := query.Get("buffer"); s != "" {
+		var err error
+		if size, err = strconv.Atoi(s); err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid buffer %q", s)
+		}
+	}
+
+	s := &tcpSink{
+		network: network,
+		addr:    addr,
+		queue:   make(chan tcpItem, size),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go s.loop()
+	return s, nil
+}
+
+// tcpItem is either an entry to write, or a marker closed once every entry
+// queued before it has been written.
+type tcpItem struct {
+	data   []byte
+	synced chan struct{}
+}
+
+type tcpSink struct {
+	network string
+	addr    string
+	queue   chan tcpItem
+	done    chan struct{}
+	stopped chan struct{}
+	dropped uint64
+}
+
+func (s *tcpSink) Write(p []byte) (int, error) {
+	select {
+	case s.queue <- tcpItem{data: append([]byte(nil), p...)}:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// Sync waits until the entries written so far have been sent.
+func (s *tcpSink) Sync() error {
+	synced := make(chan struct{})
+	timeout := time.NewTimer(httpTimeout)
+	defer timeout.Stop()
+
+	select {
+	case s.queue <- tcpItem{synced: synced}:
+	case <-s.stopped:
+		return nil
+	case <-timeout.C:
+		return errTCPSinkTimeout
+	}
+	select {
+	case <-synced:
+		return nil
+	case <-s.stopped:
+		return nil
+	case <-timeout.C:
+		return errTCPSinkTimeout
+	}
+}
+
+// Close makes a last attempt at sending the queued entries, for up to
+// httpTimeout, and closes the connection.
+func (s *tcpSink) Close() error {
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+	<-s.stopped
+	return nil
+}
+
+func (s *tcpSink) loop() {
+	defer close(s.stopped)
+
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for {
+		var item tcpItem
+		select {
+		case item = <-s.queue:
+		case <-s.done:
+			s.drain(conn)
+			return
+		}
+		if item.synced != nil {
+			close(item.synced)
+			continue
+		}
+
+		delay := retryBackoff
+		for {
+			var err error
+			if conn == nil {
+				conn, err = s.dial()
+			}
+			if err == nil {
+				conn.SetWriteDeadline(time.Now().Add(httpTimeout)) // nolint:errcheck
+				if _, err = conn.Write(item.data); err == nil {
+					break
+				}
+				conn.Close()
+				conn = nil
+			}
+
+			select {
+			case <-time.After(delay):
+			case <-s.done:
+				s.drain(conn)
+				return
+			}
+			if delay *= 2; delay > maxTCPBackoff {
+				delay = maxTCPBackoff
+			}
+		}
+	}
+}
+
+func (s *tcpSink) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout(s.network, s.addr, httpTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if dropped := atomic.SwapUint64(&s.dropped, 0); dropped > 0 {
+		fmt.Fprintf(os.Stderr, "%s sink %s dropped %d entries while disconnected\n", s.network, s.addr, dropped)
+	}
+	return conn, nil
+}
+
+// drain writes the queued entries if the sink is connected, giving up on
+// the remaining ones after httpTimeout so that closing the sink doesn't hang
+// on an unresponsive collector.
+func (s *tcpSink) drain(conn net.Conn) {
+	if conn != nil {
+		conn.SetWriteDeadline(time.Now().Add(httpTimeout)) // nolint:errcheck
+	}
+	for {
+		select {
+		case item := <-s.queue:
+			if item.synced != nil {
+				close(item.synced)
+			} else if conn != nil {
+				if _, err := conn.Write(item.data); err != nil {
+					conn = nil
+				}
+			}
+		default:
+			return
+		}
+	}
+}
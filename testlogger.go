@@ -0,0 +1,79 @@
+package log
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// TestLogger returns the event logger of system for the duration of the test
+// t, writing the entries of system to t.Logf in FormatPlaintextOutput, so that
+// they are interleaved with the output of the test. The level of system is
+// set to LevelDebug, and the levels of all subsystems, including the changes
+// made by the test, are restored when the test ends.
+func TestLogger(t testing.TB, system string) *ZapEventLogger {
+	t.Helper()
+
+	logger := Logger(system)
+
+	loggerMutex.Lock()
+	saved := make(map[string]zapcore.Level, len(levels))
+	for name, level := range levels {
+		saved[name] = level.Level()
+	}
+	levels[system].SetLevel(zapcore.DebugLevel)
+	loggerMutex.Unlock()
+
+	w := &testWriter{t: t}
+	core := &subsystemCore{
+		Core:      zapcore.NewCore(newEncoder(FormatPlaintextOutput), w, zapcore.DebugLevel),
+		subsystem: system,
+	}
+	loggerCore.AddCore(core)
+
+	t.Cleanup(func() {
+		loggerCore.DeleteCore(core)
+		w.close()
+
+		loggerMutex.Lock()
+		defer loggerMutex.Unlock()
+		for name, level := range levels {
+			if lvl, ok := saved[name]; ok {
+				level.SetLevel(lvl)
+			} else {
+				level.SetLevel(zapcore.Level(defaultLevel))
+			}
+		}
+	})
+	return logger
+}
+
+// testWriter writes the entries of a TestLogger to its test until the test
+// ends, since logging to a test after it ends panics.
+type testWriter struct {
+	t testing.TB
+
+	mu     sync.Mutex // guards closed
+	closed bool
+}
+
+func (w *testWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.t.Logf("%s", strings.TrimSuffix(string(p), "\n"))
+	}
+	return len(p), nil
+}
+
+func (w *testWriter) Sync() error {
+	return nil
+}
+
+func (w *testWriter) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+}
@@ -0,0 +1,52 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recordingTB records the logs and cleanups of a test.
+type recordingTB struct {
+	testing.TB
+	logs     []string
+	cleanups []func()
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Logf(format string, args ...interface{}) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Cleanup(f func()) {
+	r.cleanups = append(r.cleanups, f)
+}
+
+func TestTestLogger(t *testing.T) {
+	Logger("other")
+	SetLogLevel("other", "error")
+	Logger("tested")
+	before := AllLevels()
+
+	tb := &recordingTB{TB: t}
+	log := TestLogger(tb, "tested")
+	log.Debug("scooby")
+	log.Named("child").Infow("doo", "id", 3)
+	if err := SetLogLevel("other", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	getLogger("other").Info("where are you")
+	for _, f := range tb.cleanups {
+		f()
+	}
+	log.Error("after the test")
+
+	if len(tb.logs) != 2 || !strings.Contains(tb.logs[0], "DEBUG\ttested\t") || !strings.HasSuffix(tb.logs[0], "scooby") ||
+		!strings.HasSuffix(tb.logs[1], `doo	{"id": 3}`) {
+		t.Errorf("got logs %q, wanted the entries of the subsystem", tb.logs)
+	}
+	if levels := AllLevels(); levels["other"] != "error" || levels["tested"] != before["tested"] {
+		t.Errorf("got levels %v, wanted them restored to %v", levels, before)
+	}
+}
@@ -0,0 +1,175 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// WebhookConfig configures alerting on Error, DPanic, Panic and Fatal entries
+// by posting them to a chat webhook.
+type WebhookConfig struct {
+	// URL is the incoming webhook URL.
+	URL string
+
+	// Format is the payload format: "slack" (the default), "discord" or
+	// "teams". Slack compatible services such as Mattermost and Rocket.Chat
+	// use "slack".
+	Format string
+
+	// MaxAlerts is the maximum number of alerts posted per Interval. Alerts
+	// beyond it are counted, and the count is reported with the next posted
+	// alert. Defaults to 10 per minute.
+	MaxAlerts int
+	Interval  time.Duration
+}
+
+const (
+	defaultWebhookMaxAlerts = 10
+	defaultWebhookInterval  = time.Minute
+
+	// discordMaxContent is the maximum length of a Discord message.
+	discordMaxContent = 2000
+)
+
+// newWebhookCore creates a core posting error entries to a webhook, rate
+// limited so that an error storm doesn't flood the channel.
+func newWebhookCore(cfg WebhookConfig) (zapcore.Core, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("missing webhook URL")
+	}
+	w := &webhookClient{
+		url:       cfg.URL,
+		format:    cfg.Format,
+		maxAlerts: cfg.MaxAlerts,
+		interval:  cfg.Interval,
+		client:    newHTTPClient(nil),
+	}
+	switch w.format {
+	case "":
+		w.format = "slack"
+	case "slack", "discord", "teams":
+	default:
+		return nil, fmt.Errorf("unknown webhook format %q", cfg.Format)
+	}
+	if w.maxAlerts <= 0 {
+		w.maxAlerts = defaultWebhookMaxAlerts
+	}
+	if w.interval <= 0 {
+		w.interval = defaultWebhookInterval
+	}
+
+	return &batchCore{
+		LevelEnabler: zapcore.ErrorLevel,
+		enc:          newFieldsEncoder(),
+		batcher:      newBatcher(0, 0, w.send),
+	}, nil
+}
+
+type webhookClient struct {
+	url       string
+	format    string
+	maxAlerts int
+	interval  time.Duration
+	client    *http.Client
+
+	mu          sync.Mutex // guards the rate limiting state
+	windowStart time.Time
+	posted      int
+	suppressed  int
+}
+
+// allow reports whether an alert may be posted now, and returns the number of
+// alerts suppressed since the last one posted.
+func (w *webhookClient) allow(now time.Time) (bool, int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if now.Sub(w.windowStart) >= w.interval {
+		w.windowStart = now
+		w.posted = 0
+	}
+	if w.posted >= w.maxAlerts {
+		w.suppressed++
+		return false, 0
+	}
+	w.posted++
+	suppressed := w.suppressed
+	w.suppressed = 0
+	return true, suppressed
+}
+
+func (w *webhookClient) send(batch []batchEntry) error {
+	var err error
+	for _, e := range batch {
+		ok, suppressed := w.allow(time.Now())
+		if !ok {
+			continue
+		}
+		if perr := w.post(webhookText(e, suppressed)); perr != nil {
+			err = perr
+		}
+	}
+	return err
+}
+
+// webhookText formats the alert text of an entry.
+func webhookText(e batchEntry, suppressed int) string {
+	var b strings.Builder
+	b.WriteString(capitalLevelName(e.ent.Level))
+	if e.ent.LoggerName != "" {
+		b.WriteString(" [" + e.ent.LoggerName + "]")
+	}
+	b.WriteString(" " + e.ent.Message)
+	if fields := bytes.TrimSpace(e.data); len(fields) > 2 {
+		b.WriteString(" ")
+		b.Write(fields)
+	}
+	if e.ent.Caller.Defined {
+		b.WriteString("\n" + e.ent.Caller.TrimmedPath())
+	}
+	if suppressed > 0 {
+		fmt.Fprintf(&b, "\n(%d alerts suppressed by rate limiting)", suppressed)
+	}
+	return b.String()
+}
+
+func (w *webhookClient) post(text string) error {
+	var payload interface{}
+	switch w.format {
+	case "discord":
+		if len(text) > discordMaxContent {
+			text = text[:discordMaxContent]
+		}
+		payload = struct {
+			Content string `json:"content"`
+		}{text}
+	default:
+		// Slack and Teams incoming webhooks both accept a text message
+		payload = struct {
+			Text string `json:"text"`
+		}{text}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return checkHTTPResponse(resp)
+}
@@ -0,0 +1,62 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestWebhookCoreRateLimit(t *testing.T) {
+	var texts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			Content string `json:"content"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+		texts = append(texts, msg.Content)
+	}))
+	defer srv.Close()
+
+	core, err := newWebhookCore(WebhookConfig{
+		URL:       srv.URL,
+		Format:    "discord",
+		MaxAlerts: 2,
+		Interval:  time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*batchCore).Close()
+
+	logger := zap.New(core).Named("dht")
+	logger.Warn("not an alert")
+	for i := 0; i < 5; i++ {
+		logger.Error("lookup failed", zap.Int("attempt", i))
+	}
+	if err := core.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if len(texts) != 2 || texts[0] != `ERROR [dht] lookup failed {"attempt":0}` {
+		t.Fatalf("got alerts %q", texts)
+	}
+
+	// the next alert reports the suppressed ones
+	c := &webhookClient{maxAlerts: 1, interval: time.Hour}
+	c.allow(time.Now())
+	c.allow(time.Now())
+	if ok, suppressed := c.allow(time.Now().Add(2 * time.Hour)); !ok || suppressed != 1 {
+		t.Errorf("got allowed %v with %d suppressed, wanted true with 1", ok, suppressed)
+	}
+	text := webhookText(batchEntry{ent: zapcore.Entry{Level: zapcore.ErrorLevel, Message: "boom"}}, 3)
+	if !strings.HasSuffix(text, "(3 alerts suppressed by rate limiting)") {
+		t.Errorf("got text %q", text)
+	}
+}
@@ -0,0 +1,87 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxWriterLine is the length from which the lines written to a WriterLevel
+// writer are split into several entries.
+const maxWriterLine = 64 << 10
+
+// WriterLevel returns a writer logging every line written to it as an entry
+// at level, for redirecting the output of a subprocess or of a library to the
+// logger. The entries have no caller. Close logs the last line if it isn't
+// terminated.
+//
+// Writing doesn't panic nor exit: the levels from LevelDPanic to LevelFatal
+// are logged at LevelError.
+func (logger *ZapEventLogger) WriterLevel(level LogLevel) io.WriteCloser {
+	if level >= LevelDPanic && level <= LevelFatal {
+		level = LevelError
+	}
+	return &levelWriter{
+		logger: logger.SugaredLogger.Desugar().WithOptions(zap.WithCaller(false)),
+		level:  zapcore.Level(level),
+	}
+}
+
+type levelWriter struct {
+	logger *zap.Logger
+	level  zapcore.Level
+
+	mu     sync.Mutex // guards line and closed
+	line   []byte
+	closed bool
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, errors.New("write to closed log writer")
+	}
+
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.line = append(w.line, p...)
+			if len(w.line) >= maxWriterLine {
+				w.flush()
+			}
+			break
+		}
+		w.line = append(w.line, p[:i]...)
+		w.flush()
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// flush logs the buffered line.
+func (w *levelWriter) flush() {
+	line := bytes.TrimSuffix(w.line, []byte{'\r'})
+	if ce := w.logger.Check(w.level, string(line)); ce != nil {
+		ce.Write()
+	}
+	w.line = w.line[:0]
+}
+
+func (w *levelWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if len(w.line) > 0 {
+		w.flush()
+	}
+	return nil
+}
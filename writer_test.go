@@ -0,0 +1,66 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriterLevel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	SetupLogging(Config{Level: LevelInfo, Format: FormatJSONOutput, File: path})
+	defer SetupLogging(Config{Level: LevelError, Format: FormatPlaintextOutput, Stderr: true})
+
+	w := Logger("writer").WriterLevel(LevelWarn)
+	fmt.Fprint(w, "scooby\r\ndoo ")
+	fmt.Fprint(w, "where are\n\nyou")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fmt.Fprint(w, "closed\n"); err == nil {
+		t.Error("expected an error writing to a closed writer")
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	want := []string{"scooby", "doo where are", "", "you"}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d entries, wanted %d", len(lines), len(want))
+	}
+	for i, msg := range want {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry["msg"] != msg || entry["level"] != "warn" || entry["logger"] != "writer" || entry["caller"] != nil {
+			t.Errorf("got %s, wanted the warning %q", lines[i], msg)
+		}
+	}
+}
+
+func TestWriterLevelFatal(t *testing.T) {
+	capture := StartCapture(func(e Entry) bool { return e.Subsystem == "writer-fatal" })
+	defer capture.Stop()
+
+	// would exit or panic if not logged at LevelError
+	for _, level := range []LogLevel{LevelDPanic, LevelPanic, LevelFatal} {
+		w := Logger("writer-fatal").WriterLevel(level)
+		fmt.Fprintln(w, "scooby")
+	}
+
+	entries := capture.FilterMessage("scooby")
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, wanted 3", len(entries))
+	}
+	for _, e := range entries {
+		if e.Level != LevelError {
+			t.Errorf("got level %v, wanted LevelError", e.Level)
+		}
+	}
+}
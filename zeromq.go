@@ -0,0 +1,415 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ZeroMQConfig configures publishing entries on a ZeroMQ PUB socket, with
+// the subsystem as topic so that SUB sockets can subscribe to subsystems.
+type ZeroMQConfig struct {
+	// Endpoint is the TCP endpoint the socket binds to, i.e. "tcp://*:5556".
+	Endpoint string
+
+	// QueueSize is the maximum number of entries queued per subscriber,
+	// newer entries being dropped for slow subscribers like the high water
+	// mark of ZeroMQ does. Defaults to 1000.
+	QueueSize int
+}
+
+const (
+	defaultZMQQueueSize = 1000
+
+	zmqFlagMore    = 0x01
+	zmqFlagLong    = 0x02
+	zmqFlagCommand = 0x04
+
+	// zmqMaxFrame bounds the frames read from subscribers, which only send
+	// subscriptions and commands.
+	zmqMaxFrame = 64 << 10
+)
+
+// newZeroMQCore creates a core publishing JSON entries as two-part messages
+// of topic and entry, speaking ZMTP 3 with the NULL security mechanism.
+func newZeroMQCore(cfg ZeroMQConfig, level LogLevel) (zapcore.Core, error) {
+	addr := strings.TrimPrefix(cfg.Endpoint, "tcp://")
+	if addr == cfg.Endpoint {
+		return nil, fmt.Errorf("unsupported ZeroMQ endpoint %q, only tcp:// is supported", cfg.Endpoint)
+	}
+	if strings.HasPrefix(addr, "*:") {
+		addr = addr[1:]
+	}
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultZMQQueueSize
+	}
+
+	pub, err := openZMQPublisher(cfg.Endpoint, addr, queueSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &zmqCore{
+		LevelEnabler: zap.NewAtomicLevelAt(zapcore.Level(level)),
+		enc:          newEncoder(FormatJSONOutput),
+		pub:          pub,
+	}, nil
+}
+
+type zmqCore struct {
+	zapcore.LevelEnabler
+	enc zapcore.Encoder
+	pub *zmqPublisher
+}
+
+func (c *zmqCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.enc = c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(clone.enc)
+	}
+	return &clone
+}
+
+func (c *zmqCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *zmqCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	c.pub.publish(ent.LoggerName, bytes.TrimRight(buf.Bytes(), "\n"))
+	buf.Free()
+	return nil
+}
+
+func (c *zmqCore) Sync() error {
+	return nil
+}
+
+func (c *zmqCore) Close() error {
+	return c.pub.Close()
+}
+
+var (
+	zmqMutex sync.Mutex // guards zmqPublishers and the refs of the publishers
+	// zmqPublishers are the open PUB sockets by endpoint, shared by the
+	// cores so that setting up the logging again with the same endpoint
+	// doesn't fail to bind it while the previous core is still open.
+	zmqPublishers = make(map[string]*zmqPublisher)
+)
+
+// openZMQPublisher returns the PUB socket bound to endpoint, binding addr if
+// it is not open yet. It must be closed once per call.
+func openZMQPublisher(endpoint, addr string, queueSize int) (*zmqPublisher, error) {
+	zmqMutex.Lock()
+	defer zmqMutex.Unlock()
+
+	if pub, ok := zmqPublishers[endpoint]; ok {
+		pub.refs++
+		pub.mu.Lock()
+		pub.queueSize = queueSize
+		pub.mu.Unlock()
+		return pub, nil
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	pub := &zmqPublisher{
+		endpoint:  endpoint,
+		listener:  l,
+		refs:      1,
+		queueSize: queueSize,
+		subs:      make(map[*zmqSubscriber]struct{}),
+	}
+	zmqPublishers[endpoint] = pub
+	go pub.accept()
+	return pub, nil
+}
+
+// zmqPublisher is the PUB socket, sending messages to the subscribers whose
+// subscriptions match their topic.
+type zmqPublisher struct {
+	endpoint string
+	listener net.Listener
+	refs     int
+
+	mu        sync.Mutex // guards queueSize and subs
+	queueSize int
+	subs      map[*zmqSubscriber]struct{}
+}
+
+func (p *zmqPublisher) accept() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		sub := &zmqSubscriber{
+			conn:  conn,
+			queue: make(chan []byte, p.queueSize),
+			done:  make(chan struct{}),
+		}
+		p.mu.Unlock()
+		go p.serve(sub)
+	}
+}
+
+func (p *zmqPublisher) serve(sub *zmqSubscriber) {
+	defer sub.conn.Close()
+
+	r := bufio.NewReader(sub.conn)
+	if err := zmqHandshake(sub.conn, r, "PUB"); err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	p.subs[sub] = struct{}{}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.subs, sub)
+		p.mu.Unlock()
+		close(sub.done)
+	}()
+
+	go sub.writeLoop()
+
+	for {
+		flags, body, err := readZMQFrame(r)
+		if err != nil {
+			return
+		}
+		if flags&zmqFlagCommand != 0 {
+			name, data := parseZMQCommand(body)
+			switch name {
+			// ZMTP 3.1 subscriptions
+			case "SUBSCRIBE":
+				sub.subscribe(string(data), true)
+			case "CANCEL":
+				sub.subscribe(string(data), false)
+			case "PING":
+				if len(data) >= 2 {
+					sub.send(zmqCommand("PONG", data[2:]))
+				}
+			}
+			continue
+		}
+		// ZMTP 3.0 subscriptions are messages starting with 1 or 0
+		if len(body) > 0 {
+			sub.subscribe(string(body[1:]), body[0] == 1)
+		}
+	}
+}
+
+func (p *zmqPublisher) publish(topic string, data []byte) {
+	var msg []byte
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for sub := range p.subs {
+		if !sub.matches(topic) {
+			continue
+		}
+		if msg == nil {
+			msg = appendZMQFrame(nil, zmqFlagMore, []byte(topic))
+			msg = appendZMQFrame(msg, 0, data)
+		}
+		sub.send(msg)
+	}
+}
+
+// Close stops accepting subscribers and disconnects the current ones once
+// every core publishing on the socket is closed.
+func (p *zmqPublisher) Close() error {
+	zmqMutex.Lock()
+	defer zmqMutex.Unlock()
+	if p.refs--; p.refs > 0 {
+		return nil
+	}
+	delete(zmqPublishers, p.endpoint)
+
+	err := p.listener.Close()
+	p.mu.Lock()
+	for sub := range p.subs {
+		sub.conn.Close()
+	}
+	p.mu.Unlock()
+	return err
+}
+
+type zmqSubscriber struct {
+	conn  net.Conn
+	queue chan []byte
+	done  chan struct{}
+
+	mu            sync.RWMutex // guards subscriptions
+	subscriptions map[string]int
+}
+
+func (s *zmqSubscriber) subscribe(prefix string, subscribe bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subscriptions == nil {
+		s.subscriptions = make(map[string]int)
+	}
+	if subscribe {
+		s.subscriptions[prefix]++
+	} else if s.subscriptions[prefix] > 1 {
+		s.subscriptions[prefix]--
+	} else {
+		delete(s.subscriptions, prefix)
+	}
+}
+
+func (s *zmqSubscriber) matches(topic string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for prefix := range s.subscriptions {
+		if strings.HasPrefix(topic, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// send queues a message, dropping it if the subscriber is too slow.
+func (s *zmqSubscriber) send(msg []byte) {
+	select {
+	case s.queue <- msg:
+	default:
+	}
+}
+
+func (s *zmqSubscriber) writeLoop() {
+	for {
+		select {
+		case msg := <-s.queue:
+			if _, err := s.conn.Write(msg); err != nil {
+				s.conn.Close()
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// zmqHandshake exchanges the greeting and the READY commands with a peer.
+func zmqHandshake(conn net.Conn, r *bufio.Reader, socketType string) error {
+	greeting := make([]byte, 64)
+	greeting[0] = 0xff
+	greeting[9] = 0x7f
+	greeting[10] = 3 // version 3.0
+	copy(greeting[12:32], "NULL")
+	if _, err := conn.Write(greeting); err != nil {
+		return err
+	}
+
+	peer := make([]byte, 64)
+	if _, err := io.ReadFull(r, peer); err != nil {
+		return err
+	}
+	if peer[0] != 0xff || peer[9] != 0x7f || peer[10] < 3 {
+		return errors.New("unsupported ZMTP peer")
+	}
+	if mechanism := string(bytes.TrimRight(peer[12:32], "\x00")); mechanism != "NULL" {
+		return fmt.Errorf("unsupported ZMTP security mechanism %q", mechanism)
+	}
+
+	var props []byte
+	props = append(props, byte(len("Socket-Type")))
+	props = append(props, "Socket-Type"...)
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(socketType)))
+	props = append(props, size[:]...)
+	props = append(props, socketType...)
+	if _, err := conn.Write(zmqCommand("READY", props)); err != nil {
+		return err
+	}
+
+	flags, body, err := readZMQFrame(r)
+	if err != nil {
+		return err
+	}
+	if name, _ := parseZMQCommand(body); flags&zmqFlagCommand == 0 || name != "READY" {
+		return errors.New("expected ZMTP READY command")
+	}
+	return nil
+}
+
+// zmqCommand encodes a command frame.
+func zmqCommand(name string, data []byte) []byte {
+	body := make([]byte, 0, 1+len(name)+len(data))
+	body = append(body, byte(len(name)))
+	body = append(body, name...)
+	body = append(body, data...)
+	return appendZMQFrame(nil, zmqFlagCommand, body)
+}
+
+// parseZMQCommand splits the body of a command frame into its name and data.
+func parseZMQCommand(body []byte) (string, []byte) {
+	if len(body) == 0 || len(body) < 1+int(body[0]) {
+		return "", nil
+	}
+	n := 1 + int(body[0])
+	return string(body[1:n]), body[n:]
+}
+
+func appendZMQFrame(b []byte, flags byte, body []byte) []byte {
+	if len(body) > 255 {
+		var size [8]byte
+		binary.BigEndian.PutUint64(size[:], uint64(len(body)))
+		b = append(b, flags|zmqFlagLong)
+		b = append(b, size[:]...)
+	} else {
+		b = append(b, flags, byte(len(body)))
+	}
+	return append(b, body...)
+}
+
+func readZMQFrame(r *bufio.Reader) (byte, []byte, error) {
+	flags, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var size uint64
+	if flags&zmqFlagLong != 0 {
+		var b [8]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(b[:])
+	} else {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(b)
+	}
+	if size > zmqMaxFrame {
+		return 0, nil, fmt.Errorf("ZMTP frame of %d bytes too large", size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}
@@ -0,0 +1,100 @@
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestZeroMQCore(t *testing.T) {
+	core, err := newZeroMQCore(ZeroMQConfig{Endpoint: "tcp://127.0.0.1:0"}, LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.(*zmqCore).Close()
+	addr := core.(*zmqCore).pub.listener.Addr().String()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	if err := zmqHandshake(conn, r, "SUB"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(appendZMQFrame(nil, 0, append([]byte{1}, "dht"...))); err != nil {
+		t.Fatal(err)
+	}
+
+	// publish until the subscription has been processed
+	logger := zap.New(core)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			logger.Named("swarm").Info("not subscribed")
+			logger.Named("dht").Info("hello", zap.Int("peers", 3))
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second)) // nolint:errcheck
+	flags, topic, err := readZMQFrame(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags&zmqFlagMore == 0 || string(topic) != "dht" {
+		t.Fatalf("got topic %q with flags %x", topic, flags)
+	}
+	_, data, err := readZMQFrame(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["msg"] != "hello" || entry["peers"] != 3.0 {
+		t.Errorf("got entry %s", data)
+	}
+}
+
+func TestZeroMQCoreSameEndpoint(t *testing.T) {
+	core, err := newZeroMQCore(ZeroMQConfig{Endpoint: "tcp://127.0.0.1:0"}, LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// like setting up the logging again, the new core is created before the
+	// previous one is closed
+	next, err := newZeroMQCore(ZeroMQConfig{Endpoint: "tcp://127.0.0.1:0"}, LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := next.(*zmqCore).pub.listener.Addr().String()
+	if err := core.(*zmqCore).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("expected the socket to be kept open: %s", err)
+	}
+	conn.Close()
+
+	if err := next.(*zmqCore).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		conn.Close()
+		t.Error("expected the socket to be closed")
+	}
+}